@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"strings"
@@ -20,9 +22,14 @@ import (
 	"k2MarketingAi/internal/geodata"
 	"k2MarketingAi/internal/listings"
 	"k2MarketingAi/internal/llm"
+	"k2MarketingAi/internal/mail"
 	"k2MarketingAi/internal/media"
+	"k2MarketingAi/internal/notify"
+	"k2MarketingAi/internal/oidc"
+	"k2MarketingAi/internal/ratelimit"
 	"k2MarketingAi/internal/server"
 	"k2MarketingAi/internal/storage"
+	"k2MarketingAi/internal/usage"
 	"k2MarketingAi/internal/vision"
 )
 
@@ -74,8 +81,22 @@ func main() {
 		visionDesigner vision.Designer
 		visionRenderer vision.ImageGenerator
 		imagenRenderer vision.ImagenClient
-		geminiClient   llm.Client
+		llmClient      llm.Client
+		// annualReportModel is only set for Gemini; other providers read
+		// annual reports with their configured model.
+		annualReportModel string
 	)
+	eventBroker := events.NewBroker()
+	quota := &usage.Quota{
+		Store:            store,
+		Organization:     usageBudget(cfg.AI.Quotas.Organization),
+		User:             usageBudget(cfg.AI.Quotas.User),
+		Organizations:    usageBudgets(cfg.AI.Quotas.Organizations),
+		Users:            usageBudgets(cfg.AI.Quotas.Users),
+		SoftLimitPercent: cfg.AI.Quotas.SoftLimitPercent,
+		Events:           eventBroker,
+	}
+	usageRecorder := usage.Recorder{Sink: store, Quota: quota}
 	var geminiTokenSource oauth2.TokenSource
 	if tokenBytes, err := loadServiceAccountJSON(cfg.AI.Gemini.ServiceAccount, cfg.AI.Gemini.ServiceAccountJSON); err != nil {
 		log.Fatalf("failed to load gemini service account: %v", err)
@@ -90,18 +111,47 @@ func main() {
 	switch {
 	case strings.EqualFold(cfg.AI.Provider, "gemini") && (cfg.AI.Gemini.APIKey != "" || geminiTokenSource != nil):
 		timeout := time.Duration(cfg.AI.Gemini.TimeoutSeconds) * time.Second
-		geminiClient = llm.NewGeminiClient(cfg.AI.Gemini.APIKey, cfg.AI.Gemini.Model, timeout, geminiTokenSource)
-		generator = generation.NewLLM(geminiClient)
-		visionAnalyzer = vision.NewGeminiAnalyzer(cfg.AI.Gemini.APIKey, cfg.AI.Gemini.VisionModel, timeout)
-		visionDesigner = vision.NewGeminiDesigner(geminiClient)
-		visionRenderer = vision.NewGeminiImageGenerator(cfg.AI.Gemini.APIKey, cfg.AI.Gemini.ImageModel, timeout)
+		llmClient = usage.Meter(llm.NewGeminiClient(cfg.AI.Gemini.APIKey, cfg.AI.Gemini.Model, timeout, geminiTokenSource), usageRecorder)
+		generator = generation.NewLLM(llmClient)
+		annualReportModel = cfg.AI.Gemini.AnnualReportModel
+		analyzer := vision.NewGeminiAnalyzer(cfg.AI.Gemini.APIKey, cfg.AI.Gemini.VisionModel, timeout)
+		analyzer.OnUsage = usageRecorder.Record
+		visionAnalyzer = analyzer
+		visionDesigner = vision.NewGeminiDesigner(llmClient)
+		renderer := vision.NewGeminiImageGenerator(cfg.AI.Gemini.APIKey, cfg.AI.Gemini.ImageModel, timeout)
+		renderer.OnUsage = usageRecorder.Record
+		visionRenderer = renderer
 		log.Println("generator ready: Gemini")
+	case strings.EqualFold(cfg.AI.Provider, "openai") && cfg.AI.OpenAI.Model != "":
+		llmClient = usage.Meter(llm.NewOpenAIClient(llm.OpenAIConfig{
+			BaseURL:    cfg.AI.OpenAI.BaseURL,
+			APIKey:     cfg.AI.OpenAI.APIKey,
+			Model:      cfg.AI.OpenAI.Model,
+			APIVersion: cfg.AI.OpenAI.APIVersion,
+			Timeout:    time.Duration(cfg.AI.OpenAI.TimeoutSeconds) * time.Second,
+		}), usageRecorder)
+		generator = generation.NewLLM(llmClient)
+		visionDesigner = vision.NewGeminiDesigner(llmClient)
+		log.Printf("generator ready: OpenAI-compatible (%s, model %s)", cfg.AI.OpenAI.BaseURL, cfg.AI.OpenAI.Model)
+	case strings.EqualFold(cfg.AI.Provider, "ollama"):
+		ollama := llm.NewOllamaClient(llm.OllamaConfig{
+			BaseURL:     cfg.AI.Ollama.BaseURL,
+			Model:       cfg.AI.Ollama.Model,
+			PullMissing: cfg.AI.Ollama.PullMissing,
+			PullModels:  cfg.AI.Ollama.PullModels,
+			Timeout:     time.Duration(cfg.AI.Ollama.TimeoutSeconds) * time.Second,
+		})
+		llmClient = usage.Meter(ollama, usageRecorder)
+		generator = generation.NewLLM(llmClient)
+		visionDesigner = vision.NewGeminiDesigner(llmClient)
+		go probeOllama(ctx, ollama)
+		log.Printf("generator ready: Ollama (%s, model %s)", cfg.AI.Ollama.BaseURL, cfg.AI.Ollama.Model)
 	default:
 		generator = generation.NewHeuristic()
 		log.Println("generator ready: heuristic fallback")
 	}
 	if cfg.AI.Imagen.Enabled && cfg.AI.Imagen.ProjectID != "" {
-		imagenRenderer = vision.NewVertexImagen(vision.VertexImagenConfig{
+		imagen := vision.NewVertexImagen(vision.VertexImagenConfig{
 			ProjectID:          cfg.AI.Imagen.ProjectID,
 			Location:           cfg.AI.Imagen.Location,
 			Model:              cfg.AI.Imagen.Model,
@@ -109,36 +159,119 @@ func main() {
 			ServiceAccount:     cfg.AI.Imagen.ServiceAccount,
 			ServiceAccountJSON: cfg.AI.Imagen.ServiceAccountJSON,
 		}, uploader)
+		imagen.OnUsage = usageRecorder.Record
+		imagenRenderer = imagen
 	} else if cfg.AI.Imagen.Enabled {
 		log.Println("imagen renderer disabled: missing project id")
 	} else {
 		log.Println("imagen renderer disabled via config")
 	}
 
-	eventBroker := events.NewBroker()
+	sessionDuration := time.Duration(cfg.Auth.SessionHours) * time.Hour
 	sessionManager := auth.SessionManager{
+		Store:        store,
 		Secret:       []byte(cfg.Auth.Secret),
-		Duration:     time.Duration(cfg.Auth.SessionHours) * time.Hour,
+		Duration:     sessionDuration,
 		CookieName:   cfg.Auth.CookieName,
 		SecureCookie: cfg.Auth.SecureCookie,
 	}
+	if cfg.Auth.PreviousSecret != "" {
+		sessionManager.PreviousSecret = []byte(cfg.Auth.PreviousSecret)
+		sessionManager.PreviousSecretUntil = cfg.Auth.PreviousSecretUntil
+		if sessionManager.PreviousSecretUntil.IsZero() {
+			sessionManager.PreviousSecretUntil = time.Now().Add(sessionDuration)
+		}
+		log.Printf("auth: accepting the previous secret until %s", sessionManager.PreviousSecretUntil.Format(time.RFC3339))
+	}
+	var mailer mail.Sender
+	switch {
+	case cfg.Mail.SMTPHost != "":
+		mailer = mail.SMTPSender{
+			Host:     cfg.Mail.SMTPHost,
+			Port:     cfg.Mail.SMTPPort,
+			Username: cfg.Mail.Username,
+			Password: cfg.Mail.Password,
+			From:     cfg.Mail.From,
+		}
+	case cfg.Mail.Dir != "":
+		mailer, err = mail.NewFileSender(cfg.Mail.Dir, cfg.Mail.From)
+		if err != nil {
+			log.Fatalf("failed to init mail dir: %v", err)
+		}
+		log.Printf("mail: writing messages to %s (smtp_host missing)", cfg.Mail.Dir)
+	default:
+		mailer = mail.LogSender{}
+		log.Println("mail: writing messages to the log (smtp_host missing)")
+	}
+	var adminNotifier notify.Notifier
+	switch strings.ToLower(cfg.Notify.Channel) {
+	case "webhook":
+		if cfg.Notify.WebhookURL == "" {
+			log.Fatal("notify.webhook_url is required for the webhook channel")
+		}
+		adminNotifier = notify.Webhook{URL: cfg.Notify.WebhookURL}
+	case "none":
+		adminNotifier = notify.Disabled()
+	case "mail":
+		adminNotifier = notify.Mail{Sender: mailer, To: cfg.Notify.AdminEmails, Store: store}
+	default:
+		log.Fatalf("unknown notify channel %q", cfg.Notify.Channel)
+	}
+	ssoProviders, err := buildSSOProviders(cfg)
+	if err != nil {
+		log.Fatalf("failed to configure single sign-on: %v", err)
+	}
 	authHandler := auth.Handler{
-		Store:    store,
-		Sessions: sessionManager,
+		Store:     store,
+		Sessions:  sessionManager,
+		Notifier:  adminNotifier,
+		Mail:      mailer,
+		PublicURL: cfg.PublicURL,
+		SSO:       ssoProviders,
+	}
+	rateLimits, rateLimitBackend, err := buildRateLimits(cfg.Auth.RateLimit, store)
+	if err != nil {
+		log.Fatalf("failed to configure rate limiting: %v", err)
 	}
 	authMiddleware := auth.Middleware{
 		Store:    store,
 		Sessions: sessionManager,
+		Limits:   rateLimits,
 	}
 
+	var trashRetention time.Duration
+	if cfg.Trash.RetentionDays > 0 {
+		trashRetention = time.Duration(cfg.Trash.RetentionDays) * 24 * time.Hour
+	}
 	listingHandler := listings.Handler{
-		Store:       store,
-		Uploader:    uploader,
-		GeoProvider: geoProvider,
-		Generator:   generator,
-		Vision:      visionAnalyzer,
-		Events:      eventBroker,
-		LLM:         geminiClient,
+		Store:             store,
+		Uploader:          uploader,
+		GeoProvider:       geoProvider,
+		Generator:         generator,
+		Vision:            visionAnalyzer,
+		Events:            eventBroker,
+		LLM:               llmClient,
+		TrashRetention:    trashRetention,
+		AnnualReportModel: annualReportModel,
+	}
+
+	purgeCtx, stopPurge := context.WithCancel(ctx)
+	defer stopPurge()
+	go auth.SessionPurger{Store: store}.Run(purgeCtx)
+	if rateLimitBackend != nil {
+		go ratelimit.Purger{Backend: rateLimitBackend}.Run(purgeCtx)
+	}
+	if trashRetention > 0 {
+		purger := listings.Purger{
+			Store:     store,
+			Uploader:  uploader,
+			Retention: trashRetention,
+			Interval:  time.Duration(cfg.Trash.PurgeIntervalMinutes) * time.Minute,
+		}
+		go purger.Run(purgeCtx)
+		log.Printf("trash purge enabled: retention %d days", cfg.Trash.RetentionDays)
+	} else {
+		log.Println("trash purge disabled: deleted listings are kept")
 	}
 
 	staticFS := http.FileServer(http.Dir("web"))
@@ -148,7 +281,25 @@ func main() {
 		Renderer: visionRenderer,
 		Imagen:   imagenRenderer,
 	}
-	srv := server.New(cfg.Port, authHandler, authMiddleware, listingHandler, visionHandler, staticFS)
+	prices := make(usage.PriceTable, len(cfg.AI.Pricing.Models))
+	for model, price := range cfg.AI.Pricing.Models {
+		prices[model] = usage.Price{
+			InputPerMillion:  price.InputPerMillion,
+			OutputPerMillion: price.OutputPerMillion,
+			PerRequest:       price.PerRequest,
+		}
+	}
+	usageHandler := usage.Handler{
+		Store:    store,
+		Prices:   prices,
+		Currency: cfg.AI.Pricing.Currency,
+		Quota:    quota,
+	}
+	trustedProxies, err := server.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		log.Fatalf("invalid trusted_proxies: %v", err)
+	}
+	srv := server.New(cfg.Port, trustedProxies, authHandler, authMiddleware, listingHandler, visionHandler, usageHandler, staticFS)
 
 	shutdownChan := make(chan os.Signal, 1)
 	signal.Notify(shutdownChan, os.Interrupt, syscall.SIGTERM)
@@ -156,6 +307,7 @@ func main() {
 	go func() {
 		<-shutdownChan
 		log.Println("shutting down server...")
+		stopPurge()
 		if err := srv.Close(); err != nil {
 			log.Printf("server close error: %v", err)
 		}
@@ -166,6 +318,100 @@ func main() {
 	}
 }
 
+// buildSSOProviders turns auth.oidc into providers. Discovery happens on the
+// first login, so an unreachable provider does not stop startup.
+func buildSSOProviders(cfg config.Config) ([]auth.SSOProvider, error) {
+	providers := make([]auth.SSOProvider, 0, len(cfg.Auth.OIDC))
+	seen := make(map[string]bool)
+	for _, p := range cfg.Auth.OIDC {
+		if p.Name == "" || p.Issuer == "" || p.ClientID == "" {
+			return nil, fmt.Errorf("oidc provider %q: name, issuer and client_id are required", p.Name)
+		}
+		if seen[p.Name] {
+			return nil, fmt.Errorf("oidc provider %q configured twice", p.Name)
+		}
+		seen[p.Name] = true
+		role, err := storage.ParseRole(p.Role)
+		if err != nil {
+			return nil, fmt.Errorf("oidc provider %q: %w", p.Name, err)
+		}
+		domains := make(map[string]string, len(p.Domains))
+		for domain, orgID := range p.Domains {
+			domains[strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@"))] = orgID
+		}
+		providers = append(providers, auth.SSOProvider{
+			Name:        p.Name,
+			DisplayName: p.DisplayName,
+			Provider: oidc.NewProvider(oidc.Config{
+				Issuer:       p.Issuer,
+				ClientID:     p.ClientID,
+				ClientSecret: p.ClientSecret,
+				RedirectURL:  strings.TrimRight(cfg.PublicURL, "/") + "/api/auth/oidc/" + url.PathEscape(p.Name) + "/callback",
+				Scopes:       p.Scopes,
+			}),
+			Domains:    domains,
+			Role:       role,
+			TrustEmail: p.TrustEmail,
+			MFATrusted: p.MFATrusted,
+		})
+		log.Printf("sso: %s (%s)", p.Name, p.Issuer)
+	}
+	return providers, nil
+}
+
+// buildRateLimits picks where the auth rate limiter keeps its counters and
+// turns the config into rules. The backend is returned so it can be purged.
+func buildRateLimits(cfg config.RateLimitConfig, store storage.Store) (auth.RateLimits, ratelimit.Backend, error) {
+	if cfg.Disabled {
+		log.Println("auth rate limiting disabled via config")
+		return auth.RateLimits{}, nil, nil
+	}
+	var backend ratelimit.Backend
+	switch strings.ToLower(cfg.Store) {
+	case "memory":
+		backend = ratelimit.NewMemory()
+	case "database":
+		backend = store
+	default:
+		return auth.RateLimits{}, nil, fmt.Errorf("unknown rate limit store %q", cfg.Store)
+	}
+	log.Printf("auth rate limiting: %d/min per address, %d/h per email, lockout after %d failures (%s)",
+		cfg.IPPerMinute, cfg.EmailPerHour, cfg.LockoutAfter, cfg.Store)
+	return auth.RateLimits{
+		Limiter: ratelimit.New(backend),
+		IP:      ratelimit.Per(cfg.IPPerMinute, time.Minute),
+		Email:   ratelimit.Per(cfg.EmailPerHour, time.Hour),
+		Lockout: ratelimit.Lockout{
+			After:  cfg.LockoutAfter,
+			Base:   time.Duration(cfg.LockoutMinutes) * time.Minute,
+			Max:    time.Duration(cfg.MaxLockoutMinutes) * time.Minute,
+			Forget: 24 * time.Hour,
+		},
+	}, backend, nil
+}
+
+// probeOllama checks the Ollama server in the background, pulling the model
+// first if configured to, and logs which model answers. Generation keeps
+// working on the configured client either way; a failed probe only warns.
+func probeOllama(ctx context.Context, client *llm.OllamaClient) {
+	model, err := client.Probe(ctx)
+	if err != nil {
+		log.Printf("ollama health check failed: %v", err)
+		return
+	}
+	var details []string
+	for _, detail := range []string{model.ParameterSize, model.Quantization} {
+		if detail != "" {
+			details = append(details, detail)
+		}
+	}
+	if len(details) > 0 {
+		log.Printf("ollama ready: model %s (%s)", model.Name, strings.Join(details, ", "))
+		return
+	}
+	log.Printf("ollama ready: model %s", model.Name)
+}
+
 func loadServiceAccountJSON(path, inline string) ([]byte, error) {
 	trimmed := strings.TrimSpace(inline)
 	if trimmed != "" {
@@ -181,3 +427,15 @@ func loadServiceAccountJSON(path, inline string) ([]byte, error) {
 	}
 	return data, nil
 }
+
+func usageBudget(budget config.Budget) usage.Budget {
+	return usage.Budget{Tokens: budget.Tokens, Requests: budget.Requests}
+}
+
+func usageBudgets(budgets map[string]config.Budget) map[string]usage.Budget {
+	converted := make(map[string]usage.Budget, len(budgets))
+	for id, budget := range budgets {
+		converted[id] = usageBudget(budget)
+	}
+	return converted
+}
@@ -17,6 +17,8 @@ func main() {
 		approved   = flag.Bool("approved", true, "Set approved state (true/false)")
 		list       = flag.Bool("list", false, "List users")
 		deleteFlag = flag.Bool("delete", false, "Delete user by email")
+		orgID      = flag.String("org", "", "Move the user to this organization id")
+		role       = flag.String("role", "", "Set the user's role (admin, office_manager, broker, assistant)")
 	)
 	flag.Parse()
 
@@ -59,11 +61,29 @@ func main() {
 		return
 	}
 
+	if *orgID != "" {
+		if err := store.SetUserOrganization(ctx, user.ID, *orgID); err != nil {
+			log.Fatalf("set organization: %v", err)
+		}
+		user.OrgID = *orgID
+	}
+
+	if *role != "" {
+		parsed, err := storage.ParseRole(*role)
+		if err != nil {
+			log.Fatalf("parse role: %v", err)
+		}
+		if err := store.SetUserRole(ctx, user.ID, parsed); err != nil {
+			log.Fatalf("set role: %v", err)
+		}
+		user.Role = parsed
+	}
+
 	if err := store.ApproveUser(ctx, user.ID, *approved); err != nil {
 		log.Fatalf("update user: %v", err)
 	}
 
-	fmt.Printf("User %s (%s) approved=%v\n", user.Email, user.ID, *approved)
+	fmt.Printf("User %s (%s) approved=%v org=%s role=%s\n", user.Email, user.ID, *approved, user.OrgID, user.Role)
 }
 
 func listUsers(ctx context.Context, store storage.Store) error {
@@ -71,9 +91,9 @@ func listUsers(ctx context.Context, store storage.Store) error {
 	if err != nil {
 		return err
 	}
-	fmt.Printf("%-40s %-6s %-30s\n", "ID", "OK?", "EMAIL")
+	fmt.Printf("%-40s %-6s %-20s %-15s %-30s\n", "ID", "OK?", "ORG", "ROLE", "EMAIL")
 	for _, u := range users {
-		fmt.Printf("%-40s %-6v %-30s\n", u.ID, u.Approved, u.Email)
+		fmt.Printf("%-40s %-6v %-20s %-15s %-30s\n", u.ID, u.Approved, u.OrgID, u.Role, u.Email)
 	}
 	return nil
 }
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"k2MarketingAi/internal/config"
+	"k2MarketingAi/internal/storage"
+)
+
+func main() {
+	var (
+		configPath = flag.String("config", "config.json", "Path to config file")
+		dryRun     = flag.Bool("dry-run", false, "Only count listings that would be backfilled")
+	)
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("load config: %v", err)
+	}
+	if cfg.DatabaseURL == "" {
+		log.Fatal("database_url is required in config to backfill listings")
+	}
+
+	ctx := context.Background()
+	store, err := storage.NewStore(ctx, cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("connect store: %v", err)
+	}
+	defer store.Close()
+
+	backfiller, ok := store.(storage.ListingColumnBackfiller)
+	if !ok {
+		log.Fatalf("store %T does not support backfilling", store)
+	}
+
+	count, err := backfiller.BackfillListingColumns(ctx, *dryRun)
+	if err != nil {
+		log.Fatalf("backfill: %v", err)
+	}
+	if *dryRun {
+		fmt.Printf("%d listings would be backfilled from details\n", count)
+		return
+	}
+	fmt.Printf("Backfilled %d listings from details\n", count)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"k2MarketingAi/internal/config"
+	"k2MarketingAi/internal/storage"
+)
+
+const usage = `usage: migrate [-config config.json] <command>
+
+commands:
+  status        list migrations and whether they are applied
+  up            apply all pending migrations
+  down [N]      revert the N most recent migrations (default 1)
+  to <version>  migrate up or down to the given version (0 reverts everything)
+`
+
+func main() {
+	configPath := flag.String("config", "config.json", "Path to config file")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("load config: %v", err)
+	}
+	if cfg.DatabaseURL == "" {
+		log.Fatal("database_url is required in config to run migrations")
+	}
+
+	ctx := context.Background()
+	migrator, err := storage.NewMigrator(ctx, cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("connect migrator: %v", err)
+	}
+	defer migrator.Close()
+
+	switch args[0] {
+	case "status":
+		if err := printStatus(ctx, migrator); err != nil {
+			log.Fatalf("status: %v", err)
+		}
+	case "up":
+		n, err := migrator.Up(ctx)
+		if err != nil {
+			log.Fatalf("up: %v", err)
+		}
+		fmt.Printf("applied %d migration(s)\n", n)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				log.Fatalf("down: invalid step count %q", args[1])
+			}
+		}
+		n, err := migrator.Down(ctx, steps)
+		if err != nil {
+			log.Fatalf("down: %v", err)
+		}
+		fmt.Printf("reverted %d migration(s)\n", n)
+	case "to":
+		if len(args) < 2 {
+			log.Fatal("to: target version is required")
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil || version < 0 {
+			log.Fatalf("to: invalid version %q", args[1])
+		}
+		n, err := migrator.To(ctx, version)
+		if err != nil {
+			log.Fatalf("to: %v", err)
+		}
+		fmt.Printf("ran %d migration(s), now at version %d\n", n, version)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func printStatus(ctx context.Context, migrator *storage.Migrator) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%-8s %-40s %-25s\n", "VERSION", "NAME", "APPLIED AT")
+	for _, s := range statuses {
+		applied := "pending"
+		if s.Applied() {
+			applied = s.AppliedAt.Format("2006-01-02 15:04:05 MST")
+		}
+		fmt.Printf("%-8d %-40s %-25s\n", s.Version, s.Name, applied)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"k2MarketingAi/internal/config"
+	"k2MarketingAi/internal/storage"
+)
+
+func main() {
+	var (
+		configPath = flag.String("config", "config.json", "Path to config file")
+		create     = flag.String("create", "", "Name of a new organization")
+		id         = flag.String("id", "", "Id for the new organization (generated when empty), or the organization -two-factor applies to")
+		twoFactor  = flag.String("two-factor", "", "Set to required or optional to change whether members of -id must use two-factor authentication")
+	)
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("load config: %v", err)
+	}
+	if cfg.DatabaseURL == "" {
+		log.Fatal("database_url is required in config to manage organizations")
+	}
+
+	ctx := context.Background()
+	store, err := storage.NewStore(ctx, cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("connect store: %v", err)
+	}
+	defer store.Close()
+
+	if *create != "" {
+		org, err := store.CreateOrganization(ctx, storage.Organization{ID: *id, Name: *create})
+		if err != nil {
+			log.Fatalf("create organization: %v", err)
+		}
+		fmt.Printf("Organization %s (%s) created\n", org.Name, org.ID)
+		return
+	}
+
+	if *twoFactor != "" {
+		if *id == "" || *twoFactor != "required" && *twoFactor != "optional" {
+			log.Fatal("-two-factor needs -id and the value required or optional")
+		}
+		if err := store.SetOrganizationTwoFactor(ctx, *id, *twoFactor == "required"); err != nil {
+			log.Fatalf("set two-factor requirement: %v", err)
+		}
+		fmt.Printf("Two-factor authentication is now %s in %s\n", *twoFactor, *id)
+		return
+	}
+
+	orgs, err := store.ListOrganizations(ctx)
+	if err != nil {
+		log.Fatalf("list organizations: %v", err)
+	}
+	fmt.Printf("%-40s %-30s %-10s\n", "ID", "NAME", "2FA")
+	for _, org := range orgs {
+		requirement := "optional"
+		if org.RequireTwoFactor {
+			requirement = "required"
+		}
+		fmt.Printf("%-40s %-30s %-10s\n", org.ID, org.Name, requirement)
+	}
+}
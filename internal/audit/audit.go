@@ -0,0 +1,177 @@
+// Package audit writes the append-only audit log. Handlers call Record after
+// a change has been saved; the request ID and client address are taken from
+// the request, and the before and after values are reduced to the fields
+// that differ.
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"k2MarketingAi/internal/storage"
+)
+
+// Entity types used in the audit log.
+const (
+	EntityListing      = "listing"
+	EntityStyleProfile = "style_profile"
+	EntityUser         = "user"
+	EntityOrganization = "organization"
+	EntityAPIToken     = "api_token"
+	EntitySession      = "session"
+)
+
+// zeroTime is how encoding/json writes a zero time.Time.
+const zeroTime = "0001-01-01T00:00:00Z"
+
+// Sink stores audit events. storage.Store implements it.
+type Sink interface {
+	RecordAuditEvent(ctx context.Context, event storage.AuditEvent) (storage.AuditEvent, error)
+}
+
+// Entry describes one change. Before and After are any JSON-encodable values;
+// nil means the entity did not exist before or no longer exists after.
+type Entry struct {
+	// Actor is who made the change. For failed logins only Email is known.
+	Actor      storage.User
+	Action     string
+	EntityType string
+	EntityID   string
+	// OrgID defaults to the actor's organization.
+	OrgID  string
+	Before any
+	After  any
+}
+
+// Record writes the entry to sink. The audit log must not turn a saved change
+// into an error response, so failures are only logged.
+func Record(r *http.Request, sink Sink, entry Entry) {
+	if sink == nil {
+		return
+	}
+	changes, err := Diff(entry.Before, entry.After)
+	if err != nil {
+		log.Printf("audit %s %s/%s: diff: %v", entry.Action, entry.EntityType, entry.EntityID, err)
+	}
+	orgID := entry.OrgID
+	if orgID == "" {
+		orgID = entry.Actor.OrgID
+	}
+	event := storage.AuditEvent{
+		OrgID:      orgID,
+		ActorID:    entry.Actor.ID,
+		ActorEmail: entry.Actor.Email,
+		Action:     entry.Action,
+		EntityType: entry.EntityType,
+		EntityID:   entry.EntityID,
+		Changes:    changes,
+		RequestID:  middleware.GetReqID(r.Context()),
+		IP:         remoteIP(r),
+	}
+	if _, err := sink.RecordAuditEvent(context.WithoutCancel(r.Context()), event); err != nil {
+		log.Printf("audit %s %s/%s: %v", entry.Action, entry.EntityType, entry.EntityID, err)
+	}
+}
+
+// Diff compares the JSON encodings of before and after and returns the
+// fields that differ, keyed by dotted path. Objects are compared field by
+// field; arrays and scalars as whole values. Empty values, including the
+// zero time, count as absent, so a created entity lists only the fields that
+// were filled in.
+func Diff(before, after any) (map[string]storage.AuditChange, error) {
+	left, err := flatten(before)
+	if err != nil {
+		return nil, err
+	}
+	right, err := flatten(after)
+	if err != nil {
+		return nil, err
+	}
+	changes := make(map[string]storage.AuditChange)
+	for path, value := range left {
+		if other, ok := right[path]; !ok || !bytes.Equal(value, other) {
+			changes[path] = storage.AuditChange{Before: value, After: right[path]}
+		}
+	}
+	for path, value := range right {
+		if _, ok := left[path]; !ok {
+			changes[path] = storage.AuditChange{After: value}
+		}
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	return changes, nil
+}
+
+// flatten encodes v and collects its non-empty leaves by dotted path.
+func flatten(v any) (map[string]json.RawMessage, error) {
+	leaves := make(map[string]json.RawMessage)
+	if v == nil {
+		return leaves, nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var decoded any
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, err
+	}
+	return leaves, collect(leaves, "", decoded)
+}
+
+func collect(leaves map[string]json.RawMessage, prefix string, value any) error {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, child := range v {
+			path := key
+			if prefix != "" {
+				path = prefix + "." + key
+			}
+			if err := collect(leaves, path, child); err != nil {
+				return err
+			}
+		}
+		return nil
+	case nil:
+		return nil
+	case string:
+		if v == "" || v == zeroTime {
+			return nil
+		}
+	case bool:
+		if !v {
+			return nil
+		}
+	case json.Number:
+		if f, err := v.Float64(); err == nil && f == 0 {
+			return nil
+		}
+	case []any:
+		if len(v) == 0 {
+			return nil
+		}
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	leaves[prefix] = encoded
+	return nil
+}
+
+func remoteIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"k2MarketingAi/internal/audit"
+	"k2MarketingAi/internal/storage"
+)
+
+// updateUserRequest carries the fields an administrator may change. Omitted
+// fields are left as they are.
+type updateUserRequest struct {
+	Approved *bool   `json:"approved"`
+	Role     *string `json:"role"`
+	OrgID    *string `json:"org_id"`
+}
+
+// ListUsers handles GET /api/admin/users. The optional status query parameter
+// (pending or approved) and org_id narrow the result.
+func (h Handler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	status := strings.TrimSpace(r.URL.Query().Get("status"))
+	if status != "" && status != "pending" && status != "approved" {
+		http.Error(w, "status m\u00e5ste vara pending eller approved", http.StatusBadRequest)
+		return
+	}
+	orgID := strings.TrimSpace(r.URL.Query().Get("org_id"))
+
+	users, err := h.Store.ListUsers(r.Context())
+	if err != nil {
+		http.Error(w, "kunde inte h\u00e4mta anv\u00e4ndare", http.StatusInternalServerError)
+		return
+	}
+	filtered := make([]storage.User, 0, len(users))
+	for _, user := range users {
+		if status == "pending" && user.Approved || status == "approved" && !user.Approved {
+			continue
+		}
+		if orgID != "" && user.OrgID != orgID {
+			continue
+		}
+		filtered = append(filtered, user)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = jsonResponse(w, http.StatusOK, filtered)
+}
+
+// UpdateUser handles PATCH /api/admin/users/{id}: approve or disable the
+// account, change its role or move it to another organization.
+func (h Handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	admin, _ := UserFromContext(r.Context())
+	id := chi.URLParam(r, "id")
+
+	var payload updateUserRequest
+	if err := decodeJSON(r, &payload); err != nil {
+		http.Error(w, "ogiltig beg\u00e4ran", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.Store.GetUserByID(r.Context(), id)
+	if err != nil {
+		writeUserLookupError(w, err)
+		return
+	}
+
+	var role storage.Role
+	if payload.Role != nil {
+		if role, err = storage.ParseRole(*payload.Role); err != nil {
+			http.Error(w, "ok\u00e4nd roll", http.StatusBadRequest)
+			return
+		}
+	}
+	if payload.OrgID != nil {
+		if _, err := h.Store.GetOrganization(r.Context(), *payload.OrgID); err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				http.Error(w, "ok\u00e4nd byr\u00e5", http.StatusBadRequest)
+				return
+			}
+			http.Error(w, "kunde inte h\u00e4mta byr\u00e5", http.StatusInternalServerError)
+			return
+		}
+	}
+	// Admins cannot lock themselves out by disabling or demoting their own account.
+	if user.ID == admin.ID && (payload.Approved != nil && !*payload.Approved || payload.Role != nil && role != admin.Role) {
+		http.Error(w, "du kan inte sp\u00e4rra eller byta roll p\u00e5 ditt eget konto", http.StatusBadRequest)
+		return
+	}
+
+	before := user
+	if payload.OrgID != nil && *payload.OrgID != user.OrgID {
+		if err := h.Store.SetUserOrganization(r.Context(), user.ID, *payload.OrgID); err != nil {
+			writeUserLookupError(w, err)
+			return
+		}
+		user.OrgID = *payload.OrgID
+	}
+	if payload.Role != nil && role != user.Role {
+		if err := h.Store.SetUserRole(r.Context(), user.ID, role); err != nil {
+			writeUserLookupError(w, err)
+			return
+		}
+		user.Role = role
+	}
+	if payload.Approved != nil && *payload.Approved != user.Approved {
+		if err := h.Store.ApproveUser(r.Context(), user.ID, *payload.Approved); err != nil {
+			writeUserLookupError(w, err)
+			return
+		}
+		user.Approved = *payload.Approved
+		if !user.Approved {
+			h.revokeSessions(r.Context(), user.ID, "")
+		}
+	}
+	h.auditUser(r, admin, "user.update", user, before, user)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = jsonResponse(w, http.StatusOK, user)
+}
+
+// DeleteUser handles DELETE /api/admin/users/{id}. The user's listings are kept.
+func (h Handler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	admin, _ := UserFromContext(r.Context())
+	id := chi.URLParam(r, "id")
+	if id == admin.ID {
+		http.Error(w, "du kan inte ta bort ditt eget konto", http.StatusBadRequest)
+		return
+	}
+	user, err := h.Store.GetUserByID(r.Context(), id)
+	if err != nil {
+		writeUserLookupError(w, err)
+		return
+	}
+	if err := h.Store.DeleteUser(r.Context(), id); err != nil {
+		writeUserLookupError(w, err)
+		return
+	}
+	h.auditUser(r, admin, "user.delete", user, user, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// updateOrganizationRequest carries the organization settings an
+// administrator may change.
+type updateOrganizationRequest struct {
+	RequireTwoFactor *bool `json:"require_two_factor"`
+}
+
+// UpdateOrganization handles PATCH /api/admin/organizations/{id}. Requiring
+// two-factor authentication does not end existing sessions; members without it
+// enroll at their next login.
+func (h Handler) UpdateOrganization(w http.ResponseWriter, r *http.Request) {
+	admin, _ := UserFromContext(r.Context())
+	id := chi.URLParam(r, "id")
+	var payload updateOrganizationRequest
+	if err := decodeJSON(r, &payload); err != nil {
+		http.Error(w, "ogiltig beg\u00e4ran", http.StatusBadRequest)
+		return
+	}
+	before, err := h.Store.GetOrganization(r.Context(), id)
+	if err != nil {
+		writeOrganizationLookupError(w, err)
+		return
+	}
+	if payload.RequireTwoFactor != nil {
+		if err := h.Store.SetOrganizationTwoFactor(r.Context(), id, *payload.RequireTwoFactor); err != nil {
+			writeOrganizationLookupError(w, err)
+			return
+		}
+	}
+	org, err := h.Store.GetOrganization(r.Context(), id)
+	if err != nil {
+		writeOrganizationLookupError(w, err)
+		return
+	}
+	audit.Record(r, h.Store, audit.Entry{
+		Actor:      admin,
+		Action:     "organization.update",
+		EntityType: audit.EntityOrganization,
+		EntityID:   org.ID,
+		OrgID:      org.ID,
+		Before:     before,
+		After:      org,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	_ = jsonResponse(w, http.StatusOK, org)
+}
+
+func writeOrganizationLookupError(w http.ResponseWriter, err error) {
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "byr\u00e5n hittades inte", http.StatusNotFound)
+		return
+	}
+	http.Error(w, "kunde inte uppdatera byr\u00e5", http.StatusInternalServerError)
+}
+
+func writeUserLookupError(w http.ResponseWriter, err error) {
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "anv\u00e4ndaren hittades inte", http.StatusNotFound)
+		return
+	}
+	http.Error(w, "kunde inte uppdatera anv\u00e4ndare", http.StatusInternalServerError)
+}
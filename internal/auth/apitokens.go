@@ -0,0 +1,260 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"k2MarketingAi/internal/audit"
+	"k2MarketingAi/internal/storage"
+)
+
+// API token scopes. A token only reaches the routes its scopes cover, and the
+// user's role still decides what it may do there.
+const (
+	ScopeListingsRead  = "listings:read"
+	ScopeListingsWrite = "listings:write"
+	ScopeVisionUse     = "vision:use"
+)
+
+// Scopes lists every scope a token can be granted.
+var Scopes = []string{ScopeListingsRead, ScopeListingsWrite, ScopeVisionUse}
+
+const (
+	// apiTokenPrefix marks our tokens so they are easy to spot in scripts and
+	// secret scanners.
+	apiTokenPrefix = "k2_"
+	// apiTokenDisplayLength is how much of the token is kept in clear text to
+	// tell tokens apart in the list.
+	apiTokenDisplayLength = 10
+	maxAPITokenNameLength = 100
+	maxAPITokenDays       = 365
+)
+
+const apiTokenContextKey contextKey = "auth/api-token"
+
+type createAPITokenRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expires_in_days"`
+}
+
+// createdAPIToken is the only response that carries the token itself.
+type createdAPIToken struct {
+	storage.APIToken
+	Token string `json:"token"`
+}
+
+// CreateAPIToken handles POST /api/auth/tokens. The token is returned once and
+// only its hash is stored.
+func (h Handler) CreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	user, _ := UserFromContext(r.Context())
+	var payload createAPITokenRequest
+	if err := decodeJSON(r, &payload); err != nil {
+		http.Error(w, "ogiltig beg\u00e4ran", http.StatusBadRequest)
+		return
+	}
+	name := strings.TrimSpace(payload.Name)
+	if name == "" || len(name) > maxAPITokenNameLength {
+		http.Error(w, "namn kr\u00e4vs (h\u00f6gst 100 tecken)", http.StatusBadRequest)
+		return
+	}
+	if len(payload.Scopes) == 0 {
+		http.Error(w, "minst ett scope kr\u00e4vs", http.StatusBadRequest)
+		return
+	}
+	scopes := make([]string, 0, len(payload.Scopes))
+	for _, scope := range payload.Scopes {
+		if !slices.Contains(Scopes, scope) {
+			http.Error(w, "ok\u00e4nt scope: "+scope, http.StatusBadRequest)
+			return
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	if payload.ExpiresInDays < 0 || payload.ExpiresInDays > maxAPITokenDays {
+		http.Error(w, "expires_in_days m\u00e5ste vara mellan 0 och 365", http.StatusBadRequest)
+		return
+	}
+
+	secret, err := newAPITokenSecret()
+	if err != nil {
+		http.Error(w, "kunde inte skapa API-nyckel", http.StatusInternalServerError)
+		return
+	}
+	token := storage.APIToken{
+		UserID:    user.ID,
+		Name:      name,
+		Prefix:    secret[:apiTokenDisplayLength],
+		TokenHash: hashAPIToken(secret),
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+	}
+	if payload.ExpiresInDays > 0 {
+		expires := token.CreatedAt.AddDate(0, 0, payload.ExpiresInDays)
+		token.ExpiresAt = &expires
+	}
+	token, err = h.Store.CreateAPIToken(r.Context(), token)
+	if err != nil {
+		http.Error(w, "kunde inte spara API-nyckel", http.StatusInternalServerError)
+		return
+	}
+	audit.Record(r, h.Store, audit.Entry{Actor: user, Action: "api_token.create", EntityType: audit.EntityAPIToken, EntityID: token.ID, After: token})
+	w.Header().Set("Content-Type", "application/json")
+	_ = jsonResponse(w, http.StatusCreated, createdAPIToken{APIToken: token, Token: secret})
+}
+
+// ListAPITokens handles GET /api/auth/tokens.
+func (h Handler) ListAPITokens(w http.ResponseWriter, r *http.Request) {
+	user, _ := UserFromContext(r.Context())
+	tokens, err := h.Store.ListAPITokens(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, "kunde inte h\u00e4mta API-nycklar", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = jsonResponse(w, http.StatusOK, tokens)
+}
+
+// RevokeAPIToken handles DELETE /api/auth/tokens/{id}.
+func (h Handler) RevokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	user, _ := UserFromContext(r.Context())
+	id := chi.URLParam(r, "id")
+	if err := h.Store.DeleteAPIToken(r.Context(), user.ID, id); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "API-nyckeln hittades inte", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "kunde inte ta bort API-nyckel", http.StatusInternalServerError)
+		return
+	}
+	audit.Record(r, h.Store, audit.Entry{Actor: user, Action: "api_token.revoke", EntityType: audit.EntityAPIToken, EntityID: id})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authenticateToken resolves a bearer token to its user. Unknown, expired and
+// revoked tokens as well as unapproved users give ErrInvalidToken.
+func (m Middleware) authenticateToken(ctx context.Context, secret string) (storage.User, storage.APIToken, error) {
+	if !strings.HasPrefix(secret, apiTokenPrefix) {
+		return storage.User{}, storage.APIToken{}, ErrInvalidToken
+	}
+	token, err := m.Store.GetAPITokenByHash(ctx, hashAPIToken(secret))
+	if errors.Is(err, storage.ErrNotFound) {
+		return storage.User{}, storage.APIToken{}, ErrInvalidToken
+	}
+	if err != nil {
+		return storage.User{}, storage.APIToken{}, err
+	}
+	now := time.Now()
+	if token.ExpiresAt != nil && !token.ExpiresAt.After(now) {
+		return storage.User{}, storage.APIToken{}, ErrInvalidToken
+	}
+	user, err := m.Store.GetUserByID(ctx, token.UserID)
+	if errors.Is(err, storage.ErrNotFound) || err == nil && !user.Approved {
+		return storage.User{}, storage.APIToken{}, ErrInvalidToken
+	}
+	if err != nil {
+		return storage.User{}, storage.APIToken{}, err
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= touchInterval {
+		if err := m.Store.TouchAPIToken(ctx, token.ID, now); err != nil {
+			log.Printf("touch api token: %v", err)
+		} else {
+			token.LastUsedAt = &now
+		}
+	}
+	return user, token, nil
+}
+
+// WithAPIToken marks the request as authenticated by an API token.
+func WithAPIToken(ctx context.Context, token storage.APIToken) context.Context {
+	return context.WithValue(ctx, apiTokenContextKey, token)
+}
+
+// APITokenFromContext returns the API token behind the request, if any.
+func APITokenFromContext(ctx context.Context) (storage.APIToken, bool) {
+	token, ok := ctx.Value(apiTokenContextKey).(storage.APIToken)
+	return token, ok
+}
+
+// RequireScope returns 403 for API token requests whose token lacks scope.
+// Browser sessions are not limited by scopes.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token, ok := APITokenFromContext(r.Context()); ok && !slices.Contains(token.Scopes, scope) {
+				http.Error(w, "API-nyckeln saknar scope "+scope, http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireReadWriteScope applies readScope to GET and HEAD requests and
+// writeScope to everything else.
+func RequireReadWriteScope(readScope, writeScope string) func(http.Handler) http.Handler {
+	read, write := RequireScope(readScope), RequireScope(writeScope)
+	return func(next http.Handler) http.Handler {
+		readNext, writeNext := read(next), write(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				readNext.ServeHTTP(w, r)
+				return
+			}
+			writeNext.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireSession returns 401 without a signed-in user and 403 for API token
+// requests, keeping account management in the browser.
+func RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := UserFromContext(r.Context()); !ok {
+			http.Error(w, "inloggning kr\u00e4vs", http.StatusUnauthorized)
+			return
+		}
+		if _, ok := APITokenFromContext(r.Context()); ok {
+			http.Error(w, "kr\u00e4ver inloggning i webbl\u00e4saren", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// bearerToken extracts the token from an Authorization: Bearer header.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+func newAPITokenSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return apiTokenPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashAPIToken is what the store keeps. Tokens carry 256 bits of randomness,
+// so a plain SHA-256 is enough and allows lookups by hash.
+func hashAPIToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -8,13 +8,16 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
-	"strconv"
 	"strings"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
 
+	"k2MarketingAi/internal/audit"
+	"k2MarketingAi/internal/mail"
+	"k2MarketingAi/internal/notify"
 	"k2MarketingAi/internal/storage"
 )
 
@@ -23,32 +26,57 @@ var ErrInvalidCredentials = errors.New("invalid credentials")
 
 type contextKey string
 
-const userContextKey contextKey = "auth/user"
+const (
+	userContextKey    contextKey = "auth/user"
+	sessionContextKey contextKey = "auth/session"
+)
 
-// SessionManager signs and validates lightweight session tokens.
+// SessionManager keeps sessions in Store and signs the cookies that refer to them.
 type SessionManager struct {
-	Secret       []byte
+	Store  storage.Store
+	Secret []byte
+	// PreviousSecret is still accepted until PreviousSecretUntil so that
+	// rotating Secret does not sign everyone out. Cookies signed with it are
+	// re-signed with Secret on their next request.
+	PreviousSecret      []byte
+	PreviousSecretUntil time.Time
+	// Duration is the idle timeout. Every request slides the expiry forward.
 	Duration     time.Duration
 	CookieName   string
 	SecureCookie bool
 }
 
-// Claims captures decoded session data.
+// Claims captures a decoded session cookie.
 type Claims struct {
-	UserID    string
-	ExpiresAt time.Time
+	SessionID string
+	// Stale is set when the cookie was signed with the previous secret.
+	Stale bool
 }
 
-// Middleware attaches the authenticated user to the request context when a valid session cookie exists.
+// Middleware attaches the authenticated user to the request context when a
+// valid API token or session cookie exists.
 type Middleware struct {
 	Store    storage.Store
 	Sessions SessionManager
+	// Limits throttles the auth endpoints. The zero value turns it off.
+	Limits RateLimits
 }
 
+// deliveryTimeout bounds how long a background mail or admin notification may take.
+const deliveryTimeout = 30 * time.Second
+
 // Handler exposes auth endpoints for registering and logging in users.
 type Handler struct {
 	Store    storage.Store
 	Sessions SessionManager
+	// Notifier tells administrators about new registrations. Nil disables it.
+	Notifier notify.Notifier
+	// Mail delivers verification and password reset messages. Nil disables them.
+	Mail mail.Sender
+	// PublicURL is the externally visible base URL used for links in mail.
+	PublicURL string
+	// SSO lists the OpenID Connect providers users may sign in with.
+	SSO []SSOProvider
 }
 
 type authRequest struct {
@@ -56,19 +84,46 @@ type authRequest struct {
 	Password string `json:"password"`
 }
 
-// InjectUser parses the session cookie (if present) and loads the user into context.
+// InjectUser loads the user behind an Authorization: Bearer API token or,
+// without one, resumes the session named by the cookie (if present). A bearer
+// token that does not check out is answered with 401 straight away rather than
+// falling back to the cookie.
 func (m Middleware) InjectUser(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if secret, ok := bearerToken(r); ok {
+			user, token, err := m.authenticateToken(r.Context(), secret)
+			if errors.Is(err, ErrInvalidToken) {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				http.Error(w, "ogiltig API-nyckel", http.StatusUnauthorized)
+				return
+			}
+			if err != nil {
+				log.Printf("authenticate api token: %v", err)
+				http.Error(w, "kunde inte kontrollera API-nyckel", http.StatusInternalServerError)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(WithAPIToken(WithUser(r.Context(), user), token)))
+			return
+		}
+
 		cookie, err := r.Cookie(m.Sessions.cookieName())
 		if err == nil && cookie.Value != "" {
-			if claims, err := m.Sessions.Parse(cookie.Value); err == nil && claims.ExpiresAt.After(time.Now()) {
-				if user, err := m.Store.GetUserByID(r.Context(), claims.UserID); err == nil && user.Approved {
-					r = r.WithContext(WithUser(r.Context(), user))
+			session, refresh, err := m.Sessions.Resume(r.Context(), cookie.Value)
+			switch {
+			case err == nil:
+				if user, err := m.Store.GetUserByID(r.Context(), session.UserID); err == nil && user.Approved {
+					r = r.WithContext(WithSession(WithUser(r.Context(), user), session))
 				}
-			} else if err != nil {
+				if refresh {
+					renewed := m.Sessions.cookie(m.Sessions.token(session.ID), session.ExpiresAt)
+					http.SetCookie(w, &renewed)
+				}
+			case errors.Is(err, ErrInvalidSession):
 				// Clear unusable cookies to avoid loops.
 				clear := m.Sessions.expiredCookie()
 				http.SetCookie(w, &clear)
+			default:
+				log.Printf("resume session: %v", err)
 			}
 		}
 		next.ServeHTTP(w, r)
@@ -95,7 +150,7 @@ func (h Handler) Register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	email := normalizeEmail(payload.Email)
-	if email == "" || len(payload.Password) < 6 {
+	if email == "" || len(payload.Password) < minPasswordLength {
 		http.Error(w, "e-post och l\u00f6senord kr\u00e4vs (minst 6 tecken)", http.StatusBadRequest)
 		return
 	}
@@ -121,14 +176,20 @@ func (h Handler) Register(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "kunde inte spara anv\u00e4ndare", http.StatusInternalServerError)
 		return
 	}
+	h.auditUser(r, created, "user.register", created, nil, created)
+	h.notifyAdmins(created)
+	h.sendVerification(created)
 
 	w.Header().Set("Content-Type", "application/json")
 	_ = jsonResponse(w, http.StatusCreated, map[string]any{
-		"id":         created.ID,
-		"email":      created.Email,
-		"created_at": created.CreatedAt,
-		"approved":   created.Approved,
-		"status":     "pending_approval",
+		"id":             created.ID,
+		"email":          created.Email,
+		"org_id":         created.OrgID,
+		"role":           created.Role,
+		"created_at":     created.CreatedAt,
+		"approved":       created.Approved,
+		"email_verified": created.EmailVerified,
+		"status":         "pending_approval",
 	})
 }
 
@@ -148,30 +209,57 @@ func (h Handler) Login(w http.ResponseWriter, r *http.Request) {
 
 	user, err := h.Store.GetUserByEmail(r.Context(), email)
 	if err != nil {
+		h.auditLoginFailed(r, email, storage.User{}, "unknown_email")
 		http.Error(w, "felaktiga inloggningsuppgifter", http.StatusUnauthorized)
 		return
 	}
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(payload.Password)); err != nil {
+		h.auditLoginFailed(r, email, user, "wrong_password")
 		http.Error(w, "felaktiga inloggningsuppgifter", http.StatusUnauthorized)
 		return
 	}
 	if !user.Approved {
+		h.auditLoginFailed(r, email, user, "not_approved")
 		http.Error(w, "kontot v\u00e4ntar p\u00e5 godk\u00e4nnande", http.StatusForbidden)
 		return
 	}
 
-	h.setSessionCookie(w, user.ID)
+	// With two-factor authentication the password only earns a challenge; the
+	// cookie is issued by LoginTwoFactor once the code checks out.
+	step, err := h.secondFactor(r.Context(), user)
+	if err != nil {
+		http.Error(w, "kunde inte kontrollera tv\u00e5stegsverifiering", http.StatusInternalServerError)
+		return
+	}
+	if step != "" {
+		challenge, err := h.Sessions.IssueToken(PurposeTwoFactor, user.ID, user.PasswordHash, challengeTTL)
+		if err != nil {
+			http.Error(w, "kunde inte skapa session", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = jsonResponse(w, http.StatusOK, map[string]any{step: true, "challenge": challenge})
+		return
+	}
+
+	if err := h.startSession(w, r, user, "password"); err != nil {
+		http.Error(w, "kunde inte skapa session", http.StatusInternalServerError)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	_ = jsonResponse(w, http.StatusOK, map[string]any{
-		"id":         user.ID,
-		"email":      user.Email,
-		"created_at": user.CreatedAt,
-		"approved":   user.Approved,
-	})
+	_ = jsonResponse(w, http.StatusOK, userResponse(user))
 }
 
-// Logout handles POST /api/auth/logout.
-func (h Handler) Logout(w http.ResponseWriter, _ *http.Request) {
+// Logout handles POST /api/auth/logout and revokes the current session.
+func (h Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	if session, ok := SessionFromContext(r.Context()); ok {
+		if err := h.Store.DeleteSession(r.Context(), session.UserID, session.ID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "kunde inte logga ut", http.StatusInternalServerError)
+			return
+		}
+		user, _ := UserFromContext(r.Context())
+		audit.Record(r, h.Store, audit.Entry{Actor: user, Action: "auth.logout", EntityType: audit.EntitySession, EntityID: session.ID})
+	}
 	cookie := h.Sessions.expiredCookie()
 	http.SetCookie(w, &cookie)
 	w.WriteHeader(http.StatusNoContent)
@@ -185,58 +273,101 @@ func (h Handler) Me(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	_ = jsonResponse(w, http.StatusOK, map[string]any{
-		"id":         user.ID,
-		"email":      user.Email,
-		"created_at": user.CreatedAt,
-		"approved":   user.Approved,
-	})
+	_ = jsonResponse(w, http.StatusOK, userResponse(user))
+}
+
+// userResponse is the profile returned after login and by Me.
+func userResponse(user storage.User) map[string]any {
+	return map[string]any{
+		"id":             user.ID,
+		"email":          user.Email,
+		"org_id":         user.OrgID,
+		"role":           user.Role,
+		"created_at":     user.CreatedAt,
+		"approved":       user.Approved,
+		"email_verified": user.EmailVerified,
+	}
 }
 
-func (h Handler) notifyAdmins(user storage.User) {}
+// notifyAdmins tells administrators that user waits for approval. It runs in the
+// background so a slow mail server or webhook never delays registration.
+func (h Handler) notifyAdmins(user storage.User) {
+	if h.Notifier == nil {
+		return
+	}
+	n := notify.Notification{
+		Event:   notify.EventUserRegistered,
+		Subject: "Ny anv\u00e4ndare v\u00e4ntar p\u00e5 godk\u00e4nnande",
+		Text: fmt.Sprintf("%s har registrerat sig och v\u00e4ntar p\u00e5 godk\u00e4nnande.\n"+
+			"Godk\u00e4nn kontot med PATCH /api/admin/users/%s eller lista v\u00e4ntande konton med GET /api/admin/users?status=pending.",
+			user.Email, user.ID),
+		Data: map[string]any{
+			"id":         user.ID,
+			"email":      user.Email,
+			"org_id":     user.OrgID,
+			"created_at": user.CreatedAt,
+		},
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), deliveryTimeout)
+		defer cancel()
+		if err := h.Notifier.Notify(ctx, n); err != nil {
+			log.Printf("notify admins about %s: %v", user.Email, err)
+		}
+	}()
+}
 
-// Parse validates a token and returns session claims.
+// Parse validates the signature of a session cookie and returns its claims.
 func (sm SessionManager) Parse(token string) (Claims, error) {
-	parts := strings.Split(token, ".")
-	if len(parts) != 2 {
+	sessionID, encoded, ok := strings.Cut(token, ".")
+	if !ok || sessionID == "" {
 		return Claims{}, errors.New("invalid token format")
 	}
-	payload := parts[0]
-	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	sig, err := base64.RawURLEncoding.DecodeString(encoded)
 	if err != nil {
 		return Claims{}, fmt.Errorf("decode signature: %w", err)
 	}
-
-	mac := hmac.New(sha256.New, sm.Secret)
-	mac.Write([]byte(payload))
-	if !hmac.Equal(mac.Sum(nil), sig) {
+	current, ok := sm.verify(sessionID, sig)
+	if !ok {
 		return Claims{}, errors.New("signature mismatch")
 	}
+	return Claims{SessionID: sessionID, Stale: !current}, nil
+}
 
-	payloadParts := strings.Split(payload, "|")
-	if len(payloadParts) != 2 {
-		return Claims{}, errors.New("invalid payload")
-	}
-	userID := payloadParts[0]
-	expUnix, err := strconv.ParseInt(payloadParts[1], 10, 64)
-	if err != nil {
-		return Claims{}, fmt.Errorf("parse expiry: %w", err)
+// token signs a session id with the current secret.
+func (sm SessionManager) token(sessionID string) string {
+	return sessionID + "." + base64.RawURLEncoding.EncodeToString(sm.sign(sessionID))
+}
+
+// sign returns the HMAC-SHA256 of payload under the current secret.
+func (sm SessionManager) sign(payload string) []byte {
+	return signWith(sm.Secret, payload)
+}
+
+// verify checks sig against the current secret and, during the grace period,
+// the previous one. current reports whether the current secret matched.
+func (sm SessionManager) verify(payload string, sig []byte) (current, ok bool) {
+	for i, key := range sm.keys() {
+		if hmac.Equal(signWith(key, payload), sig) {
+			return i == 0, true
+		}
 	}
-	return Claims{UserID: userID, ExpiresAt: time.Unix(expUnix, 0)}, nil
+	return false, false
 }
 
-// Issue builds a signed session token for the given user.
-func (sm SessionManager) Issue(userID string) (string, time.Time, error) {
-	if len(sm.Secret) == 0 {
-		return "", time.Time{}, errors.New("session secret missing")
+// keys lists the accepted signing keys, current first.
+func (sm SessionManager) keys() [][]byte {
+	keys := [][]byte{sm.Secret}
+	if len(sm.PreviousSecret) > 0 && time.Now().Before(sm.PreviousSecretUntil) {
+		keys = append(keys, sm.PreviousSecret)
 	}
-	expires := time.Now().Add(sm.sessionDuration())
-	payload := fmt.Sprintf("%s|%d", userID, expires.Unix())
-	mac := hmac.New(sha256.New, sm.Secret)
+	return keys
+}
+
+func signWith(key []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, key)
 	mac.Write([]byte(payload))
-	sig := mac.Sum(nil)
-	token := payload + "." + base64.RawURLEncoding.EncodeToString(sig)
-	return token, expires, nil
+	return mac.Sum(nil)
 }
 
 // WithUser stores the authenticated user in context.
@@ -250,14 +381,34 @@ func UserFromContext(ctx context.Context) (storage.User, bool) {
 	return user, ok
 }
 
-func (h Handler) setSessionCookie(w http.ResponseWriter, userID string) {
-	token, exp, err := h.Sessions.Issue(userID)
+// WithSession stores the session behind the request in context.
+func WithSession(ctx context.Context, session storage.Session) context.Context {
+	return context.WithValue(ctx, sessionContextKey, session)
+}
+
+// SessionFromContext extracts the current session from context if present.
+func SessionFromContext(ctx context.Context) (storage.Session, bool) {
+	session, ok := ctx.Value(sessionContextKey).(storage.Session)
+	return session, ok
+}
+
+// startSession creates a session for the user and sets its cookie. method
+// names how the user signed in, for the audit log.
+func (h Handler) startSession(w http.ResponseWriter, r *http.Request, user storage.User, method string) error {
+	session, err := h.Sessions.Start(r.Context(), user.ID, r)
 	if err != nil {
-		http.Error(w, "kunde inte skapa session", http.StatusInternalServerError)
-		return
+		return err
 	}
-	cookie := h.Sessions.cookie(token, exp)
+	audit.Record(r, h.Store, audit.Entry{
+		Actor:      user,
+		Action:     "auth.login",
+		EntityType: audit.EntitySession,
+		EntityID:   session.ID,
+		After:      map[string]string{"method": method},
+	})
+	cookie := h.Sessions.cookie(h.Sessions.token(session.ID), session.ExpiresAt)
 	http.SetCookie(w, &cookie)
+	return nil
 }
 
 func (sm SessionManager) cookie(token string, expires time.Time) http.Cookie {
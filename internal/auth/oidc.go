@@ -0,0 +1,356 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/oauth2"
+
+	"k2MarketingAi/internal/oidc"
+	"k2MarketingAi/internal/storage"
+)
+
+// ssoAttemptTTL is how long a user has to finish signing in at the provider.
+const ssoAttemptTTL = 10 * time.Minute
+
+// Reasons passed to the start page as ?sso_error= when single sign-on fails.
+const (
+	ssoErrorDenied    = "denied"
+	ssoErrorExpired   = "expired"
+	ssoErrorFailed    = "failed"
+	ssoErrorEmail     = "email"
+	ssoErrorNoAccount = "no_account"
+	ssoErrorPending   = "pending"
+	ssoErrorTwoFactor = "two_factor"
+	ssoErrorDomain    = "domain"
+)
+
+// SSOProvider is an OpenID Connect provider offered next to password login.
+type SSOProvider struct {
+	// Name identifies the provider in URLs.
+	Name        string
+	DisplayName string
+	Provider    *oidc.Provider
+	// Domains maps lower-case email domains to the organization that users
+	// without an account are created in. Existing accounts only sign in
+	// through the provider when their domain maps to their own organization,
+	// so the provider cannot vouch for addresses it does not manage.
+	Domains map[string]string
+	// Role is given to users created on first sign-in.
+	Role storage.Role
+	// TrustEmail accepts email claims that are not marked as verified.
+	TrustEmail bool
+	// MFATrusted lets accounts that need a second factor sign in through the
+	// provider, because it enforces multi-factor authentication itself.
+	MFATrusted bool
+}
+
+// ssoAttempt is kept in a signed cookie between the redirect to the provider
+// and the callback, so nothing has to be stored server-side.
+type ssoAttempt struct {
+	Provider  string `json:"p"`
+	State     string `json:"s"`
+	Nonce     string `json:"n"`
+	Verifier  string `json:"v"`
+	ReturnTo  string `json:"r"`
+	ExpiresAt int64  `json:"e"`
+}
+
+type ssoProviderResponse struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	LoginURL    string `json:"login_url"`
+}
+
+// SSOProviders handles GET /api/auth/oidc and lists the providers for the login page.
+func (h Handler) SSOProviders(w http.ResponseWriter, _ *http.Request) {
+	providers := make([]ssoProviderResponse, 0, len(h.SSO))
+	for _, p := range h.SSO {
+		providers = append(providers, ssoProviderResponse{
+			Name:        p.Name,
+			DisplayName: p.DisplayName,
+			LoginURL:    "/api/auth/oidc/" + url.PathEscape(p.Name) + "/login",
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = jsonResponse(w, http.StatusOK, providers)
+}
+
+// SSOLogin handles GET /api/auth/oidc/{provider}/login and sends the browser to
+// the provider. ?return_to= names the page to come back to after signing in.
+func (h Handler) SSOLogin(w http.ResponseWriter, r *http.Request) {
+	provider, ok := h.ssoProvider(chi.URLParam(r, "provider"))
+	if !ok {
+		http.Error(w, "ok\u00e4nd inloggningstj\u00e4nst", http.StatusNotFound)
+		return
+	}
+	state, err := randomString()
+	if err != nil {
+		http.Error(w, "kunde inte starta inloggning", http.StatusInternalServerError)
+		return
+	}
+	nonce, err := randomString()
+	if err != nil {
+		http.Error(w, "kunde inte starta inloggning", http.StatusInternalServerError)
+		return
+	}
+	attempt := ssoAttempt{
+		Provider:  provider.Name,
+		State:     state,
+		Nonce:     nonce,
+		Verifier:  oauth2.GenerateVerifier(),
+		ReturnTo:  safeReturnPath(r.URL.Query().Get("return_to")),
+		ExpiresAt: time.Now().Add(ssoAttemptTTL).Unix(),
+	}
+	target, err := provider.Provider.AuthCodeURL(r.Context(), attempt.State, attempt.Nonce, attempt.Verifier)
+	if err != nil {
+		log.Printf("sso %s: %v", provider.Name, err)
+		http.Error(w, "inloggningstj\u00e4nsten svarar inte", http.StatusBadGateway)
+		return
+	}
+	cookie, err := h.Sessions.ssoCookie(attempt)
+	if err != nil {
+		http.Error(w, "kunde inte starta inloggning", http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &cookie)
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// SSOCallback handles GET /api/auth/oidc/{provider}/callback. It validates the
+// ID token, finds or creates the user and starts a session. Failures send the
+// browser to the start page with ?sso_error= set.
+func (h Handler) SSOCallback(w http.ResponseWriter, r *http.Request) {
+	provider, ok := h.ssoProvider(chi.URLParam(r, "provider"))
+	if !ok {
+		http.Error(w, "ok\u00e4nd inloggningstj\u00e4nst", http.StatusNotFound)
+		return
+	}
+	attempt, err := h.Sessions.readSSOCookie(r)
+	expired := h.Sessions.expiredSSOCookie()
+	http.SetCookie(w, &expired)
+
+	query := r.URL.Query()
+	if query.Get("error") != "" {
+		ssoFailed(w, r, ssoErrorDenied)
+		return
+	}
+	if err != nil || attempt.Provider != provider.Name || !hmac.Equal([]byte(attempt.State), []byte(query.Get("state"))) {
+		ssoFailed(w, r, ssoErrorExpired)
+		return
+	}
+	identity, err := provider.Provider.Exchange(r.Context(), query.Get("code"), attempt.Verifier, attempt.Nonce)
+	if err != nil {
+		log.Printf("sso %s: %v", provider.Name, err)
+		ssoFailed(w, r, ssoErrorFailed)
+		return
+	}
+	email := normalizeEmail(identity.Email)
+	if email == "" || !identity.EmailVerified && !provider.TrustEmail {
+		ssoFailed(w, r, ssoErrorEmail)
+		return
+	}
+
+	user, err := h.Store.GetUserByEmail(r.Context(), email)
+	if errors.Is(err, storage.ErrNotFound) {
+		user, err = h.provisionSSOUser(r, provider, email, identity.EmailVerified)
+		if errors.Is(err, storage.ErrNotFound) {
+			ssoFailed(w, r, ssoErrorNoAccount)
+			return
+		}
+	} else if err == nil && !provider.manages(user) {
+		log.Printf("sso %s: %s is not in a domain of the provider for organization %s", provider.Name, email, user.OrgID)
+		h.auditLoginFailed(r, email, user, "sso_domain")
+		ssoFailed(w, r, ssoErrorDomain)
+		return
+	}
+	if err != nil {
+		log.Printf("sso %s: user %s: %v", provider.Name, email, err)
+		ssoFailed(w, r, ssoErrorFailed)
+		return
+	}
+	if !user.Approved {
+		ssoFailed(w, r, ssoErrorPending)
+		return
+	}
+	// The provider vouches for the address, unless it was only trusted.
+	if identity.EmailVerified && !user.EmailVerified {
+		if err := h.Store.SetEmailVerified(r.Context(), user.ID, true); err != nil {
+			log.Printf("verify %s after sso: %v", user.Email, err)
+		}
+	}
+
+	// The callback cannot ask for a code, so accounts with two-factor
+	// authentication, or in an organization that requires it, only get in
+	// through providers trusted to have checked a second factor.
+	if !provider.MFATrusted {
+		step, err := h.secondFactor(r.Context(), user)
+		if err != nil {
+			log.Printf("sso %s: two-factor status of %s: %v", provider.Name, user.Email, err)
+			ssoFailed(w, r, ssoErrorFailed)
+			return
+		}
+		if step != "" {
+			h.auditLoginFailed(r, email, user, "sso_two_factor")
+			ssoFailed(w, r, ssoErrorTwoFactor)
+			return
+		}
+	}
+
+	if err := h.startSession(w, r, user, "sso:"+provider.Name); err != nil {
+		ssoFailed(w, r, ssoErrorFailed)
+		return
+	}
+	http.Redirect(w, r, attempt.ReturnTo, http.StatusFound)
+}
+
+// manages reports whether the user's email domain is one of the provider's
+// and maps to the user's organization.
+func (p SSOProvider) manages(user storage.User) bool {
+	_, domain, _ := strings.Cut(normalizeEmail(user.Email), "@")
+	orgID, ok := p.Domains[domain]
+	return ok && orgID == user.OrgID
+}
+
+// provisionSSOUser creates an approved account for an address in one of the
+// provider's domains. Addresses in other domains give storage.ErrNotFound.
+func (h Handler) provisionSSOUser(r *http.Request, provider SSOProvider, email string, verified bool) (storage.User, error) {
+	ctx := r.Context()
+	_, domain, _ := strings.Cut(email, "@")
+	orgID, ok := provider.Domains[domain]
+	if !ok {
+		return storage.User{}, storage.ErrNotFound
+	}
+	// No password: the account signs in through the provider until the user
+	// sets one with a reset link.
+	user, err := h.Store.CreateUser(ctx, storage.User{
+		Email:     email,
+		OrgID:     orgID,
+		Role:      provider.Role,
+		CreatedAt: time.Now(),
+	})
+	if errors.Is(err, storage.ErrUserExists) {
+		// Someone else's callback created it a moment ago.
+		existing, err := h.Store.GetUserByEmail(ctx, email)
+		if err == nil && !provider.manages(existing) {
+			return storage.User{}, storage.ErrNotFound
+		}
+		return existing, err
+	}
+	if err != nil {
+		return storage.User{}, err
+	}
+	if err := h.Store.ApproveUser(ctx, user.ID, true); err != nil {
+		return storage.User{}, err
+	}
+	if verified {
+		if err := h.Store.SetEmailVerified(ctx, user.ID, true); err != nil {
+			return storage.User{}, err
+		}
+	}
+	user.Approved = true
+	user.EmailVerified = verified
+	log.Printf("sso %s: created %s in organization %s", provider.Name, email, orgID)
+	h.auditUser(r, user, "user.provision", user, nil, user)
+	return user, nil
+}
+
+func (h Handler) ssoProvider(name string) (SSOProvider, bool) {
+	for _, p := range h.SSO {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return SSOProvider{}, false
+}
+
+func ssoFailed(w http.ResponseWriter, r *http.Request, reason string) {
+	http.Redirect(w, r, "/?sso_error="+reason, http.StatusFound)
+}
+
+// ssoCookie signs the attempt. It is scoped to the oidc routes and uses
+// SameSite=Lax so it survives the top-level redirect back from the provider.
+func (sm SessionManager) ssoCookie(attempt ssoAttempt) (http.Cookie, error) {
+	data, err := json.Marshal(attempt)
+	if err != nil {
+		return http.Cookie{}, err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return http.Cookie{
+		Name:     sm.cookieName() + "_sso",
+		Value:    payload + "." + base64.RawURLEncoding.EncodeToString(sm.sign("sso|"+payload)),
+		Path:     "/api/auth/oidc/",
+		MaxAge:   int(ssoAttemptTTL.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   sm.SecureCookie,
+	}, nil
+}
+
+func (sm SessionManager) readSSOCookie(r *http.Request) (ssoAttempt, error) {
+	cookie, err := r.Cookie(sm.cookieName() + "_sso")
+	if err != nil {
+		return ssoAttempt{}, err
+	}
+	payload, encoded, ok := strings.Cut(cookie.Value, ".")
+	if !ok {
+		return ssoAttempt{}, ErrInvalidToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return ssoAttempt{}, ErrInvalidToken
+	}
+	if _, ok := sm.verify("sso|"+payload, sig); !ok {
+		return ssoAttempt{}, ErrInvalidToken
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return ssoAttempt{}, ErrInvalidToken
+	}
+	var attempt ssoAttempt
+	if err := json.Unmarshal(data, &attempt); err != nil {
+		return ssoAttempt{}, ErrInvalidToken
+	}
+	if time.Now().Unix() > attempt.ExpiresAt {
+		return ssoAttempt{}, ErrInvalidToken
+	}
+	return attempt, nil
+}
+
+func (sm SessionManager) expiredSSOCookie() http.Cookie {
+	return http.Cookie{
+		Name:     sm.cookieName() + "_sso",
+		Value:    "",
+		Path:     "/api/auth/oidc/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   sm.SecureCookie,
+	}
+}
+
+// safeReturnPath only allows local paths so the login cannot be used as an
+// open redirect.
+func safeReturnPath(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.HasPrefix(path, "/\\") {
+		return "/"
+	}
+	return path
+}
+
+func randomString() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
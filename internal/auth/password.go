@@ -0,0 +1,265 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"k2MarketingAi/internal/mail"
+	"k2MarketingAi/internal/storage"
+)
+
+const (
+	minPasswordLength = 6
+	resetTokenTTL     = time.Hour
+	verifyTokenTTL    = 72 * time.Hour
+)
+
+type forgotRequest struct {
+	Email string `json:"email"`
+}
+
+type resetRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type verifyRequest struct {
+	Token string `json:"token"`
+}
+
+// ForgotPassword handles POST /api/auth/forgot. It always answers 202 so the
+// response does not reveal which addresses have accounts.
+func (h Handler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var payload forgotRequest
+	if err := decodeJSON(r, &payload); err != nil {
+		http.Error(w, "ogiltig beg\u00e4ran", http.StatusBadRequest)
+		return
+	}
+	email := normalizeEmail(payload.Email)
+	if email == "" {
+		http.Error(w, "e-post kr\u00e4vs", http.StatusBadRequest)
+		return
+	}
+
+	if user, err := h.Store.GetUserByEmail(r.Context(), email); err == nil {
+		token, err := h.Sessions.IssueToken(PurposePasswordReset, user.ID, user.PasswordHash, resetTokenTTL)
+		if err != nil {
+			http.Error(w, "kunde inte skapa l\u00e4nk", http.StatusInternalServerError)
+			return
+		}
+		h.sendMail(mail.Message{
+			To:      []string{user.Email},
+			Subject: "\u00c5terst\u00e4ll ditt l\u00f6senord",
+			Body: fmt.Sprintf("Hej!\n\nN\u00e5gon (f\u00f6rhoppningsvis du) har bett om att f\u00e5 \u00e5terst\u00e4lla l\u00f6senordet f\u00f6r %s.\n"+
+				"V\u00e4lj ett nytt l\u00f6senord via l\u00e4nken nedan. L\u00e4nken g\u00e4ller i en timme och kan bara anv\u00e4ndas en g\u00e5ng.\n\n%s\n\n"+
+				"Har du inte bett om detta kan du bortse fr\u00e5n meddelandet.\n",
+				user.Email, h.link("/reset.html", token)),
+		})
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "kunde inte h\u00e4mta anv\u00e4ndare", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ResetPassword handles POST /api/auth/reset with a token from ForgotPassword.
+func (h Handler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var payload resetRequest
+	if err := decodeJSON(r, &payload); err != nil {
+		http.Error(w, "ogiltig beg\u00e4ran", http.StatusBadRequest)
+		return
+	}
+	if len(payload.Password) < minPasswordLength {
+		http.Error(w, "l\u00f6senordet m\u00e5ste ha minst 6 tecken", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := h.Sessions.ParseToken(PurposePasswordReset, payload.Token)
+	if err != nil {
+		http.Error(w, "ogiltig eller utg\u00e5ngen l\u00e4nk", http.StatusBadRequest)
+		return
+	}
+	user, err := h.Store.GetUserByID(r.Context(), claims.UserID)
+	if err != nil || !h.Sessions.TokenMatches(claims, user.PasswordHash) {
+		http.Error(w, "ogiltig eller utg\u00e5ngen l\u00e4nk", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.setPassword(r.Context(), user, payload.Password); err != nil {
+		http.Error(w, "kunde inte spara l\u00f6senord", http.StatusInternalServerError)
+		return
+	}
+	h.auditUser(r, user, "user.password.reset", user, nil, nil)
+	// Whoever knew the old password is signed out everywhere.
+	h.revokeSessions(r.Context(), user.ID, "")
+	// The reset link reached the inbox, which proves the address as well.
+	if !user.EmailVerified {
+		if err := h.Store.SetEmailVerified(r.Context(), user.ID, true); err != nil {
+			log.Printf("verify %s after password reset: %v", user.Email, err)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ChangePassword handles POST /api/auth/change-password for the signed-in user.
+func (h Handler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	user, ok := UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "inloggning kr\u00e4vs", http.StatusUnauthorized)
+		return
+	}
+	var payload changePasswordRequest
+	if err := decodeJSON(r, &payload); err != nil {
+		http.Error(w, "ogiltig beg\u00e4ran", http.StatusBadRequest)
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(payload.CurrentPassword)); err != nil {
+		http.Error(w, "nuvarande l\u00f6senord st\u00e4mmer inte", http.StatusForbidden)
+		return
+	}
+	if len(payload.NewPassword) < minPasswordLength {
+		http.Error(w, "l\u00f6senordet m\u00e5ste ha minst 6 tecken", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.setPassword(r.Context(), user, payload.NewPassword); err != nil {
+		http.Error(w, "kunde inte spara l\u00f6senord", http.StatusInternalServerError)
+		return
+	}
+	h.auditUser(r, user, "user.password.change", user, nil, nil)
+	current, _ := SessionFromContext(r.Context())
+	h.revokeSessions(r.Context(), user.ID, current.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// VerifyEmail handles GET and POST /api/auth/verify. The GET form is the link in
+// the verification mail and redirects to the start page; POST answers with JSON.
+func (h Handler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if r.Method == http.MethodPost {
+		var payload verifyRequest
+		if err := decodeJSON(r, &payload); err != nil {
+			http.Error(w, "ogiltig beg\u00e4ran", http.StatusBadRequest)
+			return
+		}
+		token = payload.Token
+	}
+
+	claims, err := h.Sessions.ParseToken(PurposeVerifyEmail, token)
+	if err != nil {
+		http.Error(w, "ogiltig eller utg\u00e5ngen l\u00e4nk", http.StatusBadRequest)
+		return
+	}
+	user, err := h.Store.GetUserByID(r.Context(), claims.UserID)
+	if err != nil || !h.Sessions.TokenMatches(claims, verifyState(user)) {
+		http.Error(w, "ogiltig eller utg\u00e5ngen l\u00e4nk", http.StatusBadRequest)
+		return
+	}
+	if err := h.Store.SetEmailVerified(r.Context(), user.ID, true); err != nil {
+		http.Error(w, "kunde inte verifiera e-postadressen", http.StatusInternalServerError)
+		return
+	}
+	if !user.EmailVerified {
+		verified := user
+		verified.EmailVerified = true
+		h.auditUser(r, user, "user.email.verify", user, user, verified)
+	}
+
+	if r.Method == http.MethodGet {
+		http.Redirect(w, r, "/?email_verified=1", http.StatusSeeOther)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = jsonResponse(w, http.StatusOK, map[string]any{
+		"id":             user.ID,
+		"email":          user.Email,
+		"email_verified": true,
+	})
+}
+
+// ResendVerification handles POST /api/auth/verify/resend for the signed-in user.
+func (h Handler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	user, ok := UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "inloggning kr\u00e4vs", http.StatusUnauthorized)
+		return
+	}
+	if user.EmailVerified {
+		http.Error(w, "e-postadressen \u00e4r redan verifierad", http.StatusConflict)
+		return
+	}
+	h.sendVerification(user)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// sendVerification mails a verification link to a new or unverified user.
+func (h Handler) sendVerification(user storage.User) {
+	token, err := h.Sessions.IssueToken(PurposeVerifyEmail, user.ID, verifyState(user), verifyTokenTTL)
+	if err != nil {
+		log.Printf("issue verification token for %s: %v", user.Email, err)
+		return
+	}
+	h.sendMail(mail.Message{
+		To:      []string{user.Email},
+		Subject: "Bekr\u00e4fta din e-postadress",
+		Body: fmt.Sprintf("Hej!\n\nBekr\u00e4fta att %s \u00e4r din e-postadress genom att \u00f6ppna l\u00e4nken nedan. L\u00e4nken g\u00e4ller i tre dygn.\n\n%s\n",
+			user.Email, h.link("/api/auth/verify", token)),
+	})
+}
+
+// setPassword hashes and stores a new password and tells the user about it.
+func (h Handler) setPassword(ctx context.Context, user storage.User, password string) error {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := h.Store.SetUserPassword(ctx, user.ID, string(hashed)); err != nil {
+		return err
+	}
+	h.sendMail(mail.Message{
+		To:      []string{user.Email},
+		Subject: "Ditt l\u00f6senord har \u00e4ndrats",
+		Body:    "Hej!\n\nL\u00f6senordet f\u00f6r ditt konto har just \u00e4ndrats. Var det inte du? Kontakta din administrat\u00f6r omedelbart.\n",
+	})
+	return nil
+}
+
+// sendMail delivers msg in the background so that slow mail servers never delay
+// the response or reveal whether an account exists.
+func (h Handler) sendMail(msg mail.Message) {
+	if h.Mail == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), deliveryTimeout)
+		defer cancel()
+		if err := h.Mail.Send(ctx, msg); err != nil {
+			log.Printf("send mail %q to %s: %v", msg.Subject, strings.Join(msg.To, ", "), err)
+		}
+	}()
+}
+
+// link builds an absolute URL to path on the public site with the token attached.
+func (h Handler) link(path, token string) string {
+	return strings.TrimRight(h.PublicURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// verifyState changes once the address is verified, which makes a
+// verification token single-use.
+func verifyState(user storage.User) string {
+	return user.Email + "|" + strconv.FormatBool(user.EmailVerified)
+}
@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"k2MarketingAi/internal/ratelimit"
+)
+
+// maxPeekBytes bounds how much of a request body the rate limiter reads to
+// find the email address. Auth requests are far smaller.
+const maxPeekBytes = 64 << 10
+
+// RateLimits configures Middleware.Throttle and Middleware.GuardLogin. A nil
+// Limiter turns both off.
+type RateLimits struct {
+	Limiter *ratelimit.Limiter
+	// IP applies per client address to every request that changes something.
+	IP ratelimit.Rule
+	// Email applies per address to requests that name one, such as login,
+	// registration and password reset.
+	Email ratelimit.Rule
+	// Lockout locks an account after failed logins in a row, counting both
+	// wrong passwords and wrong two-factor codes.
+	Lockout ratelimit.Lockout
+}
+
+// peekedRequest holds the fields the rate limiter looks for in auth requests.
+type peekedRequest struct {
+	Email     string `json:"email"`
+	Challenge string `json:"challenge"`
+}
+
+// Throttle applies the per-IP and per-email buckets to POST, PUT, PATCH and
+// DELETE requests and answers 429 with Retry-After once one runs dry. Errors
+// from the limiter are logged and the request goes through, so a database
+// hiccup does not lock everybody out.
+func (m Middleware) Throttle(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limiter := m.Limits.Limiter
+		if limiter == nil || r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if wait := m.take(r.Context(), "ip|"+clientIP(r), m.Limits.IP); wait > 0 {
+			tooManyRequests(w, wait, "f\u00f6r m\u00e5nga f\u00f6rs\u00f6k fr\u00e5n din adress")
+			return
+		}
+		if email := normalizeEmail(peekRequest(r).Email); email != "" {
+			if wait := m.take(r.Context(), "email|"+email, m.Limits.Email); wait > 0 {
+				tooManyRequests(w, wait, "f\u00f6r m\u00e5nga f\u00f6rs\u00f6k f\u00f6r den h\u00e4r e-postadressen")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GuardLogin wraps the login steps. A locked account is turned away before
+// the password or code is checked. Afterwards a 401 counts as a failure and a
+// started session clears the count, so a correct password alone does not
+// reset the failures of the two-factor step that follows.
+func (m Middleware) GuardLogin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limiter := m.Limits.Limiter
+		if limiter == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		account := m.loginAccount(r)
+		if account == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key := "login|" + account
+		locked, err := limiter.LockedFor(r.Context(), key)
+		if err != nil {
+			log.Printf("rate limit %s: %v", key, err)
+		}
+		if locked > 0 {
+			tooManyRequests(w, locked, "kontot \u00e4r tillf\u00e4lligt sp\u00e4rrat efter f\u00f6r m\u00e5nga misslyckade inloggningar")
+			return
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		switch {
+		case recorder.status == http.StatusUnauthorized:
+			locked, err := limiter.Fail(context.WithoutCancel(r.Context()), key, m.Limits.Lockout)
+			if err != nil {
+				log.Printf("rate limit %s: %v", key, err)
+			} else if locked > 0 {
+				log.Printf("auth: locked %s for %s after repeated failed logins", account, locked)
+			}
+		case recorder.status < 300 && m.startedSession(w.Header()):
+			if err := limiter.Reset(context.WithoutCancel(r.Context()), key); err != nil {
+				log.Printf("rate limit %s: %v", key, err)
+			}
+		}
+	})
+}
+
+func (m Middleware) take(ctx context.Context, key string, rule ratelimit.Rule) time.Duration {
+	wait, err := m.Limits.Limiter.Allow(ctx, key, rule)
+	if err != nil {
+		log.Printf("rate limit %s: %v", key, err)
+		return 0
+	}
+	return wait
+}
+
+// loginAccount names the account a login step is for: the email address of a
+// password login, or the owner of the challenge in the two-factor step.
+func (m Middleware) loginAccount(r *http.Request) string {
+	peeked := peekRequest(r)
+	if email := normalizeEmail(peeked.Email); email != "" {
+		return email
+	}
+	if peeked.Challenge == "" {
+		return ""
+	}
+	claims, err := m.Sessions.ParseToken(PurposeTwoFactor, peeked.Challenge)
+	if err != nil {
+		return ""
+	}
+	user, err := m.Store.GetUserByID(r.Context(), claims.UserID)
+	if err != nil {
+		return ""
+	}
+	return user.Email
+}
+
+// startedSession reports whether the response sets a session cookie.
+func (m Middleware) startedSession(header http.Header) bool {
+	for _, line := range header.Values("Set-Cookie") {
+		cookie, err := http.ParseSetCookie(line)
+		if err == nil && cookie.Name == m.Sessions.cookieName() && cookie.Value != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// peekRequest decodes the JSON body without consuming it for the handler.
+func peekRequest(r *http.Request) peekedRequest {
+	var peeked peekedRequest
+	if r.Body == nil {
+		return peeked
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPeekBytes))
+	r.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(body), r.Body), Closer: r.Body}
+	if err == nil {
+		_ = json.Unmarshal(body, &peeked)
+	}
+	return peeked
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// statusRecorder remembers the status code written by the handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func tooManyRequests(w http.ResponseWriter, wait time.Duration, reason string) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	http.Error(w, fmt.Sprintf("%s, f\u00f6rs\u00f6k igen om %s", reason, waitText(seconds)), http.StatusTooManyRequests)
+}
+
+func waitText(seconds int) string {
+	switch {
+	case seconds < 2:
+		return "en sekund"
+	case seconds < 120:
+		return fmt.Sprintf("%d sekunder", seconds)
+	default:
+		return fmt.Sprintf("%d minuter", (seconds+59)/60)
+	}
+}
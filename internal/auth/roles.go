@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"net/http"
+	"slices"
+
+	"k2MarketingAi/internal/storage"
+)
+
+// Permission names an action guarded by the role matrix.
+type Permission string
+
+const (
+	// PermCreateListings allows creating listings.
+	PermCreateListings Permission = "listings:create"
+	// PermEditSections allows generating, rewriting, editing and restoring sections and images.
+	PermEditSections Permission = "listings:edit"
+	// PermDeleteListings allows moving listings to the trash and restoring them.
+	PermDeleteListings Permission = "listings:delete"
+	// PermOfficeListings widens listing access from the user's own listings to
+	// every listing in the user's organization.
+	PermOfficeListings Permission = "listings:office"
+	// PermManageStyleProfiles allows creating and editing the office's style profiles.
+	PermManageStyleProfiles Permission = "style_profiles:manage"
+	// PermManageUsers allows approving, moving and deleting accounts.
+	PermManageUsers Permission = "users:manage"
+)
+
+// rolePermissions is the permission matrix. Every role may read the listings
+// in its scope and the office's style profiles.
+var rolePermissions = map[storage.Role][]Permission{
+	storage.RoleAdmin: {
+		PermCreateListings, PermEditSections, PermDeleteListings, PermOfficeListings,
+		PermManageStyleProfiles, PermManageUsers,
+	},
+	storage.RoleOfficeManager: {
+		PermCreateListings, PermEditSections, PermDeleteListings, PermOfficeListings,
+		PermManageStyleProfiles,
+	},
+	storage.RoleBroker: {
+		PermCreateListings, PermEditSections, PermDeleteListings,
+	},
+	storage.RoleAssistant: {
+		PermEditSections, PermOfficeListings,
+	},
+}
+
+// Can reports whether the role grants the permission.
+func Can(role storage.Role, perm Permission) bool {
+	return slices.Contains(rolePermissions[role], perm)
+}
+
+// RolesWith lists the roles that grant the permission, for use with RequireRole.
+func RolesWith(perm Permission) []storage.Role {
+	var roles []storage.Role
+	for _, role := range storage.Roles {
+		if Can(role, perm) {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// RequireRole returns 401 without a signed-in user and 403 unless the user has one of the roles.
+func RequireRole(roles ...storage.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := UserFromContext(r.Context())
+			if !ok {
+				http.Error(w, "inloggning kr\u00e4vs", http.StatusUnauthorized)
+				return
+			}
+			if !slices.Contains(roles, user.Role) {
+				http.Error(w, "beh\u00f6righet saknas", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"k2MarketingAi/internal/audit"
+	"k2MarketingAi/internal/storage"
+)
+
+// ErrInvalidSession is returned for cookies that are forged, revoked or expired.
+var ErrInvalidSession = errors.New("invalid or expired session")
+
+const (
+	// touchInterval limits how often a session's last-seen time is written, so
+	// a burst of requests costs one update.
+	touchInterval = time.Minute
+	// maxUserAgentLength keeps odd clients from filling the sessions table.
+	maxUserAgentLength = 512
+)
+
+type sessionResponse struct {
+	storage.Session
+	Current bool `json:"current"`
+}
+
+// Start persists a new session for the user, remembering the client's user
+// agent and IP address.
+func (sm SessionManager) Start(ctx context.Context, userID string, r *http.Request) (storage.Session, error) {
+	if len(sm.Secret) == 0 {
+		return storage.Session{}, errors.New("session secret missing")
+	}
+	userAgent := r.UserAgent()
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+	now := time.Now()
+	return sm.Store.CreateSession(ctx, storage.Session{
+		UserID:     userID,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(sm.sessionDuration()),
+		UserAgent:  userAgent,
+		IP:         clientIP(r),
+	})
+}
+
+// Resume loads the session behind a cookie and slides its expiry forward.
+// refresh reports that the cookie should be written again, either because the
+// expiry moved or because it was signed with the previous secret.
+func (sm SessionManager) Resume(ctx context.Context, token string) (session storage.Session, refresh bool, err error) {
+	claims, err := sm.Parse(token)
+	if err != nil {
+		return storage.Session{}, false, ErrInvalidSession
+	}
+	session, err = sm.Store.GetSession(ctx, claims.SessionID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return storage.Session{}, false, ErrInvalidSession
+	}
+	if err != nil {
+		return storage.Session{}, false, err
+	}
+	now := time.Now()
+	if !session.ExpiresAt.After(now) {
+		return storage.Session{}, false, ErrInvalidSession
+	}
+
+	if now.Sub(session.LastSeenAt) >= touchInterval {
+		expires := now.Add(sm.sessionDuration())
+		if err := sm.Store.TouchSession(ctx, session.ID, now, expires); err != nil {
+			// The session stays usable; it just does not slide this time.
+			log.Printf("touch session: %v", err)
+		} else {
+			session.LastSeenAt = now
+			session.ExpiresAt = expires
+			refresh = true
+		}
+	}
+	return session, refresh || claims.Stale, nil
+}
+
+// ListSessions handles GET /api/auth/sessions.
+func (h Handler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	user, _ := UserFromContext(r.Context())
+	current, _ := SessionFromContext(r.Context())
+
+	sessions, err := h.Store.ListSessions(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, "kunde inte h\u00e4mta sessioner", http.StatusInternalServerError)
+		return
+	}
+	response := make([]sessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, sessionResponse{Session: session, Current: session.ID == current.ID})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = jsonResponse(w, http.StatusOK, response)
+}
+
+// RevokeSession handles DELETE /api/auth/sessions/{id}. Revoking the current
+// session also clears the cookie.
+func (h Handler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	user, _ := UserFromContext(r.Context())
+	current, _ := SessionFromContext(r.Context())
+	id := chi.URLParam(r, "id")
+
+	if err := h.Store.DeleteSession(r.Context(), user.ID, id); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "sessionen hittades inte", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "kunde inte avsluta sessionen", http.StatusInternalServerError)
+		return
+	}
+	audit.Record(r, h.Store, audit.Entry{Actor: user, Action: "session.revoke", EntityType: audit.EntitySession, EntityID: id})
+	if id == current.ID {
+		cookie := h.Sessions.expiredCookie()
+		http.SetCookie(w, &cookie)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeSessions handles DELETE /api/auth/sessions, logging the user out
+// everywhere. With ?keep_current=true the session making the request survives.
+func (h Handler) RevokeSessions(w http.ResponseWriter, r *http.Request) {
+	user, _ := UserFromContext(r.Context())
+	current, _ := SessionFromContext(r.Context())
+	keep := ""
+	if r.URL.Query().Get("keep_current") == "true" {
+		keep = current.ID
+	}
+
+	revoked, err := h.Store.DeleteUserSessions(r.Context(), user.ID, keep)
+	if err != nil {
+		http.Error(w, "kunde inte avsluta sessionerna", http.StatusInternalServerError)
+		return
+	}
+	h.auditUser(r, user, "session.revoke_all", user, nil, map[string]any{"revoked": revoked, "kept_current": keep != ""})
+	if keep == "" {
+		cookie := h.Sessions.expiredCookie()
+		http.SetCookie(w, &cookie)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = jsonResponse(w, http.StatusOK, map[string]any{"revoked": revoked})
+}
+
+// revokeSessions signs the user out everywhere except keepID. Failures are
+// logged because the change that triggered it has already been saved.
+func (h Handler) revokeSessions(ctx context.Context, userID, keepID string) {
+	if _, err := h.Store.DeleteUserSessions(ctx, userID, keepID); err != nil {
+		log.Printf("revoke sessions for %s: %v", userID, err)
+	}
+}
+
+// SessionPurger removes expired sessions from the store.
+type SessionPurger struct {
+	Store    storage.Store
+	Interval time.Duration
+}
+
+// Run purges once immediately and then on every Interval until ctx is cancelled.
+func (p SessionPurger) Run(ctx context.Context) {
+	if p.Store == nil {
+		return
+	}
+	interval := p.Interval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if n, err := p.Store.PurgeExpiredSessions(ctx, time.Now()); err != nil {
+			log.Printf("session purge failed: %v", err)
+		} else if n > 0 {
+			log.Printf("session purge: removed %d session(s)", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// clientIP returns the request's remote address without the port. The server
+// has already applied X-Forwarded-For when the request came from a trusted proxy.
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Token purposes. A token is only accepted for the purpose it was issued for.
+const (
+	PurposePasswordReset = "reset"
+	PurposeVerifyEmail   = "verify"
+	// PurposeTwoFactor is the challenge between the password and the code.
+	PurposeTwoFactor = "2fa"
+)
+
+// ErrInvalidToken is returned for tokens that are malformed, expired, issued for
+// another purpose or signed with another secret.
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// TokenClaims captures a decoded single-purpose token.
+type TokenClaims struct {
+	Purpose   string
+	UserID    string
+	ExpiresAt time.Time
+	state     string
+}
+
+// IssueToken signs a token for userID that expires after ttl. state is a
+// snapshot of what the token is used to change, such as the password hash for
+// resets. Once that changes the token no longer matches, which makes it
+// single-use without storing it anywhere.
+func (sm SessionManager) IssueToken(purpose, userID, state string, ttl time.Duration) (string, error) {
+	if len(sm.Secret) == 0 {
+		return "", errors.New("session secret missing")
+	}
+	expires := time.Now().Add(ttl)
+	payload := fmt.Sprintf("%s|%s|%d|%s", purpose, userID, expires.Unix(), sm.stateFingerprint(state))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(sm.sign(payload)), nil
+}
+
+// ParseToken validates the signature, purpose and expiry of a token. Callers
+// must also check TokenMatches against the user's current state.
+func (sm SessionManager) ParseToken(purpose, token string) (TokenClaims, error) {
+	dot := strings.LastIndex(token, ".")
+	if dot < 0 {
+		return TokenClaims{}, ErrInvalidToken
+	}
+	payload := token[:dot]
+	sig, err := base64.RawURLEncoding.DecodeString(token[dot+1:])
+	if err != nil {
+		return TokenClaims{}, ErrInvalidToken
+	}
+	if _, ok := sm.verify(payload, sig); !ok {
+		return TokenClaims{}, ErrInvalidToken
+	}
+
+	parts := strings.Split(payload, "|")
+	if len(parts) != 4 || parts[0] != purpose {
+		return TokenClaims{}, ErrInvalidToken
+	}
+	expUnix, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return TokenClaims{}, ErrInvalidToken
+	}
+	claims := TokenClaims{Purpose: parts[0], UserID: parts[1], ExpiresAt: time.Unix(expUnix, 0), state: parts[3]}
+	if !claims.ExpiresAt.After(time.Now()) {
+		return TokenClaims{}, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// TokenMatches reports whether state is the same as when the token was issued.
+func (sm SessionManager) TokenMatches(claims TokenClaims, state string) bool {
+	for _, key := range sm.keys() {
+		if hmac.Equal([]byte(claims.state), []byte(fingerprintWith(key, state))) {
+			return true
+		}
+	}
+	return false
+}
+
+func (sm SessionManager) stateFingerprint(state string) string {
+	return fingerprintWith(sm.Secret, state)
+}
+
+func fingerprintWith(key []byte, state string) string {
+	return base64.RawURLEncoding.EncodeToString(signWith(key, "state|"+state)[:16])
+}
@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"k2MarketingAi/internal/storage"
+)
+
+// rfc6238Secret is the SHA-1 seed of RFC 6238, Appendix B.
+var rfc6238Secret = []byte("12345678901234567890")
+
+func TestTOTPRFC6238Vectors(t *testing.T) {
+	cases := []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, tc := range cases {
+		t.Run(fmt.Sprint(tc.unix), func(t *testing.T) {
+			step := tc.unix / totpPeriod
+			if got := fmt.Sprintf("%08d", totpValue(rfc6238Secret, step)%100_000_000); got != tc.want {
+				t.Fatalf("8 digits: got %s, want %s", got, tc.want)
+			}
+			// Six digits are the low digits of the same value.
+			if got, want := totpCode(rfc6238Secret, step), tc.want[2:]; got != want {
+				t.Fatalf("6 digits: got %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+// twoFactorFixture enables two-factor authentication for a user in an
+// in-memory store with the RFC 6238 secret.
+func twoFactorFixture(t *testing.T) (Handler, *storage.TwoFactor) {
+	t.Helper()
+	store := storage.NewInMemoryStore()
+	user, err := store.CreateUser(context.Background(), storage.User{
+		Email:     "anna@example.se",
+		OrgID:     storage.DefaultOrganizationID,
+		Role:      storage.RoleBroker,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	tf := storage.TwoFactor{
+		UserID:    user.ID,
+		Secret:    totpEncoding.EncodeToString(rfc6238Secret),
+		Enabled:   true,
+		CreatedAt: time.Now(),
+	}
+	if err := store.SaveTwoFactor(context.Background(), tf); err != nil {
+		t.Fatalf("save two-factor: %v", err)
+	}
+	return Handler{Store: store}, &tf
+}
+
+func TestVerifyTOTPSkew(t *testing.T) {
+	// Halfway through a step, so the result does not hinge on a boundary.
+	at := time.Unix(1111111111/totpPeriod*totpPeriod+15, 0)
+	step := at.Unix() / totpPeriod
+	cases := []struct {
+		offset int64
+		want   bool
+	}{
+		{-2, false},
+		{-totpSkew, true},
+		{0, true},
+		{totpSkew, true},
+		{2, false},
+	}
+	for _, tc := range cases {
+		t.Run(fmt.Sprint(tc.offset), func(t *testing.T) {
+			h, tf := twoFactorFixture(t)
+			code := totpCode(rfc6238Secret, step+tc.offset)
+			ok, err := h.verifyTOTPAt(context.Background(), tf, code, at)
+			if err != nil {
+				t.Fatalf("verify: %v", err)
+			}
+			if ok != tc.want {
+				t.Fatalf("code from step %+d: got %v, want %v", tc.offset, ok, tc.want)
+			}
+			if ok && tf.LastStep != step+tc.offset {
+				t.Fatalf("last step: got %d, want %d", tf.LastStep, step+tc.offset)
+			}
+		})
+	}
+}
+
+func TestVerifyTOTPFormat(t *testing.T) {
+	h, tf := twoFactorFixture(t)
+	at := time.Unix(1234567890, 0)
+	code := totpCode(rfc6238Secret, at.Unix()/totpPeriod)
+	for _, input := range []string{"", code[:5], code + "0", "abcdef"} {
+		if ok, err := h.verifyTOTPAt(context.Background(), tf, input, at); ok || err != nil {
+			t.Fatalf("code %q: got %v, %v", input, ok, err)
+		}
+	}
+	spaced := " " + code[:3] + " " + code[3:] + " "
+	if ok, err := h.verifyTOTPAt(context.Background(), tf, spaced, at); !ok || err != nil {
+		t.Fatalf("code %q: got %v, %v", spaced, ok, err)
+	}
+}
+
+func TestVerifyTOTPReplay(t *testing.T) {
+	ctx := context.Background()
+	at := time.Unix(2000000000, 0)
+	step := at.Unix() / totpPeriod
+
+	h, tf := twoFactorFixture(t)
+	code := totpCode(rfc6238Secret, step)
+	if ok, err := h.verifyTOTPAt(ctx, tf, code, at); !ok || err != nil {
+		t.Fatalf("first use: got %v, %v", ok, err)
+	}
+	if ok, err := h.verifyTOTPAt(ctx, tf, code, at); ok || err != nil {
+		t.Fatalf("same code again: got %v, %v", ok, err)
+	}
+	// Still inside the skew window, but the step has been claimed.
+	if ok, err := h.verifyTOTPAt(ctx, tf, code, at.Add(totpPeriod*time.Second)); ok || err != nil {
+		t.Fatalf("same code next step: got %v, %v", ok, err)
+	}
+	// An older step than the one claimed is refused as well.
+	earlier := totpCode(rfc6238Secret, step-1)
+	if ok, err := h.verifyTOTPAt(ctx, tf, earlier, at); ok || err != nil {
+		t.Fatalf("earlier code: got %v, %v", ok, err)
+	}
+	next := totpCode(rfc6238Secret, step+1)
+	if ok, err := h.verifyTOTPAt(ctx, tf, next, at); !ok || err != nil {
+		t.Fatalf("next code: got %v, %v", ok, err)
+	}
+
+	// ClaimTwoFactorStep is what stops a code being used twice, also by two
+	// requests that both passed the comparison.
+	if ok, err := h.Store.ClaimTwoFactorStep(ctx, tf.UserID, step+1); ok || err != nil {
+		t.Fatalf("claim used step: got %v, %v", ok, err)
+	}
+	if ok, err := h.Store.ClaimTwoFactorStep(ctx, tf.UserID, step+2); !ok || err != nil {
+		t.Fatalf("claim new step: got %v, %v", ok, err)
+	}
+}
@@ -4,16 +4,25 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"time"
 )
 
 // Config holds runtime configuration values loaded from config.json.
 type Config struct {
 	Port        string        `json:"port"`
+	PublicURL   string        `json:"public_url"`
 	DatabaseURL string        `json:"database_url"`
 	Media       MediaConfig   `json:"media"`
 	Geodata     GeodataConfig `json:"geodata"`
 	AI          AIConfig      `json:"ai"`
 	Auth        AuthConfig    `json:"auth"`
+	Trash       TrashConfig   `json:"trash"`
+	Mail        MailConfig    `json:"mail"`
+	Notify      NotifyConfig  `json:"notify"`
+
+	// TrustedProxies lists the IPs or CIDR ranges of reverse proxies whose
+	// X-Forwarded-For and X-Real-IP headers are believed.
+	TrustedProxies []string `json:"trusted_proxies"`
 }
 
 // MediaConfig describes S3/media related configuration.
@@ -37,9 +46,13 @@ type GeodataConfig struct {
 
 // AIConfig selects which LLM provider to use.
 type AIConfig struct {
-	Provider string       `json:"provider"`
-	Gemini   GeminiConfig `json:"gemini"`
-	Imagen   ImagenConfig `json:"imagen"`
+	Provider string        `json:"provider"`
+	Gemini   GeminiConfig  `json:"gemini"`
+	OpenAI   OpenAIConfig  `json:"openai"`
+	Ollama   OllamaConfig  `json:"ollama"`
+	Imagen   ImagenConfig  `json:"imagen"`
+	Pricing  PricingConfig `json:"pricing"`
+	Quotas   QuotaConfig   `json:"quotas"`
 }
 
 // GeminiConfig holds Google Generative Language credentials.
@@ -51,6 +64,31 @@ type GeminiConfig struct {
 	TimeoutSeconds     int    `json:"timeout_seconds"`
 	ServiceAccount     string `json:"service_account"`
 	ServiceAccountJSON string `json:"service_account_json"`
+	// AnnualReportModel reads annual reports. Other providers use their own
+	// configured model for them.
+	AnnualReportModel string `json:"annual_report_model"`
+}
+
+// OpenAIConfig points at an OpenAI-compatible chat completions endpoint
+// (OpenAI, Azure OpenAI, vLLM, LM Studio, llama.cpp server).
+type OpenAIConfig struct {
+	BaseURL        string `json:"base_url"`
+	APIKey         string `json:"api_key"`
+	Model          string `json:"model"`
+	APIVersion     string `json:"api_version"`
+	TimeoutSeconds int    `json:"timeout_seconds"`
+}
+
+// OllamaConfig points at a local Ollama server.
+type OllamaConfig struct {
+	BaseURL        string `json:"base_url"`
+	Model          string `json:"model"`
+	PullMissing    bool   `json:"pull_missing"`
+	TimeoutSeconds int    `json:"timeout_seconds"`
+
+	// PullModels are further models pull_missing may download, for style
+	// profiles with a custom model.
+	PullModels []string `json:"pull_models"`
 }
 
 // ImagenConfig holds Vertex AI Imagen settings.
@@ -63,12 +101,124 @@ type ImagenConfig struct {
 	ServiceAccountJSON string `json:"service_account_json"`
 }
 
+// PricingConfig prices model calls for the usage report. Models maps a
+// model name, or a prefix of one, to its price.
+type PricingConfig struct {
+	Currency string                `json:"currency"`
+	Models   map[string]ModelPrice `json:"models"`
+}
+
+// ModelPrice is what a model charges per million tokens and per request.
+type ModelPrice struct {
+	InputPerMillion  float64 `json:"input_per_million"`
+	OutputPerMillion float64 `json:"output_per_million"`
+	PerRequest       float64 `json:"per_request"`
+}
+
+// QuotaConfig caps model usage per calendar month (UTC). Organization and
+// User apply to every organization and user; Organizations and Users, keyed
+// by id, replace them for single ones. A zero limit means unlimited.
+type QuotaConfig struct {
+	Organization  Budget            `json:"organization"`
+	User          Budget            `json:"user"`
+	Organizations map[string]Budget `json:"organizations"`
+	Users         map[string]Budget `json:"users"`
+	// SoftLimitPercent of a budget triggers a warning event. Defaults to 80.
+	SoftLimitPercent int `json:"soft_limit_percent"`
+}
+
+// Budget is a monthly allowance of tokens (prompt and completion together)
+// and model calls.
+type Budget struct {
+	Tokens   int64 `json:"tokens"`
+	Requests int64 `json:"requests"`
+}
+
 // AuthConfig controls session management and security.
 type AuthConfig struct {
-	Secret       string `json:"secret"`
-	CookieName   string `json:"cookie_name"`
-	SessionHours int    `json:"session_hours"`
-	SecureCookie bool   `json:"secure_cookie"`
+	Secret string `json:"secret"`
+	// PreviousSecret is accepted alongside Secret until PreviousSecretUntil
+	// (RFC 3339) while a new secret is rolled out. Without an end time it is
+	// accepted for SessionHours after startup.
+	PreviousSecret      string    `json:"previous_secret"`
+	PreviousSecretUntil time.Time `json:"previous_secret_until"`
+	CookieName          string    `json:"cookie_name"`
+	// SessionHours is the idle timeout; every request extends the session.
+	SessionHours int  `json:"session_hours"`
+	SecureCookie bool `json:"secure_cookie"`
+	// OIDC lists the single sign-on providers offered next to password login.
+	OIDC []OIDCConfig `json:"oidc"`
+	// RateLimit throttles login, registration and the other auth endpoints.
+	RateLimit RateLimitConfig `json:"rate_limit"`
+}
+
+// RateLimitConfig controls brute-force protection on /api/auth.
+type RateLimitConfig struct {
+	Disabled bool `json:"disabled"`
+	// Store is "memory" (default) or "database". The database shares the
+	// counters between several API instances behind a load balancer.
+	Store string `json:"store"`
+	// IPPerMinute caps requests from one client address.
+	IPPerMinute int `json:"ip_per_minute"`
+	// EmailPerHour caps requests naming one email address.
+	EmailPerHour int `json:"email_per_hour"`
+	// LockoutAfter failed logins in a row lock the account for LockoutMinutes,
+	// doubling with every further failure up to MaxLockoutMinutes.
+	LockoutAfter      int `json:"lockout_after"`
+	LockoutMinutes    int `json:"lockout_minutes"`
+	MaxLockoutMinutes int `json:"max_lockout_minutes"`
+}
+
+// OIDCConfig describes an OpenID Connect provider such as Google Workspace or
+// Azure AD. The redirect URL to register with the provider is
+// <public_url>/api/auth/oidc/<name>/callback.
+type OIDCConfig struct {
+	// Name identifies the provider in URLs, e.g. "google".
+	Name         string   `json:"name"`
+	DisplayName  string   `json:"display_name"`
+	Issuer       string   `json:"issuer"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	Scopes       []string `json:"scopes"`
+	// Domains maps email domains to the organization that users without an
+	// account are created in. Addresses in other domains need an existing account.
+	Domains map[string]string `json:"domains"`
+	// Role is given to users created on first sign-in. Defaults to broker.
+	Role string `json:"role"`
+	// TrustEmail accepts the email claim even without email_verified, for
+	// providers such as Azure AD that leave it out.
+	TrustEmail bool `json:"trust_email"`
+	// MFATrusted lets accounts with two-factor authentication, or in
+	// organizations that require it, sign in through a provider that enforces
+	// MFA itself. Without it such accounts must use their password and code.
+	MFATrusted bool `json:"mfa_trusted"`
+}
+
+// TrashConfig controls how long deleted listings are kept before they are
+// purged. Purging is opt-in: without a positive RetentionDays trashed listings
+// are kept forever.
+type TrashConfig struct {
+	RetentionDays        int `json:"retention_days"`
+	PurgeIntervalMinutes int `json:"purge_interval_minutes"`
+}
+
+// MailConfig describes the outgoing SMTP relay. Without a host, mail is
+// written as .eml files to Dir, or to the log when Dir is empty too.
+type MailConfig struct {
+	SMTPHost string `json:"smtp_host"`
+	SMTPPort int    `json:"smtp_port"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	From     string `json:"from"`
+	Dir      string `json:"dir"`
+}
+
+// NotifyConfig controls how administrators hear about new registrations.
+type NotifyConfig struct {
+	// Channel is "mail", "webhook" or "none".
+	Channel     string   `json:"channel"`
+	AdminEmails []string `json:"admin_emails"`
+	WebhookURL  string   `json:"webhook_url"`
 }
 
 // Load reads configuration from the provided JSON file.
@@ -91,6 +241,9 @@ func applyDefaults(cfg *Config) {
 	if cfg.Port == "" {
 		cfg.Port = "8080"
 	}
+	if cfg.PublicURL == "" {
+		cfg.PublicURL = "http://localhost:" + cfg.Port
+	}
 	if cfg.Geodata.CacheTTLMinutes == 0 {
 		cfg.Geodata.CacheTTLMinutes = 30
 	}
@@ -100,12 +253,37 @@ func applyDefaults(cfg *Config) {
 	if cfg.AI.Gemini.VisionModel == "" {
 		cfg.AI.Gemini.VisionModel = "gemini-1.5-flash-001"
 	}
+	if cfg.AI.Gemini.AnnualReportModel == "" {
+		cfg.AI.Gemini.AnnualReportModel = "gemini-3-pro-preview"
+	}
 	if cfg.AI.Gemini.ImageModel == "" {
 		cfg.AI.Gemini.ImageModel = "gemini-2.5-flash-image"
 	}
 	if cfg.AI.Gemini.TimeoutSeconds <= 0 {
 		cfg.AI.Gemini.TimeoutSeconds = 60
 	}
+	if cfg.AI.OpenAI.BaseURL == "" {
+		cfg.AI.OpenAI.BaseURL = "https://api.openai.com/v1"
+	}
+	if cfg.AI.OpenAI.TimeoutSeconds <= 0 {
+		cfg.AI.OpenAI.TimeoutSeconds = 60
+	}
+	if cfg.AI.Ollama.BaseURL == "" {
+		cfg.AI.Ollama.BaseURL = "http://localhost:11434"
+	}
+	if cfg.AI.Ollama.Model == "" {
+		cfg.AI.Ollama.Model = "llama3.1:8b"
+	}
+	// Local models on CPU need far longer than hosted APIs.
+	if cfg.AI.Ollama.TimeoutSeconds <= 0 {
+		cfg.AI.Ollama.TimeoutSeconds = 300
+	}
+	if cfg.AI.Pricing.Currency == "" {
+		cfg.AI.Pricing.Currency = "USD"
+	}
+	if cfg.AI.Quotas.SoftLimitPercent <= 0 {
+		cfg.AI.Quotas.SoftLimitPercent = 80
+	}
 	if cfg.AI.Imagen.Location == "" {
 		cfg.AI.Imagen.Location = "us-central1"
 	}
@@ -121,4 +299,39 @@ func applyDefaults(cfg *Config) {
 	if cfg.Auth.Secret == "" {
 		cfg.Auth.Secret = "dev-secret-change-me"
 	}
+	if cfg.Auth.RateLimit.Store == "" {
+		cfg.Auth.RateLimit.Store = "memory"
+	}
+	if cfg.Auth.RateLimit.IPPerMinute <= 0 {
+		cfg.Auth.RateLimit.IPPerMinute = 30
+	}
+	if cfg.Auth.RateLimit.EmailPerHour <= 0 {
+		cfg.Auth.RateLimit.EmailPerHour = 20
+	}
+	if cfg.Auth.RateLimit.LockoutAfter <= 0 {
+		cfg.Auth.RateLimit.LockoutAfter = 5
+	}
+	if cfg.Auth.RateLimit.LockoutMinutes <= 0 {
+		cfg.Auth.RateLimit.LockoutMinutes = 1
+	}
+	if cfg.Auth.RateLimit.MaxLockoutMinutes <= 0 {
+		cfg.Auth.RateLimit.MaxLockoutMinutes = 60
+	}
+	for i := range cfg.Auth.OIDC {
+		if cfg.Auth.OIDC[i].DisplayName == "" {
+			cfg.Auth.OIDC[i].DisplayName = cfg.Auth.OIDC[i].Name
+		}
+	}
+	if cfg.Trash.PurgeIntervalMinutes <= 0 {
+		cfg.Trash.PurgeIntervalMinutes = 60
+	}
+	if cfg.Mail.SMTPPort == 0 {
+		cfg.Mail.SMTPPort = 587
+	}
+	if cfg.Mail.From == "" {
+		cfg.Mail.From = "no-reply@localhost"
+	}
+	if cfg.Notify.Channel == "" {
+		cfg.Notify.Channel = "mail"
+	}
 }
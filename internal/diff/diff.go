@@ -0,0 +1,323 @@
+// Package diff compares listing texts at word or sentence granularity and
+// returns spans the frontend can render as insertions and deletions.
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+
+	"k2MarketingAi/internal/storage"
+)
+
+// Op says what happened to a span of text going from the old to the new version.
+type Op string
+
+const (
+	Equal  Op = "equal"
+	Insert Op = "insert"
+	Delete Op = "delete"
+)
+
+// Span is a run of text with a single operation. Concatenating the equal and
+// delete spans gives the old text; equal and insert spans give the new text.
+type Span struct {
+	Op   Op     `json:"op"`
+	Text string `json:"text"`
+}
+
+// Granularity selects the unit texts are compared in.
+type Granularity string
+
+const (
+	Word     Granularity = "word"
+	Sentence Granularity = "sentence"
+)
+
+// maxEdits bounds the edit script search. Texts that differ by more tokens than
+// this are reported as a full replacement instead of a fine-grained diff.
+const maxEdits = 1500
+
+// ParseGranularity validates a user supplied granularity, defaulting to words.
+func ParseGranularity(value string) (Granularity, error) {
+	switch Granularity(strings.ToLower(strings.TrimSpace(value))) {
+	case "", Word:
+		return Word, nil
+	case Sentence:
+		return Sentence, nil
+	default:
+		return "", fmt.Errorf("unknown granularity %q", value)
+	}
+}
+
+// SectionDiff is the difference between two versions of a section.
+type SectionDiff struct {
+	Title         []Span `json:"title"`
+	Content       []Span `json:"content"`
+	InsertedWords int    `json:"inserted_words"`
+	DeletedWords  int    `json:"deleted_words"`
+}
+
+// Sections compares title and content of two section versions.
+func Sections(from, to storage.SectionVersion, granularity Granularity) SectionDiff {
+	result := SectionDiff{
+		Title:   Text(from.Title, to.Title, Word),
+		Content: Text(from.Content, to.Content, granularity),
+	}
+	for _, span := range result.Content {
+		switch span.Op {
+		case Insert:
+			result.InsertedWords += countWords(span.Text)
+		case Delete:
+			result.DeletedWords += countWords(span.Text)
+		}
+	}
+	return result
+}
+
+// Text diffs two strings. Both are NFC-normalised first so that å, ä and ö
+// typed as a letter plus combining mark compare equal to the precomposed form.
+func Text(from, to string, granularity Granularity) []Span {
+	from, to = norm.NFC.String(from), norm.NFC.String(to)
+	tokenize := words
+	if granularity == Sentence {
+		tokenize = sentences
+	}
+	return build(compare(tokenize(from), tokenize(to)))
+}
+
+// words splits text into words, whitespace runs and single punctuation runes.
+// Combining marks stay attached to their letter.
+func words(text string) []string {
+	var tokens []string
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		j := i + 1
+		switch {
+		case isWordRune(runes[i]):
+			for j < len(runes) && isWordRune(runes[j]) {
+				j++
+			}
+		case unicode.IsSpace(runes[i]):
+			for j < len(runes) && unicode.IsSpace(runes[j]) {
+				j++
+			}
+		}
+		tokens = append(tokens, string(runes[i:j]))
+		i = j
+	}
+	return tokens
+}
+
+// sentences splits text into sentences and the whitespace between them. A
+// sentence ends at . ! ? or … followed by whitespace, or at a line break.
+func sentences(text string) []string {
+	var tokens []string
+	runes := []rune(text)
+	start := 0
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r == '\n' || (strings.ContainsRune(".!?…", r) && i+1 < len(runes) && unicode.IsSpace(runes[i+1])) {
+			end := i + 1
+			if r == '\n' {
+				end = i
+			}
+			if end > start {
+				tokens = append(tokens, string(runes[start:end]))
+			}
+			ws := end
+			for ws < len(runes) && unicode.IsSpace(runes[ws]) {
+				ws++
+			}
+			if ws > end {
+				tokens = append(tokens, string(runes[end:ws]))
+			}
+			start = ws
+			i = ws - 1
+		}
+	}
+	if start < len(runes) {
+		tokens = append(tokens, string(runes[start:]))
+	}
+	return tokens
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+}
+
+func countWords(text string) int {
+	n := 0
+	for _, token := range words(text) {
+		if isWordRune([]rune(token)[0]) {
+			n++
+		}
+	}
+	return n
+}
+
+type tokenOp struct {
+	op   Op
+	text string
+}
+
+// compare runs Myers' O(ND) algorithm over the tokens after stripping the
+// common prefix and suffix.
+func compare(a, b []string) []tokenOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]tokenOp, 0, len(a)+len(b))
+	for _, t := range a[:prefix] {
+		ops = append(ops, tokenOp{Equal, t})
+	}
+	ops = append(ops, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, t := range a[len(a)-suffix:] {
+		ops = append(ops, tokenOp{Equal, t})
+	}
+	return ops
+}
+
+func myers(a, b []string) []tokenOp {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return replace(a, b)
+	}
+
+	limit := min(n+m, maxEdits)
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	// trace[d] holds the furthest x reached on diagonals -d..d before step d.
+	var trace [][]int
+	for d := 0; d <= limit; d++ {
+		snapshot := make([]int, 2*d+1)
+		copy(snapshot, v[offset-d:offset+d+1])
+		trace = append(trace, snapshot)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace)
+			}
+		}
+	}
+	return replace(a, b)
+}
+
+func backtrack(a, b []string, trace [][]int) []tokenOp {
+	x, y := len(a), len(b)
+	var reversed []tokenOp
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		at := func(k int) int { return v[k+d] }
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := 0
+		if d > 0 {
+			prevX = at(prevK)
+		}
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			reversed = append(reversed, tokenOp{Equal, a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				reversed = append(reversed, tokenOp{Insert, b[y-1]})
+			} else {
+				reversed = append(reversed, tokenOp{Delete, a[x-1]})
+			}
+			x, y = prevX, prevY
+		}
+	}
+
+	ops := make([]tokenOp, len(reversed))
+	for i, op := range reversed {
+		ops[len(reversed)-1-i] = op
+	}
+	return ops
+}
+
+func replace(a, b []string) []tokenOp {
+	ops := make([]tokenOp, 0, len(a)+len(b))
+	for _, t := range a {
+		ops = append(ops, tokenOp{Delete, t})
+	}
+	for _, t := range b {
+		ops = append(ops, tokenOp{Insert, t})
+	}
+	return ops
+}
+
+// build merges token operations into spans. Whitespace that merely separates
+// two changed words is folded into the change, so "röd bil" -> "blå båt" reads
+// as one replacement instead of alternating fragments.
+func build(ops []tokenOp) []Span {
+	var (
+		spans    []Span
+		del, ins strings.Builder
+	)
+	flush := func() {
+		if del.Len() > 0 {
+			spans = append(spans, Span{Op: Delete, Text: del.String()})
+			del.Reset()
+		}
+		if ins.Len() > 0 {
+			spans = append(spans, Span{Op: Insert, Text: ins.String()})
+			ins.Reset()
+		}
+	}
+
+	for i, op := range ops {
+		switch op.op {
+		case Delete:
+			del.WriteString(op.text)
+		case Insert:
+			ins.WriteString(op.text)
+		default:
+			pending := del.Len() > 0 || ins.Len() > 0
+			nextChanged := i+1 < len(ops) && ops[i+1].op != Equal
+			if pending && nextChanged && strings.TrimSpace(op.text) == "" {
+				del.WriteString(op.text)
+				ins.WriteString(op.text)
+				continue
+			}
+			flush()
+			if n := len(spans); n > 0 && spans[n-1].Op == Equal {
+				spans[n-1].Text += op.text
+			} else {
+				spans = append(spans, Span{Op: Equal, Text: op.text})
+			}
+		}
+	}
+	flush()
+	if spans == nil {
+		spans = []Span{}
+	}
+	return spans
+}
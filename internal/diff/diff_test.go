@@ -0,0 +1,159 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/unicode/norm"
+
+	"k2MarketingAi/internal/storage"
+)
+
+// sides rebuilds the old and new text from spans.
+func sides(spans []Span) (from, to string) {
+	var a, b strings.Builder
+	for _, span := range spans {
+		switch span.Op {
+		case Equal:
+			a.WriteString(span.Text)
+			b.WriteString(span.Text)
+		case Delete:
+			a.WriteString(span.Text)
+		case Insert:
+			b.WriteString(span.Text)
+		default:
+			panic(fmt.Sprintf("unknown op %q", span.Op))
+		}
+	}
+	return a.String(), b.String()
+}
+
+func TestTextRoundTrip(t *testing.T) {
+	cases := []struct {
+		name     string
+		from, to string
+	}{
+		{"empty", "", ""},
+		{"insert into empty", "", "Ljus trea med balkong."},
+		{"delete all", "Ljus trea med balkong.", ""},
+		{"identical", "Öppen planlösning.", "Öppen planlösning."},
+		{"word swap", "En röd bil står på gården.", "En blå båt ligger vid bryggan."},
+		{"swedish letters", "Kök med ö och å, ä i hallen.", "Kök med å och ä, ö i hallen och badrum."},
+		{"mixed whitespace", "Stor\tbalkong  mot\nsöder.\r\nNära  skog.", "Stor balkong\tmot  väster.\nNära\t\tskog och sjö."},
+		{"decomposed letters", "Ga\u030ardsha\u0308ll med sto\u0308rre fo\u0308nster.", "Gårdshäll med större fönster och altan."},
+		{"sentences", "Lägenheten är ljus. Köket är nytt! Badrummet? Renoverat…  Klart.", "Lägenheten är ljus. Köket är från 2019! Badrummet? Renoverat…\n\nKlart."},
+		{"line breaks", "Första raden\nAndra raden\n\nTredje", "Första raden\nÄndrad rad\n\nTredje\n"},
+	}
+	for _, granularity := range []Granularity{Word, Sentence} {
+		for _, tc := range cases {
+			t.Run(string(granularity)+"/"+tc.name, func(t *testing.T) {
+				spans := Text(tc.from, tc.to, granularity)
+				from, to := sides(spans)
+				if want := norm.NFC.String(tc.from); from != want {
+					t.Fatalf("old text: got %q, want %q", from, want)
+				}
+				if want := norm.NFC.String(tc.to); to != want {
+					t.Fatalf("new text: got %q, want %q", to, want)
+				}
+				for i, span := range spans {
+					if span.Text == "" {
+						t.Fatalf("span %d is empty", i)
+					}
+					if i > 0 && span.Op == Equal && spans[i-1].Op == Equal {
+						t.Fatalf("spans %d and %d are both equal", i-1, i)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestTextWordSpans(t *testing.T) {
+	got := Text("En röd bil står här.", "En blå båt står här.", Word)
+	want := []Span{
+		{Equal, "En "},
+		{Delete, "röd bil"},
+		{Insert, "blå båt"},
+		{Equal, " står här."},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestTextSentenceSpans(t *testing.T) {
+	got := Text("Ljust kök. Stort bad. Fin utsikt.", "Ljust kök. Litet bad. Fin utsikt.", Sentence)
+	want := []Span{
+		{Equal, "Ljust kök. "},
+		{Delete, "Stort bad."},
+		{Insert, "Litet bad."},
+		{Equal, " Fin utsikt."},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+// interleaved returns n words that each differ between the two texts,
+// separated by an equal word, so every word is its own edit.
+func interleaved(n int) (from, to string) {
+	var a, b []string
+	for i := range n {
+		a = append(a, fmt.Sprintf("gammal%d", i))
+		b = append(b, fmt.Sprintf("ny%d", i))
+	}
+	return strings.Join(a, " och "), strings.Join(b, " och ")
+}
+
+func TestTextMaxEdits(t *testing.T) {
+	t.Run("below limit", func(t *testing.T) {
+		// Each differing word is one delete and one insert.
+		from, to := interleaved(maxEdits/2 - 10)
+		spans := Text(from, to, Word)
+		if gotFrom, gotTo := sides(spans); gotFrom != from || gotTo != to {
+			t.Fatal("spans do not rebuild the texts")
+		}
+		equal := 0
+		for _, span := range spans {
+			if span.Op == Equal {
+				equal++
+			}
+		}
+		if equal == 0 {
+			t.Fatal("expected a fine-grained diff with equal spans")
+		}
+	})
+	t.Run("above limit", func(t *testing.T) {
+		from, to := interleaved(maxEdits/2 + 10)
+		spans := Text(from, to, Word)
+		want := []Span{{Delete, from}, {Insert, to}}
+		if len(spans) != len(want) || spans[0] != want[0] || spans[1] != want[1] {
+			t.Fatalf("expected a full replacement, got %d spans", len(spans))
+		}
+	})
+	t.Run("common prefix and suffix kept", func(t *testing.T) {
+		from, to := interleaved(maxEdits/2 + 10)
+		spans := Text("Början. "+from+" Slut.", "Början. "+to+" Slut.", Word)
+		if len(spans) != 4 || spans[0] != (Span{Equal, "Början. "}) || spans[3] != (Span{Equal, " Slut."}) {
+			t.Fatalf("expected equal prefix and suffix around a replacement, got %d spans", len(spans))
+		}
+		if spans[1] != (Span{Delete, from}) || spans[2] != (Span{Insert, to}) {
+			t.Fatal("expected the middle to be replaced in full")
+		}
+	})
+}
+
+func TestSectionsCountsWords(t *testing.T) {
+	got := Sections(
+		storage.SectionVersion{Title: "Om bostaden", Content: "Tre rum och kök."},
+		storage.SectionVersion{Title: "Om bostaden", Content: "Fyra rum, kök och två balkonger."},
+		Word,
+	)
+	if len(got.Title) != 1 || got.Title[0].Op != Equal {
+		t.Fatalf("unchanged title: got %v", got.Title)
+	}
+	if got.InsertedWords == 0 || got.DeletedWords == 0 {
+		t.Fatalf("got %d inserted and %d deleted words", got.InsertedWords, got.DeletedWords)
+	}
+}
@@ -2,15 +2,33 @@ package events
 
 import (
 	"sync"
+	"time"
 
 	"k2MarketingAi/internal/storage"
 )
 
-// Event describes a status update for a listing.
+// Event describes a status update for a listing, or a quota warning for an
+// organization or user.
 type Event struct {
 	ListingID string         `json:"listing_id"`
 	OwnerID   string         `json:"owner_id,omitempty"`
+	OrgID     string         `json:"org_id,omitempty"`
 	Status    storage.Status `json:"status"`
+	// Quota is set instead of a status when a monthly model budget runs low.
+	Quota *QuotaWarning `json:"quota,omitempty"`
+}
+
+// QuotaWarning reports how much of a monthly model budget an organization or
+// user has used. Level is "warning" past the soft limit and "exceeded" once
+// the budget is spent. Limits of zero are unlimited.
+type QuotaWarning struct {
+	Level         string    `json:"level"`
+	Scope         string    `json:"scope"`
+	TokensUsed    int64     `json:"tokens_used"`
+	TokensLimit   int64     `json:"tokens_limit,omitempty"`
+	RequestsUsed  int64     `json:"requests_used"`
+	RequestsLimit int64     `json:"requests_limit,omitempty"`
+	ResetsAt      time.Time `json:"resets_at"`
 }
 
 // Broker manages SSE subscribers.
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"unicode/utf8"
 
 	"k2MarketingAi/internal/geodata"
 	"k2MarketingAi/internal/llm"
@@ -17,6 +18,9 @@ import (
 type Generator interface {
 	Generate(ctx context.Context, listing storage.Listing) (Result, error)
 	Rewrite(ctx context.Context, listing storage.Listing, section storage.Section, instruction string) (storage.Section, error)
+	// StreamRewrite works like Rewrite but passes the new content to onDelta
+	// piece by piece while it is being written.
+	StreamRewrite(ctx context.Context, listing storage.Listing, section storage.Section, instruction string, onDelta func(string)) (storage.Section, error)
 }
 
 // Result represents the output from a generator run.
@@ -96,6 +100,15 @@ func (heuristicGenerator) Rewrite(_ context.Context, listing storage.Listing, se
 	return section, nil
 }
 
+// StreamRewrite has nothing to stream; the rewritten content arrives at once.
+func (g heuristicGenerator) StreamRewrite(ctx context.Context, listing storage.Listing, section storage.Section, instruction string, onDelta func(string)) (storage.Section, error) {
+	updated, err := g.Rewrite(ctx, listing, section, instruction)
+	if err == nil && onDelta != nil {
+		onDelta(updated.Content)
+	}
+	return updated, err
+}
+
 func buildIntroCopy(listing storage.Listing) string {
 	var b strings.Builder
 	fmt.Fprintf(&b, "Välkommen till %s – där %s möter en trivsam planlösning.", listing.Address, strings.ToLower(defaultTone(listing.Tone)))
@@ -273,7 +286,7 @@ func (g *llmGenerator) Generate(ctx context.Context, listing storage.Listing) (R
 		return Result{}, err
 	}
 
-	content, err := g.client.ChatCompletion(ctx, []llm.ChatMessage{
+	completion, err := g.client.ChatCompletion(llm.WithJSONResponse(ctx), []llm.ChatMessage{
 		{Role: "system", Content: systemPrompt},
 		{Role: "user", Content: userPrompt},
 	}, 0.4)
@@ -281,7 +294,7 @@ func (g *llmGenerator) Generate(ctx context.Context, listing storage.Listing) (R
 		return Result{}, err
 	}
 
-	sections, parseErr := parseSections(content)
+	sections, parseErr := parseSections(completion.Text)
 	if parseErr != nil {
 		return Result{}, parseErr
 	}
@@ -291,7 +304,9 @@ func (g *llmGenerator) Generate(ctx context.Context, listing storage.Listing) (R
 	}, nil
 }
 
-func (g *llmGenerator) Rewrite(ctx context.Context, listing storage.Listing, section storage.Section, instruction string) (storage.Section, error) {
+// rewriteMessages builds the prompt asking for a new version of section as
+// JSON {"title","content"}.
+func rewriteMessages(listing storage.Listing, section storage.Section, instruction string) []llm.ChatMessage {
 	guideline := sectionGuidelines[strings.ToLower(section.Slug)]
 	if guideline == "" {
 		guideline = "Håll samma struktur men förbättra språk och tydlighet."
@@ -324,21 +339,104 @@ Ta bort självklarheter (ingen text om att "umgås i vardagsrum", "laga mat i k
 %s`, userPrompt, profile)
 	}
 
-	content, err := g.client.ChatCompletion(ctx, []llm.ChatMessage{
+	return []llm.ChatMessage{
 		{Role: "system", Content: systemPrompt},
 		{Role: "user", Content: userPrompt},
-	}, 0.5)
+	}
+}
+
+func (g *llmGenerator) Rewrite(ctx context.Context, listing storage.Listing, section storage.Section, instruction string) (storage.Section, error) {
+	completion, err := g.client.ChatCompletion(llm.WithJSONResponse(ctx), rewriteMessages(listing, section, instruction), 0.5)
 	if err != nil {
 		return storage.Section{}, err
 	}
 
-	updated, parseErr := parseSection(content, section)
+	updated, parseErr := parseSection(completion.Text, section)
 	if parseErr != nil {
 		return storage.Section{}, parseErr
 	}
 	return updated, nil
 }
 
+// StreamRewrite streams the rewrite from clients that support it. The model
+// writes JSON, so onDelta gets the growing "content" value rather than the
+// raw reply; the returned section is parsed from the complete answer.
+func (g *llmGenerator) StreamRewrite(ctx context.Context, listing storage.Listing, section storage.Section, instruction string, onDelta func(string)) (storage.Section, error) {
+	var raw strings.Builder
+	emitted := 0
+	completion, err := llm.Stream(llm.WithJSONResponse(ctx), g.client, rewriteMessages(listing, section, instruction), 0.5, func(delta string) {
+		raw.WriteString(delta)
+		text := partialJSONString(raw.String(), "content")
+		if len(text) > emitted && onDelta != nil {
+			onDelta(text[emitted:])
+			emitted = len(text)
+		}
+	})
+	if err != nil {
+		return storage.Section{}, err
+	}
+
+	updated, parseErr := parseSection(completion.Text, section)
+	if parseErr != nil {
+		return storage.Section{}, parseErr
+	}
+	return updated, nil
+}
+
+// partialJSONString decodes the string value of key from a JSON object that
+// may still be cut off, returning what has arrived so far. A trailing
+// incomplete escape sequence or UTF-8 character is held back until the rest
+// arrives.
+func partialJSONString(raw, key string) string {
+	rest, ok := afterJSONKey(raw, key)
+	if !ok {
+		return ""
+	}
+	rest, ok = strings.CutPrefix(strings.TrimLeft(rest, " \t\r\n"), `"`)
+	if !ok {
+		return ""
+	}
+	end := len(rest)
+	for i := 0; i < len(rest); i++ {
+		if rest[i] == '\\' {
+			i++
+			continue
+		}
+		if rest[i] == '"' {
+			end = i
+			break
+		}
+	}
+	value := rest[:end]
+	for cut := len(value); cut >= 0 && cut >= len(value)-6; cut-- {
+		if !utf8.ValidString(value[:cut]) {
+			continue
+		}
+		var text string
+		if err := json.Unmarshal([]byte(`"`+value[:cut]+`"`), &text); err == nil {
+			return text
+		}
+	}
+	return ""
+}
+
+// afterJSONKey returns what follows the colon after key. A string value that
+// happens to equal key is skipped, since it is followed by a comma or brace;
+// quotes inside values are escaped and never match.
+func afterJSONKey(raw, key string) (string, bool) {
+	quoted := `"` + key + `"`
+	for {
+		idx := strings.Index(raw, quoted)
+		if idx < 0 {
+			return "", false
+		}
+		raw = strings.TrimLeft(raw[idx+len(quoted):], " \t\r\n")
+		if rest, ok := strings.CutPrefix(raw, ":"); ok {
+			return rest, true
+		}
+	}
+}
+
 func composeFullCopyFromSections(sections []storage.Section) string {
 	var parts []string
 	for _, section := range sections {
@@ -447,7 +545,7 @@ Data:
 %s
 `, desiredWordCount(listing.Details.Meta), listing.Details.Meta.Tone, string(payload))
 
-	content, err := g.client.ChatCompletion(ctx, []llm.ChatMessage{
+	completion, err := g.client.ChatCompletion(ctx, []llm.ChatMessage{
 		{Role: "system", Content: systemPrompt},
 		{Role: "user", Content: userPrompt},
 	}, 0.9)
@@ -455,7 +553,7 @@ Data:
 		return "", err
 	}
 
-	return sanitizeContent(strings.TrimSpace(content)), nil
+	return sanitizeContent(strings.TrimSpace(completion.Text)), nil
 }
 
 func desiredWordCount(meta storage.MetaInfo) int {
@@ -0,0 +1,74 @@
+package generation
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPartialJSONString(t *testing.T) {
+	cases := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{"no key yet", `{"title":"Kök"`, ""},
+		{"key without colon", `{"content"`, ""},
+		{"key without value", `{"content": `, ""},
+		{"not a string", `{"content": 12`, ""},
+		{"empty value", `{"content":"`, ""},
+		{"partial value", `{"content": "Ljus trea`, "Ljus trea"},
+		{"complete value", `{"content":"Ljus trea","title":"Om"}`, "Ljus trea"},
+		{"whitespace around colon", "{\"content\"\n\t :\n \"Ljus", "Ljus"},
+
+		{"cut after backslash", `{"content":"Hallen \`, "Hallen "},
+		{"cut mid quote escape", `{"content":"Ett \"`, `Ett "`},
+		{"escaped quote", `{"content":"Ett \"ljust\" kök`, `Ett "ljust" kök`},
+		{"escaped backslash before quote", `{"content":"C:\\","title":"x"}`, `C:\`},
+		{"newline escape", `{"content":"Rad ett\nRad två`, "Rad ett\nRad två"},
+		{"cut after \\u", `{"content":"Kök \u`, "Kök "},
+		{"cut after \\u0", `{"content":"Kök \u0`, "Kök "},
+		{"cut after \\u00", `{"content":"Kök \u00`, "Kök "},
+		{"cut after \\u00e", `{"content":"Kök \u00e`, "Kök "},
+		{"complete \\u escape", `{"content":"K\u00f6k`, "Kök"},
+		{"surrogate pair", `{"content":"Sol \ud83c\udf1e`, "Sol 🌞"},
+
+		{"cut mid two-byte rune", `{"content":"Kö` + "\xc3", "Kö"},
+		{"cut mid four-byte rune", `{"content":"Sol ` + "\xf0\x9f\x8c", "Sol "},
+		{"cut mid rune after escape", `{"content":"\"Å\" och ` + "\xc3", `"Å" och `},
+
+		{"key as value of another key", `{"title":"content","content":"Text`, "Text"},
+		{"key as value only", `{"title":"content"`, ""},
+		{"key as value in array", `{"tags":["content", "kök"],"content":"Text"}`, "Text"},
+		{"key quoted inside value", `{"title":"Om \"content\": nej","content":"Rätt`, "Rätt"},
+		{"key as text inside value", `{"instruction":"skriv om content: kort","content":"Kort`, "Kort"},
+		{"similar key", `{"contents":"Fel","content":"Rätt"}`, "Rätt"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := partialJSONString(tc.raw, "content"); got != tc.want {
+				t.Fatalf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestPartialJSONStringGrows feeds a reply one byte at a time, as a stream
+// could cut it anywhere, and checks that the decoded value only ever grows.
+func TestPartialJSONStringGrows(t *testing.T) {
+	raw := `{"title":"content","content":"Ljus \"trea\" på Södermalm\nmed k\u00f6k och 🌞!","title2":"x"}`
+	want := "Ljus \"trea\" på Södermalm\nmed kök och 🌞!"
+	previous := ""
+	for i := range len(raw) + 1 {
+		got := partialJSONString(raw[:i], "content")
+		if !strings.HasPrefix(got, previous) {
+			t.Fatalf("after %d bytes: %q does not extend %q", i, got, previous)
+		}
+		if !strings.HasPrefix(want, got) {
+			t.Fatalf("after %d bytes: %q is not a prefix of the value", i, got)
+		}
+		previous = got
+	}
+	if previous != want {
+		t.Fatalf("final value %q, want %q", previous, want)
+	}
+}
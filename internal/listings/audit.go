@@ -0,0 +1,57 @@
+package listings
+
+import (
+	"net/http"
+	"time"
+
+	"k2MarketingAi/internal/audit"
+	"k2MarketingAi/internal/storage"
+)
+
+// auditedListing is the part of a listing the audit log compares: what the
+// broker entered or edited, with sections keyed by slug so a change shows up
+// as for example "sections.avgift.content". Derived copy, pipeline state and
+// insights are left out.
+type auditedListing struct {
+	storage.Listing
+	Sections map[string]storage.Section `json:"sections,omitempty"`
+}
+
+func auditSnapshot(listing storage.Listing) any {
+	sections := make(map[string]storage.Section, len(listing.Sections))
+	for _, section := range listing.Sections {
+		slug := normalizeSlug(section.Slug)
+		section.Slug = ""
+		sections[slug] = section
+	}
+	listing.ID = ""
+	listing.FullCopy = ""
+	listing.History = nil
+	listing.Status = storage.Status{}
+	listing.Insights = storage.Insights{}
+	listing.StyleProfile = nil
+	listing.Version = 0
+	return auditedListing{Listing: listing, Sections: sections}
+}
+
+// styleProfileSnapshot drops the timestamp that changes on every save.
+func styleProfileSnapshot(profile storage.StyleProfile) any {
+	profile.ID = ""
+	profile.UpdatedAt = time.Time{}
+	return profile
+}
+
+// auditListing records a change to listing. before and after are snapshots
+// from auditSnapshot, or nil for a listing that is created or deleted; take
+// the before snapshot ahead of editing the listing in place.
+func (h Handler) auditListing(r *http.Request, user storage.User, action string, listing storage.Listing, before, after any) {
+	audit.Record(r, h.Store, audit.Entry{
+		Actor:      user,
+		Action:     action,
+		EntityType: audit.EntityListing,
+		EntityID:   listing.ID,
+		OrgID:      listing.OrgID,
+		Before:     before,
+		After:      after,
+	})
+}
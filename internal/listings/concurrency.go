@@ -0,0 +1,91 @@
+package listings
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"k2MarketingAi/internal/storage"
+)
+
+// listingETag renders the listing version as a strong entity tag.
+func listingETag(listing storage.Listing) string {
+	return fmt.Sprintf(`"%d"`, listing.Version)
+}
+
+// ifMatchVersion parses If-Match into a listing version. It returns ok=false when
+// the header is absent or "*", in which case the caller falls back to the version
+// it just read.
+func ifMatchVersion(r *http.Request) (version int, ok bool, err error) {
+	raw := strings.TrimSpace(r.Header.Get("If-Match"))
+	if raw == "" || raw == "*" {
+		return 0, false, nil
+	}
+	// Only one tag makes sense for a single listing; use the first.
+	tag := strings.TrimSpace(strings.Split(raw, ",")[0])
+	tag = strings.TrimPrefix(tag, "W/")
+	tag = strings.Trim(tag, `"`)
+	version, err = strconv.Atoi(tag)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid If-Match header")
+	}
+	return version, true, nil
+}
+
+// checkPrecondition validates If-Match against the freshly loaded listing. On a
+// mismatch it writes 412 with the current listing so the client can merge.
+func checkPrecondition(w http.ResponseWriter, r *http.Request, listing storage.Listing) bool {
+	version, ok, err := ifMatchVersion(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	if ok && version != listing.Version {
+		writeConflict(w, http.StatusPreconditionFailed, listing)
+		return false
+	}
+	return true
+}
+
+// writeConflict reports a lost update together with the listing as it is now stored.
+func writeConflict(w http.ResponseWriter, status int, current storage.Listing) {
+	w.Header().Set("Content-Type", "application/json")
+	if current.ID != "" {
+		w.Header().Set("ETag", listingETag(current))
+	}
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"error":   "objektet har ändrats av någon annan, ladda om och försök igen",
+		"current": current,
+	})
+}
+
+// writeListing encodes a listing response with its ETag.
+func writeListing(w http.ResponseWriter, status int, listing storage.Listing) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", listingETag(listing))
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(listing)
+}
+
+// writeUpdateError maps store errors from a versioned update onto HTTP responses.
+func (h Handler) writeUpdateError(w http.ResponseWriter, r *http.Request, id string, err error) {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		http.NotFound(w, r)
+	case errors.Is(err, storage.ErrConflict):
+		current, getErr := h.Store.GetListing(r.Context(), id)
+		if getErr != nil {
+			current = storage.Listing{}
+		} else {
+			hydrateDetailsFromLegacy(&current)
+			h.attachStyleProfiles(r.Context(), []*storage.Listing{&current})
+		}
+		writeConflict(w, http.StatusConflict, current)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -11,13 +11,16 @@ import (
 	"net/http"
 	"os"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 	pdf "github.com/ledongthuc/pdf"
 
+	"k2MarketingAi/internal/audit"
 	"k2MarketingAi/internal/auth"
 	"k2MarketingAi/internal/events"
 	"k2MarketingAi/internal/generation"
@@ -25,6 +28,7 @@ import (
 	"k2MarketingAi/internal/llm"
 	"k2MarketingAi/internal/media"
 	"k2MarketingAi/internal/storage"
+	"k2MarketingAi/internal/usage"
 	"k2MarketingAi/internal/vision"
 )
 
@@ -41,6 +45,11 @@ type Handler struct {
 	Vision      vision.Analyzer
 	Events      *events.Broker
 	LLM         llm.Client
+	// AnnualReportModel is the model annual reports are read with. Empty uses
+	// the model LLM is configured with.
+	AnnualReportModel string
+	// TrashRetention is how long deleted listings stay restorable; zero means forever.
+	TrashRetention time.Duration
 }
 
 // CreateListingRequest describes inbound payload for creating a listing.
@@ -89,17 +98,53 @@ func currentUser(w http.ResponseWriter, r *http.Request) (storage.User, bool) {
 	return user, true
 }
 
-func (h Handler) fetchListingForUser(ctx context.Context, id string, userID string) (storage.Listing, error) {
+// currentUserWith is currentUser for actions that need a permission from the role matrix.
+func currentUserWith(w http.ResponseWriter, r *http.Request, perm auth.Permission) (storage.User, bool) {
+	user, ok := currentUser(w, r)
+	if !ok {
+		return storage.User{}, false
+	}
+	if !auth.Can(user.Role, perm) {
+		http.Error(w, "beh\u00f6righet saknas", http.StatusForbidden)
+		return storage.User{}, false
+	}
+	return user, true
+}
+
+// ownerScope is the owner filter for the user's listing queries: empty for
+// roles that see the whole office, otherwise the user's own id.
+func ownerScope(user storage.User) string {
+	if auth.Can(user.Role, auth.PermOfficeListings) {
+		return ""
+	}
+	return user.ID
+}
+
+// fetchListingForUser loads a listing the user may access. Listings outside the
+// user's scope are reported as not found rather than forbidden.
+func (h Handler) fetchListingForUser(ctx context.Context, id string, user storage.User) (storage.Listing, error) {
 	listing, err := h.Store.GetListing(ctx, id)
 	if err != nil {
 		return storage.Listing{}, err
 	}
-	if listing.OwnerID == "" || listing.OwnerID != userID {
+	if !visibleTo(listing, user) {
 		return storage.Listing{}, storage.ErrNotFound
 	}
 	return listing, nil
 }
 
+// visibleTo reports whether the listing is in the user's organization and, for
+// roles without office-wide access, owned by the user.
+func visibleTo(listing storage.Listing, user storage.User) bool {
+	if listing.OrgID != user.OrgID {
+		return false
+	}
+	if owner := ownerScope(user); owner != "" {
+		return listing.OwnerID == owner
+	}
+	return true
+}
+
 func logUploadEvent(format string, args ...interface{}) {
 	f, err := os.OpenFile("upload_debug.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
 	if err != nil {
@@ -139,28 +184,45 @@ func cleanLLMJSON(s string) string {
 	return strings.TrimSpace(trim)
 }
 
-func callLLMWithRetry(ctx context.Context, client llm.Client, msgs []llm.ChatMessage, temp float64) (string, error) {
+func callLLMWithRetry(ctx context.Context, client llm.Client, msgs []llm.ChatMessage, temp float64) (llm.Completion, error) {
 	const attempts = 3
 	const backoffFirst = 1200 * time.Millisecond
 	for i := 0; i < attempts; i++ {
-		reply, err := client.ChatCompletion(ctx, msgs, temp)
+		completion, err := client.ChatCompletion(ctx, msgs, temp)
 		if err == nil {
-			return reply, nil
+			return completion, nil
 		}
 		// If context is done, break early.
 		if ctx.Err() != nil {
-			return "", err
+			return llm.Completion{}, err
 		}
 		errMsg := strings.ToLower(err.Error())
 		if !(strings.Contains(errMsg, "503") || strings.Contains(errMsg, "overloaded")) {
-			return "", err
+			return llm.Completion{}, err
 		}
 		// Retry with simple backoff.
 		sleep := backoffFirst * time.Duration(i+1)
 		logAnnualEvent("llm overloaded, retry %d/%d after %v: %v", i+1, attempts, sleep, err)
 		time.Sleep(sleep)
 	}
-	return "", fmt.Errorf("llm overloaded after retries")
+	return llm.Completion{}, fmt.Errorf("llm overloaded after retries")
+}
+
+// annualReportContext routes annual reports to AnnualReportModel when one is
+// configured.
+func (h Handler) annualReportContext(ctx context.Context) context.Context {
+	if h.AnnualReportModel == "" {
+		return ctx
+	}
+	return llm.WithModel(ctx, h.AnnualReportModel)
+}
+
+// extractionModel names the model that answered, for AnnualReportSummary.
+func extractionModel(completion llm.Completion) string {
+	if completion.Usage.Model != "" {
+		return completion.Usage.Model
+	}
+	return "llm"
 }
 
 func applyAnnualMap(summary *AnnualReportSummary, m map[string]any) {
@@ -207,7 +269,7 @@ func (h Handler) Create(w http.ResponseWriter, r *http.Request) {
 		upload *uploadPayload
 		err    error
 	)
-	user, ok := currentUser(w, r)
+	user, ok := currentUserWith(w, r, auth.PermCreateListings)
 	if !ok {
 		return
 	}
@@ -251,6 +313,7 @@ func (h Handler) Create(w http.ResponseWriter, r *http.Request) {
 			ContentType: upload.contentType,
 			Body:        bytes.NewReader(upload.data),
 			Size:        int64(len(upload.data)),
+			Scope:       user.OrgID,
 		})
 		if err != nil {
 			status := http.StatusInternalServerError
@@ -275,7 +338,11 @@ func (h Handler) Create(w http.ResponseWriter, r *http.Request) {
 	}
 
 	listing := storage.Listing{
+		// The id is picked here rather than by the store so that model calls
+		// made while generating can be attributed to the listing.
+		ID:             uuid.NewString(),
 		OwnerID:        user.ID,
+		OrgID:          user.OrgID,
 		Address:        req.Address,
 		Neighborhood:   req.Neighborhood,
 		City:           req.City,
@@ -293,11 +360,16 @@ func (h Handler) Create(w http.ResponseWriter, r *http.Request) {
 		LivingArea:     req.LivingArea,
 		Rooms:          req.Rooms,
 		Sections:       buildSectionsFromInput(req, imageURL),
-		History:        storage.History{},
 		Insights:       storage.Insights{},
 		CreatedAt:      time.Now(),
 	}
 	listing.Details.Meta.StyleProfileID = strings.TrimSpace(req.StyleProfileID)
+	if styleID := listing.Details.Meta.StyleProfileID; styleID != "" {
+		if profile, err := h.Store.GetStyleProfile(r.Context(), styleID); err != nil || profile.OrgID != user.OrgID {
+			http.Error(w, "unknown style_profile_id", http.StatusBadRequest)
+			return
+		}
+	}
 	applyImagesToListing(&listing, req.Images)
 	hydrateDetailsFromLegacy(&listing)
 	h.attachStyleProfiles(r.Context(), []*storage.Listing{&listing})
@@ -312,7 +384,7 @@ func (h Handler) Create(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if h.Generator != nil {
-		genCtx := r.Context()
+		genCtx := usage.WithListing(r.Context(), listing.ID)
 		if listing.StyleProfile != nil && listing.StyleProfile.CustomModel != "" {
 			genCtx = llm.WithModel(genCtx, listing.StyleProfile.CustomModel)
 		}
@@ -327,7 +399,8 @@ func (h Handler) Create(w http.ResponseWriter, r *http.Request) {
 			listing.FullCopy = result.FullCopy
 		}
 	}
-	recordHistoryForAll(&listing, "generate", historyContext{
+	revisions := sectionRevisions(listing.Sections, "generate", historyContext{
+		AuthorID:       user.ID,
 		Tone:           listing.Tone,
 		TargetAudience: listing.TargetAudience,
 		Highlights:     listing.Highlights,
@@ -337,18 +410,17 @@ func (h Handler) Create(w http.ResponseWriter, r *http.Request) {
 	}
 	deriveStatus(&listing)
 
-	listing, err = h.Store.CreateListing(r.Context(), listing)
+	listing, err = h.Store.CreateListing(r.Context(), listing, revisions)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	h.auditListing(r, user, "listing.create", listing, nil, auditSnapshot(listing))
 	h.attachStyleProfiles(r.Context(), []*storage.Listing{&listing})
 	h.publishListing(listing)
 	go h.runPipeline(listing)
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	_ = json.NewEncoder(w).Encode(listing)
+	writeListing(w, http.StatusCreated, listing)
 }
 
 // List handles GET /api/listings.
@@ -357,21 +429,113 @@ func (h Handler) List(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
-	listings, err := h.Store.ListListingsByOwner(r.Context(), user.ID)
+	query, err := parseListQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query.OrgID = user.OrgID
+	query.OwnerID = ownerScope(user)
+
+	page, err := h.Store.QueryListings(r.Context(), query)
+	if err != nil {
+		if errors.Is(err, storage.ErrInvalidCursor) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if page.Listings == nil {
+		page.Listings = []storage.Listing{}
+	}
+
+	pointers := make([]*storage.Listing, len(page.Listings))
+	for i := range page.Listings {
+		hydrateDetailsFromLegacy(&page.Listings[i])
+		// section_history is only part of single-listing responses.
+		page.Listings[i].History = nil
+		pointers[i] = &page.Listings[i]
+	}
+	h.attachStyleProfiles(r.Context(), pointers)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(page)
+}
+
+// parseListQuery reads cursor, limit, sort and filter parameters for GET /api/listings.
+func parseListQuery(r *http.Request) (storage.ListListingsQuery, error) {
+	values := r.URL.Query()
+	query := storage.ListListingsQuery{
+		Cursor:         strings.TrimSpace(values.Get("cursor")),
+		City:           strings.TrimSpace(values.Get("city")),
+		PropertyType:   strings.TrimSpace(values.Get("property_type")),
+		Status:         strings.TrimSpace(values.Get("status")),
+		StyleProfileID: strings.TrimSpace(values.Get("style_profile_id")),
+	}
+	if raw := strings.TrimSpace(values.Get("limit")); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			return query, fmt.Errorf("invalid limit")
+		}
+		query.Limit = limit
+	}
+	sortBy, err := storage.ParseListingSort(values.Get("sort"))
+	if err != nil {
+		return query, err
+	}
+	query.Sort = sortBy
+	order, err := storage.ParseSortOrder(values.Get("order"))
+	if err != nil {
+		return query, err
+	}
+	query.Order = order
+	return query, nil
+}
+
+// Search handles GET /api/listings/search?q= and returns ranked hits with highlighted snippets.
+func (h Handler) Search(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	text := strings.TrimSpace(r.URL.Query().Get("q"))
+	if text == "" {
+		http.Error(w, "q is required", http.StatusBadRequest)
+		return
+	}
+	query := storage.SearchListingsQuery{OrgID: user.OrgID, OwnerID: ownerScope(user), Text: text}
+	if raw := strings.TrimSpace(r.URL.Query().Get("limit")); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		query.Limit = limit
+	}
+
+	results, err := h.Store.SearchListings(r.Context(), query)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if results == nil {
+		results = []storage.SearchResult{}
+	}
 
-	pointers := make([]*storage.Listing, len(listings))
-	for i := range listings {
-		hydrateDetailsFromLegacy(&listings[i])
-		pointers[i] = &listings[i]
+	pointers := make([]*storage.Listing, len(results))
+	for i := range results {
+		hydrateDetailsFromLegacy(&results[i].Listing)
+		results[i].Listing.History = nil
+		pointers[i] = &results[i].Listing
 	}
 	h.attachStyleProfiles(r.Context(), pointers)
 
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(listings)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"query":   text,
+		"results": results,
+	})
 }
 
 // Get returns a single listing by id.
@@ -386,7 +550,7 @@ func (h Handler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	listing, err := h.fetchListingForUser(r.Context(), id, user.ID)
+	listing, err := h.fetchListingForUser(r.Context(), id, user)
 	if err != nil {
 		if errors.Is(err, storage.ErrNotFound) {
 			http.NotFound(w, r)
@@ -398,21 +562,67 @@ func (h Handler) Get(w http.ResponseWriter, r *http.Request) {
 
 	hydrateDetailsFromLegacy(&listing)
 	h.attachStyleProfiles(r.Context(), []*storage.Listing{&listing})
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(listing)
+	writeListing(w, http.StatusOK, listing)
 }
 
 // RewriteSection accepts instructions and rewrites a section using the generator.
 func (h Handler) RewriteSection(w http.ResponseWriter, r *http.Request) {
-	user, ok := currentUser(w, r)
+	target, ok := h.loadRewriteTarget(w, r)
 	if !ok {
 		return
 	}
+
+	section := target.listing.Sections[target.idx]
+	fallbackUsed := false
+	if h.Generator != nil {
+		updated, genErr := h.Generator.Rewrite(target.generationContext(r.Context()), target.listing, section, target.instruction)
+		if genErr != nil {
+			log.Printf("rewrite failed: %v", genErr)
+			http.Error(w, fmt.Sprintf("text rewrite failed: %v", genErr), http.StatusBadGateway)
+			return
+		}
+		section = updated
+	} else if target.instruction != "" {
+		fallbackUsed = true
+		section.Content = generation.ApplyLocalRewrite(section.Content, target.instruction)
+	}
+
+	updated, err := h.saveRewrite(r, target, section, fallbackUsed)
+	if err != nil {
+		h.writeUpdateError(w, r, target.listing.ID, err)
+		return
+	}
+
+	if fallbackUsed {
+		w.Header().Set("X-Generator-Fallback", "1")
+	}
+	writeListing(w, http.StatusOK, updated)
+	h.publishListing(updated)
+}
+
+// rewriteTarget is a section picked for rewriting, with its listing loaded.
+type rewriteTarget struct {
+	user        storage.User
+	listing     storage.Listing
+	idx         int
+	instruction string
+	// before is the audit snapshot taken ahead of the rewrite.
+	before any
+}
+
+// loadRewriteTarget reads a rewrite request: it checks permissions, the
+// body and the If-Match precondition and finds the section. On failure the
+// response has been written.
+func (h Handler) loadRewriteTarget(w http.ResponseWriter, r *http.Request) (rewriteTarget, bool) {
+	user, ok := currentUserWith(w, r, auth.PermEditSections)
+	if !ok {
+		return rewriteTarget{}, false
+	}
 	id := chi.URLParam(r, "id")
 	slug := normalizeSlug(chi.URLParam(r, "slug"))
 	if id == "" || slug == "" {
 		http.Error(w, "id and slug are required", http.StatusBadRequest)
-		return
+		return rewriteTarget{}, false
 	}
 
 	var req struct {
@@ -420,52 +630,58 @@ func (h Handler) RewriteSection(w http.ResponseWriter, r *http.Request) {
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "invalid request body", http.StatusBadRequest)
-		return
+		return rewriteTarget{}, false
 	}
 
-	listing, err := h.fetchListingForUser(r.Context(), id, user.ID)
+	listing, err := h.fetchListingForUser(r.Context(), id, user)
 	if err != nil {
 		if errors.Is(err, storage.ErrNotFound) {
 			http.NotFound(w, r)
-			return
+			return rewriteTarget{}, false
 		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return rewriteTarget{}, false
+	}
+	if !checkPrecondition(w, r, listing) {
+		return rewriteTarget{}, false
 	}
 
 	h.attachStyleProfiles(r.Context(), []*storage.Listing{&listing})
 	idx := findSectionIndex(listing.Sections, slug)
 	if idx == -1 && slug == "main" && len(listing.Sections) > 0 {
 		idx = 0
-		slug = normalizeSlug(listing.Sections[idx].Slug)
 	}
 	if idx == -1 {
 		http.Error(w, "section not found", http.StatusNotFound)
-		return
+		return rewriteTarget{}, false
 	}
 
-	section := listing.Sections[idx]
-	fallbackUsed := false
-	if h.Generator != nil {
-		genCtx := r.Context()
-		if listing.StyleProfile != nil && listing.StyleProfile.CustomModel != "" {
-			genCtx = llm.WithModel(genCtx, listing.StyleProfile.CustomModel)
-		}
-		updated, genErr := h.Generator.Rewrite(genCtx, listing, section, req.Instruction)
-		if genErr != nil {
-			log.Printf("rewrite failed: %v", genErr)
-			http.Error(w, fmt.Sprintf("text rewrite failed: %v", genErr), http.StatusBadGateway)
-			return
-		}
-		section = updated
-	} else if strings.TrimSpace(req.Instruction) != "" {
-		fallbackUsed = true
-		section.Content = generation.ApplyLocalRewrite(section.Content, req.Instruction)
+	return rewriteTarget{
+		user:        user,
+		listing:     listing,
+		idx:         idx,
+		instruction: strings.TrimSpace(req.Instruction),
+		before:      auditSnapshot(listing),
+	}, true
+}
+
+// generationContext routes the rewrite to the style profile's custom model.
+func (t rewriteTarget) generationContext(ctx context.Context) context.Context {
+	if t.listing.StyleProfile != nil && t.listing.StyleProfile.CustomModel != "" {
+		return llm.WithModel(ctx, t.listing.StyleProfile.CustomModel)
 	}
+	return ctx
+}
 
-	listing.Sections[idx] = section
+// saveRewrite stores the rewritten section with a history entry and records
+// it in the audit log. The returned listing is ready to be sent.
+func (h Handler) saveRewrite(r *http.Request, target rewriteTarget, section storage.Section, fallbackUsed bool) (storage.Listing, error) {
+	listing := target.listing
+	listing.Sections = slices.Clone(listing.Sections)
+	listing.Sections[target.idx] = section
 	rewriteCtx := historyContext{
-		Instruction:    strings.TrimSpace(req.Instruction),
+		AuthorID:       target.user.ID,
+		Instruction:    target.instruction,
 		Tone:           listing.Tone,
 		TargetAudience: listing.TargetAudience,
 		Highlights:     listing.Highlights,
@@ -473,28 +689,23 @@ func (h Handler) RewriteSection(w http.ResponseWriter, r *http.Request) {
 	if fallbackUsed {
 		rewriteCtx.Notes = "lokal fallback rewriter"
 	}
-	addHistoryEntry(&listing, section, "rewrite", rewriteCtx)
+	revision := sectionRevision(section, "rewrite", rewriteCtx)
 	listing.FullCopy = composeFullCopy(listing.Sections)
-	deriveStatus(&listing)
-	updated, err := h.Store.UpdateListingSections(r.Context(), id, listing.Sections, listing.FullCopy, listing.History, listing.Status)
+	updated, err := h.Store.UpdateListingSections(r.Context(), listing.ID, listing.Version, listing.Sections, listing.FullCopy, []storage.Revision{revision})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return storage.Listing{}, err
 	}
 
-	if fallbackUsed {
-		w.Header().Set("X-Generator-Fallback", "1")
-	}
+	h.auditListing(r, target.user, "listing.section.rewrite", updated, target.before, auditSnapshot(updated))
+
 	hydrateDetailsFromLegacy(&updated)
 	h.attachStyleProfiles(r.Context(), []*storage.Listing{&updated})
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(updated)
-	h.publishListing(updated)
+	return updated, nil
 }
 
 // UpdateSection saves manual edits for a section.
 func (h Handler) UpdateSection(w http.ResponseWriter, r *http.Request) {
-	user, ok := currentUser(w, r)
+	user, ok := currentUserWith(w, r, auth.PermEditSections)
 	if !ok {
 		return
 	}
@@ -520,7 +731,7 @@ func (h Handler) UpdateSection(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	listing, err := h.fetchListingForUser(r.Context(), id, user.ID)
+	listing, err := h.fetchListingForUser(r.Context(), id, user)
 	if err != nil {
 		if errors.Is(err, storage.ErrNotFound) {
 			http.NotFound(w, r)
@@ -529,11 +740,16 @@ func (h Handler) UpdateSection(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if !checkPrecondition(w, r, listing) {
+		return
+	}
 
+	before := auditSnapshot(listing)
 	idx := findSectionIndex(listing.Sections, slug)
 	if idx == -1 && slug == "main" && len(listing.Sections) > 0 {
 		idx = 0
 	}
+	var revision storage.Revision
 	if idx == -1 {
 		newSection := storage.Section{
 			Slug:    slug,
@@ -541,7 +757,8 @@ func (h Handler) UpdateSection(w http.ResponseWriter, r *http.Request) {
 			Content: req.Content,
 		}
 		listing.Sections = append(listing.Sections, newSection)
-		addHistoryEntry(&listing, newSection, "manual", historyContext{
+		revision = sectionRevision(newSection, "manual", historyContext{
+			AuthorID:       user.ID,
 			Tone:           listing.Tone,
 			TargetAudience: listing.TargetAudience,
 			Highlights:     listing.Highlights,
@@ -551,7 +768,8 @@ func (h Handler) UpdateSection(w http.ResponseWriter, r *http.Request) {
 			listing.Sections[idx].Title = req.Title
 		}
 		listing.Sections[idx].Content = req.Content
-		addHistoryEntry(&listing, listing.Sections[idx], "manual", historyContext{
+		revision = sectionRevision(listing.Sections[idx], "manual", historyContext{
+			AuthorID:       user.ID,
 			Tone:           listing.Tone,
 			TargetAudience: listing.TargetAudience,
 			Highlights:     listing.Highlights,
@@ -560,23 +778,22 @@ func (h Handler) UpdateSection(w http.ResponseWriter, r *http.Request) {
 	}
 
 	listing.FullCopy = composeFullCopy(listing.Sections)
-	deriveStatus(&listing)
-	updated, err := h.Store.UpdateListingSections(r.Context(), id, listing.Sections, listing.FullCopy, listing.History, listing.Status)
+	updated, err := h.Store.UpdateListingSections(r.Context(), id, listing.Version, listing.Sections, listing.FullCopy, []storage.Revision{revision})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		h.writeUpdateError(w, r, id, err)
 		return
 	}
+	h.auditListing(r, user, "listing.section.update", updated, before, auditSnapshot(updated))
 
 	hydrateDetailsFromLegacy(&updated)
 	h.attachStyleProfiles(r.Context(), []*storage.Listing{&updated})
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(updated)
+	writeListing(w, http.StatusOK, updated)
 	h.publishListing(updated)
 }
 
 // DeleteSection removes a section by slug.
 func (h Handler) DeleteSection(w http.ResponseWriter, r *http.Request) {
-	user, ok := currentUser(w, r)
+	user, ok := currentUserWith(w, r, auth.PermEditSections)
 	if !ok {
 		return
 	}
@@ -587,7 +804,7 @@ func (h Handler) DeleteSection(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	listing, err := h.fetchListingForUser(r.Context(), id, user.ID)
+	listing, err := h.fetchListingForUser(r.Context(), id, user)
 	if err != nil {
 		if errors.Is(err, storage.ErrNotFound) {
 			http.NotFound(w, r)
@@ -596,6 +813,9 @@ func (h Handler) DeleteSection(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if !checkPrecondition(w, r, listing) {
+		return
+	}
 
 	idx := findSectionIndex(listing.Sections, slug)
 	if idx == -1 && slug == "main" && len(listing.Sections) > 0 {
@@ -606,7 +826,9 @@ func (h Handler) DeleteSection(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	addHistoryEntry(&listing, listing.Sections[idx], "delete", historyContext{
+	before := auditSnapshot(listing)
+	revision := sectionRevision(listing.Sections[idx], "delete", historyContext{
+		AuthorID:       user.ID,
 		Tone:           listing.Tone,
 		TargetAudience: listing.TargetAudience,
 		Highlights:     listing.Highlights,
@@ -615,18 +837,17 @@ func (h Handler) DeleteSection(w http.ResponseWriter, r *http.Request) {
 
 	listing.Sections = append(listing.Sections[:idx], listing.Sections[idx+1:]...)
 	listing.FullCopy = composeFullCopy(listing.Sections)
-	deriveStatus(&listing)
 
-	updated, err := h.Store.UpdateListingSections(r.Context(), id, listing.Sections, listing.FullCopy, listing.History, listing.Status)
+	updated, err := h.Store.UpdateListingSections(r.Context(), id, listing.Version, listing.Sections, listing.FullCopy, []storage.Revision{revision})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		h.writeUpdateError(w, r, id, err)
 		return
 	}
+	h.auditListing(r, user, "listing.section.delete", updated, before, auditSnapshot(updated))
 
 	hydrateDetailsFromLegacy(&updated)
 	h.attachStyleProfiles(r.Context(), []*storage.Listing{&updated})
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(updated)
+	writeListing(w, http.StatusOK, updated)
 	h.publishListing(updated)
 }
 
@@ -642,7 +863,7 @@ func (h Handler) ExportFullCopy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	listing, err := h.fetchListingForUser(r.Context(), id, user.ID)
+	listing, err := h.fetchListingForUser(r.Context(), id, user)
 	if err != nil {
 		if errors.Is(err, storage.ErrNotFound) {
 			http.NotFound(w, r)
@@ -674,9 +895,9 @@ func (h Handler) ExportFullCopy(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// DeleteListing removes an entire listing.
+// DeleteListing moves a listing to the trash.
 func (h Handler) DeleteListing(w http.ResponseWriter, r *http.Request) {
-	user, ok := currentUser(w, r)
+	user, ok := currentUserWith(w, r, auth.PermDeleteListings)
 	if !ok {
 		return
 	}
@@ -686,7 +907,7 @@ func (h Handler) DeleteListing(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	listing, err := h.fetchListingForUser(r.Context(), id, user.ID)
+	listing, err := h.fetchListingForUser(r.Context(), id, user)
 	if err != nil {
 		if errors.Is(err, storage.ErrNotFound) {
 			http.NotFound(w, r)
@@ -695,6 +916,9 @@ func (h Handler) DeleteListing(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if !checkPrecondition(w, r, listing) {
+		return
+	}
 
 	if err := h.Store.DeleteListing(r.Context(), listing.ID); err != nil {
 		if errors.Is(err, storage.ErrNotFound) {
@@ -704,13 +928,92 @@ func (h Handler) DeleteListing(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	h.auditListing(r, user, "listing.delete", listing, auditSnapshot(listing), nil)
 
-	h.publishDeletion(listing.ID, listing.OwnerID)
+	h.publishDeletion(listing)
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// TrashedListing is a deleted listing plus when the purge job will remove it.
+type TrashedListing struct {
+	storage.Listing
+	PurgeAt *time.Time `json:"purge_at,omitempty"`
+}
+
+// Trash lists the deleted listings in the user's scope.
+func (h Handler) Trash(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	trashed, err := h.Store.ListDeletedListings(r.Context(), user.OrgID, ownerScope(user))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	items := make([]TrashedListing, len(trashed))
+	for i := range trashed {
+		hydrateDetailsFromLegacy(&trashed[i])
+		trashed[i].History = nil
+		items[i].Listing = trashed[i]
+		if h.TrashRetention > 0 && trashed[i].DeletedAt != nil {
+			purgeAt := trashed[i].DeletedAt.Add(h.TrashRetention)
+			items[i].PurgeAt = &purgeAt
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"listings": items})
+}
+
+// RestoreListing takes a listing out of the trash.
+func (h Handler) RestoreListing(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUserWith(w, r, auth.PermDeleteListings)
+	if !ok {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		http.Error(w, "id is required", http.StatusBadRequest)
+		return
+	}
+
+	trashed, err := h.Store.ListDeletedListings(r.Context(), user.OrgID, ownerScope(user))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	i := slices.IndexFunc(trashed, func(l storage.Listing) bool { return l.ID == id })
+	if i < 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	restored, err := h.Store.RestoreListing(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.auditListing(r, user, "listing.restore", restored, auditSnapshot(trashed[i]), auditSnapshot(restored))
+
+	hydrateDetailsFromLegacy(&restored)
+	h.attachStyleProfiles(r.Context(), []*storage.Listing{&restored})
+	writeListing(w, http.StatusOK, restored)
+	h.publishListing(restored)
+}
+
 // UploadMedia handles raw file uploads to the configured uploader (S3).
 func (h Handler) UploadMedia(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
 	if h.Uploader == nil {
 		http.Error(w, "uploads disabled", http.StatusNotImplemented)
 		return
@@ -754,6 +1057,7 @@ func (h Handler) UploadMedia(w http.ResponseWriter, r *http.Request) {
 		ContentType: contentType,
 		Body:        bytes.NewReader(data),
 		Size:        int64(len(data)),
+		Scope:       user.OrgID,
 	})
 	if err != nil {
 		status := http.StatusInternalServerError
@@ -887,7 +1191,7 @@ func (h Handler) ExtractAnnualReport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	modelCtx := llm.WithModel(r.Context(), "gemini-3-pro-preview")
+	modelCtx := h.annualReportContext(r.Context())
 	systemPrompt := `Du är en svensk ekonom som sammanfattar bostadsrättsföreningars årsredovisningar.
 Plocka ut konkreta siffror och citat. Svara alltid som JSON med exakt fältnamn och inget annat (ingen inledande eller avslutande text). Använd "okänd" om du inte hittar data.`
 	userPrompt := fmt.Sprintf(`Text från årsredovisning (urklipp):
@@ -923,7 +1227,7 @@ Returnera JSON med följande nycklar:
 - board_comments: viktiga citat från förvaltningsberättelse/styrelsen
 - summary: kort svensk sammanfattning (2–3 meningar) av föreningens finansiella läge`, sanitized)
 
-	reply, err := callLLMWithRetry(modelCtx, h.LLM, []llm.ChatMessage{
+	completion, err := callLLMWithRetry(modelCtx, h.LLM, []llm.ChatMessage{
 		{Role: "system", Content: systemPrompt},
 		{Role: "user", Content: userPrompt},
 	}, 0.15)
@@ -937,7 +1241,7 @@ Returnera JSON med följande nycklar:
 		return
 	}
 
-	replyClean := cleanLLMJSON(reply)
+	replyClean := cleanLLMJSON(completion.Text)
 	if err := json.Unmarshal([]byte(replyClean), &summary); err != nil {
 		// try forgiving parse via map to handle numbers
 		var m map[string]any
@@ -954,7 +1258,7 @@ Returnera JSON med följande nycklar:
 	}
 	summary.SourcePageCount = pageCount
 	summary.CharactersAnalysed = len(sanitized)
-	summary.ExtractionModel = "gemini-3-pro-preview"
+	summary.ExtractionModel = extractionModel(completion)
 	logAnnualEvent("ok file=%s pages=%d chars=%d", header.Filename, pageCount, len(sanitized))
 
 	w.Header().Set("Content-Type", "application/json")
@@ -995,7 +1299,7 @@ func (h Handler) SummarizeAnnualReport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	modelCtx := llm.WithModel(r.Context(), "gemini-3-pro-preview")
+	modelCtx := h.annualReportContext(r.Context())
 	systemPrompt := `Du är en svensk ekonom som sammanfattar bostadsrättsföreningars årsredovisningar.
 Plocka ut konkreta siffror och citat. Svara alltid som JSON med exakt fältnamn. Använd "okänd" om du inte hittar data.`
 	userPrompt := fmt.Sprintf(`Text från årsredovisning (urklipp):
@@ -1015,7 +1319,7 @@ Returnera JSON med följande nycklar:
 - board_comments: viktiga citat från förvaltningsberättelse/styrelsen
 - summary: kort svensk sammanfattning (2–3 meningar) av föreningens finansiella läge`, sanitized)
 
-	reply, err := callLLMWithRetry(modelCtx, h.LLM, []llm.ChatMessage{
+	completion, err := callLLMWithRetry(modelCtx, h.LLM, []llm.ChatMessage{
 		{Role: "system", Content: systemPrompt},
 		{Role: "user", Content: userPrompt},
 	}, 0.15)
@@ -1028,7 +1332,7 @@ Returnera JSON med följande nycklar:
 		return
 	}
 
-	replyClean := cleanLLMJSON(reply)
+	replyClean := cleanLLMJSON(completion.Text)
 	if err := json.Unmarshal([]byte(replyClean), &summary); err != nil {
 		var m map[string]any
 		trimmed := strings.TrimSpace(replyClean)
@@ -1044,7 +1348,7 @@ Returnera JSON med följande nycklar:
 	}
 	summary.SourcePageCount = req.Pages
 	summary.CharactersAnalysed = len(sanitized)
-	summary.ExtractionModel = "gemini-3-pro-preview"
+	summary.ExtractionModel = extractionModel(completion)
 	logAnnualEvent("ok text payload file=%s pages=%d chars=%d", req.FileName, req.Pages, len(sanitized))
 
 	w.Header().Set("Content-Type", "application/json")
@@ -1108,7 +1412,7 @@ func sanitizeWhitespace(s string) string {
 
 // AttachImage wires an uploaded image to an existing listing.
 func (h Handler) AttachImage(w http.ResponseWriter, r *http.Request) {
-	user, ok := currentUser(w, r)
+	user, ok := currentUserWith(w, r, auth.PermEditSections)
 	if !ok {
 		return
 	}
@@ -1135,7 +1439,7 @@ func (h Handler) AttachImage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	listing, err := h.fetchListingForUser(r.Context(), id, user.ID)
+	listing, err := h.fetchListingForUser(r.Context(), id, user)
 	if err != nil {
 		if errors.Is(err, storage.ErrNotFound) {
 			http.NotFound(w, r)
@@ -1144,6 +1448,15 @@ func (h Handler) AttachImage(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if !checkPrecondition(w, r, listing) {
+		return
+	}
+	// The purge job deletes the keys of purged listings, so only keys this
+	// server issued to the listing's organization may be stored.
+	if key := strings.TrimSpace(req.Key); key != "" && (h.Uploader == nil || !h.Uploader.Owns(key, listing.OrgID)) {
+		http.Error(w, "ok\u00e4nd bildnyckel", http.StatusBadRequest)
+		return
+	}
 
 	asset := storage.ImageAsset{
 		URL:       strings.TrimSpace(req.URL),
@@ -1161,6 +1474,7 @@ func (h Handler) AttachImage(w http.ResponseWriter, r *http.Request) {
 		asset.Kind = "photo"
 	}
 
+	before := auditSnapshot(listing)
 	listing.Details.Media.Images = append(listing.Details.Media.Images, asset)
 	if asset.Cover || listing.ImageURL == "" {
 		listing.ImageURL = asset.URL
@@ -1169,23 +1483,19 @@ func (h Handler) AttachImage(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	updated, err := h.Store.UpdateListingDetails(r.Context(), id, listing.Details, listing.ImageURL)
+	updated, err := h.Store.UpdateListingDetails(r.Context(), id, listing.Version, listing.Details, listing.ImageURL)
 	if err != nil {
-		if errors.Is(err, storage.ErrNotFound) {
-			http.NotFound(w, r)
-			return
-		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		h.writeUpdateError(w, r, id, err)
 		return
 	}
+	h.auditListing(r, user, "listing.image.attach", updated, before, auditSnapshot(updated))
 	hydrateDetailsFromLegacy(&updated)
 	h.attachStyleProfiles(r.Context(), []*storage.Listing{&updated})
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(updated)
+	writeListing(w, http.StatusOK, updated)
 	h.publishListing(updated)
 }
 
-// StreamEvents streams status updates over SSE.
+// StreamEvents streams status updates and quota warnings over SSE.
 func (h Handler) StreamEvents(w http.ResponseWriter, r *http.Request) {
 	user, ok := currentUser(w, r)
 	if !ok {
@@ -1217,22 +1527,34 @@ func (h Handler) StreamEvents(w http.ResponseWriter, r *http.Request) {
 			if !ok {
 				return
 			}
-			if evt.OwnerID != "" && evt.OwnerID != user.ID {
+			name := "status"
+			if evt.Quota != nil {
+				// Organization budgets concern everyone in the office, a
+				// user's budget only them and the office's administrators.
+				name = "quota"
+				if evt.OrgID != user.OrgID || (evt.OwnerID != "" && evt.OwnerID != user.ID && !auth.Can(user.Role, auth.PermManageUsers)) {
+					continue
+				}
+			} else if !visibleTo(storage.Listing{OwnerID: evt.OwnerID, OrgID: evt.OrgID}, user) {
 				continue
 			}
 			payload, err := json.Marshal(evt)
 			if err != nil {
 				continue
 			}
-			_, _ = fmt.Fprintf(w, "event: status\ndata: %s\n\n", payload)
+			_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, payload)
 			flusher.Flush()
 		}
 	}
 }
 
-// ListStyleProfiles returns all stored style profiles.
+// ListStyleProfiles returns the style profiles of the user's organization.
 func (h Handler) ListStyleProfiles(w http.ResponseWriter, r *http.Request) {
-	profiles, err := h.Store.ListStyleProfiles(r.Context())
+	user, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	profiles, err := h.Store.ListStyleProfiles(r.Context(), user.OrgID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -1241,8 +1563,12 @@ func (h Handler) ListStyleProfiles(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(profiles)
 }
 
-// SaveStyleProfile creates or updates a style profile.
+// SaveStyleProfile creates or updates a style profile in the user's organization.
 func (h Handler) SaveStyleProfile(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
 	var payload storage.StyleProfile
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
 		http.Error(w, "invalid request body", http.StatusBadRequest)
@@ -1265,11 +1591,35 @@ func (h Handler) SaveStyleProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	payload.OrgID = user.OrgID
+	var before any
+	if payload.ID != "" {
+		if existing, err := h.Store.GetStyleProfile(r.Context(), payload.ID); err == nil && existing.OrgID == user.OrgID {
+			before = styleProfileSnapshot(existing)
+		}
+	}
 	profile, err := h.Store.SaveStyleProfile(r.Context(), payload)
 	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	action := "style_profile.update"
+	if before == nil {
+		action = "style_profile.create"
+	}
+	audit.Record(r, h.Store, audit.Entry{
+		Actor:      user,
+		Action:     action,
+		EntityType: audit.EntityStyleProfile,
+		EntityID:   profile.ID,
+		OrgID:      profile.OrgID,
+		Before:     before,
+		After:      styleProfileSnapshot(profile),
+	})
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(profile)
 }
@@ -1639,7 +1989,8 @@ func (h Handler) attachStyleProfiles(ctx context.Context, listings []*storage.Li
 			item.StyleProfile = nil
 			continue
 		}
-		if cached, ok := cache[styleID]; ok {
+		key := item.OrgID + "/" + styleID
+		if cached, ok := cache[key]; ok {
 			item.StyleProfile = cached
 			continue
 		}
@@ -1650,18 +2001,28 @@ func (h Handler) attachStyleProfiles(ctx context.Context, listings []*storage.Li
 			}
 			continue
 		}
-		cache[styleID] = &profile
+		// A profile from another organization never shapes this listing's text.
+		if profile.OrgID != item.OrgID {
+			continue
+		}
+		cache[key] = &profile
 		item.StyleProfile = &profile
 	}
 }
 
-func recordHistoryForAll(listing *storage.Listing, source string, ctx historyContext) {
-	for _, section := range listing.Sections {
-		addHistoryEntry(listing, section, source, ctx)
+func sectionRevisions(sections []storage.Section, source string, ctx historyContext) []storage.Revision {
+	revisions := make([]storage.Revision, 0, len(sections))
+	for _, section := range sections {
+		if section.Slug == "" {
+			continue
+		}
+		revisions = append(revisions, sectionRevision(section, source, ctx))
 	}
+	return revisions
 }
 
 type historyContext struct {
+	AuthorID       string
 	Instruction    string
 	Tone           string
 	TargetAudience string
@@ -1669,14 +2030,9 @@ type historyContext struct {
 	Notes          string
 }
 
-func addHistoryEntry(listing *storage.Listing, section storage.Section, source string, ctx historyContext) {
-	if listing.History == nil {
-		listing.History = storage.History{}
-	}
-	if section.Slug == "" {
-		return
-	}
-	entry := storage.SectionVersion{
+func sectionRevision(section storage.Section, source string, ctx historyContext) storage.Revision {
+	entry := storage.Revision{
+		Slug:           section.Slug,
 		Title:          section.Title,
 		Content:        section.Content,
 		Source:         source,
@@ -1685,17 +2041,13 @@ func addHistoryEntry(listing *storage.Listing, section storage.Section, source s
 		TargetAudience: ctx.TargetAudience,
 		Highlights:     append([]string(nil), ctx.Highlights...),
 		Notes:          strings.TrimSpace(ctx.Notes),
-		Timestamp:      time.Now(),
+		AuthorID:       ctx.AuthorID,
+		CreatedAt:      time.Now(),
 	}
 	if len(entry.Highlights) == 0 {
 		entry.Highlights = nil
 	}
-	entries := listing.History[section.Slug]
-	entries = append([]storage.SectionVersion{entry}, entries...)
-	if len(entries) > 5 {
-		entries = entries[:5]
-	}
-	listing.History[section.Slug] = entries
+	return entry
 }
 
 func deriveStatus(listing *storage.Listing) {
@@ -1733,7 +2085,12 @@ func (h Handler) runPipeline(initial storage.Listing) {
 		return
 	}
 
-	ctx := context.Background()
+	ctx := usage.WithTags(context.Background(), usage.Tags{
+		UserID:    initial.OwnerID,
+		OrgID:     initial.OrgID,
+		ListingID: initial.ID,
+		Operation: usage.OpVision,
+	})
 	status := initial.Status
 	listing := initial
 
@@ -1741,32 +2098,31 @@ func (h Handler) runPipeline(initial storage.Listing) {
 		if status.Vision == "" {
 			status.Vision = "skipped"
 			_ = h.Store.UpdateStatus(ctx, initial.ID, status)
-			h.publishStatus(initial.ID, initial.OwnerID, status)
+			h.publishStatus(initial, status)
 		}
 	} else if status.Vision != "completed" {
 		if h.Vision == nil {
 			status.Vision = "skipped"
 			_ = h.Store.UpdateStatus(ctx, initial.ID, status)
-			h.publishStatus(initial.ID, initial.OwnerID, status)
+			h.publishStatus(initial, status)
 		} else {
 			status.Vision = "in_progress"
 			_ = h.Store.UpdateStatus(ctx, initial.ID, status)
-			h.publishStatus(initial.ID, initial.OwnerID, status)
+			h.publishStatus(initial, status)
 
 			insights, err := h.Vision.Analyze(ctx, initial.ImageURL)
 			if err != nil {
 				log.Printf("vision analysis failed: %v", err)
 				status.Vision = "failed"
 				_ = h.Store.UpdateStatus(ctx, initial.ID, status)
-				h.publishStatus(initial.ID, initial.OwnerID, status)
+				h.publishStatus(initial, status)
 			} else {
-				listing.Insights.Vision = insights
 				status.Vision = "completed"
-				updated, err := h.Store.UpdateInsights(ctx, initial.ID, listing.Insights, status)
+				updated, err := h.storeVisionInsights(ctx, listing, insights, status)
 				if err != nil {
 					log.Printf("store vision insights failed: %v", err)
 					_ = h.Store.UpdateStatus(ctx, initial.ID, status)
-					h.publishStatus(initial.ID, initial.OwnerID, status)
+					h.publishStatus(initial, status)
 				} else {
 					listing = updated
 					status = updated.Status
@@ -1779,57 +2135,64 @@ func (h Handler) runPipeline(initial storage.Listing) {
 	if status.Geodata != "completed" {
 		status.Geodata = "in_progress"
 		_ = h.Store.UpdateStatus(ctx, initial.ID, status)
-		h.publishStatus(initial.ID, initial.OwnerID, status)
+		h.publishStatus(initial, status)
 		time.Sleep(1200 * time.Millisecond)
 		status.Geodata = "completed"
 		_ = h.Store.UpdateStatus(ctx, initial.ID, status)
-		h.publishStatus(initial.ID, initial.OwnerID, status)
+		h.publishStatus(initial, status)
 	}
 
 	if status.Text != "completed" {
 		status.Text = "in_progress"
 		_ = h.Store.UpdateStatus(ctx, initial.ID, status)
-		h.publishStatus(initial.ID, initial.OwnerID, status)
+		h.publishStatus(initial, status)
 		time.Sleep(800 * time.Millisecond)
 		status.Text = "completed"
 		_ = h.Store.UpdateStatus(ctx, initial.ID, status)
-		h.publishStatus(initial.ID, initial.OwnerID, status)
+		h.publishStatus(initial, status)
 	}
 
 	status.Data = "completed"
 	_ = h.Store.UpdateStatus(ctx, initial.ID, status)
-	h.publishStatus(initial.ID, initial.OwnerID, status)
+	h.publishStatus(initial, status)
 }
 
-func (h Handler) publishListing(listing storage.Listing) {
-	if h.Events == nil {
-		return
+// storeVisionInsights saves vision output on top of the latest stored listing,
+// retrying when a user edit bumped the version while analysis was running.
+func (h Handler) storeVisionInsights(ctx context.Context, listing storage.Listing, insights storage.VisionInsights, status storage.Status) (storage.Listing, error) {
+	const attempts = 3
+	var err error
+	for i := 0; i < attempts; i++ {
+		listing.Insights.Vision = insights
+		var updated storage.Listing
+		updated, err = h.Store.UpdateInsights(ctx, listing.ID, listing.Version, listing.Insights, status)
+		if !errors.Is(err, storage.ErrConflict) {
+			return updated, err
+		}
+		listing, err = h.Store.GetListing(ctx, listing.ID)
+		if err != nil {
+			return storage.Listing{}, err
+		}
 	}
-	h.Events.Publish(events.Event{
-		ListingID: listing.ID,
-		OwnerID:   listing.OwnerID,
-		Status:    listing.Status,
-	})
+	return storage.Listing{}, err
+}
+
+func (h Handler) publishListing(listing storage.Listing) {
+	h.publishStatus(listing, listing.Status)
 }
 
-func (h Handler) publishStatus(listingID, ownerID string, status storage.Status) {
+func (h Handler) publishStatus(listing storage.Listing, status storage.Status) {
 	if h.Events == nil {
 		return
 	}
 	h.Events.Publish(events.Event{
-		ListingID: listingID,
-		OwnerID:   ownerID,
+		ListingID: listing.ID,
+		OwnerID:   listing.OwnerID,
+		OrgID:     listing.OrgID,
 		Status:    status,
 	})
 }
 
-func (h Handler) publishDeletion(id, ownerID string) {
-	if h.Events == nil {
-		return
-	}
-	h.Events.Publish(events.Event{
-		ListingID: id,
-		OwnerID:   ownerID,
-		Status:    storage.Status{Data: "deleted"},
-	})
+func (h Handler) publishDeletion(listing storage.Listing) {
+	h.publishStatus(listing, storage.Status{Data: "deleted"})
 }
@@ -0,0 +1,114 @@
+package listings
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"k2MarketingAi/internal/media"
+	"k2MarketingAi/internal/storage"
+)
+
+// Purger permanently removes listings that have been in the trash longer than
+// Retention, together with their uploaded media.
+type Purger struct {
+	Store     storage.Store
+	Uploader  media.Uploader
+	Retention time.Duration
+	Interval  time.Duration
+}
+
+// Run purges once immediately and then on every Interval until ctx is cancelled.
+func (p Purger) Run(ctx context.Context) {
+	if p.Store == nil || p.Retention <= 0 {
+		return
+	}
+	interval := p.Interval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if n, err := p.PurgeOnce(ctx); err != nil {
+			log.Printf("trash purge failed: %v", err)
+		} else if n > 0 {
+			log.Printf("trash purge: removed %d listing(s)", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PurgeOnce hard-deletes expired listings and removes their media keys. Only
+// keys the uploader issued to the listing's organization are deleted, and keys
+// still referenced by a live or trashed listing are kept. Media failures are
+// logged but do not bring the listing back.
+func (p Purger) PurgeOnce(ctx context.Context) (int, error) {
+	purged, err := p.Store.PurgeDeletedListings(ctx, time.Now().Add(-p.Retention))
+	if err != nil {
+		return 0, err
+	}
+	if p.Uploader == nil || len(purged) == 0 {
+		return len(purged), nil
+	}
+	inUse, err := p.keysInUse(ctx)
+	if err != nil {
+		log.Printf("trash purge: media kept, cannot list keys in use: %v", err)
+		return len(purged), nil
+	}
+	for _, listing := range purged {
+		for _, key := range mediaKeys(listing) {
+			if !p.Uploader.Owns(key, listing.OrgID) {
+				log.Printf("trash purge: media %s for listing %s was not issued by this server, kept", key, listing.ID)
+				continue
+			}
+			if inUse[key] {
+				continue
+			}
+			if err := p.Uploader.Delete(ctx, key); err != nil && !errors.Is(err, media.ErrUploaderDisabled) {
+				log.Printf("trash purge: delete media %s for listing %s: %v", key, listing.ID, err)
+			}
+		}
+	}
+	return len(purged), nil
+}
+
+// keysInUse returns the media keys of every listing left after a purge, in the
+// trash or not.
+func (p Purger) keysInUse(ctx context.Context) (map[string]bool, error) {
+	live, err := p.Store.ListAllListings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	trashed, err := p.Store.ListDeletedListings(ctx, "", "")
+	if err != nil {
+		return nil, err
+	}
+	inUse := make(map[string]bool)
+	for _, listing := range append(live, trashed...) {
+		for _, key := range mediaKeys(listing) {
+			inUse[key] = true
+		}
+	}
+	return inUse, nil
+}
+
+// mediaKeys returns the distinct uploader keys referenced by a listing.
+func mediaKeys(listing storage.Listing) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, img := range listing.Details.Media.Images {
+		if img.Key == "" || seen[img.Key] {
+			continue
+		}
+		seen[img.Key] = true
+		keys = append(keys, img.Key)
+	}
+	return keys
+}
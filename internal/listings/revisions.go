@@ -0,0 +1,245 @@
+package listings
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"k2MarketingAi/internal/auth"
+	"k2MarketingAi/internal/diff"
+	"k2MarketingAi/internal/storage"
+)
+
+// SectionHistory pages through every stored revision of a section, newest first.
+func (h Handler) SectionHistory(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	slug := normalizeSlug(chi.URLParam(r, "slug"))
+	if id == "" || slug == "" {
+		http.Error(w, "id and slug are required", http.StatusBadRequest)
+		return
+	}
+
+	query := storage.ListRevisionsQuery{ListingID: id, Slug: slug}
+	var err error
+	if query.Page, err = positiveIntParam(r, "page"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if query.PageSize, err = positiveIntParam(r, "page_size"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.fetchListingForUser(r.Context(), id, user); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	page, err := h.Store.ListRevisions(r.Context(), query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(page)
+}
+
+// RestoreSectionRevision copies an earlier revision back into the section. The
+// restore itself is recorded as a new revision, so it can be undone the same way.
+func (h Handler) RestoreSectionRevision(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUserWith(w, r, auth.PermEditSections)
+	if !ok {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	slug := normalizeSlug(chi.URLParam(r, "slug"))
+	if id == "" || slug == "" {
+		http.Error(w, "id and slug are required", http.StatusBadRequest)
+		return
+	}
+	number, err := strconv.Atoi(chi.URLParam(r, "revision"))
+	if err != nil || number < 1 {
+		http.Error(w, "invalid revision", http.StatusBadRequest)
+		return
+	}
+
+	listing, err := h.fetchListingForUser(r.Context(), id, user)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !checkPrecondition(w, r, listing) {
+		return
+	}
+
+	rev, err := h.Store.GetRevision(r.Context(), id, slug, number)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "revision not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	before := auditSnapshot(listing)
+	// A restored deleted section is appended at the end of the listing.
+	section := storage.Section{Slug: rev.Slug, Title: rev.Title, Content: rev.Content}
+	if idx := findSectionIndex(listing.Sections, slug); idx >= 0 {
+		section.Highlights = listing.Sections[idx].Highlights
+		listing.Sections[idx] = section
+	} else {
+		listing.Sections = append(listing.Sections, section)
+	}
+
+	revision := sectionRevision(section, "restore", historyContext{
+		AuthorID:       user.ID,
+		Tone:           listing.Tone,
+		TargetAudience: listing.TargetAudience,
+		Highlights:     listing.Highlights,
+		Notes:          fmt.Sprintf("återställd från version %d", rev.Number),
+	})
+	listing.FullCopy = composeFullCopy(listing.Sections)
+	updated, err := h.Store.UpdateListingSections(r.Context(), id, listing.Version, listing.Sections, listing.FullCopy, []storage.Revision{revision})
+	if err != nil {
+		h.writeUpdateError(w, r, id, err)
+		return
+	}
+	h.auditListing(r, user, "listing.section.restore", updated, before, auditSnapshot(updated))
+
+	hydrateDetailsFromLegacy(&updated)
+	h.attachStyleProfiles(r.Context(), []*storage.Listing{&updated})
+	writeListing(w, http.StatusOK, updated)
+	h.publishListing(updated)
+}
+
+// diffSide identifies one end of a section diff: a stored revision or the current text.
+type diffSide struct {
+	Revision  int        `json:"revision,omitempty"`
+	Current   bool       `json:"current,omitempty"`
+	Source    string     `json:"source,omitempty"`
+	AuthorID  string     `json:"author_id,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+// SectionDiff compares two versions of a section. from and to are revision
+// numbers or "current"; to defaults to the current text.
+func (h Handler) SectionDiff(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	slug := normalizeSlug(chi.URLParam(r, "slug"))
+	if id == "" || slug == "" {
+		http.Error(w, "id and slug are required", http.StatusBadRequest)
+		return
+	}
+	values := r.URL.Query()
+	fromParam := strings.TrimSpace(values.Get("from"))
+	toParam := strings.TrimSpace(values.Get("to"))
+	if fromParam == "" {
+		http.Error(w, "from is required", http.StatusBadRequest)
+		return
+	}
+	if toParam == "" {
+		toParam = "current"
+	}
+	granularity, err := diff.ParseGranularity(values.Get("granularity"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	listing, err := h.fetchListingForUser(r.Context(), id, user)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resolve := func(param string) (storage.SectionVersion, diffSide, bool) {
+		if strings.EqualFold(param, "current") {
+			var version storage.SectionVersion
+			if idx := findSectionIndex(listing.Sections, slug); idx >= 0 {
+				version.Title = listing.Sections[idx].Title
+				version.Content = listing.Sections[idx].Content
+			}
+			return version, diffSide{Current: true}, true
+		}
+		number, err := strconv.Atoi(param)
+		if err != nil || number < 1 {
+			http.Error(w, "from and to must be revision numbers or current", http.StatusBadRequest)
+			return storage.SectionVersion{}, diffSide{}, false
+		}
+		rev, err := h.Store.GetRevision(r.Context(), id, slug, number)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				http.Error(w, fmt.Sprintf("revision %d not found", number), http.StatusNotFound)
+			} else {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return storage.SectionVersion{}, diffSide{}, false
+		}
+		return rev.SectionVersion(), diffSide{Revision: rev.Number, Source: rev.Source, AuthorID: rev.AuthorID, CreatedAt: &rev.CreatedAt}, true
+	}
+
+	fromVersion, fromSide, ok := resolve(fromParam)
+	if !ok {
+		return
+	}
+	toVersion, toSide, ok := resolve(toParam)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(struct {
+		Slug        string           `json:"slug"`
+		Granularity diff.Granularity `json:"granularity"`
+		From        diffSide         `json:"from"`
+		To          diffSide         `json:"to"`
+		diff.SectionDiff
+	}{
+		Slug:        slug,
+		Granularity: granularity,
+		From:        fromSide,
+		To:          toSide,
+		SectionDiff: diff.Sections(fromVersion, toVersion, granularity),
+	})
+}
+
+// positiveIntParam reads an optional positive integer query parameter.
+func positiveIntParam(r *http.Request, name string) (int, error) {
+	raw := strings.TrimSpace(r.URL.Query().Get(name))
+	if raw == "" {
+		return 0, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < 1 {
+		return 0, fmt.Errorf("invalid %s", name)
+	}
+	return value, nil
+}
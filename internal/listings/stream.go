@@ -0,0 +1,110 @@
+package listings
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"k2MarketingAi/internal/generation"
+	"k2MarketingAi/internal/storage"
+)
+
+// RewriteSectionStream rewrites a section like RewriteSection but answers
+// with server-sent events: "delta" events carry new text as the model writes
+// it, and a final "done" event carries the saved section and listing. A
+// failure after the stream has started arrives as an "error" event.
+func (h Handler) RewriteSectionStream(w http.ResponseWriter, r *http.Request) {
+	target, ok := h.loadRewriteTarget(w, r)
+	if !ok {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	send := func(event string, payload any) {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			log.Printf("rewrite stream %s: %v", event, err)
+			return
+		}
+		_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+		flusher.Flush()
+	}
+	sendDelta := func(text string) {
+		send("delta", map[string]string{"text": text})
+	}
+
+	section := target.listing.Sections[target.idx]
+	fallbackUsed := false
+	if h.Generator != nil {
+		updated, err := h.Generator.StreamRewrite(target.generationContext(r.Context()), target.listing, section, target.instruction, sendDelta)
+		if err != nil {
+			log.Printf("rewrite failed: %v", err)
+			send("error", streamError{Status: http.StatusBadGateway, Error: fmt.Sprintf("text rewrite failed: %v", err)})
+			return
+		}
+		section = updated
+	} else if target.instruction != "" {
+		fallbackUsed = true
+		section.Content = generation.ApplyLocalRewrite(section.Content, target.instruction)
+		sendDelta(section.Content)
+	}
+
+	updated, err := h.saveRewrite(r, target, section, fallbackUsed)
+	if err != nil {
+		send("error", h.streamUpdateError(r, target.listing.ID, err))
+		return
+	}
+
+	send("done", struct {
+		Section  storage.Section `json:"section"`
+		Listing  storage.Listing `json:"listing"`
+		ETag     string          `json:"etag"`
+		Fallback bool            `json:"fallback,omitempty"`
+	}{
+		Section:  updated.Sections[target.idx],
+		Listing:  updated,
+		ETag:     listingETag(updated),
+		Fallback: fallbackUsed,
+	})
+	h.publishListing(updated)
+}
+
+// streamError is the payload of an "error" event. Status is the HTTP status
+// the non-streaming endpoint would have answered with; Current is the stored
+// version after a conflict, as in writeConflict.
+type streamError struct {
+	Status  int              `json:"status"`
+	Error   string           `json:"error"`
+	Current *storage.Listing `json:"current,omitempty"`
+}
+
+// streamUpdateError is writeUpdateError for a response that has already
+// started streaming.
+func (h Handler) streamUpdateError(r *http.Request, id string, err error) streamError {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return streamError{Status: http.StatusNotFound, Error: "not found"}
+	case errors.Is(err, storage.ErrConflict):
+		failure := streamError{Status: http.StatusConflict, Error: "objektet har \u00e4ndrats av n\u00e5gon annan, ladda om och f\u00f6rs\u00f6k igen"}
+		if current, getErr := h.Store.GetListing(r.Context(), id); getErr == nil {
+			hydrateDetailsFromLegacy(&current)
+			h.attachStyleProfiles(r.Context(), []*storage.Listing{&current})
+			failure.Current = &current
+		}
+		return failure
+	default:
+		return streamError{Status: http.StatusInternalServerError, Error: err.Error()}
+	}
+}
@@ -28,3 +28,23 @@ func modelFromContext(ctx context.Context) string {
 	}
 	return ""
 }
+
+const jsonContextKey contextKey = "llm-json-response"
+
+// WithJSONResponse marks that the caller parses the reply as a JSON object.
+// Clients that can constrain their output to JSON do so; others ignore it.
+func WithJSONResponse(ctx context.Context) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, jsonContextKey, true)
+}
+
+// jsonFromContext reports whether WithJSONResponse was used.
+func jsonFromContext(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	wanted, _ := ctx.Value(jsonContextKey).(bool)
+	return wanted
+}
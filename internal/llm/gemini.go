@@ -1,6 +1,7 @@
 package llm
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -19,9 +20,44 @@ type ChatMessage struct {
 	Content string `json:"content"`
 }
 
+// Usage is what one call consumed, as reported by the provider.
+type Usage struct {
+	// Model is the model that answered, after any WithModel override.
+	Model            string `json:"model"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+}
+
+// Completion is the answer to a chat request.
+type Completion struct {
+	Text  string
+	Usage Usage
+}
+
 // Client defines the behaviour required by the generation package.
 type Client interface {
-	ChatCompletion(ctx context.Context, messages []ChatMessage, temperature float64) (string, error)
+	ChatCompletion(ctx context.Context, messages []ChatMessage, temperature float64) (Completion, error)
+}
+
+// StreamClient is a Client that can hand out the answer while it is being
+// written. onDelta receives each new piece of text in order; the full text is
+// returned at the end.
+type StreamClient interface {
+	Client
+	ChatCompletionStream(ctx context.Context, messages []ChatMessage, temperature float64, onDelta func(string)) (Completion, error)
+}
+
+// Stream uses client's streaming method when it has one. Other clients answer
+// in one piece, which is passed to onDelta once.
+func Stream(ctx context.Context, client Client, messages []ChatMessage, temperature float64, onDelta func(string)) (Completion, error) {
+	if streamer, ok := client.(StreamClient); ok {
+		return streamer.ChatCompletionStream(ctx, messages, temperature, onDelta)
+	}
+	completion, err := client.ChatCompletion(ctx, messages, temperature)
+	if err == nil && onDelta != nil {
+		onDelta(completion.Text)
+	}
+	return completion, err
 }
 
 // GeminiClient wraps the Google Generative Language API.
@@ -49,7 +85,138 @@ func NewGeminiClient(apiKey, model string, timeout time.Duration, tokenSource oa
 }
 
 // ChatCompletion sends conversational content to Gemini and returns the first candidate text.
-func (c *GeminiClient) ChatCompletion(ctx context.Context, messages []ChatMessage, temperature float64) (string, error) {
+func (c *GeminiClient) ChatCompletion(ctx context.Context, messages []ChatMessage, temperature float64) (Completion, error) {
+	req, model, err := c.newRequest(ctx, "generateContent", messages, temperature)
+	if err != nil {
+		return Completion{}, err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return Completion{}, fmt.Errorf("gemini perform request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return Completion{}, geminiStatusError(resp)
+	}
+
+	var completion geminiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&completion); err != nil {
+		return Completion{}, fmt.Errorf("gemini decode response: %w", err)
+	}
+
+	if len(completion.Candidates) == 0 || len(completion.Candidates[0].Content.Parts) == 0 {
+		return Completion{}, fmt.Errorf("gemini returned no candidates")
+	}
+
+	var parts []string
+	for _, part := range completion.Candidates[0].Content.Parts {
+		if trimmed := strings.TrimSpace(part.Text); trimmed != "" {
+			parts = append(parts, trimmed)
+		}
+	}
+	if len(parts) == 0 {
+		return Completion{}, fmt.Errorf("gemini candidate missing text")
+	}
+	return Completion{Text: strings.Join(parts, "\n\n"), Usage: completion.usage(model)}, nil
+}
+
+// ChatCompletionStream asks Gemini for a server-sent event stream
+// (streamGenerateContent?alt=sse) and calls onDelta with each text chunk as
+// it arrives. It returns the whole text once the stream ends.
+func (c *GeminiClient) ChatCompletionStream(ctx context.Context, messages []ChatMessage, temperature float64, onDelta func(string)) (Completion, error) {
+	req, model, err := c.newRequest(ctx, "streamGenerateContent", messages, temperature)
+	if err != nil {
+		return Completion{}, err
+	}
+	query := req.URL.Query()
+	query.Set("alt", "sse")
+	req.URL.RawQuery = query.Encode()
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return Completion{}, fmt.Errorf("gemini perform request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return Completion{}, geminiStatusError(resp)
+	}
+
+	var (
+		full  strings.Builder
+		usage Usage
+	)
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		var chunk geminiResponse
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &chunk); err != nil {
+			return Completion{}, fmt.Errorf("gemini decode stream chunk: %w", err)
+		}
+		// Every chunk carries the running totals; the last one wins.
+		if chunk.UsageMetadata.PromptTokenCount > 0 || chunk.UsageMetadata.CandidatesTokenCount > 0 {
+			usage = chunk.usage(model)
+		}
+		if len(chunk.Candidates) == 0 {
+			continue
+		}
+		for _, part := range chunk.Candidates[0].Content.Parts {
+			if part.Text == "" {
+				continue
+			}
+			full.WriteString(part.Text)
+			if onDelta != nil {
+				onDelta(part.Text)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Completion{}, fmt.Errorf("gemini read stream: %w", err)
+	}
+
+	text := strings.TrimSpace(full.String())
+	if text == "" {
+		return Completion{}, fmt.Errorf("gemini stream missing text")
+	}
+	usage.Model = model
+	return Completion{Text: text, Usage: usage}, nil
+}
+
+// geminiResponse is the part of a GenerateContentResponse we read. Streamed
+// chunks have the same shape.
+type geminiResponse struct {
+	Candidates []struct {
+		Content struct {
+			Parts []struct {
+				Text string `json:"text"`
+			} `json:"parts"`
+		} `json:"content"`
+	} `json:"candidates"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+func (r geminiResponse) usage(model string) Usage {
+	return Usage{
+		Model:            model,
+		PromptTokens:     r.UsageMetadata.PromptTokenCount,
+		CompletionTokens: r.UsageMetadata.CandidatesTokenCount,
+	}
+}
+
+// newRequest builds a POST to models/{model}:{action} carrying messages,
+// authenticated with the API key or the OAuth token source. It also returns
+// the model the request is for.
+func (c *GeminiClient) newRequest(ctx context.Context, action string, messages []ChatMessage, temperature float64) (*http.Request, string, error) {
 	var systemPrompts []string
 	var contents []map[string]any
 
@@ -74,7 +241,7 @@ func (c *GeminiClient) ChatCompletion(ctx context.Context, messages []ChatMessag
 	}
 
 	if len(contents) == 0 {
-		return "", fmt.Errorf("gemini: missing user or assistant messages")
+		return nil, "", fmt.Errorf("gemini: missing user or assistant messages")
 	}
 
 	payload := map[string]any{
@@ -94,7 +261,7 @@ func (c *GeminiClient) ChatCompletion(ctx context.Context, messages []ChatMessag
 
 	body, err := json.Marshal(payload)
 	if err != nil {
-		return "", fmt.Errorf("marshal gemini payload: %w", err)
+		return nil, "", fmt.Errorf("marshal gemini payload: %w", err)
 	}
 
 	model := c.model
@@ -103,73 +270,40 @@ func (c *GeminiClient) ChatCompletion(ctx context.Context, messages []ChatMessag
 	}
 
 	endpoint := fmt.Sprintf(
-		"https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent",
-		url.PathEscape(model),
+		"https://generativelanguage.googleapis.com/v1beta/models/%s:%s",
+		url.PathEscape(model), action,
 	)
 	if c.tokenSource == nil {
 		if strings.TrimSpace(c.apiKey) == "" {
-			return "", fmt.Errorf("gemini: missing API key or service account credentials")
+			return nil, "", fmt.Errorf("gemini: missing API key or service account credentials")
 		}
 		endpoint = fmt.Sprintf("%s?key=%s", endpoint, url.QueryEscape(c.apiKey))
 	}
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
 	if err != nil {
-		return "", fmt.Errorf("gemini request: %w", err)
+		return nil, "", fmt.Errorf("gemini request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
 	if c.tokenSource != nil {
 		token, err := c.tokenSource.Token()
 		if err != nil {
-			return "", fmt.Errorf("gemini: fetch oauth token: %w", err)
+			return nil, "", fmt.Errorf("gemini: fetch oauth token: %w", err)
 		}
 		req.Header.Set("Authorization", "Bearer "+token.AccessToken)
 	}
+	return req, model, nil
+}
 
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("gemini perform request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode >= 300 {
-		var failure struct {
-			Error struct {
-				Message string `json:"message"`
-			} `json:"error"`
-		}
-		_ = json.NewDecoder(resp.Body).Decode(&failure)
-		return "", fmt.Errorf("gemini status %d: %s", resp.StatusCode, failure.Error.Message)
-	}
-
-	var completion struct {
-		Candidates []struct {
-			Content struct {
-				Parts []struct {
-					Text string `json:"text"`
-				} `json:"parts"`
-			} `json:"content"`
-		} `json:"candidates"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&completion); err != nil {
-		return "", fmt.Errorf("gemini decode response: %w", err)
-	}
-
-	if len(completion.Candidates) == 0 || len(completion.Candidates[0].Content.Parts) == 0 {
-		return "", fmt.Errorf("gemini returned no candidates")
-	}
-
-	var parts []string
-	for _, part := range completion.Candidates[0].Content.Parts {
-		if trimmed := strings.TrimSpace(part.Text); trimmed != "" {
-			parts = append(parts, trimmed)
-		}
-	}
-	if len(parts) == 0 {
-		return "", fmt.Errorf("gemini candidate missing text")
+func geminiStatusError(resp *http.Response) error {
+	var failure struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
 	}
-	return strings.Join(parts, "\n\n"), nil
+	_ = json.NewDecoder(resp.Body).Decode(&failure)
+	return fmt.Errorf("gemini status %d: %s", resp.StatusCode, failure.Error.Message)
 }
 
 func normalizeModel(model string) string {
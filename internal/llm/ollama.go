@@ -0,0 +1,300 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OllamaConfig describes a local Ollama server.
+type OllamaConfig struct {
+	// BaseURL defaults to http://localhost:11434.
+	BaseURL string
+	Model   string
+	// PullMissing pulls a model the server does not have yet, but only Model
+	// or one of PullModels. Models requested by users, such as a style
+	// profile's custom model, must already be installed.
+	PullMissing bool
+	PullModels  []string
+	// Timeout bounds one chat request. Local models on a laptop are slow, so
+	// the default is generous.
+	Timeout time.Duration
+}
+
+// OllamaModel describes a model installed on the Ollama server.
+type OllamaModel struct {
+	Name          string
+	ParameterSize string
+	Quantization  string
+	SizeBytes     int64
+}
+
+// errOllamaModelMissing is returned by chat when the server lacks the model.
+var errOllamaModelMissing = errors.New("ollama: model not found")
+
+// OllamaClient talks to the Ollama REST API.
+type OllamaClient struct {
+	baseURL     string
+	model       string
+	pullMissing bool
+	pullModels  []string
+	client      *http.Client
+	// pullClient has no timeout: downloading a model takes as long as it takes
+	// and is bounded by the caller's context instead.
+	pullClient *http.Client
+}
+
+// NewOllamaClient constructs a client for an Ollama server.
+func NewOllamaClient(cfg OllamaConfig) *OllamaClient {
+	baseURL := strings.TrimRight(strings.TrimSpace(cfg.BaseURL), "/")
+	if baseURL == "" {
+		baseURL = "http://localhost:11434"
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Minute
+	}
+	return &OllamaClient{
+		baseURL:     baseURL,
+		model:       strings.TrimSpace(cfg.Model),
+		pullMissing: cfg.PullMissing,
+		pullModels:  cfg.PullModels,
+		client:      &http.Client{Timeout: timeout},
+		pullClient:  &http.Client{},
+	}
+}
+
+// ChatCompletion sends the conversation to /api/chat. A model set with
+// WithModel replaces the configured one, and WithJSONResponse switches the
+// server to JSON mode.
+func (c *OllamaClient) ChatCompletion(ctx context.Context, messages []ChatMessage, temperature float64) (Completion, error) {
+	model := c.model
+	if override := modelFromContext(ctx); override != "" {
+		model = override
+	}
+	if model == "" {
+		return Completion{}, fmt.Errorf("ollama: missing model")
+	}
+
+	turns := make([]ChatMessage, 0, len(messages))
+	for _, msg := range messages {
+		role := strings.ToLower(strings.TrimSpace(msg.Role))
+		switch role {
+		case "system", "assistant":
+		default:
+			role = "user"
+		}
+		turns = append(turns, ChatMessage{Role: role, Content: msg.Content})
+	}
+	if len(turns) == 0 {
+		return Completion{}, fmt.Errorf("ollama: missing messages")
+	}
+
+	payload := map[string]any{
+		"model":    model,
+		"messages": turns,
+		"stream":   false,
+		"options": map[string]any{
+			"temperature": temperature,
+		},
+	}
+	if jsonFromContext(ctx) {
+		payload["format"] = "json"
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return Completion{}, fmt.Errorf("marshal ollama payload: %w", err)
+	}
+
+	completion, err := c.chat(ctx, body)
+	if errors.Is(err, errOllamaModelMissing) && c.mayPull(model) {
+		if err := c.Pull(ctx, model); err != nil {
+			return Completion{}, err
+		}
+		completion, err = c.chat(ctx, body)
+	}
+	if err != nil {
+		return Completion{}, err
+	}
+	completion.Usage.Model = model
+	return completion, nil
+}
+
+func (c *OllamaClient) chat(ctx context.Context, body []byte) (Completion, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/chat", bytes.NewReader(body))
+	if err != nil {
+		return Completion{}, fmt.Errorf("ollama request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return Completion{}, fmt.Errorf("ollama perform request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		message := ollamaError(resp.Body)
+		if resp.StatusCode == http.StatusNotFound && strings.Contains(message, "not found") {
+			return Completion{}, fmt.Errorf("%w: %s", errOllamaModelMissing, message)
+		}
+		return Completion{}, fmt.Errorf("ollama status %d: %s", resp.StatusCode, message)
+	}
+
+	var completion struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+		PromptEvalCount int `json:"prompt_eval_count"`
+		EvalCount       int `json:"eval_count"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&completion); err != nil {
+		return Completion{}, fmt.Errorf("ollama decode response: %w", err)
+	}
+	text := strings.TrimSpace(completion.Message.Content)
+	if text == "" {
+		return Completion{}, fmt.Errorf("ollama response missing text")
+	}
+	return Completion{
+		Text: text,
+		Usage: Usage{
+			PromptTokens:     completion.PromptEvalCount,
+			CompletionTokens: completion.EvalCount,
+		},
+	}, nil
+}
+
+// Probe checks that the server answers and returns the configured model. When
+// the model is missing it is pulled if PullMissing is set.
+func (c *OllamaClient) Probe(ctx context.Context) (OllamaModel, error) {
+	if c.model == "" {
+		return OllamaModel{}, fmt.Errorf("ollama: missing model")
+	}
+	model, found, err := c.findModel(ctx, c.model)
+	if err != nil || found {
+		return model, err
+	}
+	if !c.pullMissing {
+		return OllamaModel{}, fmt.Errorf("ollama: model %s is not installed; run `ollama pull %s`", c.model, c.model)
+	}
+	if err := c.Pull(ctx, c.model); err != nil {
+		return OllamaModel{}, err
+	}
+	model, found, err = c.findModel(ctx, c.model)
+	if err != nil {
+		return OllamaModel{}, err
+	}
+	if !found {
+		return OllamaModel{}, fmt.Errorf("ollama: model %s missing after pull", c.model)
+	}
+	return model, nil
+}
+
+// mayPull reports whether a missing model may be downloaded: the configured
+// model or one the administrator allowed in PullModels.
+func (c *OllamaClient) mayPull(model string) bool {
+	if !c.pullMissing {
+		return false
+	}
+	if model == c.model {
+		return true
+	}
+	for _, allowed := range c.pullModels {
+		if strings.TrimSpace(allowed) == model {
+			return true
+		}
+	}
+	return false
+}
+
+// Pull downloads model to the server and waits until it is done.
+func (c *OllamaClient) Pull(ctx context.Context, model string) error {
+	body, err := json.Marshal(map[string]any{"model": model, "stream": false})
+	if err != nil {
+		return fmt.Errorf("marshal ollama pull: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/pull", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("ollama pull request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.pullClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("ollama pull %s: %w", model, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("ollama pull %s: status %d: %s", model, resp.StatusCode, ollamaError(resp.Body))
+	}
+	var status struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return fmt.Errorf("ollama pull %s: decode response: %w", model, err)
+	}
+	if status.Error != "" {
+		return fmt.Errorf("ollama pull %s: %s", model, status.Error)
+	}
+	return nil
+}
+
+// findModel looks model up in /api/tags. A name without a tag matches
+// ":latest", as it does in the Ollama CLI.
+func (c *OllamaClient) findModel(ctx context.Context, name string) (OllamaModel, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/tags", nil)
+	if err != nil {
+		return OllamaModel{}, false, fmt.Errorf("ollama tags request: %w", err)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return OllamaModel{}, false, fmt.Errorf("ollama unreachable at %s: %w", c.baseURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return OllamaModel{}, false, fmt.Errorf("ollama tags status %d: %s", resp.StatusCode, ollamaError(resp.Body))
+	}
+
+	var tags struct {
+		Models []struct {
+			Name    string `json:"name"`
+			Size    int64  `json:"size"`
+			Details struct {
+				ParameterSize     string `json:"parameter_size"`
+				QuantizationLevel string `json:"quantization_level"`
+			} `json:"details"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return OllamaModel{}, false, fmt.Errorf("ollama decode tags: %w", err)
+	}
+	want := name
+	if !strings.Contains(want, ":") {
+		want += ":latest"
+	}
+	for _, m := range tags.Models {
+		if m.Name == name || m.Name == want {
+			return OllamaModel{
+				Name:          m.Name,
+				ParameterSize: m.Details.ParameterSize,
+				Quantization:  m.Details.QuantizationLevel,
+				SizeBytes:     m.Size,
+			}, true, nil
+		}
+	}
+	return OllamaModel{}, false, nil
+}
+
+func ollamaError(body io.Reader) string {
+	var failure struct {
+		Error string `json:"error"`
+	}
+	_ = json.NewDecoder(body).Decode(&failure)
+	return failure.Error
+}
@@ -0,0 +1,162 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// OpenAIConfig describes an OpenAI-compatible chat completions endpoint.
+type OpenAIConfig struct {
+	// BaseURL is the API root, e.g. https://api.openai.com/v1 or
+	// http://localhost:8000/v1 for vLLM. For Azure OpenAI it is the resource
+	// endpoint, e.g. https://my-resource.openai.azure.com.
+	BaseURL string
+	APIKey  string
+	Model   string
+	// APIVersion switches to Azure OpenAI: the model names the deployment and
+	// the key is sent in the api-key header.
+	APIVersion string
+	Timeout    time.Duration
+}
+
+// OpenAIClient talks to any server implementing POST /chat/completions, such
+// as OpenAI, Azure OpenAI, vLLM, LM Studio or the llama.cpp server.
+type OpenAIClient struct {
+	baseURL    string
+	apiKey     string
+	model      string
+	apiVersion string
+	client     *http.Client
+}
+
+// NewOpenAIClient constructs a client for an OpenAI-compatible endpoint.
+func NewOpenAIClient(cfg OpenAIConfig) *OpenAIClient {
+	baseURL := strings.TrimRight(strings.TrimSpace(cfg.BaseURL), "/")
+	if baseURL == "" {
+		baseURL = "https://api.openai.com/v1"
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 1000 * time.Second
+	}
+	return &OpenAIClient{
+		baseURL:    baseURL,
+		apiKey:     strings.TrimSpace(cfg.APIKey),
+		model:      strings.TrimSpace(cfg.Model),
+		apiVersion: strings.TrimSpace(cfg.APIVersion),
+		client:     &http.Client{Timeout: timeout},
+	}
+}
+
+// ChatCompletion sends the conversation to /chat/completions and returns the
+// first choice. A model set with WithModel replaces the configured one.
+func (c *OpenAIClient) ChatCompletion(ctx context.Context, messages []ChatMessage, temperature float64) (Completion, error) {
+	model := c.model
+	if override := modelFromContext(ctx); override != "" {
+		model = override
+	}
+	if model == "" {
+		return Completion{}, fmt.Errorf("openai: missing model")
+	}
+
+	turns := make([]ChatMessage, 0, len(messages))
+	for _, msg := range messages {
+		role := strings.ToLower(strings.TrimSpace(msg.Role))
+		switch role {
+		case "system", "assistant":
+		default:
+			role = "user"
+		}
+		turns = append(turns, ChatMessage{Role: role, Content: msg.Content})
+	}
+	if len(turns) == 0 {
+		return Completion{}, fmt.Errorf("openai: missing messages")
+	}
+
+	payload := map[string]any{
+		"messages":    turns,
+		"temperature": temperature,
+	}
+	if c.apiVersion == "" {
+		payload["model"] = model
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return Completion{}, fmt.Errorf("marshal openai payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint(model), bytes.NewReader(body))
+	if err != nil {
+		return Completion{}, fmt.Errorf("openai request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		if c.apiVersion != "" {
+			req.Header.Set("api-key", c.apiKey)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+c.apiKey)
+		}
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return Completion{}, fmt.Errorf("openai perform request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var failure struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&failure)
+		return Completion{}, fmt.Errorf("openai status %d: %s", resp.StatusCode, failure.Error.Message)
+	}
+
+	var completion struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&completion); err != nil {
+		return Completion{}, fmt.Errorf("openai decode response: %w", err)
+	}
+	if len(completion.Choices) == 0 {
+		return Completion{}, fmt.Errorf("openai returned no choices")
+	}
+	text := strings.TrimSpace(completion.Choices[0].Message.Content)
+	if text == "" {
+		return Completion{}, fmt.Errorf("openai choice missing text")
+	}
+	return Completion{
+		Text: text,
+		Usage: Usage{
+			Model:            model,
+			PromptTokens:     completion.Usage.PromptTokens,
+			CompletionTokens: completion.Usage.CompletionTokens,
+		},
+	}, nil
+}
+
+// endpoint returns the chat completions URL. Azure OpenAI addresses the model
+// as a deployment in the path instead of in the request body.
+func (c *OpenAIClient) endpoint(model string) string {
+	if c.apiVersion == "" {
+		return c.baseURL + "/chat/completions"
+	}
+	return fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
+		c.baseURL, url.PathEscape(model), url.QueryEscape(c.apiVersion))
+}
@@ -0,0 +1,40 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileSender writes each message as an .eml file in Dir so that links in
+// development mail can be opened without a mail server.
+type FileSender struct {
+	Dir  string
+	From string
+}
+
+// NewFileSender creates dir if needed and returns a sender that writes to it.
+func NewFileSender(dir, from string) (*FileSender, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create mail dir: %w", err)
+	}
+	return &FileSender{Dir: dir, From: from}, nil
+}
+
+// Send writes the message to a new file named after the current time.
+func (f *FileSender) Send(_ context.Context, msg Message) error {
+	if len(msg.To) == 0 {
+		return ErrNoRecipients
+	}
+	file, err := os.CreateTemp(f.Dir, time.Now().Format("20060102-150405")+"-*.eml")
+	if err != nil {
+		return fmt.Errorf("create mail file: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(render(f.From, msg)); err != nil {
+		return fmt.Errorf("write mail file %s: %w", filepath.Base(file.Name()), err)
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package mail
+
+import (
+	"context"
+	"log"
+	"strings"
+)
+
+// LogSender writes messages to the standard logger instead of sending them.
+// It stands in for SMTP during local development.
+type LogSender struct{}
+
+// Send logs the recipients, subject and body.
+func (LogSender) Send(_ context.Context, msg Message) error {
+	if len(msg.To) == 0 {
+		return ErrNoRecipients
+	}
+	log.Printf("mail to %s: %s\n%s", strings.Join(msg.To, ", "), msg.Subject, msg.Body)
+	return nil
+}
@@ -0,0 +1,40 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+)
+
+// ErrNoRecipients is returned when a message has nobody to deliver to.
+var ErrNoRecipients = errors.New("mail: no recipients")
+
+// Message is a plain-text e-mail.
+type Message struct {
+	To      []string
+	Subject string
+	Body    string
+}
+
+// Sender hides the backing implementation for delivering e-mail.
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// render builds an RFC 5322 message with UTF-8 headers and body.
+func render(from string, msg Message) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(msg.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	buf.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return buf.Bytes()
+}
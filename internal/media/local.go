@@ -2,12 +2,17 @@ package media
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
+// localPrefix starts the name of every file LocalUploader writes.
+const localPrefix = "k2media-"
+
 // LocalUploader stores files on the local filesystem (typically /tmp) for short-lived processing.
 type LocalUploader struct {
 	BaseDir string
@@ -37,7 +42,11 @@ func (l *LocalUploader) Upload(_ context.Context, input UploadInput) (UploadResu
 		ext = ext[:10]
 	}
 
-	tmpFile, err := os.CreateTemp(l.BaseDir, "k2media-*"+ext)
+	dir := filepath.Join(l.BaseDir, scopeDir(input.Scope))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return UploadResult{}, fmt.Errorf("create scope dir: %w", err)
+	}
+	tmpFile, err := os.CreateTemp(dir, localPrefix+"*"+ext)
 	if err != nil {
 		return UploadResult{}, fmt.Errorf("create temp file: %w", err)
 	}
@@ -53,3 +62,25 @@ func (l *LocalUploader) Upload(_ context.Context, input UploadInput) (UploadResu
 		URL: "",
 	}, nil
 }
+
+// Owns accepts only files Upload writes: BaseDir/<scope>/k2media-*.
+func (l *LocalUploader) Owns(key, scope string) bool {
+	rel, err := filepath.Rel(l.BaseDir, filepath.Clean(key))
+	if err != nil {
+		return false
+	}
+	dir, name := filepath.Split(rel)
+	return filepath.Clean(dir) == scopeDir(scope) && strings.HasPrefix(name, localPrefix)
+}
+
+// Delete removes a file previously written by Upload. Keys outside BaseDir are refused.
+func (l *LocalUploader) Delete(_ context.Context, key string) error {
+	rel, err := filepath.Rel(l.BaseDir, filepath.Clean(key))
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return fmt.Errorf("key %q is outside the media dir", key)
+	}
+	if err := os.Remove(filepath.Join(l.BaseDir, rel)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove local file: %w", err)
+	}
+	return nil
+}
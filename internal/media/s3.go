@@ -101,7 +101,7 @@ func (u *s3Uploader) Upload(ctx context.Context, input UploadInput) (UploadResul
 		return UploadResult{}, errors.New("upload body is required")
 	}
 
-	key := u.buildKey(input.Filename)
+	key := u.buildKey(input.Scope, input.Filename)
 
 	putInput := &s3.PutObjectInput{
 		Bucket: aws.String(u.bucket),
@@ -125,18 +125,45 @@ func (u *s3Uploader) Upload(ctx context.Context, input UploadInput) (UploadResul
 	}, nil
 }
 
-func (u *s3Uploader) buildKey(filename string) string {
+// Delete removes an object from the bucket. S3 treats missing keys as success.
+func (u *s3Uploader) Delete(ctx context.Context, key string) error {
+	if key == "" {
+		return errors.New("key is required")
+	}
+	if _, err := u.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(u.bucket),
+		Key:    aws.String(key),
+	}); err != nil {
+		return fmt.Errorf("delete object: %w", err)
+	}
+	return nil
+}
+
+// buildKey returns <prefix>/<scope>/<uuid><ext>.
+func (u *s3Uploader) buildKey(scope, filename string) string {
 	name := uuid.NewString()
 	ext := strings.ToLower(filepath.Ext(filename))
 	if ext != "" {
 		name += ext
 	}
+	return path.Join(u.scopePrefix(scope), name)
+}
 
+func (u *s3Uploader) scopePrefix(scope string) string {
 	if u.prefix == "" {
-		return name
+		return scopeDir(scope)
 	}
+	return path.Join(u.prefix, scopeDir(scope))
+}
 
-	return path.Join(u.prefix, name)
+// Owns accepts only keys of the shape buildKey issues for scope.
+func (u *s3Uploader) Owns(key, scope string) bool {
+	name, ok := strings.CutPrefix(key, u.scopePrefix(scope)+"/")
+	if !ok || len(name) < 36 {
+		return false
+	}
+	_, err := uuid.Parse(name[:36])
+	return err == nil && !strings.Contains(name, "/") && (len(name) == 36 || name[36] == '.')
 }
 
 func (u *s3Uploader) objectURL(key string) string {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"io"
+	"strings"
 )
 
 // ErrUploaderDisabled indicates that uploads are not currently enabled.
@@ -15,6 +16,9 @@ type UploadInput struct {
 	ContentType string
 	Body        io.Reader
 	Size        int64
+	// Scope namespaces the key, normally by organization id, so that Owns can
+	// later tell which organization an upload belongs to.
+	Scope string
 }
 
 // UploadResult captures the canonical object key and its accessible URL.
@@ -26,6 +30,11 @@ type UploadResult struct {
 // Uploader hides the backing implementation for storing files.
 type Uploader interface {
 	Upload(ctx context.Context, input UploadInput) (UploadResult, error)
+	// Delete removes a previously uploaded object. Missing keys are not an error.
+	Delete(ctx context.Context, key string) error
+	// Owns reports whether key has the shape of a key Upload issues for scope.
+	// Keys sent by clients must pass it before they are stored or deleted.
+	Owns(key, scope string) bool
 }
 
 type disabledUploader struct{}
@@ -34,6 +43,29 @@ func (disabledUploader) Upload(_ context.Context, _ UploadInput) (UploadResult,
 	return UploadResult{}, ErrUploaderDisabled
 }
 
+func (disabledUploader) Delete(_ context.Context, _ string) error {
+	return ErrUploaderDisabled
+}
+
+func (disabledUploader) Owns(_, _ string) bool {
+	return false
+}
+
+// scopeDir turns a scope into a single safe path segment.
+func scopeDir(scope string) string {
+	scope = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, strings.TrimSpace(scope))
+	if scope == "" {
+		return "_shared"
+	}
+	return scope
+}
+
 // Disabled returns an uploader that always signals disabled uploads.
 func Disabled() Uploader {
 	return disabledUploader{}
@@ -0,0 +1,101 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"k2MarketingAi/internal/mail"
+	"k2MarketingAi/internal/storage"
+)
+
+// EventUserRegistered is sent when someone registers and waits for approval.
+const EventUserRegistered = "user.registered"
+
+// Notification is a short message for the administrators of the installation.
+type Notification struct {
+	Event   string         `json:"event"`
+	Subject string         `json:"subject"`
+	Text    string         `json:"text"`
+	Data    map[string]any `json:"data,omitempty"`
+}
+
+// Notifier delivers notifications to administrators.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+type disabledNotifier struct{}
+
+func (disabledNotifier) Notify(_ context.Context, _ Notification) error {
+	return nil
+}
+
+// Disabled returns a notifier that drops every notification.
+func Disabled() Notifier {
+	return disabledNotifier{}
+}
+
+// Mail e-mails notifications. When To is empty the message goes to every
+// approved admin in Store.
+type Mail struct {
+	Sender mail.Sender
+	To     []string
+	Store  storage.Store
+}
+
+// Notify sends the notification as a plain-text e-mail.
+func (m Mail) Notify(ctx context.Context, n Notification) error {
+	to := m.To
+	if len(to) == 0 && m.Store != nil {
+		users, err := m.Store.ListUsers(ctx)
+		if err != nil {
+			return fmt.Errorf("list admins: %w", err)
+		}
+		for _, user := range users {
+			if user.Approved && user.Role == storage.RoleAdmin {
+				to = append(to, user.Email)
+			}
+		}
+	}
+	return m.Sender.Send(ctx, mail.Message{To: to, Subject: n.Subject, Body: n.Text})
+}
+
+// Webhook posts notifications as JSON to URL. The top-level "text" field makes
+// the payload usable with Slack and Teams incoming webhooks.
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+// Notify posts the notification and expects a 2xx response.
+func (h Webhook) Notify(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("encode notification: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := h.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
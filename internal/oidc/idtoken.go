@@ -0,0 +1,222 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+// clockSkew is the leeway for exp and iat, since our clock and the provider's
+// rarely agree exactly.
+const clockSkew = time.Minute
+
+// ErrInvalidIDToken wraps every reason an ID token is rejected.
+var ErrInvalidIDToken = errors.New("invalid id token")
+
+// IDToken is the verified identity a provider vouches for.
+type IDToken struct {
+	Issuer        string
+	Subject       string
+	Audience      []string
+	ExpiresAt     time.Time
+	IssuedAt      time.Time
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Verifier checks an ID token's signature and claims (OpenID Connect Core,
+// section 3.1.3.7).
+type Verifier struct {
+	Issuer   string
+	ClientID string
+	Keys     *KeySet
+	// Now defaults to time.Now.
+	Now func() time.Time
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type idTokenClaims struct {
+	Issuer        string       `json:"iss"`
+	Subject       string       `json:"sub"`
+	Audience      audience     `json:"aud"`
+	AuthorizedBy  string       `json:"azp"`
+	Expiry        int64        `json:"exp"`
+	IssuedAt      int64        `json:"iat"`
+	Nonce         string       `json:"nonce"`
+	Email         string       `json:"email"`
+	EmailVerified flexibleBool `json:"email_verified"`
+	Name          string       `json:"name"`
+}
+
+// Verify validates raw and returns its claims. nonce must match the value sent
+// with the authorization request.
+func (v Verifier) Verify(ctx context.Context, raw, nonce string) (IDToken, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return IDToken{}, fmt.Errorf("%w: malformed", ErrInvalidIDToken)
+	}
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return IDToken{}, fmt.Errorf("%w: header: %v", ErrInvalidIDToken, err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return IDToken{}, fmt.Errorf("%w: signature: %v", ErrInvalidIDToken, err)
+	}
+	keys, err := v.Keys.lookup(ctx, header.Kid)
+	if err != nil {
+		return IDToken{}, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	signed := parts[0] + "." + parts[1]
+	verified := false
+	for _, key := range keys {
+		if key.alg != "" && key.alg != header.Alg {
+			continue
+		}
+		if verifySignature(header.Alg, key.key, signed, sig) == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return IDToken{}, fmt.Errorf("%w: bad %s signature", ErrInvalidIDToken, header.Alg)
+	}
+
+	var claims idTokenClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return IDToken{}, fmt.Errorf("%w: claims: %v", ErrInvalidIDToken, err)
+	}
+	now := time.Now()
+	if v.Now != nil {
+		now = v.Now()
+	}
+	switch {
+	case strings.TrimRight(claims.Issuer, "/") != strings.TrimRight(v.Issuer, "/"):
+		return IDToken{}, fmt.Errorf("%w: issuer %q", ErrInvalidIDToken, claims.Issuer)
+	case !slices.Contains(claims.Audience, v.ClientID):
+		return IDToken{}, fmt.Errorf("%w: not issued for this client", ErrInvalidIDToken)
+	case len(claims.Audience) > 1 && claims.AuthorizedBy != v.ClientID:
+		return IDToken{}, fmt.Errorf("%w: azp %q", ErrInvalidIDToken, claims.AuthorizedBy)
+	case claims.Subject == "":
+		return IDToken{}, fmt.Errorf("%w: sub missing", ErrInvalidIDToken)
+	case claims.Expiry == 0 || !now.Before(time.Unix(claims.Expiry, 0).Add(clockSkew)):
+		return IDToken{}, fmt.Errorf("%w: expired", ErrInvalidIDToken)
+	case time.Unix(claims.IssuedAt, 0).After(now.Add(clockSkew)):
+		return IDToken{}, fmt.Errorf("%w: issued in the future", ErrInvalidIDToken)
+	case nonce == "" || claims.Nonce != nonce:
+		return IDToken{}, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	return IDToken{
+		Issuer:        claims.Issuer,
+		Subject:       claims.Subject,
+		Audience:      claims.Audience,
+		ExpiresAt:     time.Unix(claims.Expiry, 0),
+		IssuedAt:      time.Unix(claims.IssuedAt, 0),
+		Email:         strings.TrimSpace(claims.Email),
+		EmailVerified: bool(claims.EmailVerified),
+		Name:          claims.Name,
+	}, nil
+}
+
+// verifySignature supports the algorithms the large providers use. "none" and
+// the HMAC algorithms are rejected on purpose.
+func verifySignature(alg string, key crypto.PublicKey, signed string, sig []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "PS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "PS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "PS512", "ES512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported alg %q", alg)
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	switch alg[:2] {
+	case "RS", "PS":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("key is not rsa")
+		}
+		if alg[0] == 'P' {
+			return rsa.VerifyPSS(pub, hash, digest, sig, nil)
+		}
+		return rsa.VerifyPKCS1v15(pub, hash, digest, sig)
+	default:
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("key is not ecdsa")
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return errors.New("bad ecdsa signature length")
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errors.New("ecdsa verification failed")
+		}
+		return nil
+	}
+}
+
+func decodeSegment(segment string, dst any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
+
+// audience accepts both the single string and the array form of aud.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+// flexibleBool accepts true and "true"; some providers send email_verified as
+// a string.
+type flexibleBool bool
+
+func (b *flexibleBool) UnmarshalJSON(data []byte) error {
+	var value bool
+	if err := json.Unmarshal(data, &value); err == nil {
+		*b = flexibleBool(value)
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	*b = flexibleBool(strings.EqualFold(text, "true"))
+	return nil
+}
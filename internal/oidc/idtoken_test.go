@@ -0,0 +1,256 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	testIssuer   = "https://idp.example.se"
+	testClientID = "k2-client"
+	testNonce    = "nonce-123"
+)
+
+var testNow = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+type testKeys struct {
+	rsa     *rsa.PrivateKey
+	ec      *ecdsa.PrivateKey
+	fetches atomic.Int32
+	server  *httptest.Server
+}
+
+func newTestKeys(t *testing.T) *testKeys {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ec key: %v", err)
+	}
+	keys := &testKeys{rsa: rsaKey, ec: ecKey}
+	set := JSONWebKeySet{Keys: []JSONWebKey{
+		{
+			Kty: "RSA", Kid: "rsa-1", Use: "sig", Alg: "RS256",
+			N: b64(rsaKey.N.Bytes()),
+			E: b64(big.NewInt(int64(rsaKey.E)).Bytes()),
+		},
+		{
+			Kty: "EC", Kid: "ec-1", Use: "sig", Alg: "ES256", Crv: "P-256",
+			X: b64(ecKey.X.FillBytes(make([]byte, 32))),
+			Y: b64(ecKey.Y.FillBytes(make([]byte, 32))),
+		},
+	}}
+	keys.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		keys.fetches.Add(1)
+		_ = json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(keys.server.Close)
+	return keys
+}
+
+func (k *testKeys) verifier() Verifier {
+	return Verifier{
+		Issuer:   testIssuer,
+		ClientID: testClientID,
+		Keys:     &KeySet{URL: k.server.URL, Client: k.server.Client()},
+		Now:      func() time.Time { return testNow },
+	}
+}
+
+// sign builds a compact JWT. alg picks the key; unknown algorithms get an
+// empty signature.
+func (k *testKeys) sign(t *testing.T, header map[string]string, claims map[string]any) string {
+	t.Helper()
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := b64(headerJSON) + "." + b64(claimsJSON)
+	digest := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+	switch header["alg"] {
+	case "RS256":
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k.rsa, crypto.SHA256, digest[:])
+	case "PS256":
+		sig, err = rsa.SignPSS(rand.Reader, k.rsa, crypto.SHA256, digest[:], nil)
+	case "ES256":
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k.ec, digest[:])
+		if err == nil {
+			sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+		}
+	case "HS256":
+		// Key confusion: the public RSA modulus used as an HMAC secret.
+		mac := hmac.New(sha256.New, k.rsa.N.Bytes())
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	}
+	if err != nil {
+		t.Fatalf("sign %s: %v", header["alg"], err)
+	}
+	return signed + "." + b64(sig)
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func validClaims() map[string]any {
+	return map[string]any{
+		"iss":            testIssuer,
+		"sub":            "user-1",
+		"aud":            testClientID,
+		"exp":            testNow.Add(time.Hour).Unix(),
+		"iat":            testNow.Add(-time.Minute).Unix(),
+		"nonce":          testNonce,
+		"email":          " anna@example.se ",
+		"email_verified": "true",
+		"name":           "Anna Åström",
+	}
+}
+
+func TestVerify(t *testing.T) {
+	rs256 := map[string]string{"alg": "RS256", "kid": "rsa-1"}
+	cases := []struct {
+		name    string
+		header  map[string]string
+		claims  func(map[string]any)
+		nonce   string
+		raw     func(string) string
+		wantErr string
+	}{
+		{name: "rs256", header: rs256},
+		{name: "es256", header: map[string]string{"alg": "ES256", "kid": "ec-1"}},
+		{name: "no kid", header: map[string]string{"alg": "RS256"}},
+		{name: "audience array with azp", header: rs256, claims: func(c map[string]any) {
+			c["aud"] = []string{testClientID, "other"}
+			c["azp"] = testClientID
+		}},
+		{name: "expired within skew", header: rs256, claims: func(c map[string]any) {
+			c["exp"] = testNow.Add(-30 * time.Second).Unix()
+		}},
+		{name: "trailing slash on issuer", header: rs256, claims: func(c map[string]any) {
+			c["iss"] = testIssuer + "/"
+		}},
+
+		{name: "wrong audience", header: rs256, wantErr: "not issued for this client", claims: func(c map[string]any) {
+			c["aud"] = "someone-else"
+		}},
+		{name: "audience array without azp", header: rs256, wantErr: "azp", claims: func(c map[string]any) {
+			c["aud"] = []string{"other", testClientID}
+		}},
+		{name: "wrong issuer", header: rs256, wantErr: "issuer", claims: func(c map[string]any) {
+			c["iss"] = "https://evil.example.se"
+		}},
+		{name: "expired", header: rs256, wantErr: "expired", claims: func(c map[string]any) {
+			c["exp"] = testNow.Add(-2 * time.Minute).Unix()
+		}},
+		{name: "no expiry", header: rs256, wantErr: "expired", claims: func(c map[string]any) {
+			delete(c, "exp")
+		}},
+		{name: "issued in the future", header: rs256, wantErr: "issued in the future", claims: func(c map[string]any) {
+			c["iat"] = testNow.Add(5 * time.Minute).Unix()
+		}},
+		{name: "no subject", header: rs256, wantErr: "sub missing", claims: func(c map[string]any) {
+			delete(c, "sub")
+		}},
+		{name: "nonce mismatch", header: rs256, nonce: "another-nonce", wantErr: "nonce mismatch"},
+		{name: "nonce missing from token", header: rs256, wantErr: "nonce mismatch", claims: func(c map[string]any) {
+			delete(c, "nonce")
+		}},
+		{name: "alg none", header: map[string]string{"alg": "none", "kid": "rsa-1"}, wantErr: "bad none signature"},
+		{name: "alg none without kid", header: map[string]string{"alg": "none"}, wantErr: "bad none signature"},
+		{name: "hs256 with public key", header: map[string]string{"alg": "HS256", "kid": "rsa-1"}, wantErr: "bad HS256 signature"},
+		{name: "alg not allowed for key", header: map[string]string{"alg": "PS256", "kid": "rsa-1"}, wantErr: "bad PS256 signature"},
+		{name: "unknown kid", header: map[string]string{"alg": "RS256", "kid": "rotated"}, wantErr: `no signing key "rotated"`},
+		{name: "tampered claims", header: rs256, wantErr: "bad RS256 signature", raw: func(raw string) string {
+			parts := strings.Split(raw, ".")
+			claims := validClaims()
+			claims["email"] = "admin@example.se"
+			data, _ := json.Marshal(claims)
+			return parts[0] + "." + b64(data) + "." + parts[2]
+		}},
+		{name: "malformed", header: rs256, wantErr: "malformed", raw: func(raw string) string {
+			return raw[:strings.LastIndex(raw, ".")]
+		}},
+	}
+
+	keys := newTestKeys(t)
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			claims := validClaims()
+			if tc.claims != nil {
+				tc.claims(claims)
+			}
+			raw := keys.sign(t, tc.header, claims)
+			if tc.raw != nil {
+				raw = tc.raw(raw)
+			}
+			nonce := testNonce
+			if tc.nonce != "" {
+				nonce = tc.nonce
+			}
+
+			token, err := keys.verifier().Verify(context.Background(), raw, nonce)
+			if tc.wantErr != "" {
+				if !errors.Is(err, ErrInvalidIDToken) {
+					t.Fatalf("got %v, want ErrInvalidIDToken", err)
+				}
+				if !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("got %q, want it to mention %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("verify: %v", err)
+			}
+			if token.Subject != "user-1" || token.Email != "anna@example.se" || !token.EmailVerified || token.Name != "Anna Åström" {
+				t.Fatalf("unexpected token %+v", token)
+			}
+		})
+	}
+}
+
+func TestVerifyUnknownKidIsNotRefetchedAtOnce(t *testing.T) {
+	keys := newTestKeys(t)
+	verifier := keys.verifier()
+	ctx := context.Background()
+
+	valid := keys.sign(t, map[string]string{"alg": "RS256", "kid": "rsa-1"}, validClaims())
+	if _, err := verifier.Verify(ctx, valid, testNonce); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	unknown := keys.sign(t, map[string]string{"alg": "RS256", "kid": "rotated"}, validClaims())
+	for range 3 {
+		if _, err := verifier.Verify(ctx, unknown, testNonce); !errors.Is(err, ErrInvalidIDToken) {
+			t.Fatalf("got %v, want ErrInvalidIDToken", err)
+		}
+	}
+	if got := keys.fetches.Load(); got != 1 {
+		t.Fatalf("signing keys fetched %d times, want 1", got)
+	}
+}
@@ -0,0 +1,180 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// refetchInterval stops tokens with unknown key ids from making us hammer the
+// provider's JWKS endpoint.
+const refetchInterval = time.Minute
+
+// JSONWebKey is a public key from a JWKS document (RFC 7517).
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JSONWebKeySet is the document served at jwks_uri.
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// publicKey is a parsed signing key.
+type publicKey struct {
+	kid string
+	alg string
+	key crypto.PublicKey
+}
+
+// KeySet caches the provider's signing keys. It fetches them again when a
+// token names a key it has not seen, which is how providers rotate keys.
+type KeySet struct {
+	URL    string
+	Client *http.Client
+
+	mu        sync.Mutex
+	keys      []publicKey
+	fetchedAt time.Time
+}
+
+// lookup returns the keys that may have signed a token with the given key id.
+func (ks *KeySet) lookup(ctx context.Context, kid string) ([]publicKey, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if matches := matchKeys(ks.keys, kid); len(matches) > 0 {
+		return matches, nil
+	}
+	if !ks.fetchedAt.IsZero() && time.Since(ks.fetchedAt) < refetchInterval {
+		return nil, fmt.Errorf("no signing key %q", kid)
+	}
+	keys, err := ks.fetch(ctx)
+	ks.fetchedAt = time.Now()
+	if err != nil {
+		return nil, err
+	}
+	ks.keys = keys
+	if matches := matchKeys(ks.keys, kid); len(matches) > 0 {
+		return matches, nil
+	}
+	return nil, fmt.Errorf("no signing key %q", kid)
+}
+
+func (ks *KeySet) fetch(ctx context.Context) ([]publicKey, error) {
+	client := ks.Client
+	if client == nil {
+		client = &http.Client{Timeout: defaultTimeout}
+	}
+	var set JSONWebKeySet
+	if err := getJSON(ctx, client, ks.URL, &set); err != nil {
+		return nil, fmt.Errorf("fetch signing keys: %w", err)
+	}
+	keys := make([]publicKey, 0, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			// Skip key types we do not understand instead of failing them all.
+			continue
+		}
+		keys = append(keys, publicKey{kid: jwk.Kid, alg: jwk.Alg, key: key})
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("fetch signing keys: no usable keys")
+	}
+	return keys, nil
+}
+
+// matchKeys picks keys by id. Tokens without a key id may use any key.
+func matchKeys(keys []publicKey, kid string) []publicKey {
+	if kid == "" {
+		return keys
+	}
+	var matches []publicKey
+	for _, key := range keys {
+		if key.kid == kid {
+			matches = append(matches, key)
+		}
+	}
+	return matches
+}
+
+// PublicKey decodes the key into an *rsa.PublicKey or *ecdsa.PublicKey.
+func (k JSONWebKey) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("rsa modulus: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("rsa exponent: %w", err)
+		}
+		if n.BitLen() < 2048 || !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("unsupported rsa key")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("ec x: %w", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("ec y: %w", err)
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		// ECDH rejects points that are not on the curve.
+		if _, err := key.ECDH(); err != nil {
+			return nil, fmt.Errorf("invalid ec key: %w", err)
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	if value == "" {
+		return nil, errors.New("missing")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
@@ -0,0 +1,184 @@
+// Package oidc signs users in with an OpenID Connect provider using the
+// authorization code flow with PKCE. It reads the provider's discovery document
+// and validates ID tokens against the published signing keys.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	// metadataTTL is how long a discovery document is trusted before it is
+	// fetched again.
+	metadataTTL     = time.Hour
+	defaultTimeout  = 10 * time.Second
+	maxResponseSize = 1 << 20
+)
+
+// Metadata holds the parts of the discovery document the login flow needs.
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Config describes a client registered with an OpenID Connect provider.
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// Scopes are requested in addition to openid. Defaults to email and profile.
+	Scopes []string
+	// HTTPClient talks to the provider. A client with a 10 second timeout is
+	// used when nil.
+	HTTPClient *http.Client
+}
+
+// Provider runs the login flow against one issuer. Metadata and signing keys
+// are loaded on first use and cached, so a provider that is down at startup
+// does not stop the server.
+type Provider struct {
+	cfg Config
+
+	mu       sync.Mutex
+	metadata Metadata
+	loadedAt time.Time
+	keys     *KeySet
+}
+
+// NewProvider returns a provider for cfg. Nothing is fetched until it is used.
+func NewProvider(cfg Config) *Provider {
+	cfg.Issuer = strings.TrimRight(cfg.Issuer, "/")
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"email", "profile"}
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: defaultTimeout}
+	}
+	return &Provider{cfg: cfg}
+}
+
+// Issuer returns the configured issuer URL.
+func (p *Provider) Issuer() string {
+	return p.cfg.Issuer
+}
+
+// AuthCodeURL returns the provider's login page for a new attempt. state and
+// nonce tie the callback to the attempt, verifier is the PKCE secret that
+// Exchange needs later.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	conf, _, err := p.oauthConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+	return conf.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier), oauth2.SetAuthURLParam("nonce", nonce)), nil
+}
+
+// Exchange redeems an authorization code and returns the verified ID token.
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (IDToken, error) {
+	conf, keys, err := p.oauthConfig(ctx)
+	if err != nil {
+		return IDToken{}, err
+	}
+	token, err := conf.Exchange(context.WithValue(ctx, oauth2.HTTPClient, p.cfg.HTTPClient), code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return IDToken{}, fmt.Errorf("exchange code: %w", err)
+	}
+	raw, _ := token.Extra("id_token").(string)
+	if raw == "" {
+		return IDToken{}, errors.New("token response has no id_token")
+	}
+	idVerifier := Verifier{Issuer: p.cfg.Issuer, ClientID: p.cfg.ClientID, Keys: keys}
+	return idVerifier.Verify(ctx, raw, nonce)
+}
+
+func (p *Provider) oauthConfig(ctx context.Context) (oauth2.Config, *KeySet, error) {
+	metadata, keys, err := p.load(ctx)
+	if err != nil {
+		return oauth2.Config{}, nil, err
+	}
+	return oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		RedirectURL:  p.cfg.RedirectURL,
+		Scopes:       append([]string{"openid"}, p.cfg.Scopes...),
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  metadata.AuthorizationEndpoint,
+			TokenURL: metadata.TokenEndpoint,
+		},
+	}, keys, nil
+}
+
+// load returns cached metadata, fetching the discovery document when it is
+// missing or older than metadataTTL.
+func (p *Provider) load(ctx context.Context) (Metadata, *KeySet, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.keys != nil && time.Since(p.loadedAt) < metadataTTL {
+		return p.metadata, p.keys, nil
+	}
+	metadata, err := Discover(ctx, p.cfg.HTTPClient, p.cfg.Issuer)
+	if err != nil {
+		if p.keys != nil {
+			// Keep using what we have rather than locking everyone out.
+			return p.metadata, p.keys, nil
+		}
+		return Metadata{}, nil, err
+	}
+	if p.keys == nil || p.keys.URL != metadata.JWKSURI {
+		p.keys = &KeySet{URL: metadata.JWKSURI, Client: p.cfg.HTTPClient}
+	}
+	p.metadata = metadata
+	p.loadedAt = time.Now()
+	return p.metadata, p.keys, nil
+}
+
+// Discover fetches the issuer's /.well-known/openid-configuration document.
+func Discover(ctx context.Context, client *http.Client, issuer string) (Metadata, error) {
+	issuer = strings.TrimRight(issuer, "/")
+	var metadata Metadata
+	if err := getJSON(ctx, client, issuer+"/.well-known/openid-configuration", &metadata); err != nil {
+		return Metadata{}, fmt.Errorf("discover %s: %w", issuer, err)
+	}
+	// The spec requires the document to name the issuer it was fetched from,
+	// which stops one provider from impersonating another.
+	if strings.TrimRight(metadata.Issuer, "/") != issuer {
+		return Metadata{}, fmt.Errorf("discover %s: document is for issuer %q", issuer, metadata.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return Metadata{}, fmt.Errorf("discover %s: endpoints missing", issuer)
+	}
+	return metadata, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, dst any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", url, resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(dst); err != nil {
+		return fmt.Errorf("decode %s: %w", url, err)
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+// Package ratelimit implements token buckets and progressive lockouts. The
+// state lives in a Backend: process memory for a single instance, or the
+// database (any storage.Store) when several instances must share counters.
+package ratelimit
+
+import (
+	"context"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"k2MarketingAi/internal/storage"
+)
+
+// Backend keeps the state behind each key. storage.Store implements it.
+type Backend interface {
+	UpdateRateLimit(ctx context.Context, key string, fn func(storage.RateLimit) storage.RateLimit) (storage.RateLimit, error)
+	PurgeRateLimits(ctx context.Context, before time.Time) (int, error)
+}
+
+// Rule is a token bucket: Burst attempts may be made back to back, and one
+// more is earned every Every. A zero Rule allows everything.
+type Rule struct {
+	Burst int
+	Every time.Duration
+}
+
+// Per spreads n attempts over window, allowing all of them at once.
+func Per(n int, window time.Duration) Rule {
+	if n <= 0 {
+		return Rule{}
+	}
+	return Rule{Burst: n, Every: window / time.Duration(n)}
+}
+
+// Lockout locks a key after After failures in a row. The first lock lasts
+// Base and every further failure doubles it, up to Max. Failures are
+// forgotten once the key has been quiet for Forget.
+type Lockout struct {
+	After  int
+	Base   time.Duration
+	Max    time.Duration
+	Forget time.Duration
+}
+
+// Limiter applies rules and lockouts to keys in Backend.
+type Limiter struct {
+	Backend Backend
+}
+
+// New returns a Limiter that keeps its state in backend.
+func New(backend Backend) *Limiter {
+	return &Limiter{Backend: backend}
+}
+
+// Allow takes one attempt from the key's bucket. It returns how long to wait
+// before trying again, or zero when the attempt is allowed.
+func (l *Limiter) Allow(ctx context.Context, key string, rule Rule) (time.Duration, error) {
+	if rule.Burst <= 0 || rule.Every <= 0 {
+		return 0, nil
+	}
+	var wait time.Duration
+	_, err := l.Backend.UpdateRateLimit(ctx, key, func(state storage.RateLimit) storage.RateLimit {
+		now := time.Now()
+		tokens := float64(rule.Burst)
+		if !state.UpdatedAt.IsZero() {
+			earned := float64(now.Sub(state.UpdatedAt)) / float64(rule.Every)
+			tokens = math.Min(tokens, state.Tokens+math.Max(earned, 0))
+		}
+		if tokens >= 1 {
+			tokens--
+		} else {
+			wait = time.Duration((1 - tokens) * float64(rule.Every))
+		}
+		state.Tokens = tokens
+		state.UpdatedAt = now
+		return state
+	})
+	return wait, err
+}
+
+// LockedFor reports how long the key stays locked.
+func (l *Limiter) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	state, err := l.Backend.UpdateRateLimit(ctx, key, func(state storage.RateLimit) storage.RateLimit {
+		return state
+	})
+	if err != nil || state.LockedUntil == nil {
+		return 0, err
+	}
+	return max(time.Until(*state.LockedUntil), 0), nil
+}
+
+// Fail records a failed attempt and returns how long the key is now locked,
+// which is zero until lockout.After failures have piled up.
+func (l *Limiter) Fail(ctx context.Context, key string, lockout Lockout) (time.Duration, error) {
+	var locked time.Duration
+	_, err := l.Backend.UpdateRateLimit(ctx, key, func(state storage.RateLimit) storage.RateLimit {
+		now := time.Now()
+		if lockout.Forget > 0 && !state.UpdatedAt.IsZero() && now.Sub(state.UpdatedAt) > lockout.Forget {
+			state.Failures = 0
+			state.LockedUntil = nil
+		}
+		state.Failures++
+		state.UpdatedAt = now
+		if lockout.After > 0 && state.Failures >= lockout.After {
+			locked = lockout.Base
+			for range state.Failures - lockout.After {
+				if locked >= lockout.Max {
+					break
+				}
+				locked *= 2
+			}
+			locked = min(locked, lockout.Max)
+			until := now.Add(locked)
+			state.LockedUntil = &until
+		}
+		return state
+	})
+	return locked, err
+}
+
+// Reset forgets the key, for example after a successful login.
+func (l *Limiter) Reset(ctx context.Context, key string) error {
+	_, err := l.Backend.UpdateRateLimit(ctx, key, func(storage.RateLimit) storage.RateLimit {
+		return storage.RateLimit{}
+	})
+	return err
+}
+
+// Memory is a Backend in process memory. Counters are lost on restart and not
+// shared between instances.
+type Memory struct {
+	mu   sync.Mutex
+	keys map[string]storage.RateLimit
+}
+
+// NewMemory returns an empty in-memory backend.
+func NewMemory() *Memory {
+	return &Memory{keys: make(map[string]storage.RateLimit)}
+}
+
+// UpdateRateLimit applies fn to the key's state under the lock.
+func (m *Memory) UpdateRateLimit(_ context.Context, key string, fn func(storage.RateLimit) storage.RateLimit) (storage.RateLimit, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current, ok := m.keys[key]
+	if !ok {
+		current = storage.RateLimit{Key: key}
+	}
+	next := fn(current)
+	next.Key = key
+	if next.UpdatedAt.IsZero() {
+		delete(m.keys, key)
+	} else {
+		m.keys[key] = next
+	}
+	return next, nil
+}
+
+// PurgeRateLimits removes keys idle since before.
+func (m *Memory) PurgeRateLimits(_ context.Context, before time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	removed := 0
+	for key, state := range m.keys {
+		if state.UpdatedAt.Before(before) && (state.LockedUntil == nil || state.LockedUntil.Before(before)) {
+			delete(m.keys, key)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// Purger removes keys from Backend that have been idle for MaxIdle.
+type Purger struct {
+	Backend  Backend
+	Interval time.Duration
+	MaxIdle  time.Duration
+}
+
+// Run purges once immediately and then on every Interval until ctx is cancelled.
+func (p Purger) Run(ctx context.Context) {
+	if p.Backend == nil {
+		return
+	}
+	interval := p.Interval
+	if interval <= 0 {
+		interval = 10 * time.Minute
+	}
+	maxIdle := p.MaxIdle
+	if maxIdle <= 0 {
+		maxIdle = 24 * time.Hour
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if n, err := p.Backend.PurgeRateLimits(ctx, time.Now().Add(-maxIdle)); err != nil {
+			log.Printf("rate limit purge failed: %v", err)
+		} else if n > 0 {
+			log.Printf("rate limit purge: removed %d key(s)", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -3,6 +3,7 @@ package server
 import (
 	"log"
 	"net/http"
+	"net/netip"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -10,6 +11,7 @@ import (
 
 	"k2MarketingAi/internal/auth"
 	"k2MarketingAi/internal/listings"
+	"k2MarketingAi/internal/usage"
 	"k2MarketingAi/internal/vision"
 )
 
@@ -18,11 +20,12 @@ const (
 	defaultWriteTimeout = 2 * time.Minute
 )
 
-// New constructs the HTTP server with routes and middleware.
-func New(port string, authHandler auth.Handler, authMiddleware auth.Middleware, listingHandler listings.Handler, visionHandler vision.Handler, staticFS http.Handler) *http.Server {
+// New constructs the HTTP server with routes and middleware. Forwarded client
+// addresses are only honoured from trustedProxies.
+func New(port string, trustedProxies []netip.Prefix, authHandler auth.Handler, authMiddleware auth.Middleware, listingHandler listings.Handler, visionHandler vision.Handler, usageHandler usage.Handler, staticFS http.Handler) *http.Server {
 	router := chi.NewRouter()
 	router.Use(middleware.RequestID)
-	router.Use(middleware.RealIP)
+	router.Use(realIP(trustedProxies))
 	router.Use(middleware.Logger)
 	router.Use(middleware.Recoverer)
 	router.Use(authMiddleware.InjectUser)
@@ -34,39 +37,102 @@ func New(port string, authHandler auth.Handler, authMiddleware auth.Middleware,
 
 	router.Route("/api", func(r chi.Router) {
 		r.Route("/auth", func(r chi.Router) {
+			r.Use(authMiddleware.Throttle)
 			r.Post("/register", authHandler.Register)
-			r.Post("/login", authHandler.Login)
+			r.With(authMiddleware.GuardLogin).Post("/login", authHandler.Login)
+			r.With(authMiddleware.GuardLogin).Post("/login/2fa", authHandler.LoginTwoFactor)
 			r.Post("/logout", authHandler.Logout)
 			r.Get("/me", authHandler.Me)
+			r.Post("/forgot", authHandler.ForgotPassword)
+			r.Post("/reset", authHandler.ResetPassword)
+			r.Get("/verify", authHandler.VerifyEmail)
+			r.Post("/verify", authHandler.VerifyEmail)
+			r.With(auth.RequireSession).Post("/verify/resend", authHandler.ResendVerification)
+			r.With(auth.RequireSession).Post("/change-password", authHandler.ChangePassword)
+			r.Route("/sessions", func(r chi.Router) {
+				r.Use(auth.RequireSession)
+				r.Get("/", authHandler.ListSessions)
+				r.Delete("/", authHandler.RevokeSessions)
+				r.Delete("/{id}", authHandler.RevokeSession)
+			})
+			r.Route("/oidc", func(r chi.Router) {
+				r.Get("/", authHandler.SSOProviders)
+				r.Get("/{provider}/login", authHandler.SSOLogin)
+				r.Get("/{provider}/callback", authHandler.SSOCallback)
+			})
+			r.Route("/2fa", func(r chi.Router) {
+				// Setup also accepts a login challenge, for users whose
+				// organization requires enrollment before the first session.
+				r.Post("/setup", authHandler.SetupTwoFactor)
+				r.Group(func(r chi.Router) {
+					r.Use(auth.RequireSession)
+					r.Get("/", authHandler.TwoFactorStatus)
+					r.Post("/enable", authHandler.EnableTwoFactor)
+					r.Post("/disable", authHandler.DisableTwoFactor)
+					r.Post("/recovery-codes", authHandler.RegenerateRecoveryCodes)
+				})
+			})
+			r.Route("/tokens", func(r chi.Router) {
+				r.Use(auth.RequireSession)
+				r.Get("/", authHandler.ListAPITokens)
+				r.Post("/", authHandler.CreateAPIToken)
+				r.Delete("/{id}", authHandler.RevokeAPIToken)
+			})
+		})
+
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(auth.RequireSession)
+			r.Use(auth.RequireRole(auth.RolesWith(auth.PermManageUsers)...))
+			r.Get("/users", authHandler.ListUsers)
+			r.Patch("/users/{id}", authHandler.UpdateUser)
+			r.Delete("/users/{id}", authHandler.DeleteUser)
+			r.Delete("/users/{id}/2fa", authHandler.ResetTwoFactor)
+			r.Patch("/organizations/{id}", authHandler.UpdateOrganization)
+			r.Get("/audit", authHandler.ListAuditEvents)
+			r.Get("/usage", usageHandler.Summary)
 		})
 
 		r.Group(func(r chi.Router) {
 			r.Use(auth.RequireAuth)
-			r.Post("/uploads", listingHandler.UploadMedia)
-			r.Post("/annual-reports/extract", listingHandler.ExtractAnnualReport)
-			r.Post("/annual-reports/summarize", listingHandler.SummarizeAnnualReport)
+			r.Group(func(r chi.Router) {
+				r.Use(auth.RequireScope(auth.ScopeListingsWrite))
+				r.Post("/uploads", listingHandler.UploadMedia)
+				r.With(usage.Tag(usage.OpAnnualReport), usageHandler.Quota.Enforce).Post("/annual-reports/extract", listingHandler.ExtractAnnualReport)
+				r.With(usage.Tag(usage.OpAnnualReport), usageHandler.Quota.Enforce).Post("/annual-reports/summarize", listingHandler.SummarizeAnnualReport)
+			})
 			r.Route("/listings", func(r chi.Router) {
+				r.Use(auth.RequireReadWriteScope(auth.ScopeListingsRead, auth.ScopeListingsWrite))
 				r.Get("/", listingHandler.List)
-				r.Post("/", listingHandler.Create)
+				r.With(usage.Tag(usage.OpGenerate), usageHandler.Quota.Enforce).Post("/", listingHandler.Create)
+				r.Get("/search", listingHandler.Search)
+				r.Get("/trash", listingHandler.Trash)
 				r.Route("/{id}", func(r chi.Router) {
 					r.Get("/", listingHandler.Get)
 					r.Post("/images", listingHandler.AttachImage)
-					r.Post("/sections/{slug}/rewrite", listingHandler.RewriteSection)
+					r.With(usage.Tag(usage.OpRewrite), usageHandler.Quota.Enforce).Post("/sections/{slug}/rewrite", listingHandler.RewriteSection)
+					r.With(usage.Tag(usage.OpRewrite), usageHandler.Quota.Enforce).Post("/sections/{slug}/rewrite/stream", listingHandler.RewriteSectionStream)
 					r.Patch("/sections/{slug}", listingHandler.UpdateSection)
 					r.Delete("/sections/{slug}", listingHandler.DeleteSection)
+					r.Get("/sections/{slug}/history", listingHandler.SectionHistory)
+					r.Get("/sections/{slug}/diff", listingHandler.SectionDiff)
+					r.Post("/sections/{slug}/restore/{revision}", listingHandler.RestoreSectionRevision)
 					r.Get("/export", listingHandler.ExportFullCopy)
 					r.Delete("/", listingHandler.DeleteListing)
+					r.Post("/restore", listingHandler.RestoreListing)
 				})
 			})
 			r.Route("/style-profiles", func(r chi.Router) {
+				r.Use(auth.RequireReadWriteScope(auth.ScopeListingsRead, auth.ScopeListingsWrite))
 				r.Get("/", listingHandler.ListStyleProfiles)
-				r.Post("/", listingHandler.SaveStyleProfile)
+				r.With(auth.RequireRole(auth.RolesWith(auth.PermManageStyleProfiles)...)).Post("/", listingHandler.SaveStyleProfile)
 			})
-			r.Get("/events", listingHandler.StreamEvents)
+			r.With(auth.RequireScope(auth.ScopeListingsRead)).Get("/events", listingHandler.StreamEvents)
+			r.Get("/usage/quota", usageHandler.MyQuota)
 			r.Route("/vision", func(r chi.Router) {
-				r.Post("/analyze", visionHandler.Analyze)
-				r.Post("/design", visionHandler.Design)
-				r.Post("/render", visionHandler.Render)
+				r.Use(auth.RequireScope(auth.ScopeVisionUse))
+				r.With(usage.Tag(usage.OpVision), usageHandler.Quota.Enforce).Post("/analyze", visionHandler.Analyze)
+				r.With(usage.Tag(usage.OpDesign), usageHandler.Quota.Enforce).Post("/design", visionHandler.Design)
+				r.With(usage.Tag(usage.OpRender), usageHandler.Quota.Enforce).Post("/render", visionHandler.Render)
 			})
 		})
 	})
@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultAuditPageSize is used when an audit query does not specify a page size.
+	DefaultAuditPageSize = 50
+	// MaxAuditPageSize caps how many events a single audit page may return.
+	MaxAuditPageSize = 500
+)
+
+// AuditEvent is one entry in the append-only audit log: who did what to
+// which entity, and which fields changed.
+type AuditEvent struct {
+	ID         string `json:"id"`
+	OrgID      string `json:"org_id,omitempty"`
+	ActorID    string `json:"actor_id,omitempty"`
+	ActorEmail string `json:"actor_email,omitempty"`
+	Action     string `json:"action"`
+	EntityType string `json:"entity_type"`
+	EntityID   string `json:"entity_id,omitempty"`
+	// Changes maps a dotted field path such as "sections.avgift.content" to
+	// its value before and after the change.
+	Changes   map[string]AuditChange `json:"changes,omitempty"`
+	RequestID string                 `json:"request_id,omitempty"`
+	IP        string                 `json:"ip,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// AuditChange is the JSON value of a field before and after a change. Before
+// is empty for fields that were added and After for fields that were removed.
+type AuditChange struct {
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// AuditQuery narrows and pages the audit log, newest first. Empty fields do
+// not narrow the result.
+type AuditQuery struct {
+	OrgID      string
+	ActorID    string
+	Action     string
+	EntityType string
+	EntityID   string
+	// Since and Until bound CreatedAt; Since is inclusive, Until exclusive.
+	Since    time.Time
+	Until    time.Time
+	Page     int
+	PageSize int
+}
+
+// AuditPage is one page of audit events.
+type AuditPage struct {
+	Events   []AuditEvent `json:"events"`
+	Page     int          `json:"page"`
+	PageSize int          `json:"page_size"`
+	Total    int          `json:"total"`
+}
+
+func (q AuditQuery) normalize() AuditQuery {
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.PageSize <= 0 {
+		q.PageSize = DefaultAuditPageSize
+	}
+	if q.PageSize > MaxAuditPageSize {
+		q.PageSize = MaxAuditPageSize
+	}
+	return q
+}
+
+func (q AuditQuery) offset() int {
+	return (q.Page - 1) * q.PageSize
+}
+
+func (q AuditQuery) matches(e AuditEvent) bool {
+	switch {
+	case q.OrgID != "" && e.OrgID != q.OrgID,
+		q.ActorID != "" && e.ActorID != q.ActorID,
+		q.Action != "" && e.Action != q.Action,
+		q.EntityType != "" && e.EntityType != q.EntityType,
+		q.EntityID != "" && e.EntityID != q.EntityID,
+		!q.Since.IsZero() && e.CreatedAt.Before(q.Since),
+		!q.Until.IsZero() && !e.CreatedAt.Before(q.Until):
+		return false
+	}
+	return true
+}
+
+// where renders the query filters as SQL conditions. placeholder returns the
+// parameter marker for the n-th argument, starting at 1.
+func (q AuditQuery) where(placeholder func(n int) string, timestamp func(time.Time) any) (string, []any) {
+	var (
+		conditions []string
+		args       []any
+	)
+	add := func(column, op string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, column+op+placeholder(len(args)))
+	}
+	if q.OrgID != "" {
+		add("org_id", "=", q.OrgID)
+	}
+	if q.ActorID != "" {
+		add("actor_id", "=", q.ActorID)
+	}
+	if q.Action != "" {
+		add("action", "=", q.Action)
+	}
+	if q.EntityType != "" {
+		add("entity_type", "=", q.EntityType)
+	}
+	if q.EntityID != "" {
+		add("entity_id", "=", q.EntityID)
+	}
+	if !q.Since.IsZero() {
+		add("created_at", ">=", timestamp(q.Since))
+	}
+	if !q.Until.IsZero() {
+		add("created_at", "<", timestamp(q.Until))
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// prepareAuditEvent validates a new event and fills in its id and creation time.
+func prepareAuditEvent(event AuditEvent) (AuditEvent, error) {
+	event.Action = strings.TrimSpace(event.Action)
+	event.EntityType = strings.TrimSpace(event.EntityType)
+	if event.Action == "" || event.EntityType == "" {
+		return AuditEvent{}, fmt.Errorf("audit action and entity type are required")
+	}
+	if event.ID == "" {
+		event.ID = uuid.NewString()
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	return event, nil
+}
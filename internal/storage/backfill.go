@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ListingColumnBackfiller is implemented by the SQL stores. Rows written before
+// the stores persisted every Listing field only carry those values in details.
+type ListingColumnBackfiller interface {
+	// BackfillListingColumns copies values from details into empty listing
+	// columns and reports how many rows changed. With dryRun set it only counts.
+	BackfillListingColumns(ctx context.Context, dryRun bool) (int, error)
+}
+
+// legacyColumnSources maps listing columns to the details field holding the same
+// value. Balcony and length have no counterpart in details and are left alone.
+var legacyColumnSources = []struct {
+	column string
+	path   []string
+}{
+	{"neighborhood", []string{"property", "area"}},
+	{"city", []string{"property", "city"}},
+	{"property_type", []string{"property", "property_type"}},
+	{"condition", []string{"property", "condition"}},
+	{"floor", []string{"property", "floor"}},
+	{"association", []string{"association", "name"}},
+}
+
+// backfillStatements builds the count and update statements; source renders the
+// dialect's expression for reading a details path as text.
+func backfillStatements(source func(path []string) string) (count, update string) {
+	var assignments, missing []string
+	for _, src := range legacyColumnSources {
+		current := fmt.Sprintf("NULLIF(TRIM(%s), '')", src.column)
+		recovered := fmt.Sprintf("NULLIF(TRIM(%s), '')", source(src.path))
+		assignments = append(assignments, fmt.Sprintf("%s = COALESCE(%s, %s)", src.column, current, recovered))
+		missing = append(missing, fmt.Sprintf("(%s IS NULL AND %s IS NOT NULL)", current, recovered))
+	}
+	where := strings.Join(missing, " OR ")
+	count = "SELECT COUNT(*) FROM listings WHERE " + where
+	update = "UPDATE listings SET " + strings.Join(assignments, ", ") + ", version = version + 1 WHERE " + where
+	return count, update
+}
+
+func postgresDetailsPath(path []string) string {
+	return fmt.Sprintf("details #>> '{%s}'", strings.Join(path, ","))
+}
+
+func sqliteDetailsPath(path []string) string {
+	return fmt.Sprintf("json_extract(details, '$.%s')", strings.Join(path, "."))
+}
+
+// BackfillListingColumns fills empty listing columns from details, trashed rows included.
+func (s *PostgresStore) BackfillListingColumns(ctx context.Context, dryRun bool) (int, error) {
+	count, update := backfillStatements(postgresDetailsPath)
+	if dryRun {
+		var n int
+		if err := s.pool.QueryRow(ctx, count).Scan(&n); err != nil {
+			return 0, fmt.Errorf("count listings to backfill: %w", err)
+		}
+		return n, nil
+	}
+	tag, err := s.pool.Exec(ctx, update)
+	if err != nil {
+		return 0, fmt.Errorf("backfill listing columns: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// BackfillListingColumns fills empty listing columns from details, trashed rows included.
+func (s *SQLiteStore) BackfillListingColumns(ctx context.Context, dryRun bool) (int, error) {
+	count, update := backfillStatements(sqliteDetailsPath)
+	if dryRun {
+		var n int
+		if err := s.db.QueryRowContext(ctx, count).Scan(&n); err != nil {
+			return 0, fmt.Errorf("count listings to backfill: %w", err)
+		}
+		return n, nil
+	}
+	result, err := s.db.ExecContext(ctx, update)
+	if err != nil {
+		return 0, fmt.Errorf("backfill listing columns: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("backfill listing columns: %w", err)
+	}
+	return int(n), nil
+}
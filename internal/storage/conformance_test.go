@@ -0,0 +1,61 @@
+package storage_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+
+	"k2MarketingAi/internal/storage"
+	"k2MarketingAi/internal/storage/storagetest"
+)
+
+// postgresDSNEnv names a scratch PostgreSQL database for the conformance suite.
+// Every scenario truncates its tables, so never point it at real data.
+const postgresDSNEnv = "K2_TEST_DATABASE_URL"
+
+func TestInMemoryStoreConformance(t *testing.T) {
+	storagetest.RunConformance(t, func() storage.Store {
+		return storage.NewInMemoryStore()
+	})
+}
+
+func TestSQLiteStoreConformance(t *testing.T) {
+	storagetest.RunConformance(t, func() storage.Store {
+		return openStore(t, "sqlite://"+filepath.Join(t.TempDir(), "k2.db"))
+	})
+}
+
+func TestPostgresStoreConformance(t *testing.T) {
+	dsn := os.Getenv(postgresDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", postgresDSNEnv)
+	}
+	storagetest.RunConformance(t, func() storage.Store {
+		store := openStore(t, dsn)
+		ctx := context.Background()
+		conn, err := pgx.Connect(ctx, dsn)
+		if err != nil {
+			t.Fatalf("connect: %v", err)
+		}
+		defer conn.Close(ctx)
+		if _, err := conn.Exec(ctx, `TRUNCATE listings, listing_revisions, style_profiles, sessions, api_tokens, user_two_factor, rate_limits, audit_events, llm_usage, users CASCADE`); err != nil {
+			t.Fatalf("truncate tables: %v", err)
+		}
+		if _, err := conn.Exec(ctx, `DELETE FROM organizations WHERE id <> $1`, storage.DefaultOrganizationID); err != nil {
+			t.Fatalf("delete organizations: %v", err)
+		}
+		return store
+	})
+}
+
+func openStore(t *testing.T, databaseURL string) storage.Store {
+	t.Helper()
+	store, err := storage.NewStore(context.Background(), databaseURL)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	return store
+}
@@ -3,6 +3,8 @@ package storage
 import (
 	"context"
 	"fmt"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -14,23 +16,39 @@ import (
 type InMemoryStore struct {
 	mu            sync.RWMutex
 	listings      []Listing
+	revisions     map[string][]Revision
 	styleProfiles map[string]StyleProfile
+	organizations map[string]Organization
 	users         map[string]User
 	emailIndex    map[string]string
+	sessions      map[string]Session
+	apiTokens     map[string]APIToken
+	twoFactor     map[string]TwoFactor
+	rateLimits    map[string]RateLimit
+	auditEvents   []AuditEvent
+	llmUsage      []LLMUsage
 }
 
-// NewInMemoryStore constructs an empty in-memory store.
+// NewInMemoryStore constructs an empty in-memory store holding only the default organization.
 func NewInMemoryStore() *InMemoryStore {
 	return &InMemoryStore{
 		listings:      make([]Listing, 0),
+		revisions:     make(map[string][]Revision),
 		styleProfiles: make(map[string]StyleProfile),
-		users:         make(map[string]User),
-		emailIndex:    make(map[string]string),
+		organizations: map[string]Organization{
+			DefaultOrganizationID: {ID: DefaultOrganizationID, Name: "Standardbyrå", CreatedAt: time.Now()},
+		},
+		users:      make(map[string]User),
+		emailIndex: make(map[string]string),
+		sessions:   make(map[string]Session),
+		apiTokens:  make(map[string]APIToken),
+		twoFactor:  make(map[string]TwoFactor),
+		rateLimits: make(map[string]RateLimit),
 	}
 }
 
-// CreateListing appends a listing to the in-memory slice.
-func (s *InMemoryStore) CreateListing(_ context.Context, input Listing) (Listing, error) {
+// CreateListing appends a listing to the in-memory slice together with its first revisions.
+func (s *InMemoryStore) CreateListing(_ context.Context, input Listing, revisions []Revision) (Listing, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -40,54 +58,105 @@ func (s *InMemoryStore) CreateListing(_ context.Context, input Listing) (Listing
 	if input.CreatedAt.IsZero() {
 		input.CreatedAt = time.Now()
 	}
+	if input.OrgID == "" {
+		input.OrgID = DefaultOrganizationID
+	}
 	if input.Sections == nil {
 		input.Sections = []Section{}
 	}
 	if input.FullCopy == "" {
 		input.FullCopy = ""
 	}
-	if input.History == nil {
-		input.History = History{}
-	}
+	input.History = nil
 	if input.Status == (Status{}) {
 		input.Status = Status{}
 	}
+	input.Version = 1
 
 	s.listings = append([]Listing{input}, s.listings...)
-	if len(s.listings) > 50 {
-		s.listings = s.listings[:50]
+	s.appendRevisions(input.ID, revisions)
+
+	return s.withHistory(input), nil
+}
+
+// appendRevisions numbers and stores new revisions. Callers hold the write lock.
+func (s *InMemoryStore) appendRevisions(listingID string, revisions []Revision) {
+	existing := s.revisions[listingID]
+	for _, rev := range revisions {
+		number := 0
+		for _, prev := range existing {
+			if prev.Slug == rev.Slug && prev.Number > number {
+				number = prev.Number
+			}
+		}
+		if rev.ID == "" {
+			rev.ID = uuid.NewString()
+		}
+		if rev.CreatedAt.IsZero() {
+			rev.CreatedAt = time.Now()
+		}
+		rev.ListingID = listingID
+		rev.Number = number + 1
+		existing = append(existing, rev)
 	}
+	s.revisions[listingID] = existing
+}
 
-	return input, nil
+// withHistory fills the legacy History field. Callers hold the lock.
+func (s *InMemoryStore) withHistory(l Listing) Listing {
+	l.History = historyFromRevisions(s.revisions[l.ID])
+	return l
 }
 
-// ListListings returns a snapshot of stored listings.
+// ListListings returns the DefaultListingLimit most recent listings.
 func (s *InMemoryStore) ListListings(_ context.Context) ([]Listing, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	snapshot := make([]Listing, len(s.listings))
-	copy(snapshot, s.listings)
-	return snapshot, nil
+	live := s.liveListings()
+	if len(live) > DefaultListingLimit {
+		live = live[:DefaultListingLimit]
+	}
+	return live, nil
+}
+
+// liveListings copies the listings that are not in the trash, newest first.
+// Callers hold the lock.
+func (s *InMemoryStore) liveListings() []Listing {
+	live := make([]Listing, 0, len(s.listings))
+	for _, l := range s.listings {
+		if l.DeletedAt == nil {
+			live = append(live, l)
+		}
+	}
+	sort.SliceStable(live, func(i, j int) bool {
+		return live[i].CreatedAt.After(live[j].CreatedAt)
+	})
+	return live
 }
 
-// ListListingsByOwner returns listings belonging to the provided owner.
-func (s *InMemoryStore) ListListingsByOwner(_ context.Context, ownerID string) ([]Listing, error) {
+// QueryListings returns one page of listings matching the query filters.
+func (s *InMemoryStore) QueryListings(_ context.Context, query ListListingsQuery) (ListingPage, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	var results []Listing
-	for _, l := range s.listings {
-		if l.OwnerID == ownerID {
-			results = append(results, l)
-		}
-	}
-	return results, nil
+	return pageListings(s.liveListings(), query)
+}
+
+// SearchListings performs a case-insensitive substring search over listing text.
+func (s *InMemoryStore) SearchListings(_ context.Context, query SearchListingsQuery) ([]SearchResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return searchListings(s.liveListings(), query), nil
 }
 
-// ListAllListings returns the same snapshot as ListListings for the in-memory store.
-func (s *InMemoryStore) ListAllListings(ctx context.Context) ([]Listing, error) {
-	return s.ListListings(ctx)
+// ListAllListings returns every live listing, newest first.
+func (s *InMemoryStore) ListAllListings(_ context.Context) ([]Listing, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.liveListings(), nil
 }
 
 // Close satisfies the Store interface.
@@ -99,81 +168,146 @@ func (s *InMemoryStore) GetListing(_ context.Context, id string) (Listing, error
 	defer s.mu.RUnlock()
 
 	for _, l := range s.listings {
-		if l.ID == id {
-			return l, nil
+		if l.ID == id && l.DeletedAt == nil {
+			return s.withHistory(l), nil
 		}
 	}
 	return Listing{}, ErrNotFound
 }
 
-// UpdateListingSections replaces the sections on a listing.
-func (s *InMemoryStore) UpdateListingSections(_ context.Context, id string, sections []Section, fullCopy string, history History, status Status) (Listing, error) {
+// UpdateListingSections replaces the sections on a listing. The pipeline
+// status is left alone.
+func (s *InMemoryStore) UpdateListingSections(_ context.Context, id string, version int, sections []Section, fullCopy string, revisions []Revision) (Listing, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	for idx, l := range s.listings {
-		if l.ID == id {
+		if l.ID == id && l.DeletedAt == nil {
+			if l.Version != version {
+				return Listing{}, ErrConflict
+			}
+			s.listings[idx].Version++
 			s.listings[idx].Sections = sections
 			s.listings[idx].FullCopy = fullCopy
-			s.listings[idx].History = history
-			s.listings[idx].Status = status
-			return s.listings[idx], nil
+			s.appendRevisions(id, revisions)
+			return s.withHistory(s.listings[idx]), nil
 		}
 	}
 	return Listing{}, ErrNotFound
 }
 
 // UpdateListingDetails updates the details JSON and cover image.
-func (s *InMemoryStore) UpdateListingDetails(_ context.Context, id string, details Details, imageURL string) (Listing, error) {
+func (s *InMemoryStore) UpdateListingDetails(_ context.Context, id string, version int, details Details, imageURL string) (Listing, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	for idx, l := range s.listings {
-		if l.ID == id {
+		if l.ID == id && l.DeletedAt == nil {
+			if l.Version != version {
+				return Listing{}, ErrConflict
+			}
+			s.listings[idx].Version++
 			s.listings[idx].Details = details
 			s.listings[idx].ImageURL = imageURL
-			return s.listings[idx], nil
+			return s.withHistory(s.listings[idx]), nil
 		}
 	}
 	return Listing{}, ErrNotFound
 }
 
 // UpdateInsights stores refreshed insights and status for a listing.
-func (s *InMemoryStore) UpdateInsights(_ context.Context, id string, insights Insights, status Status) (Listing, error) {
+func (s *InMemoryStore) UpdateInsights(_ context.Context, id string, version int, insights Insights, status Status) (Listing, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	for idx, l := range s.listings {
-		if l.ID == id {
+		if l.ID == id && l.DeletedAt == nil {
+			if l.Version != version {
+				return Listing{}, ErrConflict
+			}
+			s.listings[idx].Version++
 			s.listings[idx].Insights = insights
 			s.listings[idx].Status = status
-			return s.listings[idx], nil
+			return s.withHistory(s.listings[idx]), nil
 		}
 	}
 	return Listing{}, ErrNotFound
 }
 
-// DeleteListing removes a listing by ID.
+// DeleteListing moves a listing to the trash.
 func (s *InMemoryStore) DeleteListing(_ context.Context, id string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	for idx, l := range s.listings {
-		if l.ID == id {
-			s.listings = append(s.listings[:idx], s.listings[idx+1:]...)
+		if l.ID == id && l.DeletedAt == nil {
+			now := time.Now()
+			s.listings[idx].DeletedAt = &now
 			return nil
 		}
 	}
 	return ErrNotFound
 }
 
+// ListDeletedListings returns trashed listings, most recently deleted first.
+func (s *InMemoryStore) ListDeletedListings(_ context.Context, orgID, ownerID string) ([]Listing, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var trashed []Listing
+	for _, l := range s.listings {
+		if l.DeletedAt != nil && (orgID == "" || l.OrgID == orgID) && (ownerID == "" || l.OwnerID == ownerID) {
+			trashed = append(trashed, l)
+		}
+	}
+	sort.SliceStable(trashed, func(i, j int) bool {
+		return trashed[i].DeletedAt.After(*trashed[j].DeletedAt)
+	})
+	return trashed, nil
+}
+
+// RestoreListing takes a listing out of the trash.
+func (s *InMemoryStore) RestoreListing(_ context.Context, id string) (Listing, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for idx, l := range s.listings {
+		if l.ID == id && l.DeletedAt != nil {
+			s.listings[idx].DeletedAt = nil
+			s.listings[idx].Version++
+			return s.withHistory(s.listings[idx]), nil
+		}
+	}
+	return Listing{}, ErrNotFound
+}
+
+// PurgeDeletedListings permanently removes listings trashed before the cutoff
+// and returns them so their media can be cleaned up.
+func (s *InMemoryStore) PurgeDeletedListings(_ context.Context, deletedBefore time.Time) ([]Listing, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged []Listing
+	kept := s.listings[:0]
+	for _, l := range s.listings {
+		if l.DeletedAt != nil && l.DeletedAt.Before(deletedBefore) {
+			purged = append(purged, l)
+			delete(s.revisions, l.ID)
+			continue
+		}
+		kept = append(kept, l)
+	}
+	s.listings = kept
+	return purged, nil
+}
+
 // UpdateStatus sets only the pipeline status for a listing.
 func (s *InMemoryStore) UpdateStatus(_ context.Context, id string, status Status) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	for idx, l := range s.listings {
-		if l.ID == id {
+		if l.ID == id && l.DeletedAt == nil {
 			s.listings[idx].Status = status
 			return nil
 		}
@@ -181,16 +315,56 @@ func (s *InMemoryStore) UpdateStatus(_ context.Context, id string, status Status
 	return ErrNotFound
 }
 
+// ListRevisions returns one page of a section's revisions, newest first.
+func (s *InMemoryStore) ListRevisions(_ context.Context, query ListRevisionsQuery) (RevisionPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	q := query.normalize()
+	var matched []Revision
+	for _, rev := range s.revisions[q.ListingID] {
+		if rev.Slug == q.Slug {
+			matched = append(matched, rev)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].Number > matched[j].Number })
+
+	page := RevisionPage{Revisions: []Revision{}, Page: q.Page, PageSize: q.PageSize, Total: len(matched)}
+	if from := q.offset(); from < len(matched) {
+		page.Revisions = matched[from:min(from+q.PageSize, len(matched))]
+	}
+	return page, nil
+}
+
+// GetRevision returns a single section revision by number.
+func (s *InMemoryStore) GetRevision(_ context.Context, listingID, slug string, number int) (Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, rev := range s.revisions[listingID] {
+		if rev.Slug == slug && rev.Number == number {
+			return rev, nil
+		}
+	}
+	return Revision{}, ErrNotFound
+}
+
 // SaveStyleProfile stores or updates a style profile in memory.
 func (s *InMemoryStore) SaveStyleProfile(_ context.Context, profile StyleProfile) (StyleProfile, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	now := time.Now()
+	if profile.OrgID == "" {
+		profile.OrgID = DefaultOrganizationID
+	}
 	if profile.ID == "" {
 		profile.ID = uuid.NewString()
 		profile.CreatedAt = now
 	} else if existing, ok := s.styleProfiles[profile.ID]; ok {
+		if existing.OrgID != profile.OrgID {
+			return StyleProfile{}, ErrNotFound
+		}
 		if profile.CreatedAt.IsZero() {
 			profile.CreatedAt = existing.CreatedAt
 		}
@@ -203,15 +377,24 @@ func (s *InMemoryStore) SaveStyleProfile(_ context.Context, profile StyleProfile
 	return profile, nil
 }
 
-// ListStyleProfiles returns all profiles.
-func (s *InMemoryStore) ListStyleProfiles(_ context.Context) ([]StyleProfile, error) {
+// ListStyleProfiles returns the organization's profiles ordered by name, or all
+// of them when orgID is empty.
+func (s *InMemoryStore) ListStyleProfiles(_ context.Context, orgID string) ([]StyleProfile, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	profiles := make([]StyleProfile, 0, len(s.styleProfiles))
 	for _, profile := range s.styleProfiles {
-		profiles = append(profiles, profile)
+		if orgID == "" || profile.OrgID == orgID {
+			profiles = append(profiles, profile)
+		}
 	}
+	sort.Slice(profiles, func(i, j int) bool {
+		if profiles[i].Name != profiles[j].Name {
+			return profiles[i].Name < profiles[j].Name
+		}
+		return profiles[i].ID < profiles[j].ID
+	})
 	return profiles, nil
 }
 
@@ -227,6 +410,139 @@ func (s *InMemoryStore) GetStyleProfile(_ context.Context, id string) (StyleProf
 	return profile, nil
 }
 
+// CreateOrganization stores a new organization in memory.
+func (s *InMemoryStore) CreateOrganization(_ context.Context, org Organization) (Organization, error) {
+	org, err := prepareOrganization(org)
+	if err != nil {
+		return Organization{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.organizations[org.ID]; exists {
+		return Organization{}, ErrOrganizationExists
+	}
+	s.organizations[org.ID] = org
+	return org, nil
+}
+
+// GetOrganization returns an organization by ID.
+func (s *InMemoryStore) GetOrganization(_ context.Context, id string) (Organization, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	org, ok := s.organizations[id]
+	if !ok {
+		return Organization{}, ErrNotFound
+	}
+	return org, nil
+}
+
+// ListOrganizations returns all organizations ordered by name.
+func (s *InMemoryStore) ListOrganizations(_ context.Context) ([]Organization, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	orgs := make([]Organization, 0, len(s.organizations))
+	for _, org := range s.organizations {
+		orgs = append(orgs, org)
+	}
+	sort.Slice(orgs, func(i, j int) bool {
+		if orgs[i].Name != orgs[j].Name {
+			return orgs[i].Name < orgs[j].Name
+		}
+		return orgs[i].ID < orgs[j].ID
+	})
+	return orgs, nil
+}
+
+// SetOrganizationTwoFactor turns the two-factor requirement on or off.
+func (s *InMemoryStore) SetOrganizationTwoFactor(_ context.Context, id string, required bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	org, ok := s.organizations[id]
+	if !ok {
+		return ErrNotFound
+	}
+	org.RequireTwoFactor = required
+	s.organizations[id] = org
+	return nil
+}
+
+// GetTwoFactor returns the user's TOTP enrollment.
+func (s *InMemoryStore) GetTwoFactor(_ context.Context, userID string) (TwoFactor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tf, ok := s.twoFactor[userID]
+	if !ok {
+		return TwoFactor{}, ErrNotFound
+	}
+	tf.RecoveryCodes = append([]string{}, tf.RecoveryCodes...)
+	return tf, nil
+}
+
+// SaveTwoFactor creates or replaces the user's enrollment.
+func (s *InMemoryStore) SaveTwoFactor(_ context.Context, tf TwoFactor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[tf.UserID]; !ok {
+		return ErrNotFound
+	}
+	if tf.CreatedAt.IsZero() {
+		tf.CreatedAt = time.Now()
+	}
+	tf.RecoveryCodes = append([]string{}, tf.RecoveryCodes...)
+	s.twoFactor[tf.UserID] = tf
+	return nil
+}
+
+// DeleteTwoFactor removes the user's enrollment.
+func (s *InMemoryStore) DeleteTwoFactor(_ context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.twoFactor[userID]; !ok {
+		return ErrNotFound
+	}
+	delete(s.twoFactor, userID)
+	return nil
+}
+
+// ClaimTwoFactorStep records step as used unless it has been used already.
+func (s *InMemoryStore) ClaimTwoFactorStep(_ context.Context, userID string, step int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tf, ok := s.twoFactor[userID]
+	if !ok || tf.LastStep >= step {
+		return false, nil
+	}
+	tf.LastStep = step
+	s.twoFactor[userID] = tf
+	return true, nil
+}
+
+// UseRecoveryCode removes the code hash if the user still has it.
+func (s *InMemoryStore) UseRecoveryCode(_ context.Context, userID, codeHash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tf, ok := s.twoFactor[userID]
+	if !ok {
+		return false, nil
+	}
+	i := slices.Index(tf.RecoveryCodes, codeHash)
+	if i < 0 {
+		return false, nil
+	}
+	tf.RecoveryCodes = slices.Delete(append([]string{}, tf.RecoveryCodes...), i, i+1)
+	s.twoFactor[userID] = tf
+	return true, nil
+}
+
 // CreateUser stores a new user in memory.
 func (s *InMemoryStore) CreateUser(_ context.Context, user User) (User, error) {
 	s.mu.Lock()
@@ -245,8 +561,17 @@ func (s *InMemoryStore) CreateUser(_ context.Context, user User) (User, error) {
 	if user.CreatedAt.IsZero() {
 		user.CreatedAt = time.Now()
 	}
+	if user.OrgID == "" {
+		user.OrgID = DefaultOrganizationID
+	}
+	role, err := ParseRole(string(user.Role))
+	if err != nil {
+		return User{}, err
+	}
+	user.Role = role
 	user.Email = email
 	user.Approved = false
+	user.EmailVerified = false
 	s.users[user.ID] = user
 	s.emailIndex[email] = user.ID
 	return user, nil
@@ -291,7 +616,69 @@ func (s *InMemoryStore) ApproveUser(_ context.Context, id string, approved bool)
 	return nil
 }
 
-// ListUsers returns all users.
+// SetUserOrganization moves a user to another organization.
+func (s *InMemoryStore) SetUserOrganization(_ context.Context, userID, orgID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.organizations[orgID]; !ok {
+		return ErrNotFound
+	}
+	user, ok := s.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	user.OrgID = orgID
+	s.users[userID] = user
+	return nil
+}
+
+// SetUserRole changes a user's role.
+func (s *InMemoryStore) SetUserRole(_ context.Context, userID string, role Role) error {
+	if !role.Valid() {
+		return fmt.Errorf("unknown role %q", role)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	user.Role = role
+	s.users[userID] = user
+	return nil
+}
+
+// SetUserPassword replaces the stored password hash.
+func (s *InMemoryStore) SetUserPassword(_ context.Context, userID, passwordHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	user.PasswordHash = passwordHash
+	s.users[userID] = user
+	return nil
+}
+
+// SetEmailVerified records whether the user has confirmed their e-mail address.
+func (s *InMemoryStore) SetEmailVerified(_ context.Context, userID string, verified bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	user.EmailVerified = verified
+	s.users[userID] = user
+	return nil
+}
+
+// ListUsers returns all users, newest first.
 func (s *InMemoryStore) ListUsers(_ context.Context) ([]User, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -299,6 +686,9 @@ func (s *InMemoryStore) ListUsers(_ context.Context) ([]User, error) {
 	for _, u := range s.users {
 		users = append(users, u)
 	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].CreatedAt.After(users[j].CreatedAt)
+	})
 	return users, nil
 }
 
@@ -311,5 +701,315 @@ func (s *InMemoryStore) DeleteUser(_ context.Context, id string) error {
 	}
 	delete(s.emailIndex, strings.ToLower(s.users[id].Email))
 	delete(s.users, id)
+	for sid, session := range s.sessions {
+		if session.UserID == id {
+			delete(s.sessions, sid)
+		}
+	}
+	for tid, token := range s.apiTokens {
+		if token.UserID == id {
+			delete(s.apiTokens, tid)
+		}
+	}
+	delete(s.twoFactor, id)
+	return nil
+}
+
+// CreateSession stores a new session for an existing user.
+func (s *InMemoryStore) CreateSession(_ context.Context, session Session) (Session, error) {
+	session, err := prepareSession(session)
+	if err != nil {
+		return Session{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[session.UserID]; !ok {
+		return Session{}, ErrNotFound
+	}
+	s.sessions[session.ID] = session
+	return session, nil
+}
+
+// GetSession fetches a session by id.
+func (s *InMemoryStore) GetSession(_ context.Context, id string) (Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return Session{}, ErrNotFound
+	}
+	return session, nil
+}
+
+// TouchSession records activity and slides the expiry forward.
+func (s *InMemoryStore) TouchSession(_ context.Context, id string, lastSeenAt, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return ErrNotFound
+	}
+	session.LastSeenAt = lastSeenAt
+	session.ExpiresAt = expiresAt
+	s.sessions[id] = session
+	return nil
+}
+
+// ListSessions returns the user's unexpired sessions, most recently used first.
+func (s *InMemoryStore) ListSessions(_ context.Context, userID string) ([]Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	sessions := make([]Session, 0)
+	for _, session := range s.sessions {
+		if session.UserID == userID && session.ExpiresAt.After(now) {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+	return sessions, nil
+}
+
+// DeleteSession revokes one of the user's sessions.
+func (s *InMemoryStore) DeleteSession(_ context.Context, userID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok || session.UserID != userID {
+		return ErrNotFound
+	}
+	delete(s.sessions, id)
 	return nil
 }
+
+// DeleteUserSessions revokes all of the user's sessions except keepID.
+func (s *InMemoryStore) DeleteUserSessions(_ context.Context, userID, keepID string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for id, session := range s.sessions {
+		if session.UserID == userID && id != keepID {
+			delete(s.sessions, id)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// CreateAPIToken stores a new token for an existing user.
+func (s *InMemoryStore) CreateAPIToken(_ context.Context, token APIToken) (APIToken, error) {
+	token, err := prepareAPIToken(token)
+	if err != nil {
+		return APIToken{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[token.UserID]; !ok {
+		return APIToken{}, ErrNotFound
+	}
+	token.Scopes = append([]string(nil), token.Scopes...)
+	s.apiTokens[token.ID] = token
+	return token, nil
+}
+
+// GetAPITokenByHash fetches a token by the hash of its secret.
+func (s *InMemoryStore) GetAPITokenByHash(_ context.Context, tokenHash string) (APIToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, token := range s.apiTokens {
+		if token.TokenHash == tokenHash {
+			return token, nil
+		}
+	}
+	return APIToken{}, ErrNotFound
+}
+
+// ListAPITokens returns the user's tokens, newest first.
+func (s *InMemoryStore) ListAPITokens(_ context.Context, userID string) ([]APIToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tokens := make([]APIToken, 0)
+	for _, token := range s.apiTokens {
+		if token.UserID == userID {
+			tokens = append(tokens, token)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.After(tokens[j].CreatedAt)
+	})
+	return tokens, nil
+}
+
+// DeleteAPIToken revokes one of the user's tokens.
+func (s *InMemoryStore) DeleteAPIToken(_ context.Context, userID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.apiTokens[id]
+	if !ok || token.UserID != userID {
+		return ErrNotFound
+	}
+	delete(s.apiTokens, id)
+	return nil
+}
+
+// TouchAPIToken records when the token was last used.
+func (s *InMemoryStore) TouchAPIToken(_ context.Context, id string, usedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.apiTokens[id]
+	if !ok {
+		return ErrNotFound
+	}
+	token.LastUsedAt = &usedAt
+	s.apiTokens[id] = token
+	return nil
+}
+
+// PurgeExpiredSessions removes sessions that expired before the given time.
+func (s *InMemoryStore) PurgeExpiredSessions(_ context.Context, expiredBefore time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for id, session := range s.sessions {
+		if session.ExpiresAt.Before(expiredBefore) {
+			delete(s.sessions, id)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// UpdateRateLimit applies fn to the key's state under the store lock.
+func (s *InMemoryStore) UpdateRateLimit(_ context.Context, key string, fn func(RateLimit) RateLimit) (RateLimit, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.rateLimits[key]
+	if !ok {
+		current = RateLimit{Key: key}
+	}
+	next := fn(current)
+	next.Key = key
+	if next.UpdatedAt.IsZero() {
+		delete(s.rateLimits, key)
+	} else {
+		s.rateLimits[key] = next
+	}
+	return next, nil
+}
+
+// PurgeRateLimits removes keys idle since before.
+func (s *InMemoryStore) PurgeRateLimits(_ context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for key, limit := range s.rateLimits {
+		if limit.UpdatedAt.Before(before) && (limit.LockedUntil == nil || limit.LockedUntil.Before(before)) {
+			delete(s.rateLimits, key)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// RecordAuditEvent appends the event to the in-memory log.
+func (s *InMemoryStore) RecordAuditEvent(_ context.Context, event AuditEvent) (AuditEvent, error) {
+	event, err := prepareAuditEvent(event)
+	if err != nil {
+		return AuditEvent{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.auditEvents = append(s.auditEvents, event)
+	return event, nil
+}
+
+// ListAuditEvents returns one page of matching events, newest first.
+func (s *InMemoryStore) ListAuditEvents(_ context.Context, query AuditQuery) (AuditPage, error) {
+	q := query.normalize()
+	s.mu.RLock()
+	var matching []AuditEvent
+	for i := len(s.auditEvents) - 1; i >= 0; i-- {
+		if q.matches(s.auditEvents[i]) {
+			matching = append(matching, s.auditEvents[i])
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.SliceStable(matching, func(i, j int) bool {
+		return matching[i].CreatedAt.After(matching[j].CreatedAt)
+	})
+	page := AuditPage{Page: q.Page, PageSize: q.PageSize, Total: len(matching), Events: []AuditEvent{}}
+	if start := q.offset(); start < len(matching) {
+		page.Events = matching[start:min(start+q.PageSize, len(matching))]
+	}
+	return page, nil
+}
+
+// RecordLLMUsage appends the call to the in-memory usage log.
+func (s *InMemoryStore) RecordLLMUsage(_ context.Context, usage LLMUsage) (LLMUsage, error) {
+	usage, err := prepareLLMUsage(usage)
+	if err != nil {
+		return LLMUsage{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.llmUsage = append(s.llmUsage, usage)
+	return usage, nil
+}
+
+// SummarizeLLMUsage sums matching calls per UTC day, organization, model and operation.
+func (s *InMemoryStore) SummarizeLLMUsage(_ context.Context, query UsageQuery) ([]UsageDay, error) {
+	type key struct{ day, org, model, operation string }
+	totals := make(map[key]*UsageDay)
+	s.mu.RLock()
+	for _, u := range s.llmUsage {
+		if !query.matches(u) {
+			continue
+		}
+		k := key{u.CreatedAt.UTC().Format(usageDayLayout), u.OrgID, u.Model, u.Operation}
+		day, ok := totals[k]
+		if !ok {
+			day = &UsageDay{Day: k.day, OrgID: k.org, Model: k.model, Operation: k.operation}
+			totals[k] = day
+		}
+		day.Requests++
+		day.PromptTokens += int64(u.PromptTokens)
+		day.CompletionTokens += int64(u.CompletionTokens)
+	}
+	s.mu.RUnlock()
+
+	days := make([]UsageDay, 0, len(totals))
+	for _, day := range totals {
+		days = append(days, *day)
+	}
+	sort.Slice(days, func(i, j int) bool {
+		a, b := days[i], days[j]
+		if a.Day != b.Day {
+			return a.Day < b.Day
+		}
+		if a.OrgID != b.OrgID {
+			return a.OrgID < b.OrgID
+		}
+		if a.Model != b.Model {
+			return a.Model < b.Model
+		}
+		return a.Operation < b.Operation
+	})
+	return days, nil
+}
@@ -0,0 +1,415 @@
+package storage
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockKey is the pg_advisory_lock key guarding schema changes so that
+// several API replicas booting at once do not apply the same migration twice.
+const migrationLockKey int64 = 0x6b326d6967726174 // "k2migrat"
+
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)(?:\.([a-z]+))?\.(up|down)\.sql$`)
+
+// Migration dialects. Generic migration files are PostgreSQL; SQLite reads a
+// NNNN_name.sqlite.up.sql variant instead wherever one exists.
+const (
+	dialectPostgres = "postgres"
+	dialectSQLite   = "sqlite"
+)
+
+// ErrUnknownMigration indicates that a requested target version does not exist.
+var ErrUnknownMigration = errors.New("unknown migration version")
+
+// Migration is a numbered, reversible schema change.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a migration has been applied.
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// Applied reports whether the migration has been run against the database.
+func (s MigrationStatus) Applied() bool {
+	return s.AppliedAt != nil
+}
+
+// Migrator applies and reverts the embedded SQL migrations.
+type Migrator struct {
+	driver     migrationDriver
+	migrations []Migration
+}
+
+// migrationDriver hides the database specific parts of running migrations.
+type migrationDriver interface {
+	// session runs fn on a single connection with schema_migrations in place.
+	// When lock is set, concurrent migrators are kept out until fn returns.
+	session(ctx context.Context, lock bool, fn func(migrationConn) error) error
+	close()
+}
+
+// migrationConn records and runs migrations on one connection.
+type migrationConn interface {
+	applied(ctx context.Context) (map[int]time.Time, error)
+	apply(ctx context.Context, mig Migration) error
+	revert(ctx context.Context, mig Migration) error
+}
+
+// NewMigrator connects to PostgreSQL or SQLite (sqlite:// URLs) and prepares
+// the embedded migration set for that database.
+func NewMigrator(ctx context.Context, databaseURL string) (*Migrator, error) {
+	if databaseURL == "" {
+		return nil, fmt.Errorf("database url is required for migrations")
+	}
+	if isSQLiteURL(databaseURL) {
+		db, err := openSQLite(ctx, databaseURL)
+		if err != nil {
+			return nil, err
+		}
+		m, err := newSQLiteMigrator(db)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		m.driver = sqliteMigrationDriver{db: db, ownsDB: true}
+		return m, nil
+	}
+
+	pool, err := pgxpool.New(ctx, databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("create pool: %w", err)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("ping database: %w", err)
+	}
+	m, err := newPostgresMigrator(pool)
+	if err != nil {
+		pool.Close()
+		return nil, err
+	}
+	m.driver = pgMigrationDriver{pool: pool, ownsPool: true}
+	return m, nil
+}
+
+func newPostgresMigrator(pool *pgxpool.Pool) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationFiles, "migrations", dialectPostgres)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{driver: pgMigrationDriver{pool: pool}, migrations: migrations}, nil
+}
+
+// Close releases the database connection when the migrator opened it.
+func (m *Migrator) Close() {
+	m.driver.close()
+}
+
+// Migrations returns the known migrations ordered by version.
+func (m *Migrator) Migrations() []Migration {
+	return append([]Migration(nil), m.migrations...)
+}
+
+// Status lists every known migration together with its applied timestamp.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var applied map[int]time.Time
+	err := m.driver.session(ctx, false, func(conn migrationConn) error {
+		var err error
+		applied, err = conn.applied(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		status := MigrationStatus{Version: mig.Version, Name: mig.Name}
+		if at, ok := applied[mig.Version]; ok {
+			t := at
+			status.AppliedAt = &t
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Version returns the highest applied migration version (0 when none).
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+	current := 0
+	for _, s := range statuses {
+		if s.Applied() && s.Version > current {
+			current = s.Version
+		}
+	}
+	return current, nil
+}
+
+// Up applies every pending migration in order.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	if len(m.migrations) == 0 {
+		return 0, nil
+	}
+	return m.To(ctx, m.migrations[len(m.migrations)-1].Version)
+}
+
+// Down reverts the given number of most recently applied migrations.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	if steps <= 0 {
+		return 0, nil
+	}
+	count := 0
+	err := m.driver.session(ctx, true, func(conn migrationConn) error {
+		applied, err := conn.applied(ctx)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && count < steps; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			if err := conn.revert(ctx, mig); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// To migrates up or down until exactly the migrations <= version are applied.
+// Version 0 reverts everything.
+func (m *Migrator) To(ctx context.Context, version int) (int, error) {
+	if version != 0 && !m.hasVersion(version) {
+		return 0, fmt.Errorf("%w: %d", ErrUnknownMigration, version)
+	}
+	count := 0
+	err := m.driver.session(ctx, true, func(conn migrationConn) error {
+		applied, err := conn.applied(ctx)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if mig.Version <= version {
+				break
+			}
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			if err := conn.revert(ctx, mig); err != nil {
+				return err
+			}
+			count++
+		}
+		for _, mig := range m.migrations {
+			if mig.Version > version {
+				break
+			}
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+			if err := conn.apply(ctx, mig); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+func (m *Migrator) hasVersion(version int) bool {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// pgMigrationDriver runs migrations against PostgreSQL.
+type pgMigrationDriver struct {
+	pool     *pgxpool.Pool
+	ownsPool bool
+}
+
+func (d pgMigrationDriver) close() {
+	if d.ownsPool && d.pool != nil {
+		d.pool.Close()
+	}
+}
+
+// session runs fn on a dedicated connection, holding the migration advisory lock when asked to.
+func (d pgMigrationDriver) session(ctx context.Context, lock bool, fn func(migrationConn) error) error {
+	conn, err := d.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	if lock {
+		if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey); err != nil {
+			return fmt.Errorf("acquire migration lock: %w", err)
+		}
+		defer func() {
+			// Use a fresh context so the lock is released even if ctx was cancelled.
+			_, _ = conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockKey)
+		}()
+	}
+
+	if _, err := conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`); err != nil {
+		return fmt.Errorf("create schema_migrations table: %w", err)
+	}
+	return fn(pgMigrationConn{conn: conn.Conn()})
+}
+
+type pgMigrationConn struct {
+	conn *pgx.Conn
+}
+
+func (c pgMigrationConn) applied(ctx context.Context) (map[int]time.Time, error) {
+	rows, err := c.conn.Query(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("query schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var (
+			version int
+			at      time.Time
+		)
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, fmt.Errorf("scan schema_migrations: %w", err)
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+func (c pgMigrationConn) apply(ctx context.Context, mig Migration) error {
+	return pgx.BeginFunc(ctx, c.conn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, mig.Up); err != nil {
+			return fmt.Errorf("apply migration %04d_%s: %w", mig.Version, mig.Name, err)
+		}
+		if _, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, mig.Version, mig.Name); err != nil {
+			return fmt.Errorf("record migration %04d_%s: %w", mig.Version, mig.Name, err)
+		}
+		return nil
+	})
+}
+
+func (c pgMigrationConn) revert(ctx context.Context, mig Migration) error {
+	if mig.Down == "" {
+		return fmt.Errorf("migration %04d_%s has no down step", mig.Version, mig.Name)
+	}
+	return pgx.BeginFunc(ctx, c.conn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, mig.Down); err != nil {
+			return fmt.Errorf("revert migration %04d_%s: %w", mig.Version, mig.Name, err)
+		}
+		if _, err := tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version=$1`, mig.Version); err != nil {
+			return fmt.Errorf("unrecord migration %04d_%s: %w", mig.Version, mig.Name, err)
+		}
+		return nil
+	})
+}
+
+// loadMigrations reads NNNN_name.up.sql / NNNN_name.down.sql pairs from dir.
+// A file named NNNN_name.<dialect>.up.sql replaces the generic step for that
+// dialect only; the generic files are written for PostgreSQL.
+func loadMigrations(fsys fs.FS, dir, dialect string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	overridden := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file name %q", entry.Name())
+		}
+		version, err := strconv.Atoi(match[1])
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version in %q", entry.Name())
+		}
+		fileDialect, direction := match[3], match[4]
+		if fileDialect != "" && fileDialect != dialectSQLite {
+			return nil, fmt.Errorf("unknown migration dialect in %q", entry.Name())
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = mig
+		} else if mig.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, mig.Name, match[2])
+		}
+
+		step := fmt.Sprintf("%d.%s", version, direction)
+		switch {
+		case fileDialect != "" && fileDialect == dialect:
+			overridden[step] = true
+		case fileDialect != "" || overridden[step]:
+			continue
+		}
+		body, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", entry.Name(), err)
+		}
+		if direction == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migration %04d_%s is missing its up step", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
@@ -0,0 +1,31 @@
+-- JSON columns are stored as TEXT holding the same documents Postgres keeps in JSONB.
+-- Timestamps are fixed-width UTC RFC 3339 strings so they sort as text.
+CREATE TABLE IF NOT EXISTS listings (
+    id TEXT PRIMARY KEY,
+    owner_id TEXT,
+    address TEXT NOT NULL,
+    neighborhood TEXT,
+    city TEXT,
+    property_type TEXT,
+    condition TEXT,
+    balcony INTEGER,
+    floor TEXT,
+    association TEXT,
+    length TEXT,
+    tone TEXT NOT NULL,
+    target_audience TEXT NOT NULL,
+    highlights TEXT NOT NULL DEFAULT '[]',
+    image_url TEXT,
+    fee INTEGER,
+    living_area REAL,
+    rooms REAL,
+    sections TEXT NOT NULL DEFAULT '[]',
+    full_copy TEXT,
+    section_history TEXT NOT NULL DEFAULT '{}',
+    pipeline_status TEXT NOT NULL DEFAULT '{}',
+    details TEXT NOT NULL DEFAULT '{}',
+    insights TEXT NOT NULL DEFAULT '{}',
+    created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS listings_owner_created_idx ON listings (owner_id, created_at);
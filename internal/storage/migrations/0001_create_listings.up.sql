@@ -0,0 +1,48 @@
+CREATE TABLE IF NOT EXISTS listings (
+    id TEXT PRIMARY KEY,
+    owner_id TEXT,
+    address TEXT NOT NULL,
+    neighborhood TEXT,
+    city TEXT,
+    property_type TEXT,
+    condition TEXT,
+    balcony BOOLEAN,
+    floor TEXT,
+    association TEXT,
+    length TEXT,
+    tone TEXT NOT NULL,
+    target_audience TEXT NOT NULL,
+    highlights TEXT[],
+    image_url TEXT,
+    fee INTEGER,
+    living_area DOUBLE PRECISION,
+    rooms DOUBLE PRECISION,
+    sections JSONB DEFAULT '[]'::jsonb,
+    full_copy TEXT,
+    section_history JSONB DEFAULT '{}'::jsonb,
+    pipeline_status JSONB DEFAULT '{}'::jsonb,
+    details JSONB DEFAULT '{}'::jsonb,
+    insights JSONB DEFAULT '{}'::jsonb,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Installations created before versioned migrations may lack some columns.
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS neighborhood TEXT;
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS owner_id TEXT;
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS city TEXT;
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS property_type TEXT;
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS condition TEXT;
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS balcony BOOLEAN;
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS floor TEXT;
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS association TEXT;
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS length TEXT;
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS image_url TEXT;
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS fee INTEGER;
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS living_area DOUBLE PRECISION;
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS rooms DOUBLE PRECISION;
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS sections JSONB DEFAULT '[]'::jsonb;
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS full_copy TEXT;
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS section_history JSONB DEFAULT '{}'::jsonb;
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS pipeline_status JSONB DEFAULT '{}'::jsonb;
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS details JSONB DEFAULT '{}'::jsonb;
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS insights JSONB DEFAULT '{}'::jsonb;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS style_profiles (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    description TEXT,
+    tone TEXT,
+    guidelines TEXT,
+    example_texts TEXT NOT NULL DEFAULT '[]',
+    forbidden_words TEXT NOT NULL DEFAULT '[]',
+    custom_model TEXT,
+    dataset_uri TEXT,
+    last_trained_at TEXT,
+    created_at TEXT NOT NULL,
+    updated_at TEXT NOT NULL
+);
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS style_profiles (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    description TEXT,
+    tone TEXT,
+    guidelines TEXT,
+    example_texts TEXT[],
+    forbidden_words TEXT[],
+    custom_model TEXT,
+    dataset_uri TEXT,
+    last_trained_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE style_profiles ADD COLUMN IF NOT EXISTS custom_model TEXT;
+ALTER TABLE style_profiles ADD COLUMN IF NOT EXISTS dataset_uri TEXT;
+ALTER TABLE style_profiles ADD COLUMN IF NOT EXISTS last_trained_at TIMESTAMPTZ;
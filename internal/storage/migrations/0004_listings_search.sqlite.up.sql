@@ -0,0 +1,2 @@
+-- The SQLite store searches listings in Go, so there is no search index to build.
+SELECT 1;
@@ -0,0 +1,11 @@
+-- Full-text search over address, city, section content and full_copy using the
+-- Swedish text-search configuration. Weights rank address/city hits above body text.
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('swedish', COALESCE(address, '')), 'A') ||
+        setweight(to_tsvector('swedish', COALESCE(city, '') || ' ' || COALESCE(details->'property'->>'city', '')), 'B') ||
+        setweight(jsonb_to_tsvector('swedish', COALESCE(sections, '[]'::jsonb), '["string"]'), 'C') ||
+        setweight(to_tsvector('swedish', COALESCE(full_copy, '')), 'D')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS listings_search_vector_idx ON listings USING GIN (search_vector);
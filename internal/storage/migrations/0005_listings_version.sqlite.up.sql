@@ -0,0 +1 @@
+ALTER TABLE listings ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
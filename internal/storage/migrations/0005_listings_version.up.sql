@@ -0,0 +1,2 @@
+-- Row version for optimistic concurrency control on listing updates.
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,2 @@
+ALTER TABLE listings ADD COLUMN deleted_at TEXT;
+CREATE INDEX IF NOT EXISTS listings_deleted_at_idx ON listings (deleted_at) WHERE deleted_at IS NOT NULL;
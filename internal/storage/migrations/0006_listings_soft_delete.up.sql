@@ -0,0 +1,3 @@
+-- Soft delete: trashed listings keep their row until the purge job removes them.
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS listings_deleted_at_idx ON listings (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,2 @@
+-- section_history is not touched by the up migration, so rolling back restores the history as it was before the upgrade.
+DROP TABLE IF EXISTS listing_revisions;
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS listing_revisions (
+    id TEXT PRIMARY KEY,
+    listing_id TEXT NOT NULL REFERENCES listings(id) ON DELETE CASCADE,
+    slug TEXT NOT NULL,
+    revision INTEGER NOT NULL,
+    title TEXT NOT NULL DEFAULT '',
+    content TEXT NOT NULL DEFAULT '',
+    source TEXT NOT NULL DEFAULT '',
+    instruction TEXT,
+    tone TEXT,
+    target_audience TEXT,
+    highlights TEXT NOT NULL DEFAULT '[]',
+    notes TEXT,
+    author_id TEXT,
+    created_at TEXT NOT NULL,
+    UNIQUE (listing_id, slug, revision)
+);
@@ -0,0 +1,39 @@
+-- Section revisions move out of the section_history JSONB blob into their own table.
+CREATE TABLE IF NOT EXISTS listing_revisions (
+    id TEXT PRIMARY KEY,
+    listing_id TEXT NOT NULL REFERENCES listings(id) ON DELETE CASCADE,
+    slug TEXT NOT NULL,
+    revision INTEGER NOT NULL,
+    title TEXT NOT NULL DEFAULT '',
+    content TEXT NOT NULL DEFAULT '',
+    source TEXT NOT NULL DEFAULT '',
+    instruction TEXT,
+    tone TEXT,
+    target_audience TEXT,
+    highlights TEXT[],
+    notes TEXT,
+    author_id TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (listing_id, slug, revision)
+);
+
+-- Backfill from the legacy blob, where each slug holds up to five entries newest first.
+INSERT INTO listing_revisions (id, listing_id, slug, revision, title, content, source, instruction, tone, target_audience, highlights, notes, created_at)
+SELECT
+    gen_random_uuid()::text,
+    l.id,
+    h.slug,
+    jsonb_array_length(h.entries) - e.ord + 1,
+    COALESCE(e.entry->>'title', ''),
+    COALESCE(e.entry->>'content', ''),
+    COALESCE(e.entry->>'source', ''),
+    NULLIF(e.entry->>'instruction', ''),
+    NULLIF(e.entry->>'tone', ''),
+    NULLIF(e.entry->>'target_audience', ''),
+    ARRAY(SELECT jsonb_array_elements_text(CASE WHEN jsonb_typeof(e.entry->'highlights') = 'array' THEN e.entry->'highlights' ELSE '[]'::jsonb END)),
+    NULLIF(e.entry->>'notes', ''),
+    COALESCE((e.entry->>'timestamp')::timestamptz, l.created_at)
+FROM listings l
+CROSS JOIN LATERAL jsonb_each(CASE WHEN jsonb_typeof(l.section_history) = 'object' THEN l.section_history ELSE '{}'::jsonb END) AS h(slug, entries)
+CROSS JOIN LATERAL jsonb_array_elements(CASE WHEN jsonb_typeof(h.entries) = 'array' THEN h.entries ELSE '[]'::jsonb END) WITH ORDINALITY AS e(entry, ord)
+ON CONFLICT (listing_id, slug, revision) DO NOTHING;
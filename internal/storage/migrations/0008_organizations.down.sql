@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS style_profiles_org_id_idx;
+DROP INDEX IF EXISTS listings_org_id_idx;
+DROP INDEX IF EXISTS users_org_id_idx;
+ALTER TABLE style_profiles DROP COLUMN IF EXISTS org_id;
+ALTER TABLE listings DROP COLUMN IF EXISTS org_id;
+ALTER TABLE users DROP COLUMN IF EXISTS org_id;
+DROP TABLE IF EXISTS organizations;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS organizations (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    created_at TEXT NOT NULL
+);
+
+INSERT OR IGNORE INTO organizations (id, name, created_at) VALUES ('default', 'Standardbyrå', strftime('%Y-%m-%dT%H:%M:%fZ', 'now'));
+
+ALTER TABLE users ADD COLUMN org_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE listings ADD COLUMN org_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE style_profiles ADD COLUMN org_id TEXT NOT NULL DEFAULT 'default';
+
+CREATE INDEX IF NOT EXISTS users_org_id_idx ON users (org_id);
+CREATE INDEX IF NOT EXISTS listings_org_id_idx ON listings (org_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS style_profiles_org_id_idx ON style_profiles (org_id);
@@ -0,0 +1,17 @@
+-- Organizations (brokerage offices) own users, listings and style profiles.
+-- Everything created before this migration moves into the default organization.
+CREATE TABLE IF NOT EXISTS organizations (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+INSERT INTO organizations (id, name) VALUES ('default', 'Standardbyrå') ON CONFLICT (id) DO NOTHING;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS org_id TEXT NOT NULL DEFAULT 'default' REFERENCES organizations(id);
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS org_id TEXT NOT NULL DEFAULT 'default' REFERENCES organizations(id);
+ALTER TABLE style_profiles ADD COLUMN IF NOT EXISTS org_id TEXT NOT NULL DEFAULT 'default' REFERENCES organizations(id);
+
+CREATE INDEX IF NOT EXISTS users_org_id_idx ON users (org_id);
+CREATE INDEX IF NOT EXISTS listings_org_id_idx ON listings (org_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS style_profiles_org_id_idx ON style_profiles (org_id);
@@ -0,0 +1,3 @@
+-- Roles: admin, office_manager, broker, assistant. Existing accounts keep full
+-- access to their own listings as brokers.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'broker';
@@ -0,0 +1,3 @@
+-- Accounts approved before e-mail verification existed were vetted by hand.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified BOOLEAN NOT NULL DEFAULT false;
+UPDATE users SET email_verified = true WHERE approved;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS user_two_factor;
+ALTER TABLE organizations DROP COLUMN IF EXISTS require_two_factor;
@@ -0,0 +1,14 @@
+-- TOTP two-factor authentication. enabled stays false until the user confirms
+-- the first code; recovery_codes holds SHA-256 hashes of the unused codes and
+-- last_step the newest accepted time step, so a code cannot be replayed.
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS require_two_factor BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS user_two_factor (
+    user_id TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT false,
+    recovery_codes TEXT[] NOT NULL DEFAULT '{}',
+    last_step BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    enabled_at TIMESTAMPTZ
+);
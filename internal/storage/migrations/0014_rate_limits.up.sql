@@ -0,0 +1,12 @@
+-- Shared state for the auth rate limiter when several API instances run
+-- against one database. Each key is either a token bucket (tokens refill since
+-- updated_at) or a lockout counter (failures, locked_until).
+CREATE TABLE IF NOT EXISTS rate_limits (
+    key TEXT PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL DEFAULT 0,
+    failures INTEGER NOT NULL DEFAULT 0,
+    locked_until TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS rate_limits_updated_at_idx ON rate_limits (updated_at);
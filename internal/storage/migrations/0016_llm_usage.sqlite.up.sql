@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS llm_usage (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL DEFAULT '',
+    org_id TEXT NOT NULL DEFAULT '',
+    listing_id TEXT NOT NULL DEFAULT '',
+    operation TEXT NOT NULL,
+    model TEXT NOT NULL,
+    prompt_tokens INTEGER NOT NULL DEFAULT 0,
+    completion_tokens INTEGER NOT NULL DEFAULT 0,
+    created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS llm_usage_created_at_idx ON llm_usage (created_at);
+CREATE INDEX IF NOT EXISTS llm_usage_org_idx ON llm_usage (org_id, created_at);
+CREATE INDEX IF NOT EXISTS llm_usage_user_idx ON llm_usage (user_id, created_at);
@@ -20,13 +20,29 @@ type PostgresStore struct {
 	pool *pgxpool.Pool
 }
 
-const listingColumns = "id, owner_id, address, tone, target_audience, highlights, image_url, fee, living_area, rooms, sections, full_copy, section_history, pipeline_status, details, insights, created_at"
+const (
+	styleProfileColumns = "id, org_id, name, description, tone, guidelines, example_texts, forbidden_words, custom_model, dataset_uri, last_trained_at, created_at, updated_at"
+	userColumns         = "id, email, password_hash, org_id, role, approved, email_verified, created_at"
+	sessionColumns      = "id, user_id, created_at, last_seen_at, expires_at, user_agent, ip"
+	apiTokenColumns     = "id, user_id, name, prefix, token_hash, scopes, created_at, last_used_at, expires_at"
+	auditEventColumns   = "id, org_id, actor_id, actor_email, action, entity_type, entity_id, changes, request_id, ip, created_at"
+	llmUsageColumns     = "id, user_id, org_id, listing_id, operation, model, prompt_tokens, completion_tokens, created_at"
+)
+
+const listingColumns = "id, owner_id, org_id, address, neighborhood, city, property_type, condition, balcony, floor, association, length, tone, target_audience, highlights, image_url, fee, living_area, rooms, sections, full_copy, pipeline_status, details, insights, created_at, version, deleted_at"
 
-// CreateListing stores the provided listing in PostgreSQL.
-func (s *PostgresStore) CreateListing(ctx context.Context, input Listing) (Listing, error) {
+// CreateListing stores the provided listing and its first revisions in PostgreSQL.
+func (s *PostgresStore) CreateListing(ctx context.Context, input Listing, revisions []Revision) (Listing, error) {
 	if input.ID == "" {
 		input.ID = uuid.NewString()
 	}
+	if input.CreatedAt.IsZero() {
+		input.CreatedAt = time.Now()
+	}
+	if input.OrgID == "" {
+		input.OrgID = DefaultOrganizationID
+	}
+	input.Version = 1
 
 	sectionsJSON, err := json.Marshal(input.Sections)
 	if err != nil {
@@ -37,11 +53,6 @@ func (s *PostgresStore) CreateListing(ctx context.Context, input Listing) (Listi
 	if err != nil {
 		return Listing{}, fmt.Errorf("marshal insights: %w", err)
 	}
-	historyJSON, err := json.Marshal(input.History)
-	if err != nil {
-		return Listing{}, fmt.Errorf("marshal history: %w", err)
-	}
-
 	statusJSON, err := json.Marshal(input.Status)
 	if err != nil {
 		return Listing{}, fmt.Errorf("marshal status: %w", err)
@@ -52,28 +63,176 @@ func (s *PostgresStore) CreateListing(ctx context.Context, input Listing) (Listi
 		return Listing{}, fmt.Errorf("marshal details: %w", err)
 	}
 
-	if _, err := s.pool.Exec(ctx,
-		`INSERT INTO listings (id, owner_id, address, tone, target_audience, highlights, image_url, fee, living_area, rooms, sections, full_copy, section_history, pipeline_status, details, insights, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)`,
-		input.ID, input.OwnerID, input.Address, input.Tone, input.TargetAudience, input.Highlights, input.ImageURL, input.Fee, input.LivingArea, input.Rooms, sectionsJSON, input.FullCopy, historyJSON, statusJSON, detailsJSON, insightsJSON, input.CreatedAt); err != nil {
-		return Listing{}, fmt.Errorf("insert listing: %w", err)
+	err = pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO listings (id, owner_id, org_id, address, neighborhood, city, property_type, condition, balcony, floor, association, length, tone, target_audience, highlights, image_url, fee, living_area, rooms, sections, full_copy, pipeline_status, details, insights, created_at, version) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26)`,
+			input.ID, input.OwnerID, input.OrgID, input.Address, nullString(input.Neighborhood), nullString(input.City), nullString(input.PropertyType), nullString(input.Condition), input.Balcony, nullString(input.Floor), nullString(input.Association), nullString(input.Length), input.Tone, input.TargetAudience, input.Highlights, input.ImageURL, input.Fee, input.LivingArea, input.Rooms, sectionsJSON, input.FullCopy, statusJSON, detailsJSON, insightsJSON, input.CreatedAt, input.Version); err != nil {
+			return fmt.Errorf("insert listing: %w", err)
+		}
+		return insertRevisions(ctx, tx, input.ID, revisions)
+	})
+	if err != nil {
+		return Listing{}, err
 	}
 
-	return input, nil
+	return s.withHistory(ctx, input)
 }
 
-// ListListings returns a slice of the most recent listings.
+// ListListings returns the DefaultListingLimit most recent listings.
 func (s *PostgresStore) ListListings(ctx context.Context) ([]Listing, error) {
-	return s.fetchListings(ctx, `SELECT `+listingColumns+` FROM listings ORDER BY created_at DESC LIMIT 50`)
+	return s.fetchListings(ctx, `SELECT `+listingColumns+` FROM listings WHERE deleted_at IS NULL ORDER BY created_at DESC LIMIT $1`, DefaultListingLimit)
+}
+
+// pipelineStateSQL mirrors Status.Overall so listings can be filtered and sorted by pipeline state.
+const pipelineStateSQL = `(CASE
+	WHEN 'failed' IN (pipeline_status->>'data', pipeline_status->>'vision', pipeline_status->>'geodata', pipeline_status->>'text') THEN 'failed'
+	WHEN COALESCE(pipeline_status->>'data', '') IN ('completed', 'skipped')
+		AND COALESCE(pipeline_status->>'vision', '') IN ('completed', 'skipped')
+		AND COALESCE(pipeline_status->>'geodata', '') IN ('completed', 'skipped')
+		AND COALESCE(pipeline_status->>'text', '') IN ('completed', 'skipped') THEN 'completed'
+	WHEN 'in_progress' IN (pipeline_status->>'data', pipeline_status->>'vision', pipeline_status->>'geodata', pipeline_status->>'text') THEN 'in_progress'
+	ELSE 'pending'
+END)`
+
+// QueryListings returns one page of listings matching the query filters.
+func (s *PostgresStore) QueryListings(ctx context.Context, query ListListingsQuery) (ListingPage, error) {
+	q := query.normalize()
+	cursor, err := decodeCursor(q.Cursor, q)
+	if err != nil {
+		return ListingPage{}, err
+	}
+
+	var (
+		where = []string{"deleted_at IS NULL"}
+		args  []any
+	)
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if q.OrgID != "" {
+		where = append(where, "org_id="+arg(q.OrgID))
+	}
+	if q.OwnerID != "" {
+		where = append(where, "owner_id="+arg(q.OwnerID))
+	}
+	if q.City != "" {
+		where = append(where, "lower(COALESCE(NULLIF(city, ''), details->'property'->>'city', ''))=lower("+arg(q.City)+")")
+	}
+	if q.PropertyType != "" {
+		where = append(where, "lower(COALESCE(NULLIF(property_type, ''), details->'property'->>'property_type', ''))=lower("+arg(q.PropertyType)+")")
+	}
+	if q.Status != "" {
+		where = append(where, pipelineStateSQL+"="+arg(q.Status))
+	}
+	if q.StyleProfileID != "" {
+		where = append(where, "details->'meta'->>'style_profile_id'="+arg(q.StyleProfileID))
+	}
+
+	sortExpr := "created_at"
+	switch q.Sort {
+	case SortByAddress:
+		sortExpr = "lower(address)"
+	case SortByStatus:
+		sortExpr = pipelineStateSQL
+	}
+	direction, comparator := "ASC", ">"
+	if q.descending() {
+		direction, comparator = "DESC", "<"
+	}
+	if cursor != nil {
+		var value any = cursor.Value
+		if q.Sort == SortByCreatedAt {
+			value, _ = time.Parse(time.RFC3339Nano, cursor.Value)
+		}
+		where = append(where, fmt.Sprintf("(%s, id) %s (%s, %s)", sortExpr, comparator, arg(value), arg(cursor.ID)))
+	}
+
+	sqlQuery := `SELECT ` + listingColumns + ` FROM listings WHERE ` + strings.Join(where, " AND ")
+	sqlQuery += fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT %s", sortExpr, direction, direction, arg(q.Limit+1))
+
+	listings, err := s.fetchListings(ctx, sqlQuery, args...)
+	if err != nil {
+		return ListingPage{}, err
+	}
+	page := ListingPage{Listings: listings}
+	if len(listings) > q.Limit {
+		page.Listings = listings[:q.Limit]
+		page.NextCursor = cursorFor(page.Listings[q.Limit-1], q)
+	}
+	return page, nil
+}
+
+// searchHeadlineDocument is the text snippets are cut from. It holds the same
+// fields as the generated search_vector column (migration 0004), including
+// every string in the sections JSON, so hits in section text get highlighted.
+const searchHeadlineDocument = `concat_ws(E'\n', l.address, l.city, l.details->'property'->>'city',
+				(SELECT string_agg(v #>> '{}', E'\n') FROM jsonb_path_query(COALESCE(l.sections, '[]'::jsonb), 'strict $.** ? (@.type() == "string")') AS v),
+				l.full_copy)`
+
+// SearchListings runs a Swedish full-text search and returns ranked hits with highlighted snippets.
+func (s *PostgresStore) SearchListings(ctx context.Context, query SearchListingsQuery) ([]SearchResult, error) {
+	q := query.normalize()
+	if q.Text == "" {
+		return nil, nil
+	}
+
+	headlineOpts := fmt.Sprintf("StartSel=%s, StopSel=%s, MaxWords=25, MinWords=8, MaxFragments=2, FragmentDelimiter=\" … \"", highlightStart, highlightStop)
+	sqlQuery := `WITH q AS (SELECT websearch_to_tsquery('swedish', $1) AS query)
+		SELECT ` + prefixColumns("l.", listingColumns) + `,
+			ts_rank_cd(l.search_vector, q.query) AS rank,
+			ts_headline('swedish', ` + searchHeadlineDocument + `, q.query, $2) AS snippet
+		FROM listings l, q
+		WHERE l.search_vector @@ q.query AND l.deleted_at IS NULL`
+	args := []any{q.Text, headlineOpts}
+	if q.OrgID != "" {
+		args = append(args, q.OrgID)
+		sqlQuery += fmt.Sprintf(" AND l.org_id=$%d", len(args))
+	}
+	if q.OwnerID != "" {
+		args = append(args, q.OwnerID)
+		sqlQuery += fmt.Sprintf(" AND l.owner_id=$%d", len(args))
+	}
+	args = append(args, q.Limit)
+	sqlQuery += fmt.Sprintf(" ORDER BY rank DESC, l.created_at DESC LIMIT $%d", len(args))
+
+	rows, err := s.pool.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("search listings: %w", err)
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var (
+			rank    float32
+			snippet string
+		)
+		item, err := scanListingWith(rows, &rank, &snippet)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, SearchResult{
+			Listing: item,
+			Rank:    float64(rank),
+			Snippet: renderHighlights(strings.Join(strings.Fields(snippet), " ")),
+		})
+	}
+	return results, rows.Err()
 }
 
-// ListListingsByOwner returns recent listings for a specific owner.
-func (s *PostgresStore) ListListingsByOwner(ctx context.Context, ownerID string) ([]Listing, error) {
-	return s.fetchListings(ctx, `SELECT `+listingColumns+` FROM listings WHERE owner_id=$1 ORDER BY created_at DESC LIMIT 50`, ownerID)
+// prefixColumns qualifies a comma separated column list with a table alias.
+func prefixColumns(prefix, columns string) string {
+	parts := strings.Split(columns, ",")
+	for i, part := range parts {
+		parts[i] = prefix + strings.TrimSpace(part)
+	}
+	return strings.Join(parts, ", ")
 }
 
 // ListAllListings returns every stored listing (used for dataset exports).
 func (s *PostgresStore) ListAllListings(ctx context.Context) ([]Listing, error) {
-	return s.fetchListings(ctx, `SELECT `+listingColumns+` FROM listings ORDER BY created_at DESC`)
+	return s.fetchListings(ctx, `SELECT `+listingColumns+` FROM listings WHERE deleted_at IS NULL ORDER BY created_at DESC`)
 }
 
 func (s *PostgresStore) fetchListings(ctx context.Context, query string, args ...any) ([]Listing, error) {
@@ -91,7 +250,7 @@ func (s *PostgresStore) fetchListings(ctx context.Context, query string, args ..
 		}
 		listings = append(listings, item)
 	}
-	return listings, nil
+	return listings, rows.Err()
 }
 
 // Close releases database resources.
@@ -103,7 +262,7 @@ func (s *PostgresStore) Close() {
 
 // GetListing fetches a single listing by ID.
 func (s *PostgresStore) GetListing(ctx context.Context, id string) (Listing, error) {
-	row := s.pool.QueryRow(ctx, `SELECT `+listingColumns+` FROM listings WHERE id=$1`, id)
+	row := s.pool.QueryRow(ctx, `SELECT `+listingColumns+` FROM listings WHERE id=$1 AND deleted_at IS NULL`, id)
 	item, err := scanListing(row)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -111,57 +270,47 @@ func (s *PostgresStore) GetListing(ctx context.Context, id string) (Listing, err
 		}
 		return Listing{}, err
 	}
-	return item, nil
+	return s.withHistory(ctx, item)
 }
 
-// UpdateListingSections replaces the sections JSONB for a listing and returns the updated row.
-func (s *PostgresStore) UpdateListingSections(ctx context.Context, id string, sections []Section, fullCopy string, history History, status Status) (Listing, error) {
+// UpdateListingSections replaces the sections JSONB for a listing, appends the
+// given revisions in the same transaction and returns the updated row. The
+// pipeline status is left alone: UpdateStatus does not bump the version, so a
+// status snapshot taken with the listing could overwrite newer progress.
+func (s *PostgresStore) UpdateListingSections(ctx context.Context, id string, version int, sections []Section, fullCopy string, revisions []Revision) (Listing, error) {
 	payload, err := json.Marshal(sections)
 	if err != nil {
 		return Listing{}, fmt.Errorf("marshal sections: %w", err)
 	}
 
-	historyJSON, err := json.Marshal(history)
-	if err != nil {
-		return Listing{}, fmt.Errorf("marshal history: %w", err)
-	}
-
-	statusJSON, err := json.Marshal(status)
-	if err != nil {
-		return Listing{}, fmt.Errorf("marshal status: %w", err)
-	}
-
-	row := s.pool.QueryRow(ctx, `UPDATE listings SET sections=$2, full_copy=$3, section_history=$4, pipeline_status=$5 WHERE id=$1 RETURNING `+listingColumns, id, payload, fullCopy, historyJSON, statusJSON)
-	item, scanErr := scanListing(row)
-	if scanErr != nil {
-		if errors.Is(scanErr, pgx.ErrNoRows) {
-			return Listing{}, ErrNotFound
+	var item Listing
+	err = pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		row := tx.QueryRow(ctx, `UPDATE listings SET sections=$3, full_copy=$4, version=version+1 WHERE id=$1 AND version=$2 AND deleted_at IS NULL RETURNING `+listingColumns, id, version, payload, fullCopy)
+		var err error
+		if item, err = scanListing(row); err != nil {
+			return err
 		}
-		return Listing{}, scanErr
+		return insertRevisions(ctx, tx, id, revisions)
+	})
+	if err != nil {
+		return Listing{}, s.versionedUpdateError(ctx, id, err)
 	}
-	return item, nil
+	return s.withHistory(ctx, item)
 }
 
 // UpdateListingDetails replaces the details JSONB (including media) and optionally the cover image.
-func (s *PostgresStore) UpdateListingDetails(ctx context.Context, id string, details Details, imageURL string) (Listing, error) {
+func (s *PostgresStore) UpdateListingDetails(ctx context.Context, id string, version int, details Details, imageURL string) (Listing, error) {
 	payload, err := json.Marshal(details)
 	if err != nil {
 		return Listing{}, fmt.Errorf("marshal details: %w", err)
 	}
 
-	row := s.pool.QueryRow(ctx, `UPDATE listings SET details=$2, image_url=$3 WHERE id=$1 RETURNING `+listingColumns, id, payload, imageURL)
-	item, scanErr := scanListing(row)
-	if scanErr != nil {
-		if errors.Is(scanErr, pgx.ErrNoRows) {
-			return Listing{}, ErrNotFound
-		}
-		return Listing{}, scanErr
-	}
-	return item, nil
+	row := s.pool.QueryRow(ctx, `UPDATE listings SET details=$3, image_url=$4, version=version+1 WHERE id=$1 AND version=$2 AND deleted_at IS NULL RETURNING `+listingColumns, id, version, payload, imageURL)
+	return s.scanVersionedUpdate(ctx, id, row)
 }
 
 // UpdateInsights replaces insights JSON and optionally pipeline status.
-func (s *PostgresStore) UpdateInsights(ctx context.Context, id string, insights Insights, status Status) (Listing, error) {
+func (s *PostgresStore) UpdateInsights(ctx context.Context, id string, version int, insights Insights, status Status) (Listing, error) {
 	insightsJSON, err := json.Marshal(insights)
 	if err != nil {
 		return Listing{}, fmt.Errorf("marshal insights: %w", err)
@@ -172,20 +321,38 @@ func (s *PostgresStore) UpdateInsights(ctx context.Context, id string, insights
 		return Listing{}, fmt.Errorf("marshal status: %w", err)
 	}
 
-	row := s.pool.QueryRow(ctx, `UPDATE listings SET insights=$2, pipeline_status=$3 WHERE id=$1 RETURNING `+listingColumns, id, insightsJSON, statusJSON)
-	item, scanErr := scanListing(row)
-	if scanErr != nil {
-		if errors.Is(scanErr, pgx.ErrNoRows) {
-			return Listing{}, ErrNotFound
-		}
-		return Listing{}, scanErr
+	row := s.pool.QueryRow(ctx, `UPDATE listings SET insights=$3, pipeline_status=$4, version=version+1 WHERE id=$1 AND version=$2 AND deleted_at IS NULL RETURNING `+listingColumns, id, version, insightsJSON, statusJSON)
+	return s.scanVersionedUpdate(ctx, id, row)
+}
+
+// scanVersionedUpdate reads the row returned by a version-guarded UPDATE.
+func (s *PostgresStore) scanVersionedUpdate(ctx context.Context, id string, row pgx.Row) (Listing, error) {
+	item, err := scanListing(row)
+	if err != nil {
+		return Listing{}, s.versionedUpdateError(ctx, id, err)
 	}
-	return item, nil
+	return s.withHistory(ctx, item)
 }
 
-// DeleteListing removes a listing entirely.
+// versionedUpdateError tells a missing listing (ErrNotFound) apart from a stale
+// version (ErrConflict) when a version-guarded UPDATE matched no row.
+func (s *PostgresStore) versionedUpdateError(ctx context.Context, id string, err error) error {
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+	var exists bool
+	if err := s.pool.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM listings WHERE id=$1 AND deleted_at IS NULL)`, id).Scan(&exists); err != nil {
+		return fmt.Errorf("check listing version: %w", err)
+	}
+	if exists {
+		return ErrConflict
+	}
+	return ErrNotFound
+}
+
+// DeleteListing moves a listing to the trash by stamping deleted_at.
 func (s *PostgresStore) DeleteListing(ctx context.Context, id string) error {
-	result, err := s.pool.Exec(ctx, `DELETE FROM listings WHERE id=$1`, id)
+	result, err := s.pool.Exec(ctx, `UPDATE listings SET deleted_at=now() WHERE id=$1 AND deleted_at IS NULL`, id)
 	if err != nil {
 		return fmt.Errorf("delete listing: %w", err)
 	}
@@ -195,6 +362,30 @@ func (s *PostgresStore) DeleteListing(ctx context.Context, id string) error {
 	return nil
 }
 
+// ListDeletedListings returns trashed listings, most recently deleted first.
+func (s *PostgresStore) ListDeletedListings(ctx context.Context, orgID, ownerID string) ([]Listing, error) {
+	return s.fetchListings(ctx, `SELECT `+listingColumns+` FROM listings WHERE deleted_at IS NOT NULL AND ($1='' OR org_id=$1) AND ($2='' OR owner_id=$2) ORDER BY deleted_at DESC`, orgID, ownerID)
+}
+
+// RestoreListing takes a listing out of the trash.
+func (s *PostgresStore) RestoreListing(ctx context.Context, id string) (Listing, error) {
+	row := s.pool.QueryRow(ctx, `UPDATE listings SET deleted_at=NULL, version=version+1 WHERE id=$1 AND deleted_at IS NOT NULL RETURNING `+listingColumns, id)
+	item, err := scanListing(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Listing{}, ErrNotFound
+		}
+		return Listing{}, err
+	}
+	return s.withHistory(ctx, item)
+}
+
+// PurgeDeletedListings permanently removes listings trashed before the cutoff
+// and returns them so their media can be cleaned up.
+func (s *PostgresStore) PurgeDeletedListings(ctx context.Context, deletedBefore time.Time) ([]Listing, error) {
+	return s.fetchListings(ctx, `DELETE FROM listings WHERE deleted_at IS NOT NULL AND deleted_at < $1 RETURNING `+listingColumns, deletedBefore)
+}
+
 // UpdateStatus updates only the pipeline status column.
 func (s *PostgresStore) UpdateStatus(ctx context.Context, id string, status Status) error {
 	payload, err := json.Marshal(status)
@@ -202,7 +393,7 @@ func (s *PostgresStore) UpdateStatus(ctx context.Context, id string, status Stat
 		return fmt.Errorf("marshal status: %w", err)
 	}
 
-	tag, err := s.pool.Exec(ctx, `UPDATE listings SET pipeline_status=$2 WHERE id=$1`, id, payload)
+	tag, err := s.pool.Exec(ctx, `UPDATE listings SET pipeline_status=$2 WHERE id=$1 AND deleted_at IS NULL`, id, payload)
 	if err != nil {
 		return fmt.Errorf("update status: %w", err)
 	}
@@ -212,17 +403,104 @@ func (s *PostgresStore) UpdateStatus(ctx context.Context, id string, status Stat
 	return nil
 }
 
-// SaveStyleProfile creates or updates a style profile.
+const revisionColumns = "id, listing_id, slug, revision, title, content, source, instruction, tone, target_audience, highlights, notes, author_id, created_at"
+
+// insertRevisions appends revisions, numbering each one after the latest for its slug.
+// It runs inside the transaction that updated the listing row, whose lock serialises writers.
+func insertRevisions(ctx context.Context, tx pgx.Tx, listingID string, revisions []Revision) error {
+	for _, rev := range revisions {
+		if rev.ID == "" {
+			rev.ID = uuid.NewString()
+		}
+		if rev.CreatedAt.IsZero() {
+			rev.CreatedAt = time.Now()
+		}
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO listing_revisions (id, listing_id, slug, revision, title, content, source, instruction, tone, target_audience, highlights, notes, author_id, created_at)
+			VALUES ($1, $2, $3, (SELECT COALESCE(MAX(revision), 0) + 1 FROM listing_revisions WHERE listing_id=$2 AND slug=$3), $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		`, rev.ID, listingID, rev.Slug, rev.Title, rev.Content, rev.Source, nullString(rev.Instruction), nullString(rev.Tone), nullString(rev.TargetAudience), rev.Highlights, nullString(rev.Notes), nullString(rev.AuthorID), rev.CreatedAt); err != nil {
+			return fmt.Errorf("insert revision: %w", err)
+		}
+	}
+	return nil
+}
+
+// withHistory fills the legacy History field from the latest revisions of each section.
+func (s *PostgresStore) withHistory(ctx context.Context, item Listing) (Listing, error) {
+	revisions, err := s.fetchRevisions(ctx, `SELECT `+revisionColumns+` FROM (
+		SELECT *, row_number() OVER (PARTITION BY slug ORDER BY revision DESC) AS rn
+		FROM listing_revisions WHERE listing_id=$1
+	) r WHERE rn <= $2`, item.ID, LegacyHistoryDepth)
+	if err != nil {
+		return Listing{}, err
+	}
+	item.History = historyFromRevisions(revisions)
+	return item, nil
+}
+
+// ListRevisions returns one page of a section's revisions, newest first.
+func (s *PostgresStore) ListRevisions(ctx context.Context, query ListRevisionsQuery) (RevisionPage, error) {
+	q := query.normalize()
+	page := RevisionPage{Page: q.Page, PageSize: q.PageSize}
+	if err := s.pool.QueryRow(ctx, `SELECT count(*) FROM listing_revisions WHERE listing_id=$1 AND slug=$2`, q.ListingID, q.Slug).Scan(&page.Total); err != nil {
+		return RevisionPage{}, fmt.Errorf("count revisions: %w", err)
+	}
+	revisions, err := s.fetchRevisions(ctx, `SELECT `+revisionColumns+` FROM listing_revisions WHERE listing_id=$1 AND slug=$2 ORDER BY revision DESC LIMIT $3 OFFSET $4`, q.ListingID, q.Slug, q.PageSize, q.offset())
+	if err != nil {
+		return RevisionPage{}, err
+	}
+	page.Revisions = revisions
+	if page.Revisions == nil {
+		page.Revisions = []Revision{}
+	}
+	return page, nil
+}
+
+// GetRevision returns a single section revision by number.
+func (s *PostgresStore) GetRevision(ctx context.Context, listingID, slug string, number int) (Revision, error) {
+	row := s.pool.QueryRow(ctx, `SELECT `+revisionColumns+` FROM listing_revisions WHERE listing_id=$1 AND slug=$2 AND revision=$3`, listingID, slug, number)
+	rev, err := scanRevision(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Revision{}, ErrNotFound
+		}
+		return Revision{}, err
+	}
+	return rev, nil
+}
+
+func (s *PostgresStore) fetchRevisions(ctx context.Context, query string, args ...any) ([]Revision, error) {
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query revisions: %w", err)
+	}
+	defer rows.Close()
+
+	var revisions []Revision
+	for rows.Next() {
+		rev, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
+
+// SaveStyleProfile creates or updates a style profile within its organization.
 func (s *PostgresStore) SaveStyleProfile(ctx context.Context, profile StyleProfile) (StyleProfile, error) {
 	now := time.Now()
 	if profile.ID == "" {
 		profile.ID = uuid.NewString()
 		profile.CreatedAt = now
 	}
+	if profile.OrgID == "" {
+		profile.OrgID = DefaultOrganizationID
+	}
 	profile.UpdatedAt = now
-	if _, err := s.pool.Exec(ctx, `
-		INSERT INTO style_profiles (id, name, description, tone, guidelines, example_texts, forbidden_words, custom_model, dataset_uri, last_trained_at, created_at, updated_at)
-		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,COALESCE($11, now()), $12)
+	tag, err := s.pool.Exec(ctx, `
+		INSERT INTO style_profiles (id, org_id, name, description, tone, guidelines, example_texts, forbidden_words, custom_model, dataset_uri, last_trained_at, created_at, updated_at)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,COALESCE($12, now()), $13)
 		ON CONFLICT (id) DO UPDATE SET
 			name=EXCLUDED.name,
 			description=EXCLUDED.description,
@@ -234,9 +512,14 @@ func (s *PostgresStore) SaveStyleProfile(ctx context.Context, profile StyleProfi
 			dataset_uri=EXCLUDED.dataset_uri,
 			last_trained_at=EXCLUDED.last_trained_at,
 			updated_at=EXCLUDED.updated_at
-	`, profile.ID, profile.Name, profile.Description, profile.Tone, profile.Guidelines, profile.ExampleTexts, profile.ForbiddenWords, nullString(profile.CustomModel), nullString(profile.DatasetURI), profile.LastTrainedAt, nullableTime(profile.CreatedAt), profile.UpdatedAt); err != nil {
+		WHERE style_profiles.org_id=EXCLUDED.org_id
+	`, profile.ID, profile.OrgID, profile.Name, profile.Description, profile.Tone, profile.Guidelines, profile.ExampleTexts, profile.ForbiddenWords, nullString(profile.CustomModel), nullString(profile.DatasetURI), profile.LastTrainedAt, nullableTime(profile.CreatedAt), profile.UpdatedAt)
+	if err != nil {
 		return StyleProfile{}, fmt.Errorf("save style profile: %w", err)
 	}
+	if tag.RowsAffected() == 0 {
+		return StyleProfile{}, ErrNotFound
+	}
 	return s.GetStyleProfile(ctx, profile.ID)
 }
 
@@ -254,9 +537,9 @@ func nullString(value string) any {
 	return value
 }
 
-// ListStyleProfiles returns all stored style profiles.
-func (s *PostgresStore) ListStyleProfiles(ctx context.Context) ([]StyleProfile, error) {
-	rows, err := s.pool.Query(ctx, `SELECT id, name, description, tone, guidelines, example_texts, forbidden_words, custom_model, dataset_uri, last_trained_at, created_at, updated_at FROM style_profiles ORDER BY name`)
+// ListStyleProfiles returns the organization's style profiles, or all of them when orgID is empty.
+func (s *PostgresStore) ListStyleProfiles(ctx context.Context, orgID string) ([]StyleProfile, error) {
+	rows, err := s.pool.Query(ctx, `SELECT `+styleProfileColumns+` FROM style_profiles WHERE $1='' OR org_id=$1 ORDER BY name`, orgID)
 	if err != nil {
 		return nil, fmt.Errorf("list style profiles: %w", err)
 	}
@@ -270,12 +553,12 @@ func (s *PostgresStore) ListStyleProfiles(ctx context.Context) ([]StyleProfile,
 		}
 		profiles = append(profiles, profile)
 	}
-	return profiles, nil
+	return profiles, rows.Err()
 }
 
 // GetStyleProfile fetches a profile by ID.
 func (s *PostgresStore) GetStyleProfile(ctx context.Context, id string) (StyleProfile, error) {
-	row := s.pool.QueryRow(ctx, `SELECT id, name, description, tone, guidelines, example_texts, forbidden_words, custom_model, dataset_uri, last_trained_at, created_at, updated_at FROM style_profiles WHERE id=$1`, id)
+	row := s.pool.QueryRow(ctx, `SELECT `+styleProfileColumns+` FROM style_profiles WHERE id=$1`, id)
 	profile, err := scanStyleProfile(row)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -286,6 +569,66 @@ func (s *PostgresStore) GetStyleProfile(ctx context.Context, id string) (StylePr
 	return profile, nil
 }
 
+// CreateOrganization stores a new organization.
+func (s *PostgresStore) CreateOrganization(ctx context.Context, org Organization) (Organization, error) {
+	org, err := prepareOrganization(org)
+	if err != nil {
+		return Organization{}, err
+	}
+	if _, err := s.pool.Exec(ctx, `INSERT INTO organizations (id, name, require_two_factor, created_at) VALUES ($1, $2, $3, $4)`, org.ID, org.Name, org.RequireTwoFactor, org.CreatedAt); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return Organization{}, ErrOrganizationExists
+		}
+		return Organization{}, fmt.Errorf("insert organization: %w", err)
+	}
+	return org, nil
+}
+
+// GetOrganization fetches an organization by ID.
+func (s *PostgresStore) GetOrganization(ctx context.Context, id string) (Organization, error) {
+	var org Organization
+	err := s.pool.QueryRow(ctx, `SELECT id, name, require_two_factor, created_at FROM organizations WHERE id=$1`, id).Scan(&org.ID, &org.Name, &org.RequireTwoFactor, &org.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Organization{}, ErrNotFound
+		}
+		return Organization{}, fmt.Errorf("scan organization: %w", err)
+	}
+	return org, nil
+}
+
+// ListOrganizations returns all organizations ordered by name.
+func (s *PostgresStore) ListOrganizations(ctx context.Context) ([]Organization, error) {
+	rows, err := s.pool.Query(ctx, `SELECT id, name, require_two_factor, created_at FROM organizations ORDER BY name, id`)
+	if err != nil {
+		return nil, fmt.Errorf("list organizations: %w", err)
+	}
+	defer rows.Close()
+
+	var orgs []Organization
+	for rows.Next() {
+		var org Organization
+		if err := rows.Scan(&org.ID, &org.Name, &org.RequireTwoFactor, &org.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan organization: %w", err)
+		}
+		orgs = append(orgs, org)
+	}
+	return orgs, rows.Err()
+}
+
+// SetOrganizationTwoFactor turns the two-factor requirement on or off.
+func (s *PostgresStore) SetOrganizationTwoFactor(ctx context.Context, id string, required bool) error {
+	tag, err := s.pool.Exec(ctx, `UPDATE organizations SET require_two_factor=$2 WHERE id=$1`, id, required)
+	if err != nil {
+		return fmt.Errorf("update organization: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
 // CreateUser stores a new user account.
 func (s *PostgresStore) CreateUser(ctx context.Context, user User) (User, error) {
 	if user.ID == "" {
@@ -295,8 +638,20 @@ func (s *PostgresStore) CreateUser(ctx context.Context, user User) (User, error)
 		user.CreatedAt = time.Now()
 	}
 	user.Approved = false
+	user.EmailVerified = false
 	user.Email = strings.ToLower(strings.TrimSpace(user.Email))
-	if _, err := s.pool.Exec(ctx, `INSERT INTO users (id, email, password_hash, created_at) VALUES ($1, $2, $3, $4)`, user.ID, user.Email, user.PasswordHash, user.CreatedAt); err != nil {
+	if user.Email == "" {
+		return User{}, fmt.Errorf("email is required")
+	}
+	if user.OrgID == "" {
+		user.OrgID = DefaultOrganizationID
+	}
+	role, err := ParseRole(string(user.Role))
+	if err != nil {
+		return User{}, err
+	}
+	user.Role = role
+	if _, err := s.pool.Exec(ctx, `INSERT INTO users (id, email, password_hash, org_id, role, created_at) VALUES ($1, $2, $3, $4, $5, $6)`, user.ID, user.Email, user.PasswordHash, user.OrgID, user.Role, user.CreatedAt); err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
 			return User{}, ErrUserExists
@@ -308,28 +663,85 @@ func (s *PostgresStore) CreateUser(ctx context.Context, user User) (User, error)
 
 // GetUserByEmail fetches a user by their email address.
 func (s *PostgresStore) GetUserByEmail(ctx context.Context, email string) (User, error) {
-	row := s.pool.QueryRow(ctx, `SELECT id, email, password_hash, approved, created_at FROM users WHERE email=$1`, strings.ToLower(strings.TrimSpace(email)))
+	row := s.pool.QueryRow(ctx, `SELECT `+userColumns+` FROM users WHERE email=$1`, strings.ToLower(strings.TrimSpace(email)))
 	return scanUser(row)
 }
 
 // GetUserByID fetches a user by ID.
 func (s *PostgresStore) GetUserByID(ctx context.Context, id string) (User, error) {
-	row := s.pool.QueryRow(ctx, `SELECT id, email, password_hash, approved, created_at FROM users WHERE id=$1`, id)
+	row := s.pool.QueryRow(ctx, `SELECT `+userColumns+` FROM users WHERE id=$1`, id)
 	return scanUser(row)
 }
 
 // ApproveUser updates the approval flag for a user.
 func (s *PostgresStore) ApproveUser(ctx context.Context, id string, approved bool) error {
-	_, err := s.pool.Exec(ctx, `UPDATE users SET approved=$2 WHERE id=$1`, id, approved)
+	tag, err := s.pool.Exec(ctx, `UPDATE users SET approved=$2 WHERE id=$1`, id, approved)
 	if err != nil {
 		return fmt.Errorf("update user approval: %w", err)
 	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetUserOrganization moves a user to another organization.
+func (s *PostgresStore) SetUserOrganization(ctx context.Context, userID, orgID string) error {
+	if _, err := s.GetOrganization(ctx, orgID); err != nil {
+		return err
+	}
+	tag, err := s.pool.Exec(ctx, `UPDATE users SET org_id=$2 WHERE id=$1`, userID, orgID)
+	if err != nil {
+		return fmt.Errorf("update user organization: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetUserRole changes a user's role.
+func (s *PostgresStore) SetUserRole(ctx context.Context, userID string, role Role) error {
+	if !role.Valid() {
+		return fmt.Errorf("unknown role %q", role)
+	}
+	tag, err := s.pool.Exec(ctx, `UPDATE users SET role=$2 WHERE id=$1`, userID, role)
+	if err != nil {
+		return fmt.Errorf("update user role: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetUserPassword replaces the stored password hash.
+func (s *PostgresStore) SetUserPassword(ctx context.Context, userID, passwordHash string) error {
+	tag, err := s.pool.Exec(ctx, `UPDATE users SET password_hash=$2 WHERE id=$1`, userID, passwordHash)
+	if err != nil {
+		return fmt.Errorf("update user password: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetEmailVerified records whether the user has confirmed their e-mail address.
+func (s *PostgresStore) SetEmailVerified(ctx context.Context, userID string, verified bool) error {
+	tag, err := s.pool.Exec(ctx, `UPDATE users SET email_verified=$2 WHERE id=$1`, userID, verified)
+	if err != nil {
+		return fmt.Errorf("update email verification: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
 	return nil
 }
 
 // ListUsers returns all users.
 func (s *PostgresStore) ListUsers(ctx context.Context) ([]User, error) {
-	rows, err := s.pool.Query(ctx, `SELECT id, email, password_hash, approved, created_at FROM users ORDER BY created_at DESC`)
+	rows, err := s.pool.Query(ctx, `SELECT `+userColumns+` FROM users ORDER BY created_at DESC`)
 	if err != nil {
 		return nil, fmt.Errorf("list users: %w", err)
 	}
@@ -342,84 +754,499 @@ func (s *PostgresStore) ListUsers(ctx context.Context) ([]User, error) {
 		}
 		users = append(users, user)
 	}
-	return users, nil
+	return users, rows.Err()
 }
 
 // DeleteUser removes a user by id.
 func (s *PostgresStore) DeleteUser(ctx context.Context, id string) error {
-	_, err := s.pool.Exec(ctx, `DELETE FROM users WHERE id=$1`, id)
+	tag, err := s.pool.Exec(ctx, `DELETE FROM users WHERE id=$1`, id)
 	if err != nil {
 		return fmt.Errorf("delete user: %w", err)
 	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// CreateSession stores a new session for an existing user.
+func (s *PostgresStore) CreateSession(ctx context.Context, session Session) (Session, error) {
+	session, err := prepareSession(session)
+	if err != nil {
+		return Session{}, err
+	}
+	if _, err := s.pool.Exec(ctx, `INSERT INTO sessions (`+sessionColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		session.ID, session.UserID, session.CreatedAt, session.LastSeenAt, session.ExpiresAt, session.UserAgent, session.IP); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return Session{}, ErrNotFound
+		}
+		return Session{}, fmt.Errorf("insert session: %w", err)
+	}
+	return session, nil
+}
+
+// GetSession fetches a session by id.
+func (s *PostgresStore) GetSession(ctx context.Context, id string) (Session, error) {
+	row := s.pool.QueryRow(ctx, `SELECT `+sessionColumns+` FROM sessions WHERE id=$1`, id)
+	return scanSession(row)
+}
+
+// TouchSession records activity and slides the expiry forward.
+func (s *PostgresStore) TouchSession(ctx context.Context, id string, lastSeenAt, expiresAt time.Time) error {
+	tag, err := s.pool.Exec(ctx, `UPDATE sessions SET last_seen_at=$2, expires_at=$3 WHERE id=$1`, id, lastSeenAt, expiresAt)
+	if err != nil {
+		return fmt.Errorf("touch session: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ListSessions returns the user's unexpired sessions, most recently used first.
+func (s *PostgresStore) ListSessions(ctx context.Context, userID string) ([]Session, error) {
+	rows, err := s.pool.Query(ctx, `SELECT `+sessionColumns+` FROM sessions WHERE user_id=$1 AND expires_at > now() ORDER BY last_seen_at DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list sessions: %w", err)
+	}
+	defer rows.Close()
+	sessions := make([]Session, 0)
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// DeleteSession revokes one of the user's sessions.
+func (s *PostgresStore) DeleteSession(ctx context.Context, userID, id string) error {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM sessions WHERE id=$1 AND user_id=$2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("delete session: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteUserSessions revokes all of the user's sessions except keepID.
+func (s *PostgresStore) DeleteUserSessions(ctx context.Context, userID, keepID string) (int, error) {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM sessions WHERE user_id=$1 AND id<>$2`, userID, keepID)
+	if err != nil {
+		return 0, fmt.Errorf("delete user sessions: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// PurgeExpiredSessions removes sessions that expired before the given time.
+func (s *PostgresStore) PurgeExpiredSessions(ctx context.Context, expiredBefore time.Time) (int, error) {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM sessions WHERE expires_at < $1`, expiredBefore)
+	if err != nil {
+		return 0, fmt.Errorf("purge sessions: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// CreateAPIToken stores a new token for an existing user.
+func (s *PostgresStore) CreateAPIToken(ctx context.Context, token APIToken) (APIToken, error) {
+	token, err := prepareAPIToken(token)
+	if err != nil {
+		return APIToken{}, err
+	}
+	if _, err := s.pool.Exec(ctx, `INSERT INTO api_tokens (`+apiTokenColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		token.ID, token.UserID, token.Name, token.Prefix, token.TokenHash, token.Scopes, token.CreatedAt, token.LastUsedAt, token.ExpiresAt); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return APIToken{}, ErrNotFound
+		}
+		return APIToken{}, fmt.Errorf("insert api token: %w", err)
+	}
+	return token, nil
+}
+
+// GetAPITokenByHash fetches a token by the hash of its secret.
+func (s *PostgresStore) GetAPITokenByHash(ctx context.Context, tokenHash string) (APIToken, error) {
+	row := s.pool.QueryRow(ctx, `SELECT `+apiTokenColumns+` FROM api_tokens WHERE token_hash=$1`, tokenHash)
+	return scanAPIToken(row)
+}
+
+// ListAPITokens returns the user's tokens, newest first.
+func (s *PostgresStore) ListAPITokens(ctx context.Context, userID string) ([]APIToken, error) {
+	rows, err := s.pool.Query(ctx, `SELECT `+apiTokenColumns+` FROM api_tokens WHERE user_id=$1 ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list api tokens: %w", err)
+	}
+	defer rows.Close()
+	tokens := make([]APIToken, 0)
+	for rows.Next() {
+		token, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// DeleteAPIToken revokes one of the user's tokens.
+func (s *PostgresStore) DeleteAPIToken(ctx context.Context, userID, id string) error {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM api_tokens WHERE id=$1 AND user_id=$2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("delete api token: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
 	return nil
 }
 
+// TouchAPIToken records when the token was last used.
+func (s *PostgresStore) TouchAPIToken(ctx context.Context, id string, usedAt time.Time) error {
+	tag, err := s.pool.Exec(ctx, `UPDATE api_tokens SET last_used_at=$2 WHERE id=$1`, id, usedAt)
+	if err != nil {
+		return fmt.Errorf("touch api token: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetTwoFactor returns the user's TOTP enrollment.
+func (s *PostgresStore) GetTwoFactor(ctx context.Context, userID string) (TwoFactor, error) {
+	var (
+		tf        TwoFactor
+		enabledAt sql.NullTime
+	)
+	err := s.pool.QueryRow(ctx, `SELECT user_id, secret, enabled, recovery_codes, last_step, created_at, enabled_at FROM user_two_factor WHERE user_id=$1`, userID).
+		Scan(&tf.UserID, &tf.Secret, &tf.Enabled, &tf.RecoveryCodes, &tf.LastStep, &tf.CreatedAt, &enabledAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return TwoFactor{}, ErrNotFound
+		}
+		return TwoFactor{}, fmt.Errorf("scan two-factor: %w", err)
+	}
+	if enabledAt.Valid {
+		t := enabledAt.Time
+		tf.EnabledAt = &t
+	}
+	return tf, nil
+}
+
+// SaveTwoFactor creates or replaces the user's enrollment.
+func (s *PostgresStore) SaveTwoFactor(ctx context.Context, tf TwoFactor) error {
+	if tf.CreatedAt.IsZero() {
+		tf.CreatedAt = time.Now()
+	}
+	if tf.RecoveryCodes == nil {
+		tf.RecoveryCodes = []string{}
+	}
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO user_two_factor (user_id, secret, enabled, recovery_codes, last_step, created_at, enabled_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (user_id) DO UPDATE SET secret=EXCLUDED.secret, enabled=EXCLUDED.enabled, recovery_codes=EXCLUDED.recovery_codes,
+			last_step=EXCLUDED.last_step, created_at=EXCLUDED.created_at, enabled_at=EXCLUDED.enabled_at`,
+		tf.UserID, tf.Secret, tf.Enabled, tf.RecoveryCodes, tf.LastStep, tf.CreatedAt, tf.EnabledAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return ErrNotFound
+		}
+		return fmt.Errorf("save two-factor: %w", err)
+	}
+	return nil
+}
+
+// DeleteTwoFactor removes the user's enrollment.
+func (s *PostgresStore) DeleteTwoFactor(ctx context.Context, userID string) error {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM user_two_factor WHERE user_id=$1`, userID)
+	if err != nil {
+		return fmt.Errorf("delete two-factor: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ClaimTwoFactorStep records step as used unless it has been used already.
+func (s *PostgresStore) ClaimTwoFactorStep(ctx context.Context, userID string, step int64) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `UPDATE user_two_factor SET last_step=$2 WHERE user_id=$1 AND last_step < $2`, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("claim two-factor step: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// UseRecoveryCode removes the code hash if the user still has it.
+func (s *PostgresStore) UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `UPDATE user_two_factor SET recovery_codes=array_remove(recovery_codes, $2) WHERE user_id=$1 AND $2=ANY(recovery_codes)`, userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("use recovery code: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// UpdateRateLimit applies fn to the key's state inside a transaction. An
+// advisory lock on the key serializes instances, including for keys that have
+// no row yet.
+func (s *PostgresStore) UpdateRateLimit(ctx context.Context, key string, fn func(RateLimit) RateLimit) (RateLimit, error) {
+	var next RateLimit
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtextextended($1, 0))`, key); err != nil {
+			return fmt.Errorf("lock rate limit: %w", err)
+		}
+		current := RateLimit{Key: key}
+		var lockedUntil sql.NullTime
+		err := tx.QueryRow(ctx, `SELECT tokens, failures, locked_until, updated_at FROM rate_limits WHERE key=$1`, key).
+			Scan(&current.Tokens, &current.Failures, &lockedUntil, &current.UpdatedAt)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("scan rate limit: %w", err)
+		}
+		if lockedUntil.Valid {
+			t := lockedUntil.Time
+			current.LockedUntil = &t
+		}
+
+		next = fn(current)
+		next.Key = key
+		if next.UpdatedAt.IsZero() {
+			if _, err := tx.Exec(ctx, `DELETE FROM rate_limits WHERE key=$1`, key); err != nil {
+				return fmt.Errorf("delete rate limit: %w", err)
+			}
+			return nil
+		}
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO rate_limits (key, tokens, failures, locked_until, updated_at) VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (key) DO UPDATE SET tokens=EXCLUDED.tokens, failures=EXCLUDED.failures,
+				locked_until=EXCLUDED.locked_until, updated_at=EXCLUDED.updated_at`,
+			key, next.Tokens, next.Failures, next.LockedUntil, next.UpdatedAt); err != nil {
+			return fmt.Errorf("save rate limit: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return RateLimit{}, err
+	}
+	return next, nil
+}
+
+// PurgeRateLimits removes keys idle since before.
+func (s *PostgresStore) PurgeRateLimits(ctx context.Context, before time.Time) (int, error) {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM rate_limits WHERE updated_at < $1 AND (locked_until IS NULL OR locked_until < $1)`, before)
+	if err != nil {
+		return 0, fmt.Errorf("purge rate limits: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// RecordAuditEvent appends the event to audit_events.
+func (s *PostgresStore) RecordAuditEvent(ctx context.Context, event AuditEvent) (AuditEvent, error) {
+	event, err := prepareAuditEvent(event)
+	if err != nil {
+		return AuditEvent{}, err
+	}
+	var changes []byte
+	if len(event.Changes) > 0 {
+		if changes, err = json.Marshal(event.Changes); err != nil {
+			return AuditEvent{}, fmt.Errorf("marshal audit changes: %w", err)
+		}
+	}
+	if _, err := s.pool.Exec(ctx, `INSERT INTO audit_events (`+auditEventColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		event.ID, event.OrgID, event.ActorID, event.ActorEmail, event.Action, event.EntityType, event.EntityID,
+		changes, event.RequestID, event.IP, event.CreatedAt); err != nil {
+		return AuditEvent{}, fmt.Errorf("insert audit event: %w", err)
+	}
+	return event, nil
+}
+
+// ListAuditEvents returns one page of matching events, newest first.
+func (s *PostgresStore) ListAuditEvents(ctx context.Context, query AuditQuery) (AuditPage, error) {
+	q := query.normalize()
+	where, args := q.where(func(n int) string { return fmt.Sprintf("$%d", n) }, func(t time.Time) any { return t })
+	page := AuditPage{Page: q.Page, PageSize: q.PageSize, Events: []AuditEvent{}}
+	if err := s.pool.QueryRow(ctx, `SELECT count(*) FROM audit_events`+where, args...).Scan(&page.Total); err != nil {
+		return AuditPage{}, fmt.Errorf("count audit events: %w", err)
+	}
+	rows, err := s.pool.Query(ctx, fmt.Sprintf(`SELECT `+auditEventColumns+` FROM audit_events%s ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d`, where, len(args)+1, len(args)+2),
+		append(args, q.PageSize, q.offset())...)
+	if err != nil {
+		return AuditPage{}, fmt.Errorf("query audit events: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			event   AuditEvent
+			changes []byte
+		)
+		if err := rows.Scan(&event.ID, &event.OrgID, &event.ActorID, &event.ActorEmail, &event.Action, &event.EntityType, &event.EntityID,
+			&changes, &event.RequestID, &event.IP, &event.CreatedAt); err != nil {
+			return AuditPage{}, fmt.Errorf("scan audit event: %w", err)
+		}
+		if len(changes) > 0 {
+			if err := json.Unmarshal(changes, &event.Changes); err != nil {
+				return AuditPage{}, fmt.Errorf("decode audit changes: %w", err)
+			}
+		}
+		page.Events = append(page.Events, event)
+	}
+	return page, rows.Err()
+}
+
+// RecordLLMUsage appends the call to llm_usage.
+func (s *PostgresStore) RecordLLMUsage(ctx context.Context, usage LLMUsage) (LLMUsage, error) {
+	usage, err := prepareLLMUsage(usage)
+	if err != nil {
+		return LLMUsage{}, err
+	}
+	if _, err := s.pool.Exec(ctx, `INSERT INTO llm_usage (`+llmUsageColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		usage.ID, usage.UserID, usage.OrgID, usage.ListingID, usage.Operation, usage.Model,
+		usage.PromptTokens, usage.CompletionTokens, usage.CreatedAt); err != nil {
+		return LLMUsage{}, fmt.Errorf("insert llm usage: %w", err)
+	}
+	return usage, nil
+}
+
+// SummarizeLLMUsage sums matching calls per UTC day, organization, model and operation.
+func (s *PostgresStore) SummarizeLLMUsage(ctx context.Context, query UsageQuery) ([]UsageDay, error) {
+	where, args := query.where(func(n int) string { return fmt.Sprintf("$%d", n) }, func(t time.Time) any { return t })
+	rows, err := s.pool.Query(ctx, `SELECT to_char(created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD') AS day, org_id, model, operation, count(*), sum(prompt_tokens), sum(completion_tokens)
+		FROM llm_usage`+where+` GROUP BY day, org_id, model, operation ORDER BY day, org_id, model, operation`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query llm usage: %w", err)
+	}
+	defer rows.Close()
+	days := []UsageDay{}
+	for rows.Next() {
+		var day UsageDay
+		if err := rows.Scan(&day.Day, &day.OrgID, &day.Model, &day.Operation, &day.Requests, &day.PromptTokens, &day.CompletionTokens); err != nil {
+			return nil, fmt.Errorf("scan llm usage: %w", err)
+		}
+		days = append(days, day)
+	}
+	return days, rows.Err()
+}
+
 type rowScanner interface {
 	Scan(dest ...any) error
 }
 
 func scanListing(row rowScanner) (Listing, error) {
+	return scanListingWith(row)
+}
+
+// scanListingWith scans the listing columns followed by any extra destinations.
+func scanListingWith(row rowScanner, extra ...any) (Listing, error) {
 	var (
-		item         Listing
-		ownerID      sql.NullString
-		imageURL     sql.NullString
-		fee          sql.NullInt64
-		livingArea   sql.NullFloat64
-		rooms        sql.NullFloat64
-		sectionsJSON []byte
-		fullCopy     sql.NullString
-		historyJSON  []byte
-		statusJSON   []byte
-		detailsJSON  []byte
-		insightsJSON []byte
+		item Listing
+		raw  listingRow
 	)
-	if err := row.Scan(&item.ID, &ownerID, &item.Address, &item.Tone, &item.TargetAudience, &item.Highlights, &imageURL, &fee, &livingArea, &rooms, &sectionsJSON, &fullCopy, &historyJSON, &statusJSON, &detailsJSON, &insightsJSON, &item.CreatedAt); err != nil {
+	dest := []any{&item.ID, &raw.ownerID, &item.OrgID, &item.Address, &raw.neighborhood, &raw.city, &raw.propertyType, &raw.condition, &raw.balcony, &raw.floor, &raw.association, &raw.length, &item.Tone, &item.TargetAudience, &item.Highlights, &raw.imageURL, &raw.fee, &raw.livingArea, &raw.rooms, &raw.sectionsJSON, &raw.fullCopy, &raw.statusJSON, &raw.detailsJSON, &raw.insightsJSON, &item.CreatedAt, &item.Version, &item.DeletedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
 		return Listing{}, fmt.Errorf("scan listing: %w", err)
 	}
-	if ownerID.Valid {
-		item.OwnerID = ownerID.String
+	if err := raw.decode(&item); err != nil {
+		return Listing{}, err
 	}
-	if imageURL.Valid {
-		item.ImageURL = imageURL.String
+	return item, nil
+}
+
+// listingRow holds the nullable and JSON listing columns while a row is scanned.
+type listingRow struct {
+	ownerID      sql.NullString
+	neighborhood sql.NullString
+	city         sql.NullString
+	propertyType sql.NullString
+	condition    sql.NullString
+	balcony      sql.NullBool
+	floor        sql.NullString
+	association  sql.NullString
+	length       sql.NullString
+	imageURL     sql.NullString
+	fee          sql.NullInt64
+	livingArea   sql.NullFloat64
+	rooms        sql.NullFloat64
+	sectionsJSON []byte
+	fullCopy     sql.NullString
+	statusJSON   []byte
+	detailsJSON  []byte
+	insightsJSON []byte
+}
+
+// decode copies the scanned columns into item.
+func (r listingRow) decode(item *Listing) error {
+	if r.ownerID.Valid {
+		item.OwnerID = r.ownerID.String
+	}
+	item.Neighborhood = r.neighborhood.String
+	item.City = r.city.String
+	item.PropertyType = r.propertyType.String
+	item.Condition = r.condition.String
+	item.Balcony = r.balcony.Valid && r.balcony.Bool
+	item.Floor = r.floor.String
+	item.Association = r.association.String
+	item.Length = r.length.String
+	if r.imageURL.Valid {
+		item.ImageURL = r.imageURL.String
 	}
-	if fee.Valid {
-		item.Fee = int(fee.Int64)
+	if r.fee.Valid {
+		item.Fee = int(r.fee.Int64)
 	}
-	if livingArea.Valid {
-		item.LivingArea = livingArea.Float64
+	if r.livingArea.Valid {
+		item.LivingArea = r.livingArea.Float64
 	}
-	if rooms.Valid {
-		item.Rooms = rooms.Float64
+	if r.rooms.Valid {
+		item.Rooms = r.rooms.Float64
 	}
-	if len(sectionsJSON) > 0 {
-		if err := json.Unmarshal(sectionsJSON, &item.Sections); err != nil {
-			return Listing{}, fmt.Errorf("unmarshal sections: %w", err)
+	if len(r.sectionsJSON) > 0 {
+		if err := json.Unmarshal(r.sectionsJSON, &item.Sections); err != nil {
+			return fmt.Errorf("unmarshal sections: %w", err)
 		}
 	}
-	if fullCopy.Valid {
-		item.FullCopy = fullCopy.String
+	if r.fullCopy.Valid {
+		item.FullCopy = r.fullCopy.String
 	}
-	if len(historyJSON) > 0 {
-		if err := json.Unmarshal(historyJSON, &item.History); err != nil {
-			return Listing{}, fmt.Errorf("unmarshal history: %w", err)
+	if len(r.statusJSON) > 0 {
+		if err := json.Unmarshal(r.statusJSON, &item.Status); err != nil {
+			return fmt.Errorf("unmarshal status: %w", err)
 		}
 	}
-	if len(statusJSON) > 0 {
-		if err := json.Unmarshal(statusJSON, &item.Status); err != nil {
-			return Listing{}, fmt.Errorf("unmarshal status: %w", err)
+	if len(r.detailsJSON) > 0 {
+		if err := json.Unmarshal(r.detailsJSON, &item.Details); err != nil {
+			return fmt.Errorf("unmarshal details: %w", err)
 		}
 	}
-	if len(detailsJSON) > 0 {
-		if err := json.Unmarshal(detailsJSON, &item.Details); err != nil {
-			return Listing{}, fmt.Errorf("unmarshal details: %w", err)
+	if len(r.insightsJSON) > 0 {
+		if err := json.Unmarshal(r.insightsJSON, &item.Insights); err != nil {
+			return fmt.Errorf("unmarshal insights: %w", err)
 		}
 	}
-	if len(insightsJSON) > 0 {
-		if err := json.Unmarshal(insightsJSON, &item.Insights); err != nil {
-			return Listing{}, fmt.Errorf("unmarshal insights: %w", err)
-		}
+	return nil
+}
+
+func scanRevision(row rowScanner) (Revision, error) {
+	var (
+		rev            Revision
+		instruction    sql.NullString
+		tone           sql.NullString
+		targetAudience sql.NullString
+		notes          sql.NullString
+		authorID       sql.NullString
+	)
+	if err := row.Scan(&rev.ID, &rev.ListingID, &rev.Slug, &rev.Number, &rev.Title, &rev.Content, &rev.Source, &instruction, &tone, &targetAudience, &rev.Highlights, &notes, &authorID, &rev.CreatedAt); err != nil {
+		return Revision{}, fmt.Errorf("scan revision: %w", err)
 	}
-	return item, nil
+	rev.Instruction = instruction.String
+	rev.Tone = tone.String
+	rev.TargetAudience = targetAudience.String
+	rev.Notes = notes.String
+	rev.AuthorID = authorID.String
+	return rev, nil
 }
 
 func scanStyleProfile(row rowScanner) (StyleProfile, error) {
@@ -429,7 +1256,7 @@ func scanStyleProfile(row rowScanner) (StyleProfile, error) {
 		datasetURI  sql.NullString
 		lastTrained sql.NullTime
 	)
-	if err := row.Scan(&profile.ID, &profile.Name, &profile.Description, &profile.Tone, &profile.Guidelines, &profile.ExampleTexts, &profile.ForbiddenWords, &customModel, &datasetURI, &lastTrained, &profile.CreatedAt, &profile.UpdatedAt); err != nil {
+	if err := row.Scan(&profile.ID, &profile.OrgID, &profile.Name, &profile.Description, &profile.Tone, &profile.Guidelines, &profile.ExampleTexts, &profile.ForbiddenWords, &customModel, &datasetURI, &lastTrained, &profile.CreatedAt, &profile.UpdatedAt); err != nil {
 		return StyleProfile{}, fmt.Errorf("scan style profile: %w", err)
 	}
 	if customModel.Valid {
@@ -445,9 +1272,43 @@ func scanStyleProfile(row rowScanner) (StyleProfile, error) {
 	return profile, nil
 }
 
+func scanSession(row rowScanner) (Session, error) {
+	var session Session
+	if err := row.Scan(&session.ID, &session.UserID, &session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt, &session.UserAgent, &session.IP); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Session{}, ErrNotFound
+		}
+		return Session{}, fmt.Errorf("scan session: %w", err)
+	}
+	return session, nil
+}
+
+func scanAPIToken(row rowScanner) (APIToken, error) {
+	var (
+		token     APIToken
+		lastUsed  sql.NullTime
+		expiresAt sql.NullTime
+	)
+	if err := row.Scan(&token.ID, &token.UserID, &token.Name, &token.Prefix, &token.TokenHash, &token.Scopes, &token.CreatedAt, &lastUsed, &expiresAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return APIToken{}, ErrNotFound
+		}
+		return APIToken{}, fmt.Errorf("scan api token: %w", err)
+	}
+	if lastUsed.Valid {
+		t := lastUsed.Time
+		token.LastUsedAt = &t
+	}
+	if expiresAt.Valid {
+		t := expiresAt.Time
+		token.ExpiresAt = &t
+	}
+	return token, nil
+}
+
 func scanUser(row rowScanner) (User, error) {
 	var user User
-	if err := row.Scan(&user.ID, &user.Email, &user.PasswordHash, &user.Approved, &user.CreatedAt); err != nil {
+	if err := row.Scan(&user.ID, &user.Email, &user.PasswordHash, &user.OrgID, &user.Role, &user.Approved, &user.EmailVerified, &user.CreatedAt); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return User{}, ErrNotFound
 		}
@@ -0,0 +1,320 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor indicates that a pagination cursor could not be decoded or
+// does not belong to the requested sort order.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+const (
+	// DefaultListingLimit is used when a query does not specify a page size.
+	DefaultListingLimit = 50
+	// MaxListingLimit caps how many listings a single page may return.
+	MaxListingLimit = 200
+)
+
+// ListingSort selects the column listings are ordered by.
+type ListingSort string
+
+const (
+	SortByCreatedAt ListingSort = "created_at"
+	SortByAddress   ListingSort = "address"
+	SortByStatus    ListingSort = "status"
+)
+
+// SortOrder is the direction of a listing sort. Empty means the sort's default:
+// newest first for created_at, alphabetical for address and status.
+type SortOrder string
+
+const (
+	OrderAsc  SortOrder = "asc"
+	OrderDesc SortOrder = "desc"
+)
+
+// Pipeline states reported by Status.Overall.
+const (
+	PipelinePending    = "pending"
+	PipelineInProgress = "in_progress"
+	PipelineCompleted  = "completed"
+	PipelineFailed     = "failed"
+)
+
+// ListListingsQuery narrows, orders and pages a listing lookup.
+type ListListingsQuery struct {
+	OrgID          string
+	OwnerID        string
+	Cursor         string
+	Limit          int
+	Sort           ListingSort
+	Order          SortOrder
+	City           string
+	PropertyType   string
+	Status         string
+	StyleProfileID string
+}
+
+// ListingPage is a single page of listings plus the cursor for the next one.
+type ListingPage struct {
+	Listings   []Listing `json:"listings"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+// ParseListingSort validates a user supplied sort key, defaulting to created_at.
+func ParseListingSort(value string) (ListingSort, error) {
+	switch ListingSort(strings.ToLower(strings.TrimSpace(value))) {
+	case "", SortByCreatedAt:
+		return SortByCreatedAt, nil
+	case SortByAddress:
+		return SortByAddress, nil
+	case SortByStatus:
+		return SortByStatus, nil
+	default:
+		return "", fmt.Errorf("unknown sort %q", value)
+	}
+}
+
+// ParseSortOrder validates a user supplied sort direction.
+func ParseSortOrder(value string) (SortOrder, error) {
+	switch SortOrder(strings.ToLower(strings.TrimSpace(value))) {
+	case "":
+		return "", nil
+	case OrderAsc:
+		return OrderAsc, nil
+	case OrderDesc:
+		return OrderDesc, nil
+	default:
+		return "", fmt.Errorf("unknown order %q", value)
+	}
+}
+
+// ParseTimeBound reads a user supplied since or until bound: an RFC 3339 time
+// or a date. Dates are UTC days, matching the days usage is summarized by; as
+// an end bound a date means the end of that day. Empty gives the zero time.
+func ParseTimeBound(raw string, end bool) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	day, err := time.Parse(time.DateOnly, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("ange en tid som 2006-01-02 eller 2006-01-02T15:04:05Z")
+	}
+	if end {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day, nil
+}
+
+// Overall collapses the per-step pipeline status into a single state.
+func (s Status) Overall() string {
+	steps := []string{s.Data, s.Vision, s.Geodata, s.Text}
+	done := true
+	running := false
+	for _, step := range steps {
+		switch step {
+		case PipelineFailed:
+			return PipelineFailed
+		case PipelineCompleted, "skipped":
+		case PipelineInProgress:
+			running = true
+			done = false
+		default:
+			done = false
+		}
+	}
+	switch {
+	case done:
+		return PipelineCompleted
+	case running:
+		return PipelineInProgress
+	default:
+		return PipelinePending
+	}
+}
+
+// normalize fills defaults and clamps the limit.
+func (q ListListingsQuery) normalize() ListListingsQuery {
+	if q.Sort == "" {
+		q.Sort = SortByCreatedAt
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultListingLimit
+	}
+	if q.Limit > MaxListingLimit {
+		q.Limit = MaxListingLimit
+	}
+	q.City = strings.TrimSpace(q.City)
+	q.PropertyType = strings.TrimSpace(q.PropertyType)
+	q.Status = strings.ToLower(strings.TrimSpace(q.Status))
+	q.StyleProfileID = strings.TrimSpace(q.StyleProfileID)
+	return q
+}
+
+// descending reports the effective direction, applying the per-sort default.
+func (q ListListingsQuery) descending() bool {
+	switch q.Order {
+	case OrderAsc:
+		return false
+	case OrderDesc:
+		return true
+	default:
+		return q.Sort == SortByCreatedAt
+	}
+}
+
+type listingCursor struct {
+	Sort  ListingSort `json:"s"`
+	Desc  bool        `json:"d"`
+	Value string      `json:"v"`
+	ID    string      `json:"id"`
+}
+
+func encodeCursor(c listingCursor) string {
+	payload, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+func decodeCursor(raw string, q ListListingsQuery) (*listingCursor, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c listingCursor
+	if err := json.Unmarshal(payload, &c); err != nil || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort != q.Sort || c.Desc != q.descending() {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort == SortByCreatedAt {
+		if _, err := time.Parse(time.RFC3339Nano, c.Value); err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return &c, nil
+}
+
+// sortValue returns the key a listing is ordered by for the given sort.
+func sortValue(l Listing, by ListingSort) string {
+	switch by {
+	case SortByAddress:
+		return strings.ToLower(l.Address)
+	case SortByStatus:
+		return l.Status.Overall()
+	default:
+		return l.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
+}
+
+func cursorFor(l Listing, q ListListingsQuery) string {
+	return encodeCursor(listingCursor{
+		Sort:  q.Sort,
+		Desc:  q.descending(),
+		Value: sortValue(l, q.Sort),
+		ID:    l.ID,
+	})
+}
+
+func listingCity(l Listing) string {
+	if l.City != "" {
+		return l.City
+	}
+	return l.Details.Property.City
+}
+
+func listingPropertyType(l Listing) string {
+	if l.PropertyType != "" {
+		return l.PropertyType
+	}
+	return l.Details.Property.PropertyType
+}
+
+// matches applies the query filters to a listing in memory.
+func (q ListListingsQuery) matches(l Listing) bool {
+	if q.OrgID != "" && l.OrgID != q.OrgID {
+		return false
+	}
+	if q.OwnerID != "" && l.OwnerID != q.OwnerID {
+		return false
+	}
+	if q.City != "" && !strings.EqualFold(listingCity(l), q.City) {
+		return false
+	}
+	if q.PropertyType != "" && !strings.EqualFold(listingPropertyType(l), q.PropertyType) {
+		return false
+	}
+	if q.Status != "" && l.Status.Overall() != q.Status {
+		return false
+	}
+	if q.StyleProfileID != "" && strings.TrimSpace(l.Details.Meta.StyleProfileID) != q.StyleProfileID {
+		return false
+	}
+	return true
+}
+
+// compareListings orders two listings by sort key, then ID, honouring direction.
+func compareListings(a, b Listing, q ListListingsQuery) int {
+	return compareKeys(sortValue(a, q.Sort), a.ID, sortValue(b, q.Sort), b.ID, q.Sort, q.descending())
+}
+
+func compareKeys(av, aid, bv, bid string, by ListingSort, desc bool) int {
+	cmp := 0
+	if by == SortByCreatedAt {
+		at, _ := time.Parse(time.RFC3339Nano, av)
+		bt, _ := time.Parse(time.RFC3339Nano, bv)
+		cmp = at.Compare(bt)
+	} else {
+		cmp = strings.Compare(av, bv)
+	}
+	if cmp == 0 {
+		cmp = strings.Compare(aid, bid)
+	}
+	if desc {
+		return -cmp
+	}
+	return cmp
+}
+
+// pageListings filters, sorts and slices an in-memory listing set.
+func pageListings(all []Listing, q ListListingsQuery) (ListingPage, error) {
+	q = q.normalize()
+	cursor, err := decodeCursor(q.Cursor, q)
+	if err != nil {
+		return ListingPage{}, err
+	}
+
+	matched := make([]Listing, 0, len(all))
+	for _, l := range all {
+		if !q.matches(l) {
+			continue
+		}
+		if cursor != nil && compareKeys(sortValue(l, q.Sort), l.ID, cursor.Value, cursor.ID, q.Sort, q.descending()) <= 0 {
+			continue
+		}
+		matched = append(matched, l)
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		return compareListings(matched[i], matched[j], q) < 0
+	})
+
+	page := ListingPage{Listings: matched}
+	if len(matched) > q.Limit {
+		page.Listings = matched[:q.Limit]
+		page.NextCursor = cursorFor(page.Listings[q.Limit-1], q)
+	}
+	return page, nil
+}
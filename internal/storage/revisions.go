@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// LegacyHistoryDepth is how many revisions per section are copied into
+	// Listing.History for clients that still read section_history.
+	LegacyHistoryDepth = 5
+
+	// DefaultRevisionPageSize is used when a history request does not specify a page size.
+	DefaultRevisionPageSize = 20
+	// MaxRevisionPageSize caps how many revisions a single history page may return.
+	MaxRevisionPageSize = 100
+)
+
+// Revision is one stored version of a listing section. Numbers start at 1 and
+// increase per listing and slug.
+type Revision struct {
+	ID             string    `json:"id"`
+	ListingID      string    `json:"listing_id"`
+	Slug           string    `json:"slug"`
+	Number         int       `json:"revision"`
+	Title          string    `json:"title"`
+	Content        string    `json:"content"`
+	Source         string    `json:"source"`
+	Instruction    string    `json:"instruction,omitempty"`
+	Tone           string    `json:"tone,omitempty"`
+	TargetAudience string    `json:"target_audience,omitempty"`
+	Highlights     []string  `json:"highlights,omitempty"`
+	Notes          string    `json:"notes,omitempty"`
+	AuthorID       string    `json:"author_id,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// ListRevisionsQuery pages through the revisions of one section, newest first.
+type ListRevisionsQuery struct {
+	ListingID string
+	Slug      string
+	Page      int
+	PageSize  int
+}
+
+// RevisionPage is one page of section revisions.
+type RevisionPage struct {
+	Revisions []Revision `json:"revisions"`
+	Page      int        `json:"page"`
+	PageSize  int        `json:"page_size"`
+	Total     int        `json:"total"`
+}
+
+func (q ListRevisionsQuery) normalize() ListRevisionsQuery {
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.PageSize <= 0 {
+		q.PageSize = DefaultRevisionPageSize
+	}
+	if q.PageSize > MaxRevisionPageSize {
+		q.PageSize = MaxRevisionPageSize
+	}
+	return q
+}
+
+func (q ListRevisionsQuery) offset() int {
+	return (q.Page - 1) * q.PageSize
+}
+
+// SectionVersion converts a revision into the legacy history entry shape.
+func (r Revision) SectionVersion() SectionVersion {
+	return SectionVersion{
+		Title:          r.Title,
+		Content:        r.Content,
+		Source:         r.Source,
+		Instruction:    r.Instruction,
+		Tone:           r.Tone,
+		TargetAudience: r.TargetAudience,
+		Highlights:     r.Highlights,
+		Notes:          r.Notes,
+		Timestamp:      r.CreatedAt,
+	}
+}
+
+// historyFromRevisions builds the legacy History map from the newest
+// LegacyHistoryDepth revisions of every section.
+func historyFromRevisions(revisions []Revision) History {
+	sorted := append([]Revision(nil), revisions...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Slug != sorted[j].Slug {
+			return sorted[i].Slug < sorted[j].Slug
+		}
+		return sorted[i].Number > sorted[j].Number
+	})
+
+	history := History{}
+	for _, rev := range sorted {
+		if len(history[rev.Slug]) < LegacyHistoryDepth {
+			history[rev.Slug] = append(history[rev.Slug], rev.SectionVersion())
+		}
+	}
+	return history
+}
@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// DefaultSearchLimit is used when a search does not specify a result count.
+	DefaultSearchLimit = 20
+	// MaxSearchLimit caps how many hits a single search may return.
+	MaxSearchLimit = 100
+
+	snippetRadius = 60
+
+	// Sentinels wrapped around matches before the snippet is HTML-escaped, so the
+	// only markup in the final snippet is the <mark> tags we add ourselves.
+	highlightStart = "\x01"
+	highlightStop  = "\x02"
+)
+
+// SearchListingsQuery describes a free-text search over a user's listings.
+type SearchListingsQuery struct {
+	OrgID   string
+	OwnerID string
+	Text    string
+	Limit   int
+}
+
+// SearchResult is a listing hit with its relevance and a highlighted excerpt.
+type SearchResult struct {
+	Listing Listing `json:"listing"`
+	Rank    float64 `json:"rank"`
+	// Snippet is HTML-escaped text where matches are wrapped in <mark>…</mark>.
+	Snippet string `json:"snippet"`
+}
+
+func (q SearchListingsQuery) normalize() SearchListingsQuery {
+	q.Text = strings.TrimSpace(q.Text)
+	if q.Limit <= 0 {
+		q.Limit = DefaultSearchLimit
+	}
+	if q.Limit > MaxSearchLimit {
+		q.Limit = MaxSearchLimit
+	}
+	return q
+}
+
+// searchTerms splits a query into lower-cased words, dropping quotes and operators.
+func searchTerms(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-'
+	})
+	terms := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if f = strings.Trim(f, "-"); f != "" {
+			terms = append(terms, f)
+		}
+	}
+	return terms
+}
+
+// searchableFields returns the text of a listing in the order snippets prefer it.
+func searchableFields(l Listing) []string {
+	fields := []string{l.Address, listingCity(l)}
+	for _, section := range l.Sections {
+		fields = append(fields, section.Title, section.Content)
+	}
+	return append(fields, l.FullCopy)
+}
+
+// searchListings is the naive substring search used by the in-memory store:
+// every term must occur somewhere in the listing, rank is the number of hits.
+func searchListings(all []Listing, query SearchListingsQuery) []SearchResult {
+	q := query.normalize()
+	terms := searchTerms(q.Text)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	var results []SearchResult
+	for _, l := range all {
+		if (q.OrgID != "" && l.OrgID != q.OrgID) || (q.OwnerID != "" && l.OwnerID != q.OwnerID) {
+			continue
+		}
+		fields := searchableFields(l)
+		haystack := strings.ToLower(strings.Join(fields, "\n"))
+		hits := 0
+		for _, term := range terms {
+			n := strings.Count(haystack, term)
+			if n == 0 {
+				hits = 0
+				break
+			}
+			hits += n
+		}
+		if hits == 0 {
+			continue
+		}
+		results = append(results, SearchResult{
+			Listing: l,
+			Rank:    float64(hits),
+			Snippet: buildSnippet(fields, terms),
+		})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].Listing.CreatedAt.After(results[j].Listing.CreatedAt)
+	})
+	if len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+	return results
+}
+
+// buildSnippet cuts a window around the first match in the first matching field
+// and highlights every term occurrence inside it.
+func buildSnippet(fields []string, terms []string) string {
+	for _, field := range fields {
+		runes := []rune(field)
+		lower := lowerRunes(runes)
+		start := -1
+		for _, term := range terms {
+			if idx := indexRunes(lower, []rune(term), 0); idx >= 0 && (start == -1 || idx < start) {
+				start = idx
+			}
+		}
+		if start == -1 {
+			continue
+		}
+
+		from := max(start-snippetRadius, 0)
+		to := min(start+snippetRadius, len(runes))
+		marked := highlightRunes(runes[from:to], lower[from:to], terms)
+		if from > 0 {
+			marked = "…" + marked
+		}
+		if to < len(runes) {
+			marked += "…"
+		}
+		return renderHighlights(strings.Join(strings.Fields(marked), " "))
+	}
+	return ""
+}
+
+func highlightRunes(original, lower []rune, terms []string) string {
+	marks := make([]bool, len(original))
+	for _, term := range terms {
+		t := []rune(term)
+		for idx := indexRunes(lower, t, 0); idx >= 0; idx = indexRunes(lower, t, idx+len(t)) {
+			for i := idx; i < idx+len(t); i++ {
+				marks[i] = true
+			}
+		}
+	}
+
+	var b strings.Builder
+	open := false
+	for i, r := range original {
+		if marks[i] && !open {
+			b.WriteString(highlightStart)
+			open = true
+		} else if !marks[i] && open {
+			b.WriteString(highlightStop)
+			open = false
+		}
+		b.WriteRune(r)
+	}
+	if open {
+		b.WriteString(highlightStop)
+	}
+	return b.String()
+}
+
+// renderHighlights escapes snippet text and turns the sentinels into <mark> tags.
+func renderHighlights(text string) string {
+	escaped := html.EscapeString(text)
+	escaped = strings.ReplaceAll(escaped, highlightStart, "<mark>")
+	return strings.ReplaceAll(escaped, highlightStop, "</mark>")
+}
+
+// lowerRunes lower-cases rune by rune so indexes stay aligned with the original,
+// which strings.ToLower does not guarantee for every Unicode letter.
+func lowerRunes(runes []rune) []rune {
+	out := make([]rune, len(runes))
+	for i, r := range runes {
+		out[i] = unicode.ToLower(r)
+	}
+	return out
+}
+
+func indexRunes(haystack, needle []rune, from int) int {
+	if len(needle) == 0 {
+		return -1
+	}
+	for i := from; i+len(needle) <= len(haystack); i++ {
+		match := true
+		for j := range needle {
+			if haystack[i+j] != needle[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,1334 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// sqliteURLPrefix selects the SQLite store: sqlite:///var/lib/k2/k2.db opens
+// /var/lib/k2/k2.db, sqlite://k2.db a file relative to the working directory.
+const sqliteURLPrefix = "sqlite://"
+
+// sqliteTimeLayout is fixed width and always UTC so stored timestamps sort as text.
+const sqliteTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// SQLiteStore persists listings in a single SQLite file. Columns mirror the
+// PostgreSQL schema; JSONB and array columns are stored as JSON text.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+func isSQLiteURL(databaseURL string) bool {
+	return strings.HasPrefix(databaseURL, sqliteURLPrefix)
+}
+
+// openSQLite opens the database file named by a sqlite:// URL. The pool is
+// limited to one connection: SQLite allows a single writer anyway, and it keeps
+// version checks and revision numbering free of SQLITE_BUSY retries.
+func openSQLite(ctx context.Context, databaseURL string) (*sql.DB, error) {
+	path := strings.TrimPrefix(databaseURL, sqliteURLPrefix)
+	if path == "" {
+		return nil, fmt.Errorf("sqlite database path is required")
+	}
+	dsn := "file:" + path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("open sqlite %s: %w", path, err)
+	}
+	return db, nil
+}
+
+// newSQLiteStore opens the database and applies pending migrations.
+func newSQLiteStore(ctx context.Context, databaseURL string) (*SQLiteStore, error) {
+	db, err := openSQLite(ctx, databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	migrator, err := newSQLiteMigrator(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate schema: %w", err)
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+// CreateListing stores the provided listing and its first revisions.
+func (s *SQLiteStore) CreateListing(ctx context.Context, input Listing, revisions []Revision) (Listing, error) {
+	if input.ID == "" {
+		input.ID = uuid.NewString()
+	}
+	if input.CreatedAt.IsZero() {
+		input.CreatedAt = time.Now()
+	}
+	if input.OrgID == "" {
+		input.OrgID = DefaultOrganizationID
+	}
+	input.Version = 1
+
+	highlightsJSON, err := json.Marshal(input.Highlights)
+	if err != nil {
+		return Listing{}, fmt.Errorf("marshal highlights: %w", err)
+	}
+	sectionsJSON, err := json.Marshal(input.Sections)
+	if err != nil {
+		return Listing{}, fmt.Errorf("marshal sections: %w", err)
+	}
+	insightsJSON, err := json.Marshal(input.Insights)
+	if err != nil {
+		return Listing{}, fmt.Errorf("marshal insights: %w", err)
+	}
+	statusJSON, err := json.Marshal(input.Status)
+	if err != nil {
+		return Listing{}, fmt.Errorf("marshal status: %w", err)
+	}
+	detailsJSON, err := json.Marshal(input.Details)
+	if err != nil {
+		return Listing{}, fmt.Errorf("marshal details: %w", err)
+	}
+
+	err = s.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO listings (id, owner_id, org_id, address, neighborhood, city, property_type, condition, balcony, floor, association, length, tone, target_audience, highlights, image_url, fee, living_area, rooms, sections, full_copy, pipeline_status, details, insights, created_at, version) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			input.ID, input.OwnerID, input.OrgID, input.Address, nullString(input.Neighborhood), nullString(input.City), nullString(input.PropertyType), nullString(input.Condition), input.Balcony, nullString(input.Floor), nullString(input.Association), nullString(input.Length), input.Tone, input.TargetAudience, string(highlightsJSON), input.ImageURL, input.Fee, input.LivingArea, input.Rooms, string(sectionsJSON), input.FullCopy, string(statusJSON), string(detailsJSON), string(insightsJSON), sqliteTimestamp(input.CreatedAt), input.Version); err != nil {
+			return fmt.Errorf("insert listing: %w", err)
+		}
+		return sqliteInsertRevisions(ctx, tx, input.ID, revisions)
+	})
+	if err != nil {
+		return Listing{}, err
+	}
+
+	return s.withHistory(ctx, input)
+}
+
+// ListListings returns the DefaultListingLimit most recent listings.
+func (s *SQLiteStore) ListListings(ctx context.Context) ([]Listing, error) {
+	return s.fetchListings(ctx, `SELECT `+listingColumns+` FROM listings WHERE deleted_at IS NULL ORDER BY created_at DESC LIMIT ?`, DefaultListingLimit)
+}
+
+// QueryListings returns one page of listings matching the query filters. The
+// scoped listings are filtered and sorted in Go with the same code as the
+// in-memory store, so ordering and cursors behave identically.
+func (s *SQLiteStore) QueryListings(ctx context.Context, query ListListingsQuery) (ListingPage, error) {
+	all, err := s.scopedListings(ctx, query.OrgID, query.OwnerID)
+	if err != nil {
+		return ListingPage{}, err
+	}
+	return pageListings(all, query)
+}
+
+// SearchListings runs the in-memory substring search over the scoped listings.
+func (s *SQLiteStore) SearchListings(ctx context.Context, query SearchListingsQuery) ([]SearchResult, error) {
+	if query.normalize().Text == "" {
+		return nil, nil
+	}
+	all, err := s.scopedListings(ctx, query.OrgID, query.OwnerID)
+	if err != nil {
+		return nil, err
+	}
+	return searchListings(all, query), nil
+}
+
+// scopedListings loads the live listings of an organization and/or owner; empty
+// values do not narrow the result.
+func (s *SQLiteStore) scopedListings(ctx context.Context, orgID, ownerID string) ([]Listing, error) {
+	query := `SELECT ` + listingColumns + ` FROM listings WHERE deleted_at IS NULL`
+	var args []any
+	if orgID != "" {
+		query += ` AND org_id=?`
+		args = append(args, orgID)
+	}
+	if ownerID != "" {
+		query += ` AND owner_id=?`
+		args = append(args, ownerID)
+	}
+	return s.fetchListings(ctx, query+` ORDER BY created_at DESC`, args...)
+}
+
+// ListAllListings returns every stored listing (used for dataset exports).
+func (s *SQLiteStore) ListAllListings(ctx context.Context) ([]Listing, error) {
+	return s.fetchListings(ctx, `SELECT `+listingColumns+` FROM listings WHERE deleted_at IS NULL ORDER BY created_at DESC`)
+}
+
+func (s *SQLiteStore) fetchListings(ctx context.Context, query string, args ...any) ([]Listing, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query listings: %w", err)
+	}
+	defer rows.Close()
+
+	var listings []Listing
+	for rows.Next() {
+		item, err := scanSQLiteListing(rows)
+		if err != nil {
+			return nil, err
+		}
+		listings = append(listings, item)
+	}
+	return listings, rows.Err()
+}
+
+// Close releases database resources.
+func (s *SQLiteStore) Close() {
+	if s.db != nil {
+		s.db.Close()
+	}
+}
+
+// GetListing fetches a single listing by ID.
+func (s *SQLiteStore) GetListing(ctx context.Context, id string) (Listing, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+listingColumns+` FROM listings WHERE id=? AND deleted_at IS NULL`, id)
+	item, err := scanSQLiteListing(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Listing{}, ErrNotFound
+		}
+		return Listing{}, err
+	}
+	return s.withHistory(ctx, item)
+}
+
+// UpdateListingSections replaces the sections for a listing and appends the
+// given revisions in the same transaction. Like the Postgres store it leaves
+// the pipeline status alone.
+func (s *SQLiteStore) UpdateListingSections(ctx context.Context, id string, version int, sections []Section, fullCopy string, revisions []Revision) (Listing, error) {
+	payload, err := json.Marshal(sections)
+	if err != nil {
+		return Listing{}, fmt.Errorf("marshal sections: %w", err)
+	}
+
+	var item Listing
+	err = s.withTx(ctx, func(tx *sql.Tx) error {
+		row := tx.QueryRowContext(ctx, `UPDATE listings SET sections=?, full_copy=?, version=version+1 WHERE id=? AND version=? AND deleted_at IS NULL RETURNING `+listingColumns, string(payload), fullCopy, id, version)
+		var err error
+		if item, err = scanSQLiteListing(row); err != nil {
+			return err
+		}
+		return sqliteInsertRevisions(ctx, tx, id, revisions)
+	})
+	if err != nil {
+		return Listing{}, s.versionedUpdateError(ctx, id, err)
+	}
+	return s.withHistory(ctx, item)
+}
+
+// UpdateListingDetails replaces the details JSON (including media) and the cover image.
+func (s *SQLiteStore) UpdateListingDetails(ctx context.Context, id string, version int, details Details, imageURL string) (Listing, error) {
+	payload, err := json.Marshal(details)
+	if err != nil {
+		return Listing{}, fmt.Errorf("marshal details: %w", err)
+	}
+
+	row := s.db.QueryRowContext(ctx, `UPDATE listings SET details=?, image_url=?, version=version+1 WHERE id=? AND version=? AND deleted_at IS NULL RETURNING `+listingColumns, string(payload), imageURL, id, version)
+	return s.scanVersionedUpdate(ctx, id, row)
+}
+
+// UpdateInsights replaces insights JSON and pipeline status.
+func (s *SQLiteStore) UpdateInsights(ctx context.Context, id string, version int, insights Insights, status Status) (Listing, error) {
+	insightsJSON, err := json.Marshal(insights)
+	if err != nil {
+		return Listing{}, fmt.Errorf("marshal insights: %w", err)
+	}
+	statusJSON, err := json.Marshal(status)
+	if err != nil {
+		return Listing{}, fmt.Errorf("marshal status: %w", err)
+	}
+
+	row := s.db.QueryRowContext(ctx, `UPDATE listings SET insights=?, pipeline_status=?, version=version+1 WHERE id=? AND version=? AND deleted_at IS NULL RETURNING `+listingColumns, string(insightsJSON), string(statusJSON), id, version)
+	return s.scanVersionedUpdate(ctx, id, row)
+}
+
+// scanVersionedUpdate reads the row returned by a version-guarded UPDATE.
+func (s *SQLiteStore) scanVersionedUpdate(ctx context.Context, id string, row rowScanner) (Listing, error) {
+	item, err := scanSQLiteListing(row)
+	if err != nil {
+		return Listing{}, s.versionedUpdateError(ctx, id, err)
+	}
+	return s.withHistory(ctx, item)
+}
+
+// versionedUpdateError tells a missing listing (ErrNotFound) apart from a stale
+// version (ErrConflict) when a version-guarded UPDATE matched no row.
+func (s *SQLiteStore) versionedUpdateError(ctx context.Context, id string, err error) error {
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM listings WHERE id=? AND deleted_at IS NULL)`, id).Scan(&exists); err != nil {
+		return fmt.Errorf("check listing version: %w", err)
+	}
+	if exists {
+		return ErrConflict
+	}
+	return ErrNotFound
+}
+
+// DeleteListing moves a listing to the trash by stamping deleted_at.
+func (s *SQLiteStore) DeleteListing(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(ctx, `UPDATE listings SET deleted_at=? WHERE id=? AND deleted_at IS NULL`, sqliteTimestamp(time.Now()), id)
+	if err != nil {
+		return fmt.Errorf("delete listing: %w", err)
+	}
+	return requireAffected(result)
+}
+
+// ListDeletedListings returns trashed listings, most recently deleted first.
+func (s *SQLiteStore) ListDeletedListings(ctx context.Context, orgID, ownerID string) ([]Listing, error) {
+	return s.fetchListings(ctx, `SELECT `+listingColumns+` FROM listings WHERE deleted_at IS NOT NULL AND (?1='' OR org_id=?1) AND (?2='' OR owner_id=?2) ORDER BY deleted_at DESC`, orgID, ownerID)
+}
+
+// RestoreListing takes a listing out of the trash.
+func (s *SQLiteStore) RestoreListing(ctx context.Context, id string) (Listing, error) {
+	row := s.db.QueryRowContext(ctx, `UPDATE listings SET deleted_at=NULL, version=version+1 WHERE id=? AND deleted_at IS NOT NULL RETURNING `+listingColumns, id)
+	item, err := scanSQLiteListing(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Listing{}, ErrNotFound
+		}
+		return Listing{}, err
+	}
+	return s.withHistory(ctx, item)
+}
+
+// PurgeDeletedListings permanently removes listings trashed before the cutoff
+// and returns them so their media can be cleaned up. Revisions go with them
+// through the foreign key cascade.
+func (s *SQLiteStore) PurgeDeletedListings(ctx context.Context, deletedBefore time.Time) ([]Listing, error) {
+	return s.fetchListings(ctx, `DELETE FROM listings WHERE deleted_at IS NOT NULL AND deleted_at < ? RETURNING `+listingColumns, sqliteTimestamp(deletedBefore))
+}
+
+// UpdateStatus updates only the pipeline status column.
+func (s *SQLiteStore) UpdateStatus(ctx context.Context, id string, status Status) error {
+	payload, err := json.Marshal(status)
+	if err != nil {
+		return fmt.Errorf("marshal status: %w", err)
+	}
+
+	result, err := s.db.ExecContext(ctx, `UPDATE listings SET pipeline_status=? WHERE id=? AND deleted_at IS NULL`, string(payload), id)
+	if err != nil {
+		return fmt.Errorf("update status: %w", err)
+	}
+	return requireAffected(result)
+}
+
+// sqliteInsertRevisions appends revisions, numbering each one after the latest for its slug.
+func sqliteInsertRevisions(ctx context.Context, tx *sql.Tx, listingID string, revisions []Revision) error {
+	for _, rev := range revisions {
+		if rev.ID == "" {
+			rev.ID = uuid.NewString()
+		}
+		if rev.CreatedAt.IsZero() {
+			rev.CreatedAt = time.Now()
+		}
+		highlights, err := json.Marshal(rev.Highlights)
+		if err != nil {
+			return fmt.Errorf("marshal revision highlights: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO listing_revisions (id, listing_id, slug, revision, title, content, source, instruction, tone, target_audience, highlights, notes, author_id, created_at)
+			VALUES (?1, ?2, ?3, (SELECT COALESCE(MAX(revision), 0) + 1 FROM listing_revisions WHERE listing_id=?2 AND slug=?3), ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11, ?12, ?13)
+		`, rev.ID, listingID, rev.Slug, rev.Title, rev.Content, rev.Source, nullString(rev.Instruction), nullString(rev.Tone), nullString(rev.TargetAudience), string(highlights), nullString(rev.Notes), nullString(rev.AuthorID), sqliteTimestamp(rev.CreatedAt)); err != nil {
+			return fmt.Errorf("insert revision: %w", err)
+		}
+	}
+	return nil
+}
+
+// withHistory fills the legacy History field from the latest revisions of each section.
+func (s *SQLiteStore) withHistory(ctx context.Context, item Listing) (Listing, error) {
+	revisions, err := s.fetchRevisions(ctx, `SELECT `+revisionColumns+` FROM (
+		SELECT *, row_number() OVER (PARTITION BY slug ORDER BY revision DESC) AS rn
+		FROM listing_revisions WHERE listing_id=?
+	) r WHERE rn <= ?`, item.ID, LegacyHistoryDepth)
+	if err != nil {
+		return Listing{}, err
+	}
+	item.History = historyFromRevisions(revisions)
+	return item, nil
+}
+
+// ListRevisions returns one page of a section's revisions, newest first.
+func (s *SQLiteStore) ListRevisions(ctx context.Context, query ListRevisionsQuery) (RevisionPage, error) {
+	q := query.normalize()
+	page := RevisionPage{Page: q.Page, PageSize: q.PageSize}
+	if err := s.db.QueryRowContext(ctx, `SELECT count(*) FROM listing_revisions WHERE listing_id=? AND slug=?`, q.ListingID, q.Slug).Scan(&page.Total); err != nil {
+		return RevisionPage{}, fmt.Errorf("count revisions: %w", err)
+	}
+	revisions, err := s.fetchRevisions(ctx, `SELECT `+revisionColumns+` FROM listing_revisions WHERE listing_id=? AND slug=? ORDER BY revision DESC LIMIT ? OFFSET ?`, q.ListingID, q.Slug, q.PageSize, q.offset())
+	if err != nil {
+		return RevisionPage{}, err
+	}
+	page.Revisions = revisions
+	if page.Revisions == nil {
+		page.Revisions = []Revision{}
+	}
+	return page, nil
+}
+
+// GetRevision returns a single section revision by number.
+func (s *SQLiteStore) GetRevision(ctx context.Context, listingID, slug string, number int) (Revision, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+revisionColumns+` FROM listing_revisions WHERE listing_id=? AND slug=? AND revision=?`, listingID, slug, number)
+	rev, err := scanSQLiteRevision(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Revision{}, ErrNotFound
+		}
+		return Revision{}, err
+	}
+	return rev, nil
+}
+
+func (s *SQLiteStore) fetchRevisions(ctx context.Context, query string, args ...any) ([]Revision, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query revisions: %w", err)
+	}
+	defer rows.Close()
+
+	var revisions []Revision
+	for rows.Next() {
+		rev, err := scanSQLiteRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
+
+// SaveStyleProfile creates or updates a style profile within its organization.
+func (s *SQLiteStore) SaveStyleProfile(ctx context.Context, profile StyleProfile) (StyleProfile, error) {
+	now := time.Now()
+	if profile.ID == "" {
+		profile.ID = uuid.NewString()
+		profile.CreatedAt = now
+	}
+	if profile.CreatedAt.IsZero() {
+		profile.CreatedAt = now
+	}
+	if profile.OrgID == "" {
+		profile.OrgID = DefaultOrganizationID
+	}
+	profile.UpdatedAt = now
+
+	examples, err := json.Marshal(profile.ExampleTexts)
+	if err != nil {
+		return StyleProfile{}, fmt.Errorf("marshal example texts: %w", err)
+	}
+	forbidden, err := json.Marshal(profile.ForbiddenWords)
+	if err != nil {
+		return StyleProfile{}, fmt.Errorf("marshal forbidden words: %w", err)
+	}
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO style_profiles (id, org_id, name, description, tone, guidelines, example_texts, forbidden_words, custom_model, dataset_uri, last_trained_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			name=excluded.name,
+			description=excluded.description,
+			tone=excluded.tone,
+			guidelines=excluded.guidelines,
+			example_texts=excluded.example_texts,
+			forbidden_words=excluded.forbidden_words,
+			custom_model=excluded.custom_model,
+			dataset_uri=excluded.dataset_uri,
+			last_trained_at=excluded.last_trained_at,
+			updated_at=excluded.updated_at
+		WHERE style_profiles.org_id=excluded.org_id
+	`, profile.ID, profile.OrgID, profile.Name, profile.Description, profile.Tone, profile.Guidelines, string(examples), string(forbidden), nullString(profile.CustomModel), nullString(profile.DatasetURI), sqliteNullTimestamp(profile.LastTrainedAt), sqliteTimestamp(profile.CreatedAt), sqliteTimestamp(profile.UpdatedAt))
+	if err != nil {
+		return StyleProfile{}, fmt.Errorf("save style profile: %w", err)
+	}
+	if err := requireAffected(result); err != nil {
+		return StyleProfile{}, err
+	}
+	return s.GetStyleProfile(ctx, profile.ID)
+}
+
+// ListStyleProfiles returns the organization's style profiles, or all of them when orgID is empty.
+func (s *SQLiteStore) ListStyleProfiles(ctx context.Context, orgID string) ([]StyleProfile, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+styleProfileColumns+` FROM style_profiles WHERE ?1='' OR org_id=?1 ORDER BY name`, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("list style profiles: %w", err)
+	}
+	defer rows.Close()
+
+	var profiles []StyleProfile
+	for rows.Next() {
+		profile, err := scanSQLiteStyleProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, rows.Err()
+}
+
+// GetStyleProfile fetches a profile by ID.
+func (s *SQLiteStore) GetStyleProfile(ctx context.Context, id string) (StyleProfile, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+styleProfileColumns+` FROM style_profiles WHERE id=?`, id)
+	profile, err := scanSQLiteStyleProfile(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return StyleProfile{}, ErrNotFound
+		}
+		return StyleProfile{}, err
+	}
+	return profile, nil
+}
+
+// CreateOrganization stores a new organization.
+func (s *SQLiteStore) CreateOrganization(ctx context.Context, org Organization) (Organization, error) {
+	org, err := prepareOrganization(org)
+	if err != nil {
+		return Organization{}, err
+	}
+	if _, err := s.db.ExecContext(ctx, `INSERT INTO organizations (id, name, require_two_factor, created_at) VALUES (?, ?, ?, ?)`, org.ID, org.Name, org.RequireTwoFactor, sqliteTimestamp(org.CreatedAt)); err != nil {
+		var sqliteErr *sqlite.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY {
+			return Organization{}, ErrOrganizationExists
+		}
+		return Organization{}, fmt.Errorf("insert organization: %w", err)
+	}
+	return org, nil
+}
+
+// GetOrganization fetches an organization by ID.
+func (s *SQLiteStore) GetOrganization(ctx context.Context, id string) (Organization, error) {
+	var org Organization
+	err := s.db.QueryRowContext(ctx, `SELECT id, name, require_two_factor, created_at FROM organizations WHERE id=?`, id).Scan(&org.ID, &org.Name, &org.RequireTwoFactor, sqliteTime{&org.CreatedAt})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Organization{}, ErrNotFound
+		}
+		return Organization{}, fmt.Errorf("scan organization: %w", err)
+	}
+	return org, nil
+}
+
+// ListOrganizations returns all organizations ordered by name.
+func (s *SQLiteStore) ListOrganizations(ctx context.Context) ([]Organization, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, name, require_two_factor, created_at FROM organizations ORDER BY name, id`)
+	if err != nil {
+		return nil, fmt.Errorf("list organizations: %w", err)
+	}
+	defer rows.Close()
+
+	var orgs []Organization
+	for rows.Next() {
+		var org Organization
+		if err := rows.Scan(&org.ID, &org.Name, &org.RequireTwoFactor, sqliteTime{&org.CreatedAt}); err != nil {
+			return nil, fmt.Errorf("scan organization: %w", err)
+		}
+		orgs = append(orgs, org)
+	}
+	return orgs, rows.Err()
+}
+
+// SetOrganizationTwoFactor turns the two-factor requirement on or off.
+func (s *SQLiteStore) SetOrganizationTwoFactor(ctx context.Context, id string, required bool) error {
+	result, err := s.db.ExecContext(ctx, `UPDATE organizations SET require_two_factor=? WHERE id=?`, required, id)
+	if err != nil {
+		return fmt.Errorf("update organization: %w", err)
+	}
+	return requireAffected(result)
+}
+
+// CreateUser stores a new user account.
+func (s *SQLiteStore) CreateUser(ctx context.Context, user User) (User, error) {
+	if user.ID == "" {
+		user.ID = uuid.NewString()
+	}
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = time.Now()
+	}
+	user.Approved = false
+	user.EmailVerified = false
+	user.Email = strings.ToLower(strings.TrimSpace(user.Email))
+	if user.Email == "" {
+		return User{}, fmt.Errorf("email is required")
+	}
+	if user.OrgID == "" {
+		user.OrgID = DefaultOrganizationID
+	}
+	role, err := ParseRole(string(user.Role))
+	if err != nil {
+		return User{}, err
+	}
+	user.Role = role
+	if _, err := s.db.ExecContext(ctx, `INSERT INTO users (id, email, password_hash, org_id, role, created_at) VALUES (?, ?, ?, ?, ?, ?)`, user.ID, user.Email, user.PasswordHash, user.OrgID, string(user.Role), sqliteTimestamp(user.CreatedAt)); err != nil {
+		var sqliteErr *sqlite.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+			return User{}, ErrUserExists
+		}
+		return User{}, fmt.Errorf("insert user: %w", err)
+	}
+	return user, nil
+}
+
+// GetUserByEmail fetches a user by their email address.
+func (s *SQLiteStore) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE email=?`, strings.ToLower(strings.TrimSpace(email)))
+	return scanSQLiteUser(row)
+}
+
+// GetUserByID fetches a user by ID.
+func (s *SQLiteStore) GetUserByID(ctx context.Context, id string) (User, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE id=?`, id)
+	return scanSQLiteUser(row)
+}
+
+// ApproveUser updates the approval flag for a user.
+func (s *SQLiteStore) ApproveUser(ctx context.Context, id string, approved bool) error {
+	result, err := s.db.ExecContext(ctx, `UPDATE users SET approved=? WHERE id=?`, approved, id)
+	if err != nil {
+		return fmt.Errorf("update user approval: %w", err)
+	}
+	return requireAffected(result)
+}
+
+// SetUserOrganization moves a user to another organization.
+func (s *SQLiteStore) SetUserOrganization(ctx context.Context, userID, orgID string) error {
+	if _, err := s.GetOrganization(ctx, orgID); err != nil {
+		return err
+	}
+	result, err := s.db.ExecContext(ctx, `UPDATE users SET org_id=? WHERE id=?`, orgID, userID)
+	if err != nil {
+		return fmt.Errorf("update user organization: %w", err)
+	}
+	return requireAffected(result)
+}
+
+// SetUserRole changes a user's role.
+func (s *SQLiteStore) SetUserRole(ctx context.Context, userID string, role Role) error {
+	if !role.Valid() {
+		return fmt.Errorf("unknown role %q", role)
+	}
+	result, err := s.db.ExecContext(ctx, `UPDATE users SET role=? WHERE id=?`, string(role), userID)
+	if err != nil {
+		return fmt.Errorf("update user role: %w", err)
+	}
+	return requireAffected(result)
+}
+
+// SetUserPassword replaces the stored password hash.
+func (s *SQLiteStore) SetUserPassword(ctx context.Context, userID, passwordHash string) error {
+	result, err := s.db.ExecContext(ctx, `UPDATE users SET password_hash=? WHERE id=?`, passwordHash, userID)
+	if err != nil {
+		return fmt.Errorf("update user password: %w", err)
+	}
+	return requireAffected(result)
+}
+
+// SetEmailVerified records whether the user has confirmed their e-mail address.
+func (s *SQLiteStore) SetEmailVerified(ctx context.Context, userID string, verified bool) error {
+	result, err := s.db.ExecContext(ctx, `UPDATE users SET email_verified=? WHERE id=?`, verified, userID)
+	if err != nil {
+		return fmt.Errorf("update email verification: %w", err)
+	}
+	return requireAffected(result)
+}
+
+// ListUsers returns all users, newest first.
+func (s *SQLiteStore) ListUsers(ctx context.Context) ([]User, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+userColumns+` FROM users ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("list users: %w", err)
+	}
+	defer rows.Close()
+	var users []User
+	for rows.Next() {
+		user, err := scanSQLiteUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// DeleteUser removes a user by id.
+func (s *SQLiteStore) DeleteUser(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM users WHERE id=?`, id)
+	if err != nil {
+		return fmt.Errorf("delete user: %w", err)
+	}
+	return requireAffected(result)
+}
+
+// CreateSession stores a new session for an existing user.
+func (s *SQLiteStore) CreateSession(ctx context.Context, session Session) (Session, error) {
+	session, err := prepareSession(session)
+	if err != nil {
+		return Session{}, err
+	}
+	if _, err := s.db.ExecContext(ctx, `INSERT INTO sessions (`+sessionColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		session.ID, session.UserID, sqliteTimestamp(session.CreatedAt), sqliteTimestamp(session.LastSeenAt),
+		sqliteTimestamp(session.ExpiresAt), session.UserAgent, session.IP); err != nil {
+		var sqliteErr *sqlite.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY {
+			return Session{}, ErrNotFound
+		}
+		return Session{}, fmt.Errorf("insert session: %w", err)
+	}
+	return session, nil
+}
+
+// GetSession fetches a session by id.
+func (s *SQLiteStore) GetSession(ctx context.Context, id string) (Session, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+sessionColumns+` FROM sessions WHERE id=?`, id)
+	return scanSQLiteSession(row)
+}
+
+// TouchSession records activity and slides the expiry forward.
+func (s *SQLiteStore) TouchSession(ctx context.Context, id string, lastSeenAt, expiresAt time.Time) error {
+	result, err := s.db.ExecContext(ctx, `UPDATE sessions SET last_seen_at=?, expires_at=? WHERE id=?`,
+		sqliteTimestamp(lastSeenAt), sqliteTimestamp(expiresAt), id)
+	if err != nil {
+		return fmt.Errorf("touch session: %w", err)
+	}
+	return requireAffected(result)
+}
+
+// ListSessions returns the user's unexpired sessions, most recently used first.
+func (s *SQLiteStore) ListSessions(ctx context.Context, userID string) ([]Session, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+sessionColumns+` FROM sessions WHERE user_id=? AND expires_at > ? ORDER BY last_seen_at DESC`,
+		userID, sqliteTimestamp(time.Now()))
+	if err != nil {
+		return nil, fmt.Errorf("list sessions: %w", err)
+	}
+	defer rows.Close()
+	sessions := make([]Session, 0)
+	for rows.Next() {
+		session, err := scanSQLiteSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// DeleteSession revokes one of the user's sessions.
+func (s *SQLiteStore) DeleteSession(ctx context.Context, userID, id string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM sessions WHERE id=? AND user_id=?`, id, userID)
+	if err != nil {
+		return fmt.Errorf("delete session: %w", err)
+	}
+	return requireAffected(result)
+}
+
+// DeleteUserSessions revokes all of the user's sessions except keepID.
+func (s *SQLiteStore) DeleteUserSessions(ctx context.Context, userID, keepID string) (int, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM sessions WHERE user_id=? AND id<>?`, userID, keepID)
+	if err != nil {
+		return 0, fmt.Errorf("delete user sessions: %w", err)
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// PurgeExpiredSessions removes sessions that expired before the given time.
+func (s *SQLiteStore) PurgeExpiredSessions(ctx context.Context, expiredBefore time.Time) (int, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM sessions WHERE expires_at < ?`, sqliteTimestamp(expiredBefore))
+	if err != nil {
+		return 0, fmt.Errorf("purge sessions: %w", err)
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// CreateAPIToken stores a new token for an existing user.
+func (s *SQLiteStore) CreateAPIToken(ctx context.Context, token APIToken) (APIToken, error) {
+	token, err := prepareAPIToken(token)
+	if err != nil {
+		return APIToken{}, err
+	}
+	scopes, err := json.Marshal(token.Scopes)
+	if err != nil {
+		return APIToken{}, fmt.Errorf("marshal token scopes: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, `INSERT INTO api_tokens (`+apiTokenColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		token.ID, token.UserID, token.Name, token.Prefix, token.TokenHash, string(scopes), sqliteTimestamp(token.CreatedAt),
+		sqliteNullTimestamp(token.LastUsedAt), sqliteNullTimestamp(token.ExpiresAt)); err != nil {
+		var sqliteErr *sqlite.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY {
+			return APIToken{}, ErrNotFound
+		}
+		return APIToken{}, fmt.Errorf("insert api token: %w", err)
+	}
+	return token, nil
+}
+
+// GetAPITokenByHash fetches a token by the hash of its secret.
+func (s *SQLiteStore) GetAPITokenByHash(ctx context.Context, tokenHash string) (APIToken, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+apiTokenColumns+` FROM api_tokens WHERE token_hash=?`, tokenHash)
+	return scanSQLiteAPIToken(row)
+}
+
+// ListAPITokens returns the user's tokens, newest first.
+func (s *SQLiteStore) ListAPITokens(ctx context.Context, userID string) ([]APIToken, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+apiTokenColumns+` FROM api_tokens WHERE user_id=? ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list api tokens: %w", err)
+	}
+	defer rows.Close()
+	tokens := make([]APIToken, 0)
+	for rows.Next() {
+		token, err := scanSQLiteAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// DeleteAPIToken revokes one of the user's tokens.
+func (s *SQLiteStore) DeleteAPIToken(ctx context.Context, userID, id string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM api_tokens WHERE id=? AND user_id=?`, id, userID)
+	if err != nil {
+		return fmt.Errorf("delete api token: %w", err)
+	}
+	return requireAffected(result)
+}
+
+// TouchAPIToken records when the token was last used.
+func (s *SQLiteStore) TouchAPIToken(ctx context.Context, id string, usedAt time.Time) error {
+	result, err := s.db.ExecContext(ctx, `UPDATE api_tokens SET last_used_at=? WHERE id=?`, sqliteTimestamp(usedAt), id)
+	if err != nil {
+		return fmt.Errorf("touch api token: %w", err)
+	}
+	return requireAffected(result)
+}
+
+// GetTwoFactor returns the user's TOTP enrollment.
+func (s *SQLiteStore) GetTwoFactor(ctx context.Context, userID string) (TwoFactor, error) {
+	var tf TwoFactor
+	err := s.db.QueryRowContext(ctx, `SELECT user_id, secret, enabled, recovery_codes, last_step, created_at, enabled_at FROM user_two_factor WHERE user_id=?`, userID).
+		Scan(&tf.UserID, &tf.Secret, &tf.Enabled, sqliteJSON{&tf.RecoveryCodes}, &tf.LastStep, sqliteTime{&tf.CreatedAt}, sqliteNullTime{&tf.EnabledAt})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return TwoFactor{}, ErrNotFound
+		}
+		return TwoFactor{}, fmt.Errorf("scan two-factor: %w", err)
+	}
+	if tf.RecoveryCodes == nil {
+		tf.RecoveryCodes = []string{}
+	}
+	return tf, nil
+}
+
+// SaveTwoFactor creates or replaces the user's enrollment.
+func (s *SQLiteStore) SaveTwoFactor(ctx context.Context, tf TwoFactor) error {
+	if tf.CreatedAt.IsZero() {
+		tf.CreatedAt = time.Now()
+	}
+	if tf.RecoveryCodes == nil {
+		tf.RecoveryCodes = []string{}
+	}
+	codes, err := json.Marshal(tf.RecoveryCodes)
+	if err != nil {
+		return fmt.Errorf("marshal recovery codes: %w", err)
+	}
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO user_two_factor (user_id, secret, enabled, recovery_codes, last_step, created_at, enabled_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET secret=excluded.secret, enabled=excluded.enabled, recovery_codes=excluded.recovery_codes,
+			last_step=excluded.last_step, created_at=excluded.created_at, enabled_at=excluded.enabled_at`,
+		tf.UserID, tf.Secret, tf.Enabled, string(codes), tf.LastStep, sqliteTimestamp(tf.CreatedAt), sqliteNullTimestamp(tf.EnabledAt))
+	if err != nil {
+		var sqliteErr *sqlite.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY {
+			return ErrNotFound
+		}
+		return fmt.Errorf("save two-factor: %w", err)
+	}
+	return nil
+}
+
+// DeleteTwoFactor removes the user's enrollment.
+func (s *SQLiteStore) DeleteTwoFactor(ctx context.Context, userID string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM user_two_factor WHERE user_id=?`, userID)
+	if err != nil {
+		return fmt.Errorf("delete two-factor: %w", err)
+	}
+	return requireAffected(result)
+}
+
+// ClaimTwoFactorStep records step as used unless it has been used already.
+func (s *SQLiteStore) ClaimTwoFactorStep(ctx context.Context, userID string, step int64) (bool, error) {
+	result, err := s.db.ExecContext(ctx, `UPDATE user_two_factor SET last_step=? WHERE user_id=? AND last_step < ?`, step, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("claim two-factor step: %w", err)
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// UseRecoveryCode removes the code hash if the user still has it.
+func (s *SQLiteStore) UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error) {
+	used := false
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		var codes []string
+		err := tx.QueryRowContext(ctx, `SELECT recovery_codes FROM user_two_factor WHERE user_id=?`, userID).Scan(sqliteJSON{&codes})
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("load recovery codes: %w", err)
+		}
+		i := slices.Index(codes, codeHash)
+		if i < 0 {
+			return nil
+		}
+		remaining, err := json.Marshal(slices.Delete(codes, i, i+1))
+		if err != nil {
+			return fmt.Errorf("marshal recovery codes: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE user_two_factor SET recovery_codes=? WHERE user_id=?`, string(remaining), userID); err != nil {
+			return fmt.Errorf("use recovery code: %w", err)
+		}
+		used = true
+		return nil
+	})
+	return used, err
+}
+
+// UpdateRateLimit applies fn to the key's state inside a transaction.
+func (s *SQLiteStore) UpdateRateLimit(ctx context.Context, key string, fn func(RateLimit) RateLimit) (RateLimit, error) {
+	var next RateLimit
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		current := RateLimit{Key: key}
+		err := tx.QueryRowContext(ctx, `SELECT tokens, failures, locked_until, updated_at FROM rate_limits WHERE key=?`, key).
+			Scan(&current.Tokens, &current.Failures, sqliteNullTime{&current.LockedUntil}, sqliteTime{&current.UpdatedAt})
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("scan rate limit: %w", err)
+		}
+
+		next = fn(current)
+		next.Key = key
+		if next.UpdatedAt.IsZero() {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM rate_limits WHERE key=?`, key); err != nil {
+				return fmt.Errorf("delete rate limit: %w", err)
+			}
+			return nil
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO rate_limits (key, tokens, failures, locked_until, updated_at) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (key) DO UPDATE SET tokens=excluded.tokens, failures=excluded.failures,
+				locked_until=excluded.locked_until, updated_at=excluded.updated_at`,
+			key, next.Tokens, next.Failures, sqliteNullTimestamp(next.LockedUntil), sqliteTimestamp(next.UpdatedAt)); err != nil {
+			return fmt.Errorf("save rate limit: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return RateLimit{}, err
+	}
+	return next, nil
+}
+
+// PurgeRateLimits removes keys idle since before.
+func (s *SQLiteStore) PurgeRateLimits(ctx context.Context, before time.Time) (int, error) {
+	cutoff := sqliteTimestamp(before)
+	result, err := s.db.ExecContext(ctx, `DELETE FROM rate_limits WHERE updated_at < ? AND (locked_until IS NULL OR locked_until < ?)`, cutoff, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("purge rate limits: %w", err)
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// RecordAuditEvent appends the event to audit_events.
+func (s *SQLiteStore) RecordAuditEvent(ctx context.Context, event AuditEvent) (AuditEvent, error) {
+	event, err := prepareAuditEvent(event)
+	if err != nil {
+		return AuditEvent{}, err
+	}
+	var changes any
+	if len(event.Changes) > 0 {
+		payload, err := json.Marshal(event.Changes)
+		if err != nil {
+			return AuditEvent{}, fmt.Errorf("marshal audit changes: %w", err)
+		}
+		changes = string(payload)
+	}
+	if _, err := s.db.ExecContext(ctx, `INSERT INTO audit_events (`+auditEventColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		event.ID, event.OrgID, event.ActorID, event.ActorEmail, event.Action, event.EntityType, event.EntityID,
+		changes, event.RequestID, event.IP, sqliteTimestamp(event.CreatedAt)); err != nil {
+		return AuditEvent{}, fmt.Errorf("insert audit event: %w", err)
+	}
+	return event, nil
+}
+
+// ListAuditEvents returns one page of matching events, newest first.
+func (s *SQLiteStore) ListAuditEvents(ctx context.Context, query AuditQuery) (AuditPage, error) {
+	q := query.normalize()
+	where, args := q.where(func(int) string { return "?" }, func(t time.Time) any { return sqliteTimestamp(t) })
+	page := AuditPage{Page: q.Page, PageSize: q.PageSize, Events: []AuditEvent{}}
+	if err := s.db.QueryRowContext(ctx, `SELECT count(*) FROM audit_events`+where, args...).Scan(&page.Total); err != nil {
+		return AuditPage{}, fmt.Errorf("count audit events: %w", err)
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT `+auditEventColumns+` FROM audit_events`+where+` ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?`,
+		append(args, q.PageSize, q.offset())...)
+	if err != nil {
+		return AuditPage{}, fmt.Errorf("query audit events: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var event AuditEvent
+		if err := rows.Scan(&event.ID, &event.OrgID, &event.ActorID, &event.ActorEmail, &event.Action, &event.EntityType, &event.EntityID,
+			sqliteJSON{&event.Changes}, &event.RequestID, &event.IP, sqliteTime{&event.CreatedAt}); err != nil {
+			return AuditPage{}, fmt.Errorf("scan audit event: %w", err)
+		}
+		page.Events = append(page.Events, event)
+	}
+	return page, rows.Err()
+}
+
+// RecordLLMUsage appends the call to llm_usage.
+func (s *SQLiteStore) RecordLLMUsage(ctx context.Context, usage LLMUsage) (LLMUsage, error) {
+	usage, err := prepareLLMUsage(usage)
+	if err != nil {
+		return LLMUsage{}, err
+	}
+	if _, err := s.db.ExecContext(ctx, `INSERT INTO llm_usage (`+llmUsageColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		usage.ID, usage.UserID, usage.OrgID, usage.ListingID, usage.Operation, usage.Model,
+		usage.PromptTokens, usage.CompletionTokens, sqliteTimestamp(usage.CreatedAt)); err != nil {
+		return LLMUsage{}, fmt.Errorf("insert llm usage: %w", err)
+	}
+	return usage, nil
+}
+
+// SummarizeLLMUsage sums matching calls per UTC day, organization, model and
+// operation. Timestamps are stored in UTC, so the day is the date prefix.
+func (s *SQLiteStore) SummarizeLLMUsage(ctx context.Context, query UsageQuery) ([]UsageDay, error) {
+	where, args := query.where(func(int) string { return "?" }, func(t time.Time) any { return sqliteTimestamp(t) })
+	rows, err := s.db.QueryContext(ctx, `SELECT substr(created_at, 1, 10) AS day, org_id, model, operation, count(*), sum(prompt_tokens), sum(completion_tokens)
+		FROM llm_usage`+where+` GROUP BY day, org_id, model, operation ORDER BY day, org_id, model, operation`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query llm usage: %w", err)
+	}
+	defer rows.Close()
+	days := []UsageDay{}
+	for rows.Next() {
+		var day UsageDay
+		if err := rows.Scan(&day.Day, &day.OrgID, &day.Model, &day.Operation, &day.Requests, &day.PromptTokens, &day.CompletionTokens); err != nil {
+			return nil, fmt.Errorf("scan llm usage: %w", err)
+		}
+		days = append(days, day)
+	}
+	return days, rows.Err()
+}
+
+func (s *SQLiteStore) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// requireAffected maps an UPDATE or DELETE that matched no row to ErrNotFound.
+func requireAffected(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func sqliteTimestamp(t time.Time) string {
+	return t.UTC().Format(sqliteTimeLayout)
+}
+
+func sqliteNullTimestamp(t *time.Time) any {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+	return sqliteTimestamp(*t)
+}
+
+// sqliteTime scans a timestamp written by sqliteTimestamp.
+type sqliteTime struct{ dst *time.Time }
+
+func (t sqliteTime) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*t.dst = time.Time{}
+		return nil
+	case string:
+		return t.parse(v)
+	case []byte:
+		return t.parse(string(v))
+	case time.Time:
+		*t.dst = v
+		return nil
+	default:
+		return fmt.Errorf("unsupported timestamp value %T", src)
+	}
+}
+
+func (t sqliteTime) parse(value string) error {
+	parsed, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return fmt.Errorf("parse timestamp %q: %w", value, err)
+	}
+	*t.dst = parsed
+	return nil
+}
+
+// sqliteNullTime scans a nullable timestamp into a pointer that stays nil for NULL.
+type sqliteNullTime struct{ dst **time.Time }
+
+func (t sqliteNullTime) Scan(src any) error {
+	if src == nil {
+		*t.dst = nil
+		return nil
+	}
+	var value time.Time
+	if err := (sqliteTime{dst: &value}).Scan(src); err != nil {
+		return err
+	}
+	*t.dst = &value
+	return nil
+}
+
+// sqliteJSON decodes a JSON text column into dst. NULL leaves dst untouched.
+type sqliteJSON struct{ dst any }
+
+func (j sqliteJSON) Scan(src any) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("unsupported json value %T", src)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, j.dst)
+}
+
+func scanSQLiteListing(row rowScanner) (Listing, error) {
+	var (
+		item Listing
+		raw  listingRow
+	)
+	if err := row.Scan(&item.ID, &raw.ownerID, &item.OrgID, &item.Address, &raw.neighborhood, &raw.city, &raw.propertyType, &raw.condition, &raw.balcony, &raw.floor, &raw.association, &raw.length, &item.Tone, &item.TargetAudience, sqliteJSON{&item.Highlights}, &raw.imageURL, &raw.fee, &raw.livingArea, &raw.rooms, &raw.sectionsJSON, &raw.fullCopy, &raw.statusJSON, &raw.detailsJSON, &raw.insightsJSON, sqliteTime{&item.CreatedAt}, &item.Version, sqliteNullTime{&item.DeletedAt}); err != nil {
+		return Listing{}, fmt.Errorf("scan listing: %w", err)
+	}
+	if err := raw.decode(&item); err != nil {
+		return Listing{}, err
+	}
+	return item, nil
+}
+
+func scanSQLiteRevision(row rowScanner) (Revision, error) {
+	var (
+		rev            Revision
+		instruction    sql.NullString
+		tone           sql.NullString
+		targetAudience sql.NullString
+		notes          sql.NullString
+		authorID       sql.NullString
+	)
+	if err := row.Scan(&rev.ID, &rev.ListingID, &rev.Slug, &rev.Number, &rev.Title, &rev.Content, &rev.Source, &instruction, &tone, &targetAudience, sqliteJSON{&rev.Highlights}, &notes, &authorID, sqliteTime{&rev.CreatedAt}); err != nil {
+		return Revision{}, fmt.Errorf("scan revision: %w", err)
+	}
+	rev.Instruction = instruction.String
+	rev.Tone = tone.String
+	rev.TargetAudience = targetAudience.String
+	rev.Notes = notes.String
+	rev.AuthorID = authorID.String
+	return rev, nil
+}
+
+func scanSQLiteStyleProfile(row rowScanner) (StyleProfile, error) {
+	var (
+		profile     StyleProfile
+		description sql.NullString
+		tone        sql.NullString
+		guidelines  sql.NullString
+		customModel sql.NullString
+		datasetURI  sql.NullString
+	)
+	if err := row.Scan(&profile.ID, &profile.OrgID, &profile.Name, &description, &tone, &guidelines, sqliteJSON{&profile.ExampleTexts}, sqliteJSON{&profile.ForbiddenWords}, &customModel, &datasetURI, sqliteNullTime{&profile.LastTrainedAt}, sqliteTime{&profile.CreatedAt}, sqliteTime{&profile.UpdatedAt}); err != nil {
+		return StyleProfile{}, fmt.Errorf("scan style profile: %w", err)
+	}
+	profile.Description = description.String
+	profile.Tone = tone.String
+	profile.Guidelines = guidelines.String
+	profile.CustomModel = customModel.String
+	profile.DatasetURI = datasetURI.String
+	return profile, nil
+}
+
+func scanSQLiteSession(row rowScanner) (Session, error) {
+	var session Session
+	if err := row.Scan(&session.ID, &session.UserID, sqliteTime{&session.CreatedAt}, sqliteTime{&session.LastSeenAt},
+		sqliteTime{&session.ExpiresAt}, &session.UserAgent, &session.IP); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Session{}, ErrNotFound
+		}
+		return Session{}, fmt.Errorf("scan session: %w", err)
+	}
+	return session, nil
+}
+
+func scanSQLiteAPIToken(row rowScanner) (APIToken, error) {
+	var token APIToken
+	if err := row.Scan(&token.ID, &token.UserID, &token.Name, &token.Prefix, &token.TokenHash, sqliteJSON{&token.Scopes},
+		sqliteTime{&token.CreatedAt}, sqliteNullTime{&token.LastUsedAt}, sqliteNullTime{&token.ExpiresAt}); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return APIToken{}, ErrNotFound
+		}
+		return APIToken{}, fmt.Errorf("scan api token: %w", err)
+	}
+	return token, nil
+}
+
+func scanSQLiteUser(row rowScanner) (User, error) {
+	var user User
+	if err := row.Scan(&user.ID, &user.Email, &user.PasswordHash, &user.OrgID, &user.Role, &user.Approved, &user.EmailVerified, sqliteTime{&user.CreatedAt}); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return User{}, ErrNotFound
+		}
+		return User{}, fmt.Errorf("scan user: %w", err)
+	}
+	return user, nil
+}
+
+// newSQLiteMigrator prepares the SQLite flavour of the embedded migrations.
+func newSQLiteMigrator(db *sql.DB) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationFiles, "migrations", dialectSQLite)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{driver: sqliteMigrationDriver{db: db}, migrations: migrations}, nil
+}
+
+// sqliteMigrationDriver runs migrations against a SQLite database. The file
+// lock SQLite takes for every write transaction stands in for the advisory lock.
+type sqliteMigrationDriver struct {
+	db     *sql.DB
+	ownsDB bool
+}
+
+func (d sqliteMigrationDriver) close() {
+	if d.ownsDB && d.db != nil {
+		d.db.Close()
+	}
+}
+
+func (d sqliteMigrationDriver) session(ctx context.Context, _ bool, fn func(migrationConn) error) error {
+	conn, err := d.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TEXT NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("create schema_migrations table: %w", err)
+	}
+	return fn(sqliteMigrationConn{conn: conn})
+}
+
+type sqliteMigrationConn struct {
+	conn *sql.Conn
+}
+
+func (c sqliteMigrationConn) applied(ctx context.Context) (map[int]time.Time, error) {
+	rows, err := c.conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("query schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var (
+			version int
+			at      time.Time
+		)
+		if err := rows.Scan(&version, sqliteTime{&at}); err != nil {
+			return nil, fmt.Errorf("scan schema_migrations: %w", err)
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+func (c sqliteMigrationConn) apply(ctx context.Context, mig Migration) error {
+	return c.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, mig.Up); err != nil {
+			return fmt.Errorf("apply migration %04d_%s: %w", mig.Version, mig.Name, err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`, mig.Version, mig.Name, sqliteTimestamp(time.Now())); err != nil {
+			return fmt.Errorf("record migration %04d_%s: %w", mig.Version, mig.Name, err)
+		}
+		return nil
+	})
+}
+
+func (c sqliteMigrationConn) revert(ctx context.Context, mig Migration) error {
+	if mig.Down == "" {
+		return fmt.Errorf("migration %04d_%s has no down step", mig.Version, mig.Name)
+	}
+	return c.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, mig.Down); err != nil {
+			return fmt.Errorf("revert migration %04d_%s: %w", mig.Version, mig.Name, err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version=?`, mig.Version); err != nil {
+			return fmt.Errorf("unrecord migration %04d_%s: %w", mig.Version, mig.Name, err)
+		}
+		return nil
+	})
+}
+
+func (c sqliteMigrationConn) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := c.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
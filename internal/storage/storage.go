@@ -4,21 +4,46 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // ErrNotFound indicates that a listing could not be located in the backing store.
 var ErrNotFound = errors.New("listing not found")
 
+// ErrConflict indicates that a listing was modified after the caller read it,
+// i.e. the expected version no longer matches the stored one.
+var ErrConflict = errors.New("listing was modified concurrently")
+
 // ErrUserExists indicates that a user already exists with the given unique value.
 var ErrUserExists = errors.New("user already exists")
 
+// ErrOrganizationExists indicates that an organization already uses the given id.
+var ErrOrganizationExists = errors.New("organization already exists")
+
+// DefaultOrganizationID is the office every account and listing belongs to until
+// an administrator moves it. Data created before organizations existed lives here.
+const DefaultOrganizationID = "default"
+
+// Organization is a brokerage office (mäklarbyrå). Listings, style profiles and
+// users belong to exactly one organization and never leak into another.
+type Organization struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// RequireTwoFactor makes every member enroll in TOTP before signing in.
+	RequireTwoFactor bool      `json:"require_two_factor"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
 // Listing represents the metadata and generated insights for a real estate listing.
 type Listing struct {
 	ID             string        `json:"id"`
 	OwnerID        string        `json:"owner_id,omitempty"`
+	OrgID          string        `json:"org_id,omitempty"`
 	Address        string        `json:"address"`
 	Neighborhood   string        `json:"neighborhood,omitempty"`
 	City           string        `json:"city,omitempty"`
@@ -43,6 +68,10 @@ type Listing struct {
 	Details        Details       `json:"details,omitempty"`
 	StyleProfile   *StyleProfile `json:"style_profile,omitempty"`
 	CreatedAt      time.Time     `json:"created_at"`
+	// Version increases on every content update and backs optimistic locking.
+	Version int `json:"version"`
+	// DeletedAt is set while the listing sits in the trash.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 // Section represents an editable block of text in the listing description.
@@ -173,7 +202,9 @@ type AreaInfo struct {
 	Other         string `json:"other"`
 }
 
-// History maps section slugs to previous versions.
+// History maps section slugs to previous versions, newest first. It is filled
+// from listing_revisions on single-listing reads and kept for older clients;
+// list, query and search reads leave it empty.
 type History map[string][]SectionVersion
 
 // SectionVersion tracks historical changes to a section.
@@ -211,6 +242,7 @@ type TransitInfo struct {
 // StyleProfile describes a stored tone-of-voice with sample texts.
 type StyleProfile struct {
 	ID             string     `json:"id"`
+	OrgID          string     `json:"org_id,omitempty"`
 	Name           string     `json:"name"`
 	Description    string     `json:"description"`
 	Tone           string     `json:"tone"`
@@ -224,44 +256,261 @@ type StyleProfile struct {
 	UpdatedAt      time.Time  `json:"updated_at"`
 }
 
+// Role decides what a user may do within their organization; see auth.Can.
+type Role string
+
+const (
+	RoleAdmin         Role = "admin"
+	RoleOfficeManager Role = "office_manager"
+	RoleBroker        Role = "broker"
+	RoleAssistant     Role = "assistant"
+)
+
+// Roles lists every role, most privileged first.
+var Roles = []Role{RoleAdmin, RoleOfficeManager, RoleBroker, RoleAssistant}
+
+// ParseRole validates a role name. Empty input yields RoleBroker, the role new accounts get.
+func ParseRole(value string) (Role, error) {
+	role := Role(strings.ToLower(strings.TrimSpace(value)))
+	if role == "" {
+		return RoleBroker, nil
+	}
+	if !role.Valid() {
+		return "", fmt.Errorf("unknown role %q", value)
+	}
+	return role, nil
+}
+
+// Valid reports whether r is one of Roles.
+func (r Role) Valid() bool {
+	return slices.Contains(Roles, r)
+}
+
 // User represents an authenticated account that owns listings.
 type User struct {
-	ID           string    `json:"id"`
-	Email        string    `json:"email"`
-	PasswordHash string    `json:"-"`
-	Approved     bool      `json:"approved"`
-	CreatedAt    time.Time `json:"created_at"`
+	ID            string    `json:"id"`
+	Email         string    `json:"email"`
+	PasswordHash  string    `json:"-"`
+	OrgID         string    `json:"org_id"`
+	Role          Role      `json:"role"`
+	Approved      bool      `json:"approved"`
+	EmailVerified bool      `json:"email_verified"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// prepareOrganization validates a new organization and fills in its id and creation time.
+func prepareOrganization(org Organization) (Organization, error) {
+	org.ID = strings.TrimSpace(org.ID)
+	org.Name = strings.TrimSpace(org.Name)
+	if org.Name == "" {
+		return Organization{}, fmt.Errorf("organization name is required")
+	}
+	if org.ID == "" {
+		org.ID = uuid.NewString()
+	}
+	if org.CreatedAt.IsZero() {
+		org.CreatedAt = time.Now()
+	}
+	return org, nil
+}
+
+// Session is a signed-in browser or device. The session cookie carries its ID,
+// so deleting the session signs that device out.
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"user_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+}
+
+// prepareSession validates a new session and fills in its id and timestamps.
+func prepareSession(session Session) (Session, error) {
+	if session.UserID == "" {
+		return Session{}, fmt.Errorf("session user is required")
+	}
+	if session.ExpiresAt.IsZero() {
+		return Session{}, fmt.Errorf("session expiry is required")
+	}
+	if session.ID == "" {
+		session.ID = uuid.NewString()
+	}
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = time.Now()
+	}
+	if session.LastSeenAt.IsZero() {
+		session.LastSeenAt = session.CreatedAt
+	}
+	return session, nil
+}
+
+// APIToken is a personal access token for scripts and integrations. Only the
+// hash of the secret is stored.
+type APIToken struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	TokenHash  string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+// prepareAPIToken validates a new token and fills in its id and creation time.
+func prepareAPIToken(token APIToken) (APIToken, error) {
+	token.Name = strings.TrimSpace(token.Name)
+	if token.UserID == "" || token.TokenHash == "" {
+		return APIToken{}, fmt.Errorf("token user and hash are required")
+	}
+	if token.Name == "" {
+		return APIToken{}, fmt.Errorf("token name is required")
+	}
+	if token.ID == "" {
+		token.ID = uuid.NewString()
+	}
+	if token.CreatedAt.IsZero() {
+		token.CreatedAt = time.Now()
+	}
+	if token.Scopes == nil {
+		token.Scopes = []string{}
+	}
+	return token, nil
+}
+
+// TwoFactor is a user's TOTP enrollment. It is pending until Enabled is set
+// by confirming a first code.
+type TwoFactor struct {
+	UserID string
+	// Secret is the base32 shared secret.
+	Secret  string
+	Enabled bool
+	// RecoveryCodes holds hashes of the unused recovery codes.
+	RecoveryCodes []string
+	// LastStep is the newest accepted TOTP time step.
+	LastStep  int64
+	CreatedAt time.Time
+	EnabledAt *time.Time
+}
+
+// RateLimit is the state behind one rate limiter key: a token bucket or a
+// count of failed attempts that locks the key. A zero UpdatedAt means the key
+// has no state.
+type RateLimit struct {
+	Key         string
+	Tokens      float64
+	Failures    int
+	LockedUntil *time.Time
+	UpdatedAt   time.Time
 }
 
 // Store defines the persistence behaviors the application relies on.
 type Store interface {
-	CreateListing(ctx context.Context, input Listing) (Listing, error)
+	CreateListing(ctx context.Context, input Listing, revisions []Revision) (Listing, error)
 	ListListings(ctx context.Context) ([]Listing, error)
-	ListListingsByOwner(ctx context.Context, ownerID string) ([]Listing, error)
+	QueryListings(ctx context.Context, query ListListingsQuery) (ListingPage, error)
+	SearchListings(ctx context.Context, query SearchListingsQuery) ([]SearchResult, error)
 	ListAllListings(ctx context.Context) ([]Listing, error)
 	GetListing(ctx context.Context, id string) (Listing, error)
-	UpdateListingSections(ctx context.Context, id string, sections []Section, fullCopy string, history History, status Status) (Listing, error)
-	UpdateListingDetails(ctx context.Context, id string, details Details, imageURL string) (Listing, error)
-	UpdateInsights(ctx context.Context, id string, insights Insights, status Status) (Listing, error)
+	UpdateListingSections(ctx context.Context, id string, version int, sections []Section, fullCopy string, revisions []Revision) (Listing, error)
+	UpdateListingDetails(ctx context.Context, id string, version int, details Details, imageURL string) (Listing, error)
+	UpdateInsights(ctx context.Context, id string, version int, insights Insights, status Status) (Listing, error)
 	UpdateStatus(ctx context.Context, id string, status Status) error
+	ListRevisions(ctx context.Context, query ListRevisionsQuery) (RevisionPage, error)
+	GetRevision(ctx context.Context, listingID, slug string, number int) (Revision, error)
 	DeleteListing(ctx context.Context, id string) error
+	// ListDeletedListings returns trashed listings; empty orgID or ownerID do not narrow the result.
+	ListDeletedListings(ctx context.Context, orgID, ownerID string) ([]Listing, error)
+	RestoreListing(ctx context.Context, id string) (Listing, error)
+	PurgeDeletedListings(ctx context.Context, deletedBefore time.Time) ([]Listing, error)
+	// SaveStyleProfile only updates a profile within its own organization and
+	// returns ErrNotFound when the id belongs to another one.
 	SaveStyleProfile(ctx context.Context, profile StyleProfile) (StyleProfile, error)
-	ListStyleProfiles(ctx context.Context) ([]StyleProfile, error)
+	// ListStyleProfiles returns the organization's profiles, or all of them when orgID is empty.
+	ListStyleProfiles(ctx context.Context, orgID string) ([]StyleProfile, error)
 	GetStyleProfile(ctx context.Context, id string) (StyleProfile, error)
+	CreateOrganization(ctx context.Context, org Organization) (Organization, error)
+	GetOrganization(ctx context.Context, id string) (Organization, error)
+	ListOrganizations(ctx context.Context) ([]Organization, error)
+	SetOrganizationTwoFactor(ctx context.Context, id string, required bool) error
 	CreateUser(ctx context.Context, user User) (User, error)
 	GetUserByEmail(ctx context.Context, email string) (User, error)
 	GetUserByID(ctx context.Context, id string) (User, error)
 	ApproveUser(ctx context.Context, id string, approved bool) error
+	// SetUserOrganization moves a user to another organization. Listings keep
+	// the organization they were created in.
+	SetUserOrganization(ctx context.Context, userID, orgID string) error
+	SetUserRole(ctx context.Context, userID string, role Role) error
+	// SetUserPassword replaces the stored password hash.
+	SetUserPassword(ctx context.Context, userID, passwordHash string) error
+	SetEmailVerified(ctx context.Context, userID string, verified bool) error
 	ListUsers(ctx context.Context) ([]User, error)
+	// DeleteUser removes the user together with their sessions.
 	DeleteUser(ctx context.Context, id string) error
+	CreateSession(ctx context.Context, session Session) (Session, error)
+	// GetSession returns the session even when it has expired; callers check ExpiresAt.
+	GetSession(ctx context.Context, id string) (Session, error)
+	// TouchSession records activity and slides the expiry forward.
+	TouchSession(ctx context.Context, id string, lastSeenAt, expiresAt time.Time) error
+	// ListSessions returns the user's unexpired sessions, most recently used first.
+	ListSessions(ctx context.Context, userID string) ([]Session, error)
+	// DeleteSession revokes one of the user's sessions. Sessions of other users
+	// are reported as ErrNotFound.
+	DeleteSession(ctx context.Context, userID, id string) error
+	// DeleteUserSessions revokes all of the user's sessions except keepID and
+	// returns how many were removed.
+	DeleteUserSessions(ctx context.Context, userID, keepID string) (int, error)
+	PurgeExpiredSessions(ctx context.Context, expiredBefore time.Time) (int, error)
+	CreateAPIToken(ctx context.Context, token APIToken) (APIToken, error)
+	// GetAPITokenByHash returns the token even when it has expired; callers check ExpiresAt.
+	GetAPITokenByHash(ctx context.Context, tokenHash string) (APIToken, error)
+	// ListAPITokens returns the user's tokens, newest first.
+	ListAPITokens(ctx context.Context, userID string) ([]APIToken, error)
+	// DeleteAPIToken revokes one of the user's tokens. Tokens of other users
+	// are reported as ErrNotFound.
+	DeleteAPIToken(ctx context.Context, userID, id string) error
+	TouchAPIToken(ctx context.Context, id string, usedAt time.Time) error
+	GetTwoFactor(ctx context.Context, userID string) (TwoFactor, error)
+	// SaveTwoFactor creates or replaces the user's enrollment.
+	SaveTwoFactor(ctx context.Context, tf TwoFactor) error
+	DeleteTwoFactor(ctx context.Context, userID string) error
+	// ClaimTwoFactorStep records step as used. It reports false when the same
+	// or a later step was already accepted, which stops codes being replayed.
+	ClaimTwoFactorStep(ctx context.Context, userID string, step int64) (bool, error)
+	// UseRecoveryCode removes the code hash. It reports false when the code is
+	// unknown or already used.
+	UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error)
+	// UpdateRateLimit passes the key's state to fn and saves what it returns,
+	// atomically with respect to other callers for the same key. A result with
+	// a zero UpdatedAt removes the key.
+	UpdateRateLimit(ctx context.Context, key string, fn func(RateLimit) RateLimit) (RateLimit, error)
+	// PurgeRateLimits removes keys that have not changed since before and are
+	// not locked beyond it.
+	PurgeRateLimits(ctx context.Context, before time.Time) (int, error)
+	// RecordAuditEvent appends to the audit log. Events are never updated or removed.
+	RecordAuditEvent(ctx context.Context, event AuditEvent) (AuditEvent, error)
+	// ListAuditEvents returns one page of matching events, newest first.
+	ListAuditEvents(ctx context.Context, query AuditQuery) (AuditPage, error)
+	// RecordLLMUsage appends one model call to the usage log.
+	RecordLLMUsage(ctx context.Context, usage LLMUsage) (LLMUsage, error)
+	// SummarizeLLMUsage sums matching calls per UTC day, organization, model
+	// and operation, oldest day first.
+	SummarizeLLMUsage(ctx context.Context, query UsageQuery) ([]UsageDay, error)
 	Close()
 }
 
-// NewStore selects a backing store based on whether a database URL is provided.
+// NewStore selects a backing store from the database URL: in-memory when it is
+// empty, an embedded SQLite file for sqlite:// URLs and PostgreSQL otherwise.
 func NewStore(ctx context.Context, databaseURL string) (Store, error) {
 	if databaseURL == "" {
 		return NewInMemoryStore(), nil
 	}
+	if isSQLiteURL(databaseURL) {
+		return newSQLiteStore(ctx, databaseURL)
+	}
 
 	pool, err := pgxpool.New(ctx, databaseURL)
 	if err != nil {
@@ -281,103 +530,14 @@ func NewStore(ctx context.Context, databaseURL string) (Store, error) {
 	return &PostgresStore{pool: pool}, nil
 }
 
+// ensureSchema applies any pending migrations so a fresh boot always runs against the latest schema.
 func ensureSchema(ctx context.Context, pool *pgxpool.Pool) error {
-	_, err := pool.Exec(ctx, `CREATE TABLE IF NOT EXISTS listings (
-        id TEXT PRIMARY KEY,
-        owner_id TEXT,
-        address TEXT NOT NULL,
-        neighborhood TEXT,
-        city TEXT,
-        property_type TEXT,
-        condition TEXT,
-        balcony BOOLEAN,
-        floor TEXT,
-        association TEXT,
-        length TEXT,
-        tone TEXT NOT NULL,
-        target_audience TEXT NOT NULL,
-        highlights TEXT[],
-        image_url TEXT,
-        fee INTEGER,
-        living_area DOUBLE PRECISION,
-		rooms DOUBLE PRECISION,
-		sections JSONB DEFAULT '[]'::jsonb,
-        full_copy TEXT,
-        section_history JSONB DEFAULT '{}'::jsonb,
-        pipeline_status JSONB DEFAULT '{}'::jsonb,
-        details JSONB DEFAULT '{}'::jsonb,
-		insights JSONB DEFAULT '{}'::jsonb,
-		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
-    )`)
+	migrator, err := newPostgresMigrator(pool)
 	if err != nil {
-		return fmt.Errorf("create listings table: %w", err)
-	}
-
-	var schemaAlters = []string{
-		`ALTER TABLE listings ADD COLUMN IF NOT EXISTS neighborhood TEXT`,
-		`ALTER TABLE listings ADD COLUMN IF NOT EXISTS owner_id TEXT`,
-		`ALTER TABLE listings ADD COLUMN IF NOT EXISTS city TEXT`,
-		`ALTER TABLE listings ADD COLUMN IF NOT EXISTS property_type TEXT`,
-		`ALTER TABLE listings ADD COLUMN IF NOT EXISTS condition TEXT`,
-		`ALTER TABLE listings ADD COLUMN IF NOT EXISTS balcony BOOLEAN`,
-		`ALTER TABLE listings ADD COLUMN IF NOT EXISTS floor TEXT`,
-		`ALTER TABLE listings ADD COLUMN IF NOT EXISTS association TEXT`,
-		`ALTER TABLE listings ADD COLUMN IF NOT EXISTS length TEXT`,
-		`ALTER TABLE listings ADD COLUMN IF NOT EXISTS image_url TEXT`,
-		`ALTER TABLE listings ADD COLUMN IF NOT EXISTS fee INTEGER`,
-		`ALTER TABLE listings ADD COLUMN IF NOT EXISTS living_area DOUBLE PRECISION`,
-		`ALTER TABLE listings ADD COLUMN IF NOT EXISTS rooms DOUBLE PRECISION`,
-		`ALTER TABLE listings ADD COLUMN IF NOT EXISTS sections JSONB DEFAULT '[]'::jsonb`,
-		`ALTER TABLE listings ADD COLUMN IF NOT EXISTS full_copy TEXT`,
-		`ALTER TABLE listings ADD COLUMN IF NOT EXISTS section_history JSONB DEFAULT '{}'::jsonb`,
-		`ALTER TABLE listings ADD COLUMN IF NOT EXISTS pipeline_status JSONB DEFAULT '{}'::jsonb`,
-		`ALTER TABLE listings ADD COLUMN IF NOT EXISTS details JSONB DEFAULT '{}'::jsonb`,
-		`ALTER TABLE listings ADD COLUMN IF NOT EXISTS insights JSONB DEFAULT '{}'::jsonb`,
-	}
-	for _, stmt := range schemaAlters {
-		if _, err := pool.Exec(ctx, stmt); err != nil {
-			return fmt.Errorf("alter listings table: %w", err)
-		}
-	}
-
-	if _, err := pool.Exec(ctx, `CREATE TABLE IF NOT EXISTS style_profiles (
-		id TEXT PRIMARY KEY,
-		name TEXT NOT NULL,
-		description TEXT,
-		tone TEXT,
-		guidelines TEXT,
-		example_texts TEXT[],
-		forbidden_words TEXT[],
-		custom_model TEXT,
-		dataset_uri TEXT,
-		last_trained_at TIMESTAMPTZ,
-		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
-		updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
-	)`); err != nil {
-		return fmt.Errorf("create style_profiles table: %w", err)
+		return err
 	}
-	if _, err := pool.Exec(ctx, `ALTER TABLE style_profiles ADD COLUMN IF NOT EXISTS custom_model TEXT`); err != nil {
-		return fmt.Errorf("alter style_profiles custom_model: %w", err)
+	if _, err := migrator.Up(ctx); err != nil {
+		return fmt.Errorf("migrate schema: %w", err)
 	}
-	if _, err := pool.Exec(ctx, `ALTER TABLE style_profiles ADD COLUMN IF NOT EXISTS dataset_uri TEXT`); err != nil {
-		return fmt.Errorf("alter style_profiles dataset_uri: %w", err)
-	}
-	if _, err := pool.Exec(ctx, `ALTER TABLE style_profiles ADD COLUMN IF NOT EXISTS last_trained_at TIMESTAMPTZ`); err != nil {
-		return fmt.Errorf("alter style_profiles last_trained_at: %w", err)
-	}
-
-	if _, err := pool.Exec(ctx, `CREATE TABLE IF NOT EXISTS users (
-		id TEXT PRIMARY KEY,
-		email TEXT NOT NULL UNIQUE,
-		password_hash TEXT NOT NULL,
-		approved BOOLEAN NOT NULL DEFAULT false,
-		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
-	)`); err != nil {
-		return fmt.Errorf("create users table: %w", err)
-	}
-	if _, err := pool.Exec(ctx, `ALTER TABLE users ADD COLUMN IF NOT EXISTS approved BOOLEAN NOT NULL DEFAULT false`); err != nil {
-		return fmt.Errorf("alter users approved: %w", err)
-	}
-
 	return nil
 }
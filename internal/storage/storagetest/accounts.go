@@ -0,0 +1,269 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"k2MarketingAi/internal/storage"
+)
+
+var styleProfileScenarios = []scenario{
+	{"save and update", testStyleProfileSave},
+	{"ordering", testStyleProfileOrdering},
+	{"not found", testStyleProfileNotFound},
+}
+
+var userScenarios = []scenario{
+	{"create", testUserCreate},
+	{"approve and delete", testUserApproveDelete},
+	{"ordering", testUserOrdering},
+	{"roles", testUserRoles},
+	{"password and verification", testUserCredentials},
+	{"not found", testUserNotFound},
+}
+
+func testStyleProfileSave(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	saved, err := store.SaveStyleProfile(ctx, storage.StyleProfile{
+		Name:           "Mäklarbyrån",
+		Description:    "Byråns standardton",
+		Tone:           "Saklig",
+		Guidelines:     "Korta meningar.",
+		ExampleTexts:   []string{"Välkommen hem."},
+		ForbiddenWords: []string{"drömboende"},
+	})
+	if err != nil {
+		t.Fatalf("save style profile: %v", err)
+	}
+	if saved.ID == "" || saved.CreatedAt.IsZero() || saved.UpdatedAt.IsZero() || saved.LastTrainedAt != nil {
+		t.Fatalf("saved profile = %+v", saved)
+	}
+
+	trained := timestamp(0)
+	saved.CustomModel = "tuned-model"
+	saved.DatasetURI = "gs://k2/dataset.jsonl"
+	saved.LastTrainedAt = &trained
+	updated, err := store.SaveStyleProfile(ctx, saved)
+	if err != nil {
+		t.Fatalf("update style profile: %v", err)
+	}
+	if updated.ID != saved.ID || updated.CustomModel != "tuned-model" || updated.DatasetURI != saved.DatasetURI {
+		t.Errorf("updated profile = %+v", updated)
+	}
+	if updated.LastTrainedAt == nil || !updated.LastTrainedAt.Equal(trained) {
+		t.Errorf("last_trained_at = %v, want %v", updated.LastTrainedAt, trained)
+	}
+	if !updated.CreatedAt.Equal(saved.CreatedAt) {
+		t.Errorf("created_at changed from %v to %v", saved.CreatedAt, updated.CreatedAt)
+	}
+	if updated.UpdatedAt.Before(saved.UpdatedAt) {
+		t.Errorf("updated_at went back from %v to %v", saved.UpdatedAt, updated.UpdatedAt)
+	}
+
+	got, err := store.GetStyleProfile(ctx, saved.ID)
+	if err != nil {
+		t.Fatalf("get style profile: %v", err)
+	}
+	if got.Name != "Mäklarbyrån" || got.Description != "Byråns standardton" || got.Guidelines != "Korta meningar." {
+		t.Errorf("text fields = %+v", got)
+	}
+	if len(got.ExampleTexts) != 1 || got.ExampleTexts[0] != "Välkommen hem." || len(got.ForbiddenWords) != 1 || got.ForbiddenWords[0] != "drömboende" {
+		t.Errorf("word lists = %v / %v", got.ExampleTexts, got.ForbiddenWords)
+	}
+
+	withID, err := store.SaveStyleProfile(ctx, storage.StyleProfile{ID: "fixed-id", Name: "Egen nyckel"})
+	if err != nil {
+		t.Fatalf("save profile with caller id: %v", err)
+	}
+	if withID.ID != "fixed-id" || withID.CreatedAt.IsZero() {
+		t.Errorf("profile with caller id = %+v", withID)
+	}
+}
+
+func testStyleProfileOrdering(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	for _, name := range []string{"charlie", "alfa", "bravo"} {
+		if _, err := store.SaveStyleProfile(ctx, storage.StyleProfile{Name: name}); err != nil {
+			t.Fatalf("save %s: %v", name, err)
+		}
+	}
+	profiles, err := store.ListStyleProfiles(ctx, "")
+	if err != nil {
+		t.Fatalf("list style profiles: %v", err)
+	}
+	var names []string
+	for _, p := range profiles {
+		names = append(names, p.Name)
+	}
+	if len(names) != 3 || names[0] != "alfa" || names[1] != "bravo" || names[2] != "charlie" {
+		t.Errorf("profiles = %v, want sorted by name", names)
+	}
+}
+
+func testStyleProfileNotFound(t *testing.T, store storage.Store) {
+	if _, err := store.GetStyleProfile(context.Background(), "missing"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("get missing profile: %v, want ErrNotFound", err)
+	}
+}
+
+func testUserCreate(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	user, err := store.CreateUser(ctx, storage.User{Email: " Anna@Example.se ", PasswordHash: "hash", Approved: true})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if user.ID == "" || user.Email != "anna@example.se" || user.CreatedAt.IsZero() {
+		t.Errorf("created user = %+v", user)
+	}
+	if user.Approved {
+		t.Error("new users must start unapproved")
+	}
+	if _, err := store.CreateUser(ctx, storage.User{Email: "ANNA@example.se", PasswordHash: "x"}); !errors.Is(err, storage.ErrUserExists) {
+		t.Errorf("duplicate email: %v, want ErrUserExists", err)
+	}
+	if _, err := store.CreateUser(ctx, storage.User{Email: "   ", PasswordHash: "x"}); err == nil {
+		t.Error("blank email was accepted")
+	}
+
+	byEmail, err := store.GetUserByEmail(ctx, "ANNA@example.se ")
+	if err != nil {
+		t.Fatalf("get user by email: %v", err)
+	}
+	byID, err := store.GetUserByID(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("get user by id: %v", err)
+	}
+	for _, got := range []storage.User{byEmail, byID} {
+		// A defaulted created_at may lose sub-microsecond precision in the database.
+		if got.ID != user.ID || got.PasswordHash != "hash" || got.Approved || got.CreatedAt.Sub(user.CreatedAt).Abs() >= time.Microsecond {
+			t.Errorf("stored user = %+v, want %+v", got, user)
+		}
+	}
+}
+
+func testUserApproveDelete(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	user, err := store.CreateUser(ctx, storage.User{Email: "bertil@example.se", PasswordHash: "hash"})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	if err := store.ApproveUser(ctx, user.ID, true); err != nil {
+		t.Fatalf("approve user: %v", err)
+	}
+	if got, err := store.GetUserByID(ctx, user.ID); err != nil || !got.Approved {
+		t.Errorf("after approval: %+v, err %v", got, err)
+	}
+	if err := store.ApproveUser(ctx, user.ID, false); err != nil {
+		t.Fatalf("revoke approval: %v", err)
+	}
+	if got, err := store.GetUserByID(ctx, user.ID); err != nil || got.Approved {
+		t.Errorf("after revoking: %+v, err %v", got, err)
+	}
+
+	if err := store.DeleteUser(ctx, user.ID); err != nil {
+		t.Fatalf("delete user: %v", err)
+	}
+	if _, err := store.GetUserByEmail(ctx, "bertil@example.se"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("get deleted user: %v, want ErrNotFound", err)
+	}
+	if _, err := store.CreateUser(ctx, storage.User{Email: "bertil@example.se", PasswordHash: "ny"}); err != nil {
+		t.Errorf("email of deleted user cannot be reused: %v", err)
+	}
+}
+
+func testUserOrdering(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	base := timestamp(-time.Hour)
+	for i, email := range []string{"a@example.se", "c@example.se", "b@example.se"} {
+		created := base.Add(time.Duration(i) * time.Minute)
+		if _, err := store.CreateUser(ctx, storage.User{Email: email, PasswordHash: "x", CreatedAt: created}); err != nil {
+			t.Fatalf("create %s: %v", email, err)
+		}
+	}
+	users, err := store.ListUsers(ctx)
+	if err != nil {
+		t.Fatalf("list users: %v", err)
+	}
+	var emails []string
+	for _, u := range users {
+		emails = append(emails, u.Email)
+	}
+	if len(emails) != 3 || emails[0] != "b@example.se" || emails[1] != "c@example.se" || emails[2] != "a@example.se" {
+		t.Errorf("users = %v, want newest first", emails)
+	}
+}
+
+func testUserRoles(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	broker, err := store.CreateUser(ctx, storage.User{Email: "erik@example.se", PasswordHash: "hash"})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if broker.Role != storage.RoleBroker {
+		t.Errorf("default role = %q, want %q", broker.Role, storage.RoleBroker)
+	}
+	assistant, err := store.CreateUser(ctx, storage.User{Email: "frida@example.se", PasswordHash: "hash", Role: storage.RoleAssistant})
+	if err != nil || assistant.Role != storage.RoleAssistant {
+		t.Errorf("create assistant: %+v, err %v", assistant, err)
+	}
+	if _, err := store.CreateUser(ctx, storage.User{Email: "gustav@example.se", PasswordHash: "hash", Role: "owner"}); err == nil {
+		t.Error("unknown role was accepted")
+	}
+
+	if err := store.SetUserRole(ctx, broker.ID, storage.RoleOfficeManager); err != nil {
+		t.Fatalf("set role: %v", err)
+	}
+	if got, err := store.GetUserByEmail(ctx, broker.Email); err != nil || got.Role != storage.RoleOfficeManager {
+		t.Errorf("after role change: %+v, err %v", got, err)
+	}
+	if err := store.SetUserRole(ctx, broker.ID, "owner"); err == nil || errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("set unknown role: %v, want a validation error", err)
+	}
+	if err := store.SetUserRole(ctx, "missing", storage.RoleAdmin); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("set role on missing user: %v, want ErrNotFound", err)
+	}
+}
+
+func testUserCredentials(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	user, err := store.CreateUser(ctx, storage.User{Email: "hanna@example.se", PasswordHash: "old", EmailVerified: true})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if user.EmailVerified {
+		t.Error("new user starts out verified")
+	}
+
+	if err := store.SetUserPassword(ctx, user.ID, "new"); err != nil {
+		t.Fatalf("set password: %v", err)
+	}
+	if err := store.SetEmailVerified(ctx, user.ID, true); err != nil {
+		t.Fatalf("set email verified: %v", err)
+	}
+	got, err := store.GetUserByEmail(ctx, user.Email)
+	if err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	if got.PasswordHash != "new" || !got.EmailVerified || got.Approved {
+		t.Errorf("after update: %+v", got)
+	}
+}
+
+func testUserNotFound(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	checks := map[string]error{}
+	_, checks["GetUserByEmail"] = store.GetUserByEmail(ctx, "nobody@example.se")
+	_, checks["GetUserByID"] = store.GetUserByID(ctx, "missing")
+	checks["ApproveUser"] = store.ApproveUser(ctx, "missing", true)
+	checks["SetUserPassword"] = store.SetUserPassword(ctx, "missing", "hash")
+	checks["SetEmailVerified"] = store.SetEmailVerified(ctx, "missing", true)
+	checks["DeleteUser"] = store.DeleteUser(ctx, "missing")
+	for call, err := range checks {
+		if !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("%s: %v, want ErrNotFound", call, err)
+		}
+	}
+}
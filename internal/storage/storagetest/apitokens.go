@@ -0,0 +1,137 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"k2MarketingAi/internal/storage"
+)
+
+var apiTokenScenarios = []scenario{
+	{"lifecycle", testAPITokenLifecycle},
+	{"ownership", testAPITokenOwnership},
+}
+
+func mustCreateAPIToken(t *testing.T, store storage.Store, userID, name, hash string, createdAt time.Time) storage.APIToken {
+	t.Helper()
+	token, err := store.CreateAPIToken(context.Background(), storage.APIToken{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    "k2_" + hash[:4],
+		TokenHash: hash,
+		Scopes:    []string{"listings:read", "listings:write"},
+		CreatedAt: createdAt,
+	})
+	if err != nil {
+		t.Fatalf("create api token: %v", err)
+	}
+	return token
+}
+
+func testAPITokenLifecycle(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	user, err := store.CreateUser(ctx, storage.User{Email: "lars@example.se", PasswordHash: "hash"})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	expires := timestamp(30 * 24 * time.Hour)
+	older := mustCreateAPIToken(t, store, user.ID, "CRM-synk", "aaaa1111", timestamp(-2*time.Hour))
+	newer, err := store.CreateAPIToken(ctx, storage.APIToken{
+		UserID:    user.ID,
+		Name:      "  Rapport  ",
+		Prefix:    "k2_bbbb",
+		TokenHash: "bbbb2222",
+		Scopes:    []string{"listings:read"},
+		CreatedAt: timestamp(-time.Hour),
+		ExpiresAt: &expires,
+	})
+	if err != nil {
+		t.Fatalf("create expiring token: %v", err)
+	}
+	if newer.Name != "Rapport" {
+		t.Errorf("name = %q, want trimmed", newer.Name)
+	}
+
+	got, err := store.GetAPITokenByHash(ctx, "aaaa1111")
+	if err != nil {
+		t.Fatalf("get by hash: %v", err)
+	}
+	if got.ID != older.ID || got.UserID != user.ID || got.Name != "CRM-synk" || got.Prefix != "k2_aaaa" ||
+		len(got.Scopes) != 2 || got.Scopes[1] != "listings:write" || got.LastUsedAt != nil || got.ExpiresAt != nil {
+		t.Errorf("stored token = %+v, want %+v", got, older)
+	}
+	got, err = store.GetAPITokenByHash(ctx, "bbbb2222")
+	if err != nil {
+		t.Fatalf("get expiring token: %v", err)
+	}
+	if got.ExpiresAt == nil || !got.ExpiresAt.Equal(expires) {
+		t.Errorf("expires_at = %v, want %v", got.ExpiresAt, expires)
+	}
+
+	tokens, err := store.ListAPITokens(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("list tokens: %v", err)
+	}
+	if len(tokens) != 2 || tokens[0].ID != newer.ID || tokens[1].ID != older.ID {
+		t.Errorf("tokens = %+v, want newest first", tokens)
+	}
+
+	used := timestamp(0)
+	if err := store.TouchAPIToken(ctx, older.ID, used); err != nil {
+		t.Fatalf("touch token: %v", err)
+	}
+	got, err = store.GetAPITokenByHash(ctx, "aaaa1111")
+	if err != nil {
+		t.Fatalf("get after touch: %v", err)
+	}
+	if got.LastUsedAt == nil || !got.LastUsedAt.Equal(used) {
+		t.Errorf("last_used_at = %v, want %v", got.LastUsedAt, used)
+	}
+
+	if _, err := store.CreateAPIToken(ctx, storage.APIToken{UserID: "missing", Name: "x", TokenHash: "cccc3333"}); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("token for missing user: %v, want ErrNotFound", err)
+	}
+	if _, err := store.GetAPITokenByHash(ctx, "missing"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("get missing token: %v, want ErrNotFound", err)
+	}
+	if err := store.TouchAPIToken(ctx, "missing", used); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("touch missing token: %v, want ErrNotFound", err)
+	}
+}
+
+func testAPITokenOwnership(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	user, err := store.CreateUser(ctx, storage.User{Email: "maja@example.se", PasswordHash: "hash"})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	other, err := store.CreateUser(ctx, storage.User{Email: "nils@example.se", PasswordHash: "hash"})
+	if err != nil {
+		t.Fatalf("create other user: %v", err)
+	}
+	mine := mustCreateAPIToken(t, store, user.ID, "Skript", "dddd4444", timestamp(-time.Hour))
+	foreign := mustCreateAPIToken(t, store, other.ID, "Skript", "eeee5555", timestamp(-time.Hour))
+
+	tokens, err := store.ListAPITokens(ctx, user.ID)
+	if err != nil || len(tokens) != 1 || tokens[0].ID != mine.ID {
+		t.Errorf("list own tokens = %+v, err %v", tokens, err)
+	}
+	if err := store.DeleteAPIToken(ctx, user.ID, foreign.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("delete another user's token: %v, want ErrNotFound", err)
+	}
+	if err := store.DeleteAPIToken(ctx, user.ID, mine.ID); err != nil {
+		t.Fatalf("delete token: %v", err)
+	}
+	if _, err := store.GetAPITokenByHash(ctx, "dddd4444"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("revoked token still readable: %v", err)
+	}
+
+	if err := store.DeleteUser(ctx, other.ID); err != nil {
+		t.Fatalf("delete user: %v", err)
+	}
+	if _, err := store.GetAPITokenByHash(ctx, "eeee5555"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("token outlived its user: %v", err)
+	}
+}
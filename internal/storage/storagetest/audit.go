@@ -0,0 +1,137 @@
+package storagetest
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"k2MarketingAi/internal/storage"
+)
+
+var auditScenarios = []scenario{
+	{"round trip", testAuditRoundTrip},
+	{"filters and paging", testAuditFilters},
+}
+
+func testAuditRoundTrip(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	if _, err := store.RecordAuditEvent(ctx, storage.AuditEvent{EntityType: "listing"}); err == nil {
+		t.Errorf("event without action was accepted")
+	}
+
+	created := timestamp(-time.Minute)
+	recorded, err := store.RecordAuditEvent(ctx, storage.AuditEvent{
+		OrgID:      storage.DefaultOrganizationID,
+		ActorID:    "user-1",
+		ActorEmail: "anna@example.se",
+		Action:     "listing.section.update",
+		EntityType: "listing",
+		EntityID:   "listing-1",
+		Changes: map[string]storage.AuditChange{
+			"sections.avgift.content": {Before: json.RawMessage(`"3 200 kr"`), After: json.RawMessage(`"3 400 kr"`)},
+			"sections.avgift.title":   {After: json.RawMessage(`"Avgift"`)},
+		},
+		RequestID: "host/abc-000001",
+		IP:        "10.0.0.1",
+		CreatedAt: created,
+	})
+	if err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	if recorded.ID == "" {
+		t.Fatalf("recorded event has no id")
+	}
+	if _, err := store.RecordAuditEvent(ctx, storage.AuditEvent{Action: "auth.login_failed", EntityType: "user", ActorEmail: "okand@example.se"}); err != nil {
+		t.Fatalf("record without actor: %v", err)
+	}
+
+	page, err := store.ListAuditEvents(ctx, storage.AuditQuery{EntityID: "listing-1"})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if page.Total != 1 || len(page.Events) != 1 {
+		t.Fatalf("page = %+v, want one event", page)
+	}
+	got := page.Events[0]
+	if got.ID != recorded.ID || got.OrgID != storage.DefaultOrganizationID || got.ActorID != "user-1" || got.ActorEmail != "anna@example.se" ||
+		got.Action != "listing.section.update" || got.EntityType != "listing" || got.RequestID != "host/abc-000001" || got.IP != "10.0.0.1" {
+		t.Errorf("stored event = %+v", got)
+	}
+	if !got.CreatedAt.Equal(created) {
+		t.Errorf("created_at = %v, want %v", got.CreatedAt, created)
+	}
+	content := got.Changes["sections.avgift.content"]
+	if string(content.Before) != `"3 200 kr"` || string(content.After) != `"3 400 kr"` {
+		t.Errorf("content change = %s -> %s", content.Before, content.After)
+	}
+	if title, ok := got.Changes["sections.avgift.title"]; !ok || len(title.Before) != 0 || string(title.After) != `"Avgift"` {
+		t.Errorf("title change = %+v", title)
+	}
+
+	all, err := store.ListAuditEvents(ctx, storage.AuditQuery{})
+	if err != nil {
+		t.Fatalf("list all: %v", err)
+	}
+	if all.Total != 2 || all.Events[0].Action != "auth.login_failed" || all.Events[0].Changes != nil {
+		t.Errorf("all events = %+v, want the newest without changes first", all.Events)
+	}
+}
+
+func testAuditFilters(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	record := func(offset time.Duration, org, actor, action, entityType, entityID string) {
+		t.Helper()
+		if _, err := store.RecordAuditEvent(ctx, storage.AuditEvent{
+			OrgID: org, ActorID: actor, Action: action, EntityType: entityType, EntityID: entityID, CreatedAt: timestamp(offset),
+		}); err != nil {
+			t.Fatalf("record %s: %v", action, err)
+		}
+	}
+	record(-5*time.Hour, "org-a", "anna", "listing.create", "listing", "l1")
+	record(-4*time.Hour, "org-a", "anna", "listing.section.update", "listing", "l1")
+	record(-3*time.Hour, "org-a", "bertil", "listing.section.update", "listing", "l1")
+	record(-2*time.Hour, "org-a", "bertil", "style_profile.save", "style_profile", "p1")
+	record(-time.Hour, "org-b", "cecilia", "listing.section.update", "listing", "l2")
+
+	cases := []struct {
+		name    string
+		query   storage.AuditQuery
+		actions []string
+	}{
+		{"org", storage.AuditQuery{OrgID: "org-b"}, []string{"listing.section.update"}},
+		{"actor", storage.AuditQuery{ActorID: "bertil"}, []string{"style_profile.save", "listing.section.update"}},
+		{"action", storage.AuditQuery{Action: "listing.create"}, []string{"listing.create"}},
+		{"entity", storage.AuditQuery{EntityType: "listing", EntityID: "l1"}, []string{"listing.section.update", "listing.section.update", "listing.create"}},
+		{"time range", storage.AuditQuery{Since: timestamp(-4 * time.Hour).Add(-time.Second), Until: timestamp(-2 * time.Hour).Add(-time.Second)}, []string{"listing.section.update", "listing.section.update"}},
+		{"last page", storage.AuditQuery{Page: 3, PageSize: 2}, []string{"listing.create"}},
+	}
+	for _, tc := range cases {
+		page, err := store.ListAuditEvents(ctx, tc.query)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		var actions []string
+		for _, e := range page.Events {
+			actions = append(actions, e.Action)
+		}
+		if len(actions) != len(tc.actions) {
+			t.Errorf("%s: actions = %v, want %v", tc.name, actions, tc.actions)
+			continue
+		}
+		for i := range actions {
+			if actions[i] != tc.actions[i] {
+				t.Errorf("%s: actions = %v, want %v", tc.name, actions, tc.actions)
+				break
+			}
+		}
+	}
+
+	page, err := store.ListAuditEvents(ctx, storage.AuditQuery{PageSize: 2})
+	if err != nil {
+		t.Fatalf("first page: %v", err)
+	}
+	if page.Total != 5 || len(page.Events) != 2 || page.PageSize != 2 || page.Page != 1 {
+		t.Errorf("first page = total %d, %d events, page %d size %d", page.Total, len(page.Events), page.Page, page.PageSize)
+	}
+}
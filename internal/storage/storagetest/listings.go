@@ -0,0 +1,503 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"k2MarketingAi/internal/storage"
+)
+
+var listingScenarios = []scenario{
+	{"round trip", testListingRoundTrip},
+	{"not found", testListingNotFound},
+	{"versioned updates", testVersionedUpdates},
+	{"concurrent updates", testConcurrentUpdates},
+	{"concurrent revisions", testConcurrentRevisions},
+	{"ordering", testListingOrdering},
+	{"query and search", testQueryAndSearch},
+	{"trash", testTrash},
+	{"revisions", testRevisions},
+}
+
+func testListingRoundTrip(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	input := newListing("owner-1", "Storgatan 1")
+	input.Neighborhood = "Luthagen"
+	input.City = "Uppsala"
+	input.PropertyType = "Bostadsrätt"
+	input.Condition = "Renoverad 2021"
+	input.Balcony = true
+	input.Floor = "3 av 5"
+	input.Association = "BRF Kakelugnen"
+	input.Length = "medium"
+	created := mustCreate(t, store, input)
+	if created.ID == "" || created.Version != 1 {
+		t.Fatalf("created listing: id %q version %d", created.ID, created.Version)
+	}
+
+	got, err := store.GetListing(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("get listing: %v", err)
+	}
+	if got.Address != input.Address || got.OwnerID != input.OwnerID || got.Fee != input.Fee || got.LivingArea != input.LivingArea || got.Rooms != input.Rooms {
+		t.Errorf("scalar columns not preserved: %+v", got)
+	}
+	if got.Tone != input.Tone || got.TargetAudience != input.TargetAudience || got.FullCopy != input.FullCopy {
+		t.Errorf("text columns not preserved: %+v", got)
+	}
+	if got.Neighborhood != input.Neighborhood || got.City != input.City || got.PropertyType != input.PropertyType || got.Condition != input.Condition {
+		t.Errorf("property columns not preserved: %+v", got)
+	}
+	if !got.Balcony || got.Floor != input.Floor || got.Association != input.Association || got.Length != input.Length {
+		t.Errorf("legacy columns not preserved: %+v", got)
+	}
+	if len(got.Highlights) != 2 || got.Highlights[1] != "Kakelugn" {
+		t.Errorf("highlights = %v", got.Highlights)
+	}
+	if len(got.Sections) != 1 || got.Sections[0].Content != input.Sections[0].Content {
+		t.Errorf("sections = %+v", got.Sections)
+	}
+	if got.Details.Property.City != "Uppsala" || len(got.Details.Media.Images) != 1 {
+		t.Errorf("details = %+v", got.Details)
+	}
+	if got.Status != input.Status {
+		t.Errorf("status = %+v", got.Status)
+	}
+	if !got.CreatedAt.Equal(input.CreatedAt) {
+		t.Errorf("created_at = %v, want %v", got.CreatedAt, input.CreatedAt)
+	}
+	if got.DeletedAt != nil {
+		t.Errorf("new listing has deleted_at %v", got.DeletedAt)
+	}
+
+	withoutTimestamp := newListing("owner-1", "Storgatan 1B")
+	withoutTimestamp.CreatedAt = time.Time{}
+	if created := mustCreate(t, store, withoutTimestamp); created.CreatedAt.IsZero() {
+		t.Error("created_at was not defaulted")
+	}
+}
+
+func testListingNotFound(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	live := mustCreate(t, store, newListing("owner-1", "Finnsgatan 1"))
+
+	checks := map[string]error{}
+	_, checks["GetListing"] = store.GetListing(ctx, "missing")
+	_, checks["UpdateListingSections"] = store.UpdateListingSections(ctx, "missing", 1, nil, "", nil)
+	_, checks["UpdateListingDetails"] = store.UpdateListingDetails(ctx, "missing", 1, storage.Details{}, "")
+	_, checks["UpdateInsights"] = store.UpdateInsights(ctx, "missing", 1, storage.Insights{}, storage.Status{})
+	checks["UpdateStatus"] = store.UpdateStatus(ctx, "missing", storage.Status{})
+	checks["DeleteListing"] = store.DeleteListing(ctx, "missing")
+	_, checks["RestoreListing"] = store.RestoreListing(ctx, "missing")
+	_, checks["RestoreListing(live)"] = store.RestoreListing(ctx, live.ID)
+	_, checks["GetRevision"] = store.GetRevision(ctx, live.ID, "intro", 1)
+	for call, err := range checks {
+		if !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("%s: %v, want ErrNotFound", call, err)
+		}
+	}
+
+	page, err := store.ListRevisions(ctx, storage.ListRevisionsQuery{ListingID: "missing", Slug: "intro"})
+	if err != nil {
+		t.Fatalf("list revisions of missing listing: %v", err)
+	}
+	if page.Total != 0 || page.Revisions == nil || len(page.Revisions) != 0 {
+		t.Errorf("revisions of missing listing = %+v, want an empty page", page)
+	}
+}
+
+func testVersionedUpdates(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	created := mustCreate(t, store, newListing("owner-1", "Storgatan 2"))
+
+	details := created.Details
+	details.Property.Floor = "3"
+	updated, err := store.UpdateListingDetails(ctx, created.ID, created.Version, details, "https://example.test/cover.jpg")
+	if err != nil {
+		t.Fatalf("update details: %v", err)
+	}
+	if updated.Version != 2 || updated.ImageURL != "https://example.test/cover.jpg" || updated.Details.Property.Floor != "3" {
+		t.Errorf("updated listing: version %d image %q floor %q", updated.Version, updated.ImageURL, updated.Details.Property.Floor)
+	}
+
+	if _, err := store.UpdateInsights(ctx, created.ID, created.Version, storage.Insights{}, storage.Status{}); !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("stale update: %v, want ErrConflict", err)
+	}
+
+	insights := storage.Insights{Vision: storage.VisionInsights{Summary: "Ljust kök", Tags: []string{"kök"}}}
+	updated, err = store.UpdateInsights(ctx, created.ID, updated.Version, insights, storage.Status{Vision: "completed"})
+	if err != nil {
+		t.Fatalf("update insights: %v", err)
+	}
+	if updated.Version != 3 || updated.Insights.Vision.Summary != "Ljust kök" || updated.Status.Vision != "completed" {
+		t.Errorf("insights not stored: %+v", updated)
+	}
+
+	// UpdateStatus is deliberately unversioned: the pipeline reports progress
+	// while the user edits and must not invalidate their ETag.
+	if err := store.UpdateStatus(ctx, created.ID, storage.Status{Text: "in_progress"}); err != nil {
+		t.Fatalf("update status: %v", err)
+	}
+	got, err := store.GetListing(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("get listing: %v", err)
+	}
+	if got.Version != 3 || got.Status.Text != "in_progress" {
+		t.Errorf("after status update: version %d status %+v", got.Version, got.Status)
+	}
+
+	// Section saves must not write back a status snapshot read before the
+	// pipeline moved on.
+	if err := store.UpdateStatus(ctx, created.ID, storage.Status{Text: "completed"}); err != nil {
+		t.Fatalf("update status: %v", err)
+	}
+	saved, err := store.UpdateListingSections(ctx, created.ID, got.Version, got.Sections, got.FullCopy, nil)
+	if err != nil {
+		t.Fatalf("update sections: %v", err)
+	}
+	if saved.Status.Text != "completed" {
+		t.Errorf("section save overwrote status: %+v", saved.Status)
+	}
+}
+
+func testConcurrentUpdates(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	created := mustCreate(t, store, newListing("owner-1", "Samtidigt 1"))
+
+	const writers = 8
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+		conflicts int
+		failures  []error
+	)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			insights := storage.Insights{Vision: storage.VisionInsights{Summary: fmt.Sprintf("skribent %d", i)}}
+			_, err := store.UpdateInsights(ctx, created.ID, created.Version, insights, created.Status)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				succeeded++
+			case errors.Is(err, storage.ErrConflict):
+				conflicts++
+			default:
+				failures = append(failures, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if len(failures) > 0 {
+		t.Fatalf("unexpected errors: %v", failures)
+	}
+	if succeeded != 1 || conflicts != writers-1 {
+		t.Errorf("%d updates succeeded and %d conflicted, want 1 and %d", succeeded, conflicts, writers-1)
+	}
+	got, err := store.GetListing(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("get listing: %v", err)
+	}
+	if got.Version != created.Version+1 {
+		t.Errorf("version = %d, want %d", got.Version, created.Version+1)
+	}
+}
+
+// testConcurrentRevisions has writers retry on conflict, as the handlers do,
+// and checks that every revision is kept with a unique, gapless number.
+func testConcurrentRevisions(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	created := mustCreate(t, store, newListing("owner-1", "Samtidigt 2"))
+
+	const writers = 6
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rev := storage.Revision{Slug: "intro", Title: "Inledning", Content: fmt.Sprintf("version från skribent %d", i), Source: "manual"}
+			for attempt := 0; attempt < 100; attempt++ {
+				current, err := store.GetListing(ctx, created.ID)
+				if err != nil {
+					errs <- err
+					return
+				}
+				_, err = store.UpdateListingSections(ctx, current.ID, current.Version, current.Sections, current.FullCopy, []storage.Revision{rev})
+				if errors.Is(err, storage.ErrConflict) {
+					continue
+				}
+				errs <- err
+				return
+			}
+			errs <- fmt.Errorf("writer %d: gave up after repeated conflicts", i)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("update sections: %v", err)
+		}
+	}
+
+	page, err := store.ListRevisions(ctx, storage.ListRevisionsQuery{ListingID: created.ID, Slug: "intro", PageSize: writers * 2})
+	if err != nil {
+		t.Fatalf("list revisions: %v", err)
+	}
+	if page.Total != writers || len(page.Revisions) != writers {
+		t.Fatalf("revisions = %d (total %d), want %d", len(page.Revisions), page.Total, writers)
+	}
+	for i, rev := range page.Revisions {
+		if want := writers - i; rev.Number != want {
+			t.Errorf("revision %d has number %d, want %d", i, rev.Number, want)
+		}
+	}
+}
+
+func testListingOrdering(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	base := timestamp(-48 * time.Hour)
+	// Insert out of chronological order so stores cannot rely on insertion order.
+	total := storage.DefaultListingLimit + 3
+	for i := 0; i < total; i++ {
+		n := (i * 7) % total
+		listing := newListing("owner-1", fmt.Sprintf("gata %03d", n))
+		listing.CreatedAt = base.Add(time.Duration(n) * time.Minute)
+		mustCreate(t, store, listing)
+	}
+
+	recent, err := store.ListListings(ctx)
+	if err != nil {
+		t.Fatalf("list listings: %v", err)
+	}
+	if len(recent) != storage.DefaultListingLimit {
+		t.Fatalf("ListListings returned %d listings, want %d", len(recent), storage.DefaultListingLimit)
+	}
+	for i, l := range recent {
+		if want := fmt.Sprintf("gata %03d", total-1-i); l.Address != want {
+			t.Fatalf("ListListings[%d] = %q, want %q", i, l.Address, want)
+		}
+	}
+
+	all, err := store.ListAllListings(ctx)
+	if err != nil {
+		t.Fatalf("list all listings: %v", err)
+	}
+	if len(all) != total || all[0].Address != fmt.Sprintf("gata %03d", total-1) || all[total-1].Address != "gata 000" {
+		t.Errorf("ListAllListings returned %d listings from %q to %q", len(all), all[0].Address, all[len(all)-1].Address)
+	}
+
+	seen := map[string]bool{}
+	query := storage.ListListingsQuery{OwnerID: "owner-1", Sort: storage.SortByAddress, Limit: 20}
+	var previous string
+	for pages := 0; ; pages++ {
+		if pages > total {
+			t.Fatal("pagination does not terminate")
+		}
+		page, err := store.QueryListings(ctx, query)
+		if err != nil {
+			t.Fatalf("query listings: %v", err)
+		}
+		for _, l := range page.Listings {
+			if seen[l.ID] {
+				t.Fatalf("listing %s returned twice", l.ID)
+			}
+			if l.Address < previous {
+				t.Fatalf("address %q sorted after %q", l.Address, previous)
+			}
+			seen[l.ID] = true
+			previous = l.Address
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	if len(seen) != total {
+		t.Errorf("paged through %d listings, want %d", len(seen), total)
+	}
+}
+
+func testQueryAndSearch(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	base := timestamp(-24 * time.Hour)
+	for i, address := range []string{"Åsgatan 3", "Björkvägen 7", "Ekallén 12"} {
+		listing := newListing("owner-1", address)
+		listing.CreatedAt = base.Add(time.Duration(i) * time.Minute)
+		if i == 1 {
+			listing.Details.Property.City = "Stockholm"
+		}
+		mustCreate(t, store, listing)
+	}
+	mustCreate(t, store, newListing("owner-2", "Främmande gatan 1"))
+
+	first, err := store.QueryListings(ctx, storage.ListListingsQuery{OwnerID: "owner-1", Limit: 2})
+	if err != nil {
+		t.Fatalf("query listings: %v", err)
+	}
+	if len(first.Listings) != 2 || first.Listings[0].Address != "Ekallén 12" || first.NextCursor == "" {
+		t.Fatalf("first page = %v cursor %q", addresses(first.Listings), first.NextCursor)
+	}
+	second, err := store.QueryListings(ctx, storage.ListListingsQuery{OwnerID: "owner-1", Limit: 2, Cursor: first.NextCursor})
+	if err != nil {
+		t.Fatalf("query second page: %v", err)
+	}
+	if len(second.Listings) != 1 || second.Listings[0].Address != "Åsgatan 3" || second.NextCursor != "" {
+		t.Errorf("second page = %v cursor %q", addresses(second.Listings), second.NextCursor)
+	}
+
+	byCity, err := store.QueryListings(ctx, storage.ListListingsQuery{OwnerID: "owner-1", City: "stockholm"})
+	if err != nil {
+		t.Fatalf("query by city: %v", err)
+	}
+	if len(byCity.Listings) != 1 || byCity.Listings[0].Address != "Björkvägen 7" {
+		t.Errorf("city filter = %v", addresses(byCity.Listings))
+	}
+
+	if _, err := store.QueryListings(ctx, storage.ListListingsQuery{Cursor: "not-a-cursor"}); !errors.Is(err, storage.ErrInvalidCursor) {
+		t.Errorf("bad cursor: %v, want ErrInvalidCursor", err)
+	}
+
+	results, err := store.SearchListings(ctx, storage.SearchListingsQuery{OwnerID: "owner-1", Text: "kakelugn"})
+	if err != nil {
+		t.Fatalf("search listings: %v", err)
+	}
+	if len(results) != 3 {
+		t.Errorf("search hits = %d, want 3", len(results))
+	}
+	for _, result := range results {
+		if result.Listing.OwnerID != "owner-1" || result.Snippet == "" {
+			t.Errorf("unexpected hit %+v", result)
+		}
+	}
+	if results, err := store.SearchListings(ctx, storage.SearchListingsQuery{OwnerID: "owner-1", Text: "  "}); err != nil || len(results) != 0 {
+		t.Errorf("blank search = %d hits, err %v", len(results), err)
+	}
+}
+
+func testTrash(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	kept := mustCreate(t, store, newListing("owner-1", "Kvar 1"))
+	trashed, err := store.CreateListing(ctx, newListing("owner-1", "Borta 1"), []storage.Revision{{Slug: "intro", Content: "v1", Source: "generated"}})
+	if err != nil {
+		t.Fatalf("create listing: %v", err)
+	}
+	mustCreate(t, store, newListing("owner-2", "Annans 1"))
+
+	if err := store.DeleteListing(ctx, trashed.ID); err != nil {
+		t.Fatalf("delete listing: %v", err)
+	}
+	if err := store.DeleteListing(ctx, trashed.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("delete twice: %v, want ErrNotFound", err)
+	}
+	if _, err := store.GetListing(ctx, trashed.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("get trashed listing: %v, want ErrNotFound", err)
+	}
+	if _, err := store.UpdateInsights(ctx, trashed.ID, trashed.Version, storage.Insights{}, storage.Status{}); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("update trashed listing: %v, want ErrNotFound", err)
+	}
+	page, err := store.QueryListings(ctx, storage.ListListingsQuery{OwnerID: "owner-1"})
+	if err != nil {
+		t.Fatalf("query listings: %v", err)
+	}
+	if len(page.Listings) != 1 || page.Listings[0].ID != kept.ID {
+		t.Errorf("live listings = %v, want only %s", ids(page.Listings), kept.ID)
+	}
+
+	deleted, err := store.ListDeletedListings(ctx, "", "owner-1")
+	if err != nil {
+		t.Fatalf("list trash: %v", err)
+	}
+	if len(deleted) != 1 || deleted[0].ID != trashed.ID || deleted[0].DeletedAt == nil {
+		t.Fatalf("trash = %+v", deleted)
+	}
+	if others, err := store.ListDeletedListings(ctx, "", "owner-2"); err != nil || len(others) != 0 {
+		t.Errorf("other owner's trash = %v, err %v", ids(others), err)
+	}
+
+	restored, err := store.RestoreListing(ctx, trashed.ID)
+	if err != nil {
+		t.Fatalf("restore listing: %v", err)
+	}
+	if restored.DeletedAt != nil || restored.Version != trashed.Version+1 {
+		t.Errorf("restored listing: deleted_at %v version %d", restored.DeletedAt, restored.Version)
+	}
+
+	if err := store.DeleteListing(ctx, trashed.ID); err != nil {
+		t.Fatalf("delete listing again: %v", err)
+	}
+	if purged, err := store.PurgeDeletedListings(ctx, timestamp(-time.Minute)); err != nil || len(purged) != 0 {
+		t.Errorf("purge before cutoff removed %v, err %v", ids(purged), err)
+	}
+	purged, err := store.PurgeDeletedListings(ctx, timestamp(time.Minute))
+	if err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if len(purged) != 1 || purged[0].ID != trashed.ID || len(purged[0].Details.Media.Images) != 1 {
+		t.Errorf("purged = %v", ids(purged))
+	}
+	if _, err := store.RestoreListing(ctx, trashed.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("restore purged listing: %v, want ErrNotFound", err)
+	}
+	if _, err := store.GetRevision(ctx, trashed.ID, "intro", 1); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("revision of purged listing: %v, want ErrNotFound", err)
+	}
+}
+
+func testRevisions(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	created, err := store.CreateListing(ctx, newListing("owner-1", "Revisionsvägen 1"), []storage.Revision{
+		{Slug: "intro", Title: "Inledning", Content: "v1", Source: "generated"},
+		{Slug: "area", Title: "Området", Content: "område v1", Source: "generated"},
+	})
+	if err != nil {
+		t.Fatalf("create listing: %v", err)
+	}
+
+	current := created
+	for i := 2; i <= 7; i++ {
+		rev := storage.Revision{Slug: "intro", Title: "Inledning", Content: fmt.Sprintf("v%d", i), Source: "manual", AuthorID: "owner-1", Highlights: []string{"ny"}}
+		current, err = store.UpdateListingSections(ctx, current.ID, current.Version, current.Sections, current.FullCopy, []storage.Revision{rev})
+		if err != nil {
+			t.Fatalf("update sections %d: %v", i, err)
+		}
+	}
+	if _, err := store.UpdateListingSections(ctx, current.ID, created.Version, nil, "", nil); !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("stale section update: %v, want ErrConflict", err)
+	}
+
+	if got := current.History["intro"]; len(got) != storage.LegacyHistoryDepth || got[0].Content != "v7" {
+		t.Errorf("legacy history = %+v", got)
+	}
+	if got := current.History["area"]; len(got) != 1 || got[0].Content != "område v1" {
+		t.Errorf("legacy history for area = %+v", got)
+	}
+
+	page, err := store.ListRevisions(ctx, storage.ListRevisionsQuery{ListingID: current.ID, Slug: "intro", Page: 2, PageSize: 3})
+	if err != nil {
+		t.Fatalf("list revisions: %v", err)
+	}
+	if page.Total != 7 || len(page.Revisions) != 3 || page.Revisions[0].Number != 4 {
+		t.Errorf("page 2 = total %d, revisions %d", page.Total, len(page.Revisions))
+	}
+
+	rev, err := store.GetRevision(ctx, current.ID, "intro", 5)
+	if err != nil {
+		t.Fatalf("get revision: %v", err)
+	}
+	if rev.Content != "v5" || rev.AuthorID != "owner-1" || len(rev.Highlights) != 1 || rev.CreatedAt.IsZero() {
+		t.Errorf("revision 5 = %+v", rev)
+	}
+	if _, err := store.GetRevision(ctx, current.ID, "intro", 99); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("get missing revision: %v, want ErrNotFound", err)
+	}
+}
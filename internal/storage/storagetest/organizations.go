@@ -0,0 +1,201 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"k2MarketingAi/internal/storage"
+)
+
+var organizationScenarios = []scenario{
+	{"create and list", testOrganizationCreate},
+	{"membership", testOrganizationMembership},
+	{"listing isolation", testListingIsolation},
+	{"style profile isolation", testStyleProfileIsolation},
+}
+
+func mustCreateOrganization(t *testing.T, store storage.Store, id, name string) storage.Organization {
+	t.Helper()
+	org, err := store.CreateOrganization(context.Background(), storage.Organization{ID: id, Name: name})
+	if err != nil {
+		t.Fatalf("create organization %s: %v", name, err)
+	}
+	return org
+}
+
+func testOrganizationCreate(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	if _, err := store.GetOrganization(ctx, storage.DefaultOrganizationID); err != nil {
+		t.Fatalf("default organization: %v", err)
+	}
+
+	created := mustCreateOrganization(t, store, "", " Centrummäklarna ")
+	if created.ID == "" || created.Name != "Centrummäklarna" || created.CreatedAt.IsZero() {
+		t.Errorf("created organization = %+v", created)
+	}
+	got, err := store.GetOrganization(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("get organization: %v", err)
+	}
+	if got.Name != created.Name || got.CreatedAt.Sub(created.CreatedAt).Abs() >= time.Microsecond {
+		t.Errorf("stored organization = %+v, want %+v", got, created)
+	}
+
+	mustCreateOrganization(t, store, "alfa", "Alfa Mäkleri")
+	if _, err := store.CreateOrganization(ctx, storage.Organization{ID: "alfa", Name: "Dubblett"}); !errors.Is(err, storage.ErrOrganizationExists) {
+		t.Errorf("duplicate id: %v, want ErrOrganizationExists", err)
+	}
+	if _, err := store.CreateOrganization(ctx, storage.Organization{Name: "  "}); err == nil {
+		t.Error("blank name was accepted")
+	}
+
+	orgs, err := store.ListOrganizations(ctx)
+	if err != nil {
+		t.Fatalf("list organizations: %v", err)
+	}
+	var names []string
+	for _, org := range orgs {
+		names = append(names, org.Name)
+	}
+	if len(names) != 3 || names[0] != "Alfa Mäkleri" || names[1] != "Centrummäklarna" || names[2] != "Standardbyrå" {
+		t.Errorf("organizations = %v, want sorted by name", names)
+	}
+	if _, err := store.GetOrganization(ctx, "missing"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("get missing organization: %v, want ErrNotFound", err)
+	}
+}
+
+func testOrganizationMembership(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	user, err := store.CreateUser(ctx, storage.User{Email: "cecilia@example.se", PasswordHash: "hash"})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if user.OrgID != storage.DefaultOrganizationID {
+		t.Errorf("new user org = %q, want the default organization", user.OrgID)
+	}
+
+	office := mustCreateOrganization(t, store, "norr", "Norrmäklarna")
+	if err := store.SetUserOrganization(ctx, user.ID, office.ID); err != nil {
+		t.Fatalf("set organization: %v", err)
+	}
+	for _, lookup := range []func() (storage.User, error){
+		func() (storage.User, error) { return store.GetUserByID(ctx, user.ID) },
+		func() (storage.User, error) { return store.GetUserByEmail(ctx, user.Email) },
+	} {
+		got, err := lookup()
+		if err != nil || got.OrgID != office.ID {
+			t.Errorf("after move: %+v, err %v", got, err)
+		}
+	}
+
+	member, err := store.CreateUser(ctx, storage.User{Email: "david@example.se", PasswordHash: "hash", OrgID: office.ID})
+	if err != nil || member.OrgID != office.ID {
+		t.Errorf("create user in organization: %+v, err %v", member, err)
+	}
+
+	if err := store.SetUserOrganization(ctx, user.ID, "missing"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("move to missing organization: %v, want ErrNotFound", err)
+	}
+	if err := store.SetUserOrganization(ctx, "missing", office.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("move missing user: %v, want ErrNotFound", err)
+	}
+}
+
+func testListingIsolation(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	mustCreateOrganization(t, store, "syd", "Sydmäklarna")
+
+	home := mustCreate(t, store, newListing("owner-1", "Hemgatan 1"))
+	if home.OrgID != storage.DefaultOrganizationID {
+		t.Errorf("listing without org got %q", home.OrgID)
+	}
+	foreign := newListing("owner-1", "Bortagatan 2")
+	foreign.OrgID = "syd"
+	foreign = mustCreate(t, store, foreign)
+
+	got, err := store.GetListing(ctx, foreign.ID)
+	if err != nil || got.OrgID != "syd" {
+		t.Fatalf("get listing: %+v, err %v", got, err)
+	}
+
+	page, err := store.QueryListings(ctx, storage.ListListingsQuery{OrgID: "syd"})
+	if err != nil {
+		t.Fatalf("query by organization: %v", err)
+	}
+	if len(page.Listings) != 1 || page.Listings[0].ID != foreign.ID {
+		t.Errorf("organization page = %v", addresses(page.Listings))
+	}
+	page, err = store.QueryListings(ctx, storage.ListListingsQuery{OrgID: storage.DefaultOrganizationID, OwnerID: "owner-1"})
+	if err != nil {
+		t.Fatalf("query by organization and owner: %v", err)
+	}
+	if len(page.Listings) != 1 || page.Listings[0].ID != home.ID {
+		t.Errorf("default organization page = %v", addresses(page.Listings))
+	}
+
+	results, err := store.SearchListings(ctx, storage.SearchListingsQuery{OrgID: "syd", Text: "kakelugn"})
+	if err != nil {
+		t.Fatalf("search by organization: %v", err)
+	}
+	if len(results) != 1 || results[0].Listing.ID != foreign.ID {
+		t.Errorf("organization search hits = %d", len(results))
+	}
+
+	if err := store.DeleteListing(ctx, foreign.ID); err != nil {
+		t.Fatalf("delete listing: %v", err)
+	}
+	if trashed, err := store.ListDeletedListings(ctx, "syd", ""); err != nil || len(trashed) != 1 {
+		t.Errorf("organization trash = %d listings, err %v", len(trashed), err)
+	}
+	if trashed, err := store.ListDeletedListings(ctx, storage.DefaultOrganizationID, "owner-1"); err != nil || len(trashed) != 0 {
+		t.Errorf("default organization trash = %d listings, err %v", len(trashed), err)
+	}
+}
+
+func testStyleProfileIsolation(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	mustCreateOrganization(t, store, "vast", "Västmäklarna")
+
+	own, err := store.SaveStyleProfile(ctx, storage.StyleProfile{Name: "Egen", OrgID: "vast"})
+	if err != nil {
+		t.Fatalf("save profile: %v", err)
+	}
+	if own.OrgID != "vast" {
+		t.Errorf("saved profile org = %q", own.OrgID)
+	}
+	other, err := store.SaveStyleProfile(ctx, storage.StyleProfile{Name: "Standard"})
+	if err != nil {
+		t.Fatalf("save default profile: %v", err)
+	}
+	if other.OrgID != storage.DefaultOrganizationID {
+		t.Errorf("profile without org got %q", other.OrgID)
+	}
+
+	hijack := other
+	hijack.OrgID = "vast"
+	hijack.Name = "Kapad"
+	if _, err := store.SaveStyleProfile(ctx, hijack); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("overwrite from another organization: %v, want ErrNotFound", err)
+	}
+	if got, err := store.GetStyleProfile(ctx, other.ID); err != nil || got.Name != "Standard" || got.OrgID != storage.DefaultOrganizationID {
+		t.Errorf("profile after rejected overwrite: %+v, err %v", got, err)
+	}
+
+	profiles, err := store.ListStyleProfiles(ctx, "vast")
+	if err != nil {
+		t.Fatalf("list organization profiles: %v", err)
+	}
+	if len(profiles) != 1 || profiles[0].ID != own.ID {
+		t.Errorf("organization profiles = %+v", profiles)
+	}
+	all, err := store.ListStyleProfiles(ctx, "")
+	if err != nil {
+		t.Fatalf("list all profiles: %v", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("all profiles = %d, want 2", len(all))
+	}
+}
@@ -0,0 +1,118 @@
+package storagetest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"k2MarketingAi/internal/storage"
+)
+
+var rateLimitScenarios = []scenario{
+	{"round trip", testRateLimitRoundTrip},
+	{"concurrent updates", testRateLimitConcurrentUpdates},
+	{"purge", testPurgeRateLimits},
+}
+
+func testRateLimitRoundTrip(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	got, err := store.UpdateRateLimit(ctx, "ip|10.0.0.1", func(current storage.RateLimit) storage.RateLimit {
+		if current.Key != "ip|10.0.0.1" || !current.UpdatedAt.IsZero() || current.Tokens != 0 || current.LockedUntil != nil {
+			t.Errorf("new key state = %+v", current)
+		}
+		return current
+	})
+	if err != nil {
+		t.Fatalf("read new key: %v", err)
+	}
+	if !got.UpdatedAt.IsZero() {
+		t.Errorf("untouched key was saved: %+v", got)
+	}
+
+	updated := timestamp(0)
+	locked := timestamp(time.Minute)
+	if _, err := store.UpdateRateLimit(ctx, "login|anna@example.se", func(current storage.RateLimit) storage.RateLimit {
+		current.Tokens = 2.5
+		current.Failures = 3
+		current.LockedUntil = &locked
+		current.UpdatedAt = updated
+		return current
+	}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if _, err := store.UpdateRateLimit(ctx, "login|anna@example.se", func(current storage.RateLimit) storage.RateLimit {
+		if current.Tokens != 2.5 || current.Failures != 3 || current.LockedUntil == nil || !current.LockedUntil.Equal(locked) || !current.UpdatedAt.Equal(updated) {
+			t.Errorf("stored state = %+v", current)
+		}
+		return storage.RateLimit{}
+	}); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	if _, err := store.UpdateRateLimit(ctx, "login|anna@example.se", func(current storage.RateLimit) storage.RateLimit {
+		if !current.UpdatedAt.IsZero() || current.Failures != 0 {
+			t.Errorf("state after reset = %+v", current)
+		}
+		return current
+	}); err != nil {
+		t.Fatalf("read after reset: %v", err)
+	}
+}
+
+func testRateLimitConcurrentUpdates(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	const workers = 8
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := store.UpdateRateLimit(ctx, "shared", func(current storage.RateLimit) storage.RateLimit {
+				current.Failures++
+				current.UpdatedAt = timestamp(0)
+				return current
+			}); err != nil {
+				t.Errorf("update: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	got, err := store.UpdateRateLimit(ctx, "shared", func(current storage.RateLimit) storage.RateLimit { return current })
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if got.Failures != workers {
+		t.Errorf("failures = %d, want %d (lost updates)", got.Failures, workers)
+	}
+}
+
+func testPurgeRateLimits(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	lockedUntil := timestamp(time.Hour)
+	for key, state := range map[string]storage.RateLimit{
+		"idle":   {Tokens: 1, UpdatedAt: timestamp(-2 * time.Hour)},
+		"fresh":  {Tokens: 1, UpdatedAt: timestamp(0)},
+		"locked": {Failures: 9, LockedUntil: &lockedUntil, UpdatedAt: timestamp(-2 * time.Hour)},
+	} {
+		if _, err := store.UpdateRateLimit(ctx, key, func(storage.RateLimit) storage.RateLimit { return state }); err != nil {
+			t.Fatalf("save %s: %v", key, err)
+		}
+	}
+
+	n, err := store.PurgeRateLimits(ctx, timestamp(-time.Hour))
+	if err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("purged %d keys, want 1", n)
+	}
+	for key, wantKept := range map[string]bool{"idle": false, "fresh": true, "locked": true} {
+		got, err := store.UpdateRateLimit(ctx, key, func(current storage.RateLimit) storage.RateLimit { return current })
+		if err != nil {
+			t.Fatalf("read %s: %v", key, err)
+		}
+		if kept := !got.UpdatedAt.IsZero(); kept != wantKept {
+			t.Errorf("%s kept = %v, want %v", key, kept, wantKept)
+		}
+	}
+}
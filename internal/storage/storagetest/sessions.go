@@ -0,0 +1,134 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"k2MarketingAi/internal/storage"
+)
+
+var sessionScenarios = []scenario{
+	{"lifecycle", testSessionLifecycle},
+	{"revoke", testSessionRevoke},
+}
+
+func mustCreateSession(t *testing.T, store storage.Store, userID string, lastSeen time.Time, ttl time.Duration) storage.Session {
+	t.Helper()
+	session, err := store.CreateSession(context.Background(), storage.Session{
+		UserID:     userID,
+		CreatedAt:  lastSeen,
+		LastSeenAt: lastSeen,
+		ExpiresAt:  lastSeen.Add(ttl),
+		UserAgent:  "Firefox",
+		IP:         "192.0.2.1",
+	})
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	return session
+}
+
+func testSessionLifecycle(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	user, err := store.CreateUser(ctx, storage.User{Email: "ida@example.se", PasswordHash: "hash"})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	now := time.Now()
+	older := mustCreateSession(t, store, user.ID, now.Add(-2*time.Hour), 24*time.Hour)
+	newer := mustCreateSession(t, store, user.ID, now.Add(-time.Hour), 24*time.Hour)
+	mustCreateSession(t, store, user.ID, now.Add(-48*time.Hour), time.Hour)
+
+	got, err := store.GetSession(ctx, older.ID)
+	if err != nil {
+		t.Fatalf("get session: %v", err)
+	}
+	if got.UserID != user.ID || got.UserAgent != "Firefox" || got.IP != "192.0.2.1" || got.ExpiresAt.Sub(older.ExpiresAt).Abs() >= time.Microsecond {
+		t.Errorf("stored session = %+v, want %+v", got, older)
+	}
+
+	sessions, err := store.ListSessions(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("list sessions: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].ID != newer.ID || sessions[1].ID != older.ID {
+		t.Errorf("sessions = %+v, want the two unexpired ones, most recent first", sessions)
+	}
+
+	if err := store.TouchSession(ctx, older.ID, now, now.Add(48*time.Hour)); err != nil {
+		t.Fatalf("touch session: %v", err)
+	}
+	sessions, err = store.ListSessions(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("list after touch: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].ID != older.ID || sessions[0].ExpiresAt.Sub(now.Add(48*time.Hour)).Abs() >= time.Microsecond {
+		t.Errorf("after touch: %+v", sessions)
+	}
+
+	if _, err := store.CreateSession(ctx, storage.Session{UserID: "missing", ExpiresAt: now.Add(time.Hour)}); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("session for missing user: %v, want ErrNotFound", err)
+	}
+	if _, err := store.GetSession(ctx, "missing"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("get missing session: %v, want ErrNotFound", err)
+	}
+	if err := store.TouchSession(ctx, "missing", now, now); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("touch missing session: %v, want ErrNotFound", err)
+	}
+}
+
+func testSessionRevoke(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	user, err := store.CreateUser(ctx, storage.User{Email: "johan@example.se", PasswordHash: "hash"})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	other, err := store.CreateUser(ctx, storage.User{Email: "karin@example.se", PasswordHash: "hash"})
+	if err != nil {
+		t.Fatalf("create other user: %v", err)
+	}
+	now := time.Now()
+	current := mustCreateSession(t, store, user.ID, now, time.Hour)
+	phone := mustCreateSession(t, store, user.ID, now, time.Hour)
+	laptop := mustCreateSession(t, store, user.ID, now, time.Hour)
+	foreign := mustCreateSession(t, store, other.ID, now, time.Hour)
+	expired := mustCreateSession(t, store, other.ID, now.Add(-3*time.Hour), time.Hour)
+
+	if err := store.DeleteSession(ctx, user.ID, foreign.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("delete another user's session: %v, want ErrNotFound", err)
+	}
+	if err := store.DeleteSession(ctx, user.ID, phone.ID); err != nil {
+		t.Fatalf("delete session: %v", err)
+	}
+	if _, err := store.GetSession(ctx, phone.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("revoked session still readable: %v", err)
+	}
+
+	removed, err := store.DeleteUserSessions(ctx, user.ID, current.ID)
+	if err != nil || removed != 1 {
+		t.Errorf("delete other sessions = %d, err %v, want 1", removed, err)
+	}
+	if _, err := store.GetSession(ctx, laptop.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("laptop session survived: %v", err)
+	}
+	if _, err := store.GetSession(ctx, current.ID); err != nil {
+		t.Errorf("kept session: %v", err)
+	}
+
+	purged, err := store.PurgeExpiredSessions(ctx, now)
+	if err != nil || purged != 1 {
+		t.Errorf("purge expired = %d, err %v, want 1", purged, err)
+	}
+	if _, err := store.GetSession(ctx, expired.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expired session survived purge: %v", err)
+	}
+
+	if err := store.DeleteUser(ctx, other.ID); err != nil {
+		t.Fatalf("delete user: %v", err)
+	}
+	if _, err := store.GetSession(ctx, foreign.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("session outlived its user: %v", err)
+	}
+}
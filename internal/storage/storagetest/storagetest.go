@@ -0,0 +1,102 @@
+// Package storagetest holds the conformance suite every storage.Store
+// implementation must pass, so the in-memory, SQLite and PostgreSQL stores
+// behave the same towards the handlers.
+package storagetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k2MarketingAi/internal/storage"
+)
+
+// RunConformance runs every scenario against a fresh store from newStore.
+// newStore must return an empty store each time; RunConformance closes it when
+// the scenario ends.
+func RunConformance(t *testing.T, newStore func() storage.Store) {
+	t.Helper()
+	groups := []struct {
+		name      string
+		scenarios []scenario
+	}{
+		{"listings", listingScenarios},
+		{"style profiles", styleProfileScenarios},
+		{"users", userScenarios},
+		{"organizations", organizationScenarios},
+		{"sessions", sessionScenarios},
+		{"api tokens", apiTokenScenarios},
+		{"two-factor", twoFactorScenarios},
+		{"rate limits", rateLimitScenarios},
+		{"audit log", auditScenarios},
+		{"llm usage", usageScenarios},
+	}
+	for _, group := range groups {
+		t.Run(group.name, func(t *testing.T) {
+			for _, sc := range group.scenarios {
+				t.Run(sc.name, func(t *testing.T) {
+					store := newStore()
+					t.Cleanup(store.Close)
+					sc.run(t, store)
+				})
+			}
+		})
+	}
+}
+
+type scenario struct {
+	name string
+	run  func(t *testing.T, store storage.Store)
+}
+
+// timestamp returns a time the stores can round-trip exactly: PostgreSQL keeps
+// microseconds, so finer precision is dropped up front.
+func timestamp(offset time.Duration) time.Time {
+	return time.Now().Add(offset).Truncate(time.Microsecond)
+}
+
+func newListing(owner, address string) storage.Listing {
+	return storage.Listing{
+		OwnerID:        owner,
+		Address:        address,
+		Tone:           "Varm",
+		TargetAudience: "Barnfamiljer",
+		Highlights:     []string{"Balkong", "Kakelugn"},
+		Fee:            3200,
+		LivingArea:     74.5,
+		Rooms:          3,
+		Sections:       []storage.Section{{Slug: "intro", Title: "Inledning", Content: "Ljus trea med kakelugn."}},
+		FullCopy:       "Ljus trea med kakelugn.",
+		Status:         storage.Status{Data: "completed", Text: "completed"},
+		Details: storage.Details{
+			Property: storage.PropertyInfo{City: "Uppsala", PropertyType: "Bostadsrätt", Rooms: 3},
+			Media:    storage.MediaLibrary{Images: []storage.ImageAsset{{URL: "https://example.test/a.jpg", Key: "a.jpg"}}},
+		},
+		CreatedAt: timestamp(-time.Hour),
+	}
+}
+
+func mustCreate(t *testing.T, store storage.Store, listing storage.Listing) storage.Listing {
+	t.Helper()
+	created, err := store.CreateListing(context.Background(), listing, nil)
+	if err != nil {
+		t.Fatalf("create listing: %v", err)
+	}
+	return created
+}
+
+func ids(listings []storage.Listing) []string {
+	out := make([]string, len(listings))
+	for i, l := range listings {
+		out[i] = l.ID
+	}
+	return out
+}
+
+func addresses(listings []storage.Listing) []string {
+	out := make([]string, len(listings))
+	for i, l := range listings {
+		out[i] = l.Address
+	}
+	return out
+}
@@ -0,0 +1,150 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"k2MarketingAi/internal/storage"
+)
+
+var twoFactorScenarios = []scenario{
+	{"enrollment", testTwoFactorEnrollment},
+	{"one-time codes", testTwoFactorOneTimeCodes},
+	{"organization requirement", testOrganizationTwoFactor},
+}
+
+func testTwoFactorEnrollment(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	user, err := store.CreateUser(ctx, storage.User{Email: "olle@example.se", PasswordHash: "hash"})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if _, err := store.GetTwoFactor(ctx, user.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("get before enrollment: %v, want ErrNotFound", err)
+	}
+
+	pending := storage.TwoFactor{UserID: user.ID, Secret: "JBSWY3DPEHPK3PXP", CreatedAt: timestamp(-time.Minute)}
+	if err := store.SaveTwoFactor(ctx, pending); err != nil {
+		t.Fatalf("save pending: %v", err)
+	}
+	got, err := store.GetTwoFactor(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("get pending: %v", err)
+	}
+	if got.Secret != pending.Secret || got.Enabled || got.EnabledAt != nil || len(got.RecoveryCodes) != 0 || !got.CreatedAt.Equal(pending.CreatedAt) {
+		t.Errorf("pending enrollment = %+v, want %+v", got, pending)
+	}
+
+	enabledAt := timestamp(0)
+	got.Enabled = true
+	got.EnabledAt = &enabledAt
+	got.RecoveryCodes = []string{"hash-a", "hash-b"}
+	if err := store.SaveTwoFactor(ctx, got); err != nil {
+		t.Fatalf("save enabled: %v", err)
+	}
+	got, err = store.GetTwoFactor(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("get enabled: %v", err)
+	}
+	if !got.Enabled || got.EnabledAt == nil || !got.EnabledAt.Equal(enabledAt) || len(got.RecoveryCodes) != 2 || got.RecoveryCodes[1] != "hash-b" {
+		t.Errorf("enabled enrollment = %+v", got)
+	}
+
+	if err := store.SaveTwoFactor(ctx, storage.TwoFactor{UserID: "missing", Secret: "x"}); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("save for missing user: %v, want ErrNotFound", err)
+	}
+	if err := store.DeleteTwoFactor(ctx, user.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := store.GetTwoFactor(ctx, user.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("get after delete: %v, want ErrNotFound", err)
+	}
+	if err := store.DeleteTwoFactor(ctx, user.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("delete twice: %v, want ErrNotFound", err)
+	}
+
+	if err := store.SaveTwoFactor(ctx, pending); err != nil {
+		t.Fatalf("save again: %v", err)
+	}
+	if err := store.DeleteUser(ctx, user.ID); err != nil {
+		t.Fatalf("delete user: %v", err)
+	}
+	if _, err := store.GetTwoFactor(ctx, user.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("enrollment outlived its user: %v", err)
+	}
+}
+
+func testTwoFactorOneTimeCodes(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	user, err := store.CreateUser(ctx, storage.User{Email: "pia@example.se", PasswordHash: "hash"})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if err := store.SaveTwoFactor(ctx, storage.TwoFactor{
+		UserID:        user.ID,
+		Secret:        "JBSWY3DPEHPK3PXP",
+		Enabled:       true,
+		RecoveryCodes: []string{"hash-a", "hash-b", "hash-c"},
+	}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	for _, tc := range []struct {
+		step int64
+		want bool
+	}{{100, true}, {100, false}, {99, false}, {101, true}} {
+		ok, err := store.ClaimTwoFactorStep(ctx, user.ID, tc.step)
+		if err != nil || ok != tc.want {
+			t.Errorf("claim step %d = %v, err %v, want %v", tc.step, ok, err, tc.want)
+		}
+	}
+	if ok, err := store.ClaimTwoFactorStep(ctx, "missing", 1); err != nil || ok {
+		t.Errorf("claim for missing user = %v, err %v", ok, err)
+	}
+
+	if ok, err := store.UseRecoveryCode(ctx, user.ID, "hash-b"); err != nil || !ok {
+		t.Errorf("use recovery code = %v, err %v", ok, err)
+	}
+	if ok, err := store.UseRecoveryCode(ctx, user.ID, "hash-b"); err != nil || ok {
+		t.Errorf("reuse recovery code = %v, err %v", ok, err)
+	}
+	if ok, err := store.UseRecoveryCode(ctx, "missing", "hash-a"); err != nil || ok {
+		t.Errorf("recovery code for missing user = %v, err %v", ok, err)
+	}
+	got, err := store.GetTwoFactor(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.LastStep != 101 || len(got.RecoveryCodes) != 2 || got.RecoveryCodes[0] != "hash-a" || got.RecoveryCodes[1] != "hash-c" {
+		t.Errorf("after use = %+v", got)
+	}
+}
+
+func testOrganizationTwoFactor(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	org := mustCreateOrganization(t, store, "saker", "Säkra Mäklare")
+	if org.RequireTwoFactor {
+		t.Fatalf("new organization requires two-factor")
+	}
+	if err := store.SetOrganizationTwoFactor(ctx, org.ID, true); err != nil {
+		t.Fatalf("require two-factor: %v", err)
+	}
+	got, err := store.GetOrganization(ctx, org.ID)
+	if err != nil || !got.RequireTwoFactor {
+		t.Errorf("organization = %+v, err %v, want two-factor required", got, err)
+	}
+	orgs, err := store.ListOrganizations(ctx)
+	if err != nil {
+		t.Fatalf("list organizations: %v", err)
+	}
+	for _, o := range orgs {
+		if o.RequireTwoFactor != (o.ID == org.ID) {
+			t.Errorf("listed %s with require_two_factor=%v", o.ID, o.RequireTwoFactor)
+		}
+	}
+	if err := store.SetOrganizationTwoFactor(ctx, "missing", true); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("missing organization: %v, want ErrNotFound", err)
+	}
+}
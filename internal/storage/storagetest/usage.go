@@ -0,0 +1,83 @@
+package storagetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k2MarketingAi/internal/storage"
+)
+
+var usageScenarios = []scenario{
+	{"daily summary", testUsageSummary},
+}
+
+func testUsageSummary(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	if _, err := store.RecordLLMUsage(ctx, storage.LLMUsage{Operation: "generate"}); err == nil {
+		t.Errorf("usage without model was accepted")
+	}
+
+	// 23:30 in Stockholm on March 1st is still March 1st in UTC; 00:30 on
+	// March 2nd is not.
+	stockholm := time.FixedZone("CET", 60*60)
+	day1 := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	record := func(at time.Time, org, user, listing, operation, model string, prompt, completion int) {
+		t.Helper()
+		if _, err := store.RecordLLMUsage(ctx, storage.LLMUsage{
+			UserID: user, OrgID: org, ListingID: listing, Operation: operation, Model: model,
+			PromptTokens: prompt, CompletionTokens: completion, CreatedAt: at,
+		}); err != nil {
+			t.Fatalf("record %s: %v", operation, err)
+		}
+	}
+	record(day1, "org-a", "anna", "l1", "generate", "gemini-pro", 1000, 400)
+	record(day1.Add(time.Hour), "org-a", "bertil", "l1", "generate", "gemini-pro", 500, 100)
+	record(day1.Add(2*time.Hour), "org-a", "anna", "l1", "rewrite", "gemini-pro", 300, 80)
+	record(time.Date(2025, 3, 2, 0, 30, 0, 0, stockholm), "org-a", "anna", "l2", "generate", "gemini-pro", 10, 5)
+	record(time.Date(2025, 3, 2, 9, 0, 0, 0, time.UTC), "org-b", "cecilia", "", "design", "gpt-4o", 200, 50)
+
+	days, err := store.SummarizeLLMUsage(ctx, storage.UsageQuery{})
+	if err != nil {
+		t.Fatalf("summarize: %v", err)
+	}
+	want := []storage.UsageDay{
+		{Day: "2025-03-01", OrgID: "org-a", Model: "gemini-pro", Operation: "generate", Requests: 3, PromptTokens: 1510, CompletionTokens: 505},
+		{Day: "2025-03-01", OrgID: "org-a", Model: "gemini-pro", Operation: "rewrite", Requests: 1, PromptTokens: 300, CompletionTokens: 80},
+		{Day: "2025-03-02", OrgID: "org-b", Model: "gpt-4o", Operation: "design", Requests: 1, PromptTokens: 200, CompletionTokens: 50},
+	}
+	if len(days) != len(want) {
+		t.Fatalf("days = %+v, want %+v", days, want)
+	}
+	for i := range want {
+		if days[i] != want[i] {
+			t.Errorf("day %d = %+v, want %+v", i, days[i], want[i])
+		}
+	}
+
+	cases := []struct {
+		name     string
+		query    storage.UsageQuery
+		requests int64
+	}{
+		{"org", storage.UsageQuery{OrgID: "org-a"}, 4},
+		{"user", storage.UsageQuery{UserID: "anna"}, 3},
+		{"listing", storage.UsageQuery{ListingID: "l1"}, 3},
+		{"operation", storage.UsageQuery{Operation: "design"}, 1},
+		{"model", storage.UsageQuery{Model: "gemini-pro"}, 4},
+		{"time range", storage.UsageQuery{Since: day1.Add(time.Hour), Until: day1.Add(12 * time.Hour)}, 3},
+	}
+	for _, tc := range cases {
+		days, err := store.SummarizeLLMUsage(ctx, tc.query)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		var requests int64
+		for _, day := range days {
+			requests += day.Requests
+		}
+		if requests != tc.requests {
+			t.Errorf("%s: requests = %d, want %d", tc.name, requests, tc.requests)
+		}
+	}
+}
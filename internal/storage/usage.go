@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LLMUsage records one call to a language or image model and the tokens it
+// consumed. UserID and ListingID are empty for calls made outside a request
+// or before a listing exists.
+type LLMUsage struct {
+	ID               string    `json:"id"`
+	UserID           string    `json:"user_id,omitempty"`
+	OrgID            string    `json:"org_id,omitempty"`
+	ListingID        string    `json:"listing_id,omitempty"`
+	Operation        string    `json:"operation"`
+	Model            string    `json:"model"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// UsageQuery narrows the usage summary. Empty fields do not narrow it.
+type UsageQuery struct {
+	OrgID     string
+	UserID    string
+	ListingID string
+	Operation string
+	Model     string
+	// Since and Until bound CreatedAt; Since is inclusive, Until exclusive.
+	Since time.Time
+	Until time.Time
+}
+
+// UsageDay sums the calls of one UTC day for an organization, model and
+// operation.
+type UsageDay struct {
+	Day              string `json:"day"`
+	OrgID            string `json:"org_id"`
+	Model            string `json:"model"`
+	Operation        string `json:"operation"`
+	Requests         int64  `json:"requests"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+}
+
+// usageDayLayout formats UsageDay.Day.
+const usageDayLayout = "2006-01-02"
+
+func (q UsageQuery) matches(u LLMUsage) bool {
+	switch {
+	case q.OrgID != "" && u.OrgID != q.OrgID,
+		q.UserID != "" && u.UserID != q.UserID,
+		q.ListingID != "" && u.ListingID != q.ListingID,
+		q.Operation != "" && u.Operation != q.Operation,
+		q.Model != "" && u.Model != q.Model,
+		!q.Since.IsZero() && u.CreatedAt.Before(q.Since),
+		!q.Until.IsZero() && !u.CreatedAt.Before(q.Until):
+		return false
+	}
+	return true
+}
+
+// where renders the query filters as SQL conditions. placeholder returns the
+// parameter marker for the n-th argument, starting at 1.
+func (q UsageQuery) where(placeholder func(n int) string, timestamp func(time.Time) any) (string, []any) {
+	var (
+		conditions []string
+		args       []any
+	)
+	add := func(column, op string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, column+op+placeholder(len(args)))
+	}
+	if q.OrgID != "" {
+		add("org_id", "=", q.OrgID)
+	}
+	if q.UserID != "" {
+		add("user_id", "=", q.UserID)
+	}
+	if q.ListingID != "" {
+		add("listing_id", "=", q.ListingID)
+	}
+	if q.Operation != "" {
+		add("operation", "=", q.Operation)
+	}
+	if q.Model != "" {
+		add("model", "=", q.Model)
+	}
+	if !q.Since.IsZero() {
+		add("created_at", ">=", timestamp(q.Since))
+	}
+	if !q.Until.IsZero() {
+		add("created_at", "<", timestamp(q.Until))
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// prepareLLMUsage validates a new record and fills in its id and creation time.
+func prepareLLMUsage(usage LLMUsage) (LLMUsage, error) {
+	usage.Operation = strings.TrimSpace(usage.Operation)
+	usage.Model = strings.TrimSpace(usage.Model)
+	if usage.Operation == "" || usage.Model == "" {
+		return LLMUsage{}, fmt.Errorf("usage operation and model are required")
+	}
+	if usage.PromptTokens < 0 || usage.CompletionTokens < 0 {
+		return LLMUsage{}, fmt.Errorf("usage token counts cannot be negative")
+	}
+	if usage.ID == "" {
+		usage.ID = uuid.NewString()
+	}
+	if usage.CreatedAt.IsZero() {
+		usage.CreatedAt = time.Now()
+	}
+	return usage, nil
+}
@@ -0,0 +1,232 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"k2MarketingAi/internal/events"
+	"k2MarketingAi/internal/storage"
+)
+
+// Quota scopes.
+const (
+	ScopeOrganization = "organization"
+	ScopeUser         = "user"
+)
+
+// Quota warning levels.
+const (
+	LevelWarning  = "warning"
+	LevelExceeded = "exceeded"
+)
+
+// Budget is a monthly allowance. Tokens count prompt and completion tokens
+// together. A zero limit is unlimited.
+type Budget struct {
+	Tokens   int64
+	Requests int64
+}
+
+func (b Budget) unlimited() bool {
+	return b.Tokens <= 0 && b.Requests <= 0
+}
+
+// Quota enforces monthly budgets per organization and per user. Months are
+// calendar months in UTC, matching the days of the usage report.
+type Quota struct {
+	Store Summarizer
+	// Organization and User apply to everyone; Organizations and Users,
+	// keyed by id, replace them for single organizations and users.
+	Organization  Budget
+	User          Budget
+	Organizations map[string]Budget
+	Users         map[string]Budget
+	// SoftLimitPercent of a budget publishes a warning on Events.
+	SoftLimitPercent int
+	Events           *events.Broker
+}
+
+// Allowance is what is left of one budget this month.
+type Allowance struct {
+	Scope             string    `json:"scope"`
+	ID                string    `json:"id"`
+	TokensLimit       int64     `json:"tokens_limit,omitempty"`
+	TokensUsed        int64     `json:"tokens_used"`
+	TokensRemaining   *int64    `json:"tokens_remaining,omitempty"`
+	RequestsLimit     int64     `json:"requests_limit,omitempty"`
+	RequestsUsed      int64     `json:"requests_used"`
+	RequestsRemaining *int64    `json:"requests_remaining,omitempty"`
+	ResetsAt          time.Time `json:"resets_at"`
+}
+
+// Exhausted reports whether any limit of the allowance is used up.
+func (a Allowance) Exhausted() bool {
+	return (a.TokensRemaining != nil && *a.TokensRemaining == 0) ||
+		(a.RequestsRemaining != nil && *a.RequestsRemaining == 0)
+}
+
+func (q *Quota) budget(scope, id string) Budget {
+	if scope == ScopeOrganization {
+		if budget, ok := q.Organizations[id]; ok {
+			return budget
+		}
+		return q.Organization
+	}
+	if budget, ok := q.Users[id]; ok {
+		return budget
+	}
+	return q.User
+}
+
+// Allowances returns the budgets that apply to the tagged organization and
+// user with what is left of them this month. Unlimited scopes are left out.
+func (q *Quota) Allowances(ctx context.Context, tags Tags) ([]Allowance, error) {
+	now := time.Now().UTC()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	var allowances []Allowance
+	for _, scope := range []struct{ name, id string }{
+		{ScopeOrganization, tags.OrgID},
+		{ScopeUser, tags.UserID},
+	} {
+		if scope.id == "" {
+			continue
+		}
+		budget := q.budget(scope.name, scope.id)
+		if budget.unlimited() {
+			continue
+		}
+		query := storage.UsageQuery{Since: monthStart}
+		if scope.name == ScopeOrganization {
+			query.OrgID = scope.id
+		} else {
+			query.UserID = scope.id
+		}
+		days, err := q.Store.SummarizeLLMUsage(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		allowance := Allowance{
+			Scope:         scope.name,
+			ID:            scope.id,
+			TokensLimit:   max(budget.Tokens, 0),
+			RequestsLimit: max(budget.Requests, 0),
+			ResetsAt:      monthStart.AddDate(0, 1, 0),
+		}
+		for _, day := range days {
+			allowance.TokensUsed += day.PromptTokens + day.CompletionTokens
+			allowance.RequestsUsed += day.Requests
+		}
+		if allowance.TokensLimit > 0 {
+			remaining := max(allowance.TokensLimit-allowance.TokensUsed, 0)
+			allowance.TokensRemaining = &remaining
+		}
+		if allowance.RequestsLimit > 0 {
+			remaining := max(allowance.RequestsLimit-allowance.RequestsUsed, 0)
+			allowance.RequestsRemaining = &remaining
+		}
+		allowances = append(allowances, allowance)
+	}
+	return allowances, nil
+}
+
+// quotaExceeded is the body of a 429 answer.
+type quotaExceeded struct {
+	Error string      `json:"error"`
+	Quota []Allowance `json:"quota"`
+}
+
+// Enforce is middleware that answers 429 Too Many Requests, with the
+// remaining quota, when the tagged organization or user has spent its budget.
+// It must run after Tag. A call already under way is allowed to finish, so a
+// budget can be overrun by the last call. When usage cannot be read the
+// request is let through rather than blocking the brokers' work.
+func (q *Quota) Enforce(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if q == nil || q.Store == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		allowances, err := q.Allowances(r.Context(), TagsFromContext(r.Context()))
+		if err != nil {
+			log.Printf("quota check: %v", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+		for _, allowance := range allowances {
+			if !allowance.Exhausted() {
+				continue
+			}
+			message := "Byr\u00e5ns m\u00e5nadskvot f\u00f6r AI-anrop \u00e4r slut."
+			if allowance.Scope == ScopeUser {
+				message = "Din m\u00e5nadskvot f\u00f6r AI-anrop \u00e4r slut."
+			}
+			retry := int(time.Until(allowance.ResetsAt).Seconds()) + 1
+			w.Header().Set("Retry-After", strconv.Itoa(retry))
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_ = json.NewEncoder(w).Encode(quotaExceeded{Error: message, Quota: allowances})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// warn publishes a quota warning for every budget that record pushed past
+// the soft limit or past the limit itself, so each level is announced once a
+// month per budget.
+func (q *Quota) warn(ctx context.Context, record storage.LLMUsage) {
+	if q == nil || q.Store == nil || q.Events == nil {
+		return
+	}
+	allowances, err := q.Allowances(ctx, Tags{OrgID: record.OrgID, UserID: record.UserID})
+	if err != nil {
+		log.Printf("quota warning: %v", err)
+		return
+	}
+	tokens := int64(record.PromptTokens + record.CompletionTokens)
+	for _, allowance := range allowances {
+		level := ""
+		if crossed(allowance.TokensUsed-tokens, allowance.TokensUsed, allowance.TokensLimit, 100) ||
+			crossed(allowance.RequestsUsed-1, allowance.RequestsUsed, allowance.RequestsLimit, 100) {
+			level = LevelExceeded
+		} else if q.SoftLimitPercent < 100 &&
+			(crossed(allowance.TokensUsed-tokens, allowance.TokensUsed, allowance.TokensLimit, q.SoftLimitPercent) ||
+				crossed(allowance.RequestsUsed-1, allowance.RequestsUsed, allowance.RequestsLimit, q.SoftLimitPercent)) {
+			level = LevelWarning
+		}
+		if level == "" {
+			continue
+		}
+		evt := events.Event{
+			OrgID: record.OrgID,
+			Quota: &events.QuotaWarning{
+				Level:         level,
+				Scope:         allowance.Scope,
+				TokensUsed:    allowance.TokensUsed,
+				TokensLimit:   allowance.TokensLimit,
+				RequestsUsed:  allowance.RequestsUsed,
+				RequestsLimit: allowance.RequestsLimit,
+				ResetsAt:      allowance.ResetsAt,
+			},
+		}
+		if allowance.Scope == ScopeUser {
+			evt.OwnerID = record.UserID
+		}
+		log.Printf("quota %s: %s %s at %d tokens, %d requests", level, allowance.Scope, allowance.ID, allowance.TokensUsed, allowance.RequestsUsed)
+		q.Events.Publish(evt)
+	}
+}
+
+// crossed reports whether usage went from below percent of limit to at or
+// above it.
+func crossed(before, after, limit int64, percent int) bool {
+	if limit <= 0 {
+		return false
+	}
+	threshold := limit * int64(percent)
+	return before*100 < threshold && after*100 >= threshold
+}
@@ -0,0 +1,193 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"k2MarketingAi/internal/auth"
+	"k2MarketingAi/internal/storage"
+)
+
+// defaultReportDays is how far back the report goes without since or until.
+const defaultReportDays = 30
+
+// Price is what a model charges. Language models are priced per million
+// tokens; image models usually per request.
+type Price struct {
+	InputPerMillion  float64
+	OutputPerMillion float64
+	PerRequest       float64
+}
+
+// Cost estimates what requests calls with the given token totals cost.
+func (p Price) Cost(requests, promptTokens, completionTokens int64) float64 {
+	return float64(promptTokens)/1e6*p.InputPerMillion +
+		float64(completionTokens)/1e6*p.OutputPerMillion +
+		float64(requests)*p.PerRequest
+}
+
+// PriceTable maps model names to prices.
+type PriceTable map[string]Price
+
+// Lookup finds the price of model: an exact match first, otherwise the
+// longest configured name that model starts with, so "gemini-1.5-pro" also
+// prices "gemini-1.5-pro-002".
+func (t PriceTable) Lookup(model string) (Price, bool) {
+	if price, ok := t[model]; ok {
+		return price, true
+	}
+	best := ""
+	for name := range t {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return Price{}, false
+	}
+	return t[best], true
+}
+
+// Summarizer reads aggregated usage. storage.Store implements it.
+type Summarizer interface {
+	SummarizeLLMUsage(ctx context.Context, query storage.UsageQuery) ([]storage.UsageDay, error)
+}
+
+// Handler serves the usage report and the caller's quota.
+type Handler struct {
+	Store    Summarizer
+	Prices   PriceTable
+	Currency string
+	Quota    *Quota
+}
+
+// Day is one row of the report: a storage.UsageDay with its estimated cost.
+// Cost is nil when the model has no price.
+type Day struct {
+	storage.UsageDay
+	Cost *float64 `json:"cost"`
+}
+
+// Totals sums the report. Cost only covers priced models.
+type Totals struct {
+	Requests         int64   `json:"requests"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// Report is the answer of GET /api/admin/usage.
+type Report struct {
+	// Since and Until echo the bounds of the report; an open bound is left out.
+	Since          *time.Time `json:"since,omitempty"`
+	Until          *time.Time `json:"until,omitempty"`
+	Currency       string     `json:"currency"`
+	Days           []Day      `json:"days"`
+	Totals         Totals     `json:"totals"`
+	UnpricedModels []string   `json:"unpriced_models,omitempty"`
+}
+
+// Summary handles GET /api/admin/usage. The query parameters org_id,
+// user_id, listing_id, operation and model narrow the report; since and until
+// take RFC 3339 times or dates, until includes the whole day, and without
+// either the report covers the last 30 days.
+func (h Handler) Summary(w http.ResponseWriter, r *http.Request) {
+	query, err := parseQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	days, err := h.Store.SummarizeLLMUsage(r.Context(), query)
+	if err != nil {
+		http.Error(w, "kunde inte h\u00e4mta f\u00f6rbrukningen", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(h.report(query, days))
+}
+
+// MyQuota handles GET /api/usage/quota: what is left this month of the
+// budgets of the signed-in user and their organization. Unlimited budgets are
+// left out.
+func (h Handler) MyQuota(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "inloggning kr\u00e4vs", http.StatusUnauthorized)
+		return
+	}
+	allowances := []Allowance{}
+	if h.Quota != nil && h.Quota.Store != nil {
+		found, err := h.Quota.Allowances(r.Context(), Tags{UserID: user.ID, OrgID: user.OrgID})
+		if err != nil {
+			http.Error(w, "kunde inte h\u00e4mta kvoten", http.StatusInternalServerError)
+			return
+		}
+		allowances = append(allowances, found...)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"quota": allowances})
+}
+
+func (h Handler) report(query storage.UsageQuery, days []storage.UsageDay) Report {
+	report := Report{Currency: h.Currency, Days: make([]Day, 0, len(days))}
+	if !query.Since.IsZero() {
+		report.Since = &query.Since
+	}
+	if !query.Until.IsZero() {
+		report.Until = &query.Until
+	}
+	unpriced := make(map[string]bool)
+	for _, day := range days {
+		row := Day{UsageDay: day}
+		if price, ok := h.Prices.Lookup(day.Model); ok {
+			cost := roundCost(price.Cost(day.Requests, day.PromptTokens, day.CompletionTokens))
+			row.Cost = &cost
+			report.Totals.Cost += cost
+		} else {
+			unpriced[day.Model] = true
+		}
+		report.Totals.Requests += day.Requests
+		report.Totals.PromptTokens += day.PromptTokens
+		report.Totals.CompletionTokens += day.CompletionTokens
+		report.Days = append(report.Days, row)
+	}
+	report.Totals.Cost = roundCost(report.Totals.Cost)
+	for model := range unpriced {
+		report.UnpricedModels = append(report.UnpricedModels, model)
+	}
+	sort.Strings(report.UnpricedModels)
+	return report
+}
+
+// roundCost keeps six decimals, enough for single cheap calls.
+func roundCost(cost float64) float64 {
+	return math.Round(cost*1e6) / 1e6
+}
+
+func parseQuery(r *http.Request) (storage.UsageQuery, error) {
+	values := r.URL.Query()
+	query := storage.UsageQuery{
+		OrgID:     strings.TrimSpace(values.Get("org_id")),
+		UserID:    strings.TrimSpace(values.Get("user_id")),
+		ListingID: strings.TrimSpace(values.Get("listing_id")),
+		Operation: strings.TrimSpace(values.Get("operation")),
+		Model:     strings.TrimSpace(values.Get("model")),
+	}
+	var err error
+	if query.Since, err = storage.ParseTimeBound(values.Get("since"), false); err != nil {
+		return storage.UsageQuery{}, fmt.Errorf("ogiltigt since: %w", err)
+	}
+	if query.Until, err = storage.ParseTimeBound(values.Get("until"), true); err != nil {
+		return storage.UsageQuery{}, fmt.Errorf("ogiltigt until: %w", err)
+	}
+	if query.Since.IsZero() && query.Until.IsZero() {
+		query.Since = time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -defaultReportDays+1)
+	}
+	return query, nil
+}
@@ -0,0 +1,140 @@
+// Package usage records what model calls consume. Handlers tag the request
+// context with the operation, user, organization and listing; the metered
+// client and the vision hooks then write one storage.LLMUsage per call.
+package usage
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"k2MarketingAi/internal/auth"
+	"k2MarketingAi/internal/llm"
+	"k2MarketingAi/internal/storage"
+)
+
+// Operations a model call can be made for.
+const (
+	OpGenerate     = "generate"
+	OpRewrite      = "rewrite"
+	OpAnnualReport = "annual-report"
+	OpDesign       = "design"
+	OpVision       = "vision"
+	OpRender       = "render"
+	// OpOther is recorded for calls made without tags.
+	OpOther = "other"
+)
+
+// Tags say on whose behalf and for what a model call is made.
+type Tags struct {
+	UserID    string
+	OrgID     string
+	ListingID string
+	Operation string
+}
+
+type contextKey struct{}
+
+// WithTags returns a context whose model calls are recorded with tags.
+func WithTags(ctx context.Context, tags Tags) context.Context {
+	return context.WithValue(ctx, contextKey{}, tags)
+}
+
+// WithListing sets the listing on the tags already in ctx, for calls made
+// before the listing had an id in the URL.
+func WithListing(ctx context.Context, listingID string) context.Context {
+	tags := TagsFromContext(ctx)
+	tags.ListingID = listingID
+	return WithTags(ctx, tags)
+}
+
+// TagsFromContext returns the tags set with WithTags, if any.
+func TagsFromContext(ctx context.Context) Tags {
+	tags, _ := ctx.Value(contextKey{}).(Tags)
+	return tags
+}
+
+// Tag is middleware that tags model calls made while serving the request with
+// operation, the signed-in user and the listing in the {id} URL parameter.
+func Tag(operation string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tags := Tags{Operation: operation, ListingID: chi.URLParam(r, "id")}
+			if user, ok := auth.UserFromContext(r.Context()); ok {
+				tags.UserID = user.ID
+				tags.OrgID = user.OrgID
+			}
+			next.ServeHTTP(w, r.WithContext(WithTags(r.Context(), tags)))
+		})
+	}
+}
+
+// Sink stores usage records. storage.Store implements it.
+type Sink interface {
+	RecordLLMUsage(ctx context.Context, usage storage.LLMUsage) (storage.LLMUsage, error)
+}
+
+// Recorder writes usage with the tags from the call's context.
+type Recorder struct {
+	Sink Sink
+	// Quota, when set, is told about each record to warn about budgets
+	// running low.
+	Quota *Quota
+}
+
+// Record stores one call. Like the audit log it never fails the request that
+// made the call; errors are only logged.
+func (r Recorder) Record(ctx context.Context, u llm.Usage) {
+	if r.Sink == nil || u.Model == "" {
+		return
+	}
+	tags := TagsFromContext(ctx)
+	if tags.Operation == "" {
+		tags.Operation = OpOther
+	}
+	record := storage.LLMUsage{
+		UserID:           tags.UserID,
+		OrgID:            tags.OrgID,
+		ListingID:        tags.ListingID,
+		Operation:        tags.Operation,
+		Model:            u.Model,
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+	}
+	ctx = context.WithoutCancel(ctx)
+	record, err := r.Sink.RecordLLMUsage(ctx, record)
+	if err != nil {
+		log.Printf("usage %s %s: %v", tags.Operation, u.Model, err)
+		return
+	}
+	r.Quota.warn(ctx, record)
+}
+
+// Meter wraps client so that every completion is recorded. The result also
+// streams, through llm.Stream, so wrapping keeps streaming clients streaming.
+func Meter(client llm.Client, recorder Recorder) llm.StreamClient {
+	return meteredClient{client: client, recorder: recorder}
+}
+
+type meteredClient struct {
+	client   llm.Client
+	recorder Recorder
+}
+
+func (m meteredClient) ChatCompletion(ctx context.Context, messages []llm.ChatMessage, temperature float64) (llm.Completion, error) {
+	completion, err := m.client.ChatCompletion(ctx, messages, temperature)
+	if err == nil {
+		m.recorder.Record(ctx, completion.Usage)
+	}
+	return completion, err
+}
+
+func (m meteredClient) ChatCompletionStream(ctx context.Context, messages []llm.ChatMessage, temperature float64, onDelta func(string)) (llm.Completion, error) {
+	completion, err := llm.Stream(ctx, m.client, messages, temperature, onDelta)
+	if err == nil {
+		m.recorder.Record(ctx, completion.Usage)
+	}
+	return completion, err
+}
@@ -52,7 +52,7 @@ func (d *GeminiDesigner) Design(ctx context.Context, prompt string) (DesignConce
 %s
 `, prompt)
 
-	content, err := d.client.ChatCompletion(ctx, []llm.ChatMessage{
+	completion, err := d.client.ChatCompletion(ctx, []llm.ChatMessage{
 		{Role: "system", Content: systemPrompt},
 		{Role: "user", Content: userPrompt},
 	}, 0.3)
@@ -60,7 +60,7 @@ func (d *GeminiDesigner) Design(ctx context.Context, prompt string) (DesignConce
 		return DesignConcept{}, err
 	}
 
-	return parseDesignConcept(content)
+	return parseDesignConcept(completion.Text)
 }
 
 func parseDesignConcept(content string) (DesignConcept, error) {
@@ -11,9 +11,14 @@ import (
 	"strings"
 	"time"
 
+	"k2MarketingAi/internal/llm"
 	"k2MarketingAi/internal/storage"
 )
 
+// UsageFunc receives what a model call consumed. The Gemini and Imagen
+// clients call it after every successful request when it is set.
+type UsageFunc func(ctx context.Context, usage llm.Usage)
+
 // Analyzer extracts structured insights from property images.
 type Analyzer interface {
 	Analyze(ctx context.Context, imageURL string) (storage.VisionInsights, error)
@@ -25,6 +30,8 @@ type GeminiAnalyzer struct {
 	apiKey string
 	model  string
 	client *http.Client
+	// OnUsage, when set, receives the token counts of each analysis.
+	OnUsage UsageFunc
 }
 
 const (
@@ -137,10 +144,21 @@ Svara ENDAST med JSON med följande struktur:
 				} `json:"parts"`
 			} `json:"content"`
 		} `json:"candidates"`
+		UsageMetadata struct {
+			PromptTokenCount     int `json:"promptTokenCount"`
+			CandidatesTokenCount int `json:"candidatesTokenCount"`
+		} `json:"usageMetadata"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&completion); err != nil {
 		return storage.VisionInsights{}, fmt.Errorf("vision: decode response: %w", err)
 	}
+	if g.OnUsage != nil {
+		g.OnUsage(ctx, llm.Usage{
+			Model:            g.model,
+			PromptTokens:     completion.UsageMetadata.PromptTokenCount,
+			CompletionTokens: completion.UsageMetadata.CandidatesTokenCount,
+		})
+	}
 
 	if len(completion.Candidates) == 0 || len(completion.Candidates[0].Content.Parts) == 0 {
 		return storage.VisionInsights{}, fmt.Errorf("vision: empty response")
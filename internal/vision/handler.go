@@ -6,6 +6,8 @@ import (
 	"io"
 	"net/http"
 	"strings"
+
+	"k2MarketingAi/internal/auth"
 )
 
 // Handler exposes HTTP endpoints for ad-hoc vision tooling.
@@ -128,10 +130,12 @@ func (h Handler) Render(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if h.Imagen != nil && (strings.TrimSpace(req.BaseImageURL) != "" || strings.TrimSpace(req.BaseImageData) != "") {
+		user, _ := auth.UserFromContext(r.Context())
 		result, err := h.Imagen.Edit(r.Context(), ImagenPayload{
 			Prompt:        req.Prompt,
 			BaseImageURL:  strings.TrimSpace(req.BaseImageURL),
 			BaseImageData: strings.TrimSpace(req.BaseImageData),
+			Scope:         user.OrgID,
 		})
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadGateway)
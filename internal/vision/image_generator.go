@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"google.golang.org/genai"
+
+	"k2MarketingAi/internal/llm"
 )
 
 // ImageGenerator returns rendered interiors based on prompts.
@@ -28,6 +30,8 @@ type GeminiImageGenerator struct {
 	apiKey  string
 	model   string
 	timeout time.Duration
+	// OnUsage, when set, receives the token counts of each render.
+	OnUsage UsageFunc
 }
 
 const defaultImageModel = "gemini-2.5-flash-image"
@@ -71,6 +75,14 @@ func (g *GeminiImageGenerator) Generate(ctx context.Context, prompt string) (Ima
 	if err != nil {
 		return ImageResult{}, fmt.Errorf("vision: render misslyckades: %w", err)
 	}
+	if g.OnUsage != nil {
+		usage := llm.Usage{Model: g.model}
+		if meta := resp.UsageMetadata; meta != nil {
+			usage.PromptTokens = int(meta.PromptTokenCount)
+			usage.CompletionTokens = int(meta.CandidatesTokenCount)
+		}
+		g.OnUsage(ctx, usage)
+	}
 	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
 		return ImageResult{}, fmt.Errorf("vision: render saknar kandidater")
 	}
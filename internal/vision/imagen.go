@@ -14,6 +14,7 @@ import (
 	"google.golang.org/api/option"
 	"google.golang.org/protobuf/types/known/structpb"
 
+	"k2MarketingAi/internal/llm"
 	"k2MarketingAi/internal/media"
 )
 
@@ -27,6 +28,9 @@ type ImagenPayload struct {
 	Prompt        string
 	BaseImageURL  string
 	BaseImageData string
+	// Scope is passed to the uploader for the rendered image, normally the
+	// organization id.
+	Scope string
 }
 
 // VertexImagen implements ImagenClient via the Vertex AI SDK.
@@ -38,6 +42,9 @@ type VertexImagen struct {
 	serviceAccount     string
 	serviceAccountJSON string
 	uploader           mediaUploader
+	// OnUsage, when set, is told about each prediction. Imagen bills per
+	// image, so the token counts are zero.
+	OnUsage UsageFunc
 }
 
 // VertexImagenConfig describes how to connect to Imagen.
@@ -126,6 +133,9 @@ func (v *VertexImagen) Edit(ctx context.Context, payload ImagenPayload) (ImageRe
 	if err != nil {
 		return ImageResult{}, fmt.Errorf("imagen: predict: %w", err)
 	}
+	if v.OnUsage != nil {
+		v.OnUsage(ctx, llm.Usage{Model: v.model})
+	}
 	if len(resp.Predictions) == 0 {
 		return ImageResult{}, fmt.Errorf("imagen: empty prediction response")
 	}
@@ -145,6 +155,7 @@ func (v *VertexImagen) Edit(ctx context.Context, payload ImagenPayload) (ImageRe
 		ContentType: "image/png",
 		Body:        bytes.NewReader(data),
 		Size:        int64(len(data)),
+		Scope:       payload.Scope,
 	})
 	if err != nil {
 		return ImageResult{}, fmt.Errorf("imagen: upload render: %w", err)
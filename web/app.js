@@ -2,6 +2,8 @@ const state = {
     user: null,
     authMode: 'login',
     listings: [],
+    listingsCursor: '',
+    loadingMoreListings: false,
     current: null,
     selectedId: null,
     versions: [],
@@ -20,6 +22,7 @@ const state = {
         storageURL: '',
         storageKey: '',
     },
+    twoFactor: null,
     visionRenderImage: '',
     visionRenderAsset: null,
     editingListingId: null,
@@ -109,6 +112,8 @@ function setAuthMode(mode) {
     const registerTab = document.getElementById('auth-toggle-register');
     loginForm?.classList.toggle('hidden', mode !== 'login');
     registerForm?.classList.toggle('hidden', mode !== 'register');
+    document.getElementById('two-factor-form')?.classList.toggle('hidden', mode !== 'two-factor');
+    document.getElementById('recovery-codes')?.classList.toggle('hidden', mode !== 'recovery-codes');
     loginTab?.classList.toggle('active', mode === 'login');
     registerTab?.classList.toggle('active', mode === 'register');
     clearAuthError();
@@ -163,6 +168,10 @@ async function handleLoginSubmit(e) {
             return;
         }
         const user = await res.json();
+        if (user.two_factor_required || user.two_factor_setup_required) {
+            await startTwoFactor(user);
+            return;
+        }
         if (user.approved === false) {
             showAuthError('Kontot väntar på godkännande.');
             return;
@@ -176,6 +185,103 @@ async function handleLoginSubmit(e) {
     }
 }
 
+// startTwoFactor shows the code step after a correct password. Users whose
+// office requires two-factor authentication but who have not enrolled yet get
+// a new secret to add to their app first.
+async function startTwoFactor(step) {
+    state.twoFactor = { challenge: step.challenge, recovery: false, user: null };
+    setAuthMode('two-factor');
+    setTwoFactorRecovery(false);
+    document.getElementById('two-factor-recovery-toggle')?.classList.toggle('hidden', !!step.two_factor_setup_required);
+    const setup = document.getElementById('two-factor-setup');
+    setup?.classList.add('hidden');
+    if (step.two_factor_setup_required) {
+        const res = await nativeFetch('/api/auth/2fa/setup', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ challenge: step.challenge }),
+        });
+        if (!res.ok) {
+            showAuthError(await res.text() || 'Kunde inte starta tvåstegsverifiering');
+            return;
+        }
+        const enrollment = await res.json();
+        document.getElementById('two-factor-link').href = enrollment.otpauth_url;
+        document.getElementById('two-factor-secret').textContent = enrollment.secret.replace(/(.{4})/g, '$1 ').trim();
+        setup?.classList.remove('hidden');
+    }
+    document.getElementById('two-factor-code')?.focus();
+}
+
+function setTwoFactorRecovery(recovery) {
+    if (state.twoFactor) state.twoFactor.recovery = recovery;
+    const input = document.getElementById('two-factor-code');
+    const label = document.getElementById('two-factor-label');
+    const toggle = document.getElementById('two-factor-recovery-toggle');
+    if (input) {
+        input.value = '';
+        input.inputMode = recovery ? 'text' : 'numeric';
+        input.placeholder = recovery ? 'xxxxx-xxxxx' : '123456';
+    }
+    if (label) label.textContent = recovery ? 'Återställningskod' : 'Kod från autentiseringsappen';
+    if (toggle) toggle.textContent = recovery ? 'Använd koden från appen' : 'Använd en återställningskod';
+}
+
+async function handleTwoFactorSubmit(e) {
+    e.preventDefault();
+    clearAuthError();
+    if (!state.twoFactor) {
+        setAuthMode('login');
+        return;
+    }
+    const code = document.getElementById('two-factor-code')?.value.trim();
+    if (!code) {
+        showAuthError('Fyll i koden');
+        return;
+    }
+    const body = { challenge: state.twoFactor.challenge };
+    if (state.twoFactor.recovery) body.recovery_code = code;
+    else body.code = code;
+    try {
+        // A wrong code answers 401, which must not end up in the global
+        // session-expired handling.
+        const res = await nativeFetch('/api/auth/login/2fa', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify(body),
+        });
+        if (!res.ok) {
+            const msg = await res.text();
+            showAuthError(msg || 'Felaktig kod');
+            if (msg.includes('logga in igen')) {
+                state.twoFactor = null;
+                setAuthMode('login');
+                showAuthError(msg);
+            }
+            return;
+        }
+        const user = await res.json();
+        if (user.recovery_codes) {
+            state.twoFactor.user = user;
+            document.getElementById('recovery-codes-list').textContent = user.recovery_codes.join('\n');
+            setAuthMode('recovery-codes');
+            return;
+        }
+        await finishTwoFactor(user);
+    } catch (err) {
+        showAuthError('Kunde inte logga in just nu');
+        console.error(err);
+    }
+}
+
+async function finishTwoFactor(user) {
+    state.twoFactor = null;
+    setAuthMode('login');
+    setUser(user);
+    hideAuthOverlay();
+    await initApp();
+}
+
 async function handleRegisterSubmit(e) {
     e.preventDefault();
     clearAuthError();
@@ -223,6 +329,49 @@ async function handleLogout() {
     redirectToLanding('Du är utloggad.');
 }
 
+const ssoErrors = {
+    denied: 'Inloggningen avbröts.',
+    expired: 'Inloggningen tog för lång tid. Försök igen.',
+    failed: 'Kunde inte logga in via inloggningstjänsten.',
+    email: 'Inloggningstjänsten skickade ingen verifierad e-postadress.',
+    no_account: 'Det finns inget konto för din e-postadress. Kontakta din administratör.',
+    pending: 'Kontot väntar på godkännande.',
+    two_factor: 'Kontot kräver tvåstegsverifiering. Logga in med lösenord och kod i stället.',
+    domain: 'Ditt konto kan inte logga in via den här inloggningstjänsten. Logga in med lösenord i stället.',
+};
+
+async function loadSSOProviders() {
+    const container = document.getElementById('sso-providers');
+    if (!container) return;
+    try {
+        const res = await fetch('/api/auth/oidc/');
+        if (!res.ok) return;
+        const providers = await res.json();
+        container.replaceChildren(...providers.map(provider => {
+            const link = document.createElement('a');
+            link.className = 'secondary full-width';
+            link.href = `${provider.login_url}?return_to=${encodeURIComponent('/')}`;
+            link.textContent = `Logga in med ${provider.display_name}`;
+            return link;
+        }));
+        container.classList.toggle('hidden', providers.length === 0);
+    } catch (err) {
+        console.warn('Could not load sign-in providers', err);
+    }
+}
+
+function showSSOError() {
+    const params = new URLSearchParams(window.location.search);
+    const reason = params.get('sso_error');
+    if (!reason) return;
+    params.delete('sso_error');
+    const query = params.toString();
+    window.history.replaceState(null, '', window.location.pathname + (query ? `?${query}` : ''));
+    setAuthMode('login');
+    showAuthOverlay('Logga in för att se dina objekt.');
+    showAuthError(ssoErrors[reason] || ssoErrors.failed);
+}
+
 async function checkSession() {
     try {
         const res = await fetch('/api/auth/me');
@@ -261,7 +410,9 @@ async function fetchListings() {
     try {
         const res = await fetch('/api/listings/');
         if (!res.ok) throw new Error('Kunde inte hämta listor');
-        state.listings = await res.json();
+        const page = await res.json();
+        state.listings = page.listings || [];
+        state.listingsCursor = page.next_cursor || '';
         updateVolumeStats();
         updateTimeSavings();
         updateImageStats();
@@ -284,6 +435,41 @@ async function fetchListings() {
     }
 }
 
+// loadMoreListings follows next_cursor and appends the next page to the list.
+async function loadMoreListings() {
+    if (!state.listingsCursor || state.loadingMoreListings) return;
+    state.loadingMoreListings = true;
+    renderObjectList();
+    try {
+        const res = await fetch(`/api/listings/?cursor=${encodeURIComponent(state.listingsCursor)}`);
+        if (!res.ok) throw new Error('Kunde inte hämta fler objekt');
+        const page = await res.json();
+        const known = new Set(state.listings.map(item => item.id));
+        state.listings = state.listings.concat((page.listings || []).filter(item => !known.has(item.id)));
+        state.listingsCursor = page.next_cursor || '';
+        updateVolumeStats();
+        updateTimeSavings();
+        updateImageStats();
+        renderVisionLab();
+    } catch (err) {
+        console.error(err);
+    } finally {
+        state.loadingMoreListings = false;
+        renderObjectList();
+    }
+}
+
+function appendLoadMoreButton(container) {
+    if (!state.listingsCursor) return;
+    const more = document.createElement('button');
+    more.type = 'button';
+    more.className = 'secondary object-list__more';
+    more.disabled = state.loadingMoreListings;
+    more.textContent = state.loadingMoreListings ? 'Hämtar…' : 'Visa fler objekt';
+    more.addEventListener('click', loadMoreListings);
+    container.appendChild(more);
+}
+
 async function fetchStyleProfiles() {
     try {
         const res = await fetch('/api/style-profiles/');
@@ -963,7 +1149,7 @@ async function applySelectionRewrite(mode) {
     setAIStatus(`Omskriver: ${instruction}`, true);
     try {
         const targetSlug = getPrimarySectionSlug();
-        const res = await fetch(`/api/listings/${state.selectedId}/sections/${targetSlug}/rewrite`, {
+        const res = await fetch(`/api/listings/${state.selectedId}/sections/${targetSlug}/rewrite/stream`, {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
             body: JSON.stringify({ instruction, selection: selected }),
@@ -972,7 +1158,22 @@ async function applySelectionRewrite(mode) {
             const text = await res.text();
             throw new Error(text || 'Misslyckades med omskrivning');
         }
-        state.current = await res.json();
+        const original = editor.value;
+        let partial = '';
+        editor.readOnly = true;
+        try {
+            const done = await readRewriteStream(res, (text) => {
+                partial += text;
+                editor.value = partial;
+                editor.scrollTop = editor.scrollHeight;
+            });
+            state.current = done.listing;
+        } catch (err) {
+            editor.value = original;
+            throw err;
+        } finally {
+            editor.readOnly = false;
+        }
         pushVersion(getFullCopy(state.current), 'Omskriven');
         renderDetail();
         incrementRewriteStat();
@@ -983,6 +1184,41 @@ async function applySelectionRewrite(mode) {
     }
 }
 
+// readRewriteStream reads the server-sent events from a streamed rewrite,
+// passing each piece of new text to onDelta, and resolves with the final
+// "done" payload ({ section, listing }).
+async function readRewriteStream(res, onDelta) {
+    const reader = res.body.getReader();
+    const decoder = new TextDecoder();
+    let buffer = '';
+    for (;;) {
+        const { value, done } = await reader.read();
+        if (done) break;
+        buffer += decoder.decode(value, { stream: true });
+        let boundary;
+        while ((boundary = buffer.indexOf('\n\n')) !== -1) {
+            const block = buffer.slice(0, boundary);
+            buffer = buffer.slice(boundary + 2);
+            let event = 'message';
+            let data = '';
+            block.split('\n').forEach((line) => {
+                if (line.startsWith('event:')) event = line.slice(6).trim();
+                if (line.startsWith('data:')) data += line.slice(5).trim();
+            });
+            if (!data) continue;
+            const payload = JSON.parse(data);
+            if (event === 'delta') {
+                onDelta(payload.text || '');
+            } else if (event === 'done') {
+                return payload;
+            } else if (event === 'error') {
+                throw new Error(payload.error || 'Misslyckades med omskrivning');
+            }
+        }
+    }
+    throw new Error('Omskrivningen avbröts innan den blev klar');
+}
+
 function instructionForMode(mode, tone) {
     switch (mode) {
     case 'sales':
@@ -1036,6 +1272,12 @@ function bindEvents() {
     document.getElementById('auth-toggle-register')?.addEventListener('click', () => setAuthMode('register'));
     document.getElementById('login-form')?.addEventListener('submit', handleLoginSubmit);
     document.getElementById('register-form')?.addEventListener('submit', handleRegisterSubmit);
+    document.getElementById('two-factor-form')?.addEventListener('submit', handleTwoFactorSubmit);
+    document.getElementById('two-factor-recovery-toggle')?.addEventListener('click', () => {
+        setTwoFactorRecovery(!state.twoFactor?.recovery);
+        clearAuthError();
+    });
+    document.getElementById('recovery-codes-done')?.addEventListener('click', () => finishTwoFactor(state.twoFactor?.user));
     document.getElementById('logout-btn')?.addEventListener('click', handleLogout);
     document.querySelectorAll('[data-auth-trigger]').forEach(btn => {
         btn.addEventListener('click', () => {
@@ -1283,6 +1525,7 @@ function renderObjectList() {
             ? 'Inga objekt matchar din sökning.'
             : 'Inga objekt ännu. Skapa din första annons.';
         container.appendChild(empty);
+        appendLoadMoreButton(container);
         return;
     }
 
@@ -1361,6 +1604,7 @@ function renderObjectList() {
         card.addEventListener('click', () => selectListing(listing.id));
         container.appendChild(card);
     });
+    appendLoadMoreButton(container);
 }
 
 function buildListingMeta(listing) {
@@ -2110,7 +2354,8 @@ bindEvents();
 renderVisionLab();
 renderAnnualResult();
 showView('objects');
-checkSession();
+loadSSOProviders();
+checkSession().then(showSSOError);
 
 async function initApp() {
     if (!state.user) return;
@@ -2120,7 +2365,7 @@ async function initApp() {
 
 async function deleteListing(id) {
     if (!id) return;
-    const ok = window.confirm('Ta bort detta objekt?');
+    const ok = window.confirm('Flytta objektet till papperskorgen? Det kan återställas i 30 dagar.');
     if (!ok) return;
     try {
         const res = await fetch(`/api/listings/${id}/`, { method: 'DELETE' });